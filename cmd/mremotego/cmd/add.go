@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"github.com/jaydenthorup/mremotego/internal/launcher"
 	"github.com/jaydenthorup/mremotego/pkg/models"
 )
 
@@ -18,44 +19,92 @@ var (
 	addDescription string
 	addFolder      string
 	addTags        []string
+	addTemplate    string
+	addSSH         sshOptions
+	addJumpHost    string
 )
 
 var addCmd = &cobra.Command{
 	Use:   "add",
 	Short: "Add a new connection",
-	Long:  `Add a new connection to the configuration file.`,
+	Long: `Add a new connection to the configuration file.
+
+With --template, the connection starts from the named template in the config's
+'templates' section; any other flags override the template's values.
+Placeholders such as {{.Name}} or {{.Host}} in the template are filled in
+from the new connection.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if addName == "" {
 			return fmt.Errorf("connection name is required (--name)")
 		}
-		if addHost == "" {
-			return fmt.Errorf("host is required (--host)")
-		}
-		if addProtocol == "" {
-			return fmt.Errorf("protocol is required (--protocol)")
-		}
 
 		manager, err := getConfigManager()
 		if err != nil {
 			return err
 		}
 
-		// Create the connection
-		conn := models.NewConnection(addName, models.Protocol(addProtocol))
-		conn.Host = addHost
-		conn.Username = addUsername
-		conn.Password = addPassword
-		conn.Domain = addDomain
-		conn.Description = addDescription
-		conn.Tags = addTags
+		// Create the connection, from a template if one was given
+		var conn *models.Connection
+		if addTemplate != "" {
+			template, err := manager.GetConfig().FindTemplate(addTemplate)
+			if err != nil {
+				return err
+			}
+			conn = template.Instantiate(addName)
+		} else {
+			conn = models.NewConnection(addName, "")
+		}
+
+		// Explicit flags override template values
+		flags := cmd.Flags()
+		if flags.Changed("protocol") {
+			conn.Protocol = models.Protocol(addProtocol)
+		}
+		if flags.Changed("host") {
+			conn.Host = addHost
+		}
+		if flags.Changed("username") {
+			conn.Username = addUsername
+		}
+		if flags.Changed("password") {
+			conn.Password = addPassword
+		}
+		if flags.Changed("domain") {
+			conn.Domain = addDomain
+		}
+		if flags.Changed("description") {
+			conn.Description = addDescription
+		}
+		if flags.Changed("tags") {
+			conn.Tags = addTags
+		}
+		if err := addSSH.apply(cmd, conn); err != nil {
+			return err
+		}
+		if flags.Changed("jump-host") {
+			conn.JumpHost = addJumpHost
+			if err := checkJumpHost(manager, conn, conn.JumpHost); err != nil {
+				return err
+			}
+		}
+
+		if conn.Host == "" {
+			return fmt.Errorf("host is required (--host)")
+		}
+		if conn.Protocol == "" {
+			return fmt.Errorf("protocol is required (--protocol)")
+		}
 
 		// Set port or use default
 		if addPort != 0 {
 			conn.Port = addPort
-		} else {
+		} else if conn.Port == 0 {
 			conn.Port = conn.Protocol.GetDefaultPort()
 		}
 
+		// Fill in {{.Name}}, {{.Host}}, ... now that every field is known
+		conn.ExpandPlaceholders(manager.GetConfig().IsSecretField)
+
 		// Add to config
 		if err := manager.AddConnection(conn, addFolder); err != nil {
 			return fmt.Errorf("failed to add connection: %w", err)
@@ -77,8 +126,9 @@ func init() {
 	rootCmd.AddCommand(addCmd)
 
 	addCmd.Flags().StringVar(&addName, "name", "", "Connection name (required)")
-	addCmd.Flags().StringVar(&addProtocol, "protocol", "", "Protocol: ssh, rdp, vnc, http, https, telnet (required)")
-	addCmd.Flags().StringVar(&addHost, "host", "", "Host address or IP (required)")
+	addCmd.Flags().StringVar(&addTemplate, "template", "", "Create the connection from a template")
+	addCmd.Flags().StringVar(&addProtocol, "protocol", "", "Protocol: "+launcher.ProtocolList()+" (required unless set by the template)")
+	addCmd.Flags().StringVar(&addHost, "host", "", "Host address or IP (required unless set by the template)")
 	addCmd.Flags().IntVar(&addPort, "port", 0, "Port number (default: protocol default)")
 	addCmd.Flags().StringVar(&addUsername, "username", "", "Username")
 	addCmd.Flags().StringVar(&addPassword, "password", "", "Password (stored in plain text)")
@@ -86,4 +136,6 @@ func init() {
 	addCmd.Flags().StringVar(&addDescription, "description", "", "Connection description")
 	addCmd.Flags().StringVar(&addFolder, "folder", "", "Folder path (e.g., 'Production/Servers')")
 	addCmd.Flags().StringSliceVar(&addTags, "tags", []string{}, "Tags (comma-separated)")
+	addSSH.register(addCmd)
+	addCmd.Flags().StringVar(&addJumpHost, "jump-host", "", jumpHostFlagHelp)
 }
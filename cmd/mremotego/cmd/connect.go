@@ -1,19 +1,83 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"github.com/jaydenthorup/mremotego/internal/config"
 	"github.com/jaydenthorup/mremotego/internal/launcher"
+	"github.com/jaydenthorup/mremotego/internal/query"
+	"github.com/jaydenthorup/mremotego/internal/tui"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+var (
+	connectVars   []string
+	connectFilter string
+	connectDryRun bool
+	connectReveal bool
+	connectTarget string
+	connectTmux   string
 )
 
 var connectCmd = &cobra.Command{
-	Use:   "connect [connection name]",
+	Use:   "connect [connection name...]",
 	Short: "Connect to a configured host",
-	Long:  `Launch a connection using the configured protocol handler.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Launch a connection using the configured protocol handler.
+
+Connection fields may refer to variables as {{name}} and to environment
+variables as ${NAME}. Variables are defined in 'variables' sections of the
+config, its folders or the connection itself; --var overrides them.
+
+Instead of a name, --filter selects the connection with a query, which must
+match exactly one connection (see 'mremotego list --help' for the syntax).
+
+Run in a terminal without a name, with a name that doesn't exist or with a
+filter matching several connections, connect opens a picker: type to fuzzy
+search folder paths, names, hosts and tags, move with the arrow keys, press
+Enter to connect or Esc to cancel.
+
+--dry-run prints the command the protocol handler would run instead of
+running it, with the password replaced by ******** unless --reveal is given.
+
+Console sessions such as SSH open in a new tmux window when connect runs
+inside tmux, in a new screen window inside screen, and in a terminal window
+otherwise. --target (or session_target in the settings file) chooses
+explicitly: auto, terminal, current, tmux, tmux-pane or screen.
+
+--tmux-session opens several connections, given by name or --filter, side by
+side in one window of a tmux session with synchronized input, so what you
+type goes to every host. The session is created when it doesn't exist.`,
+	Example: `  mremotego connect web1
+  mremotego connect          # pick from every connection
+  mremotego connect prodweb  # pick, starting with "prodweb" as the search
+  mremotego connect --filter "tag:prod name:web*"
+  mremotego connect web1 --dry-run
+  mremotego connect web1 --target tmux-pane
+  mremotego connect --tmux-session web web1 web2 web3
+  mremotego connect --tmux-session prod --filter "tag:prod protocol:ssh"`,
+	Args:          cobra.ArbitraryArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		connectionName := args[0]
+		if connectTmux != "" {
+			return connectTmuxSession(args)
+		}
+		if len(args) > 1 {
+			return fmt.Errorf("give one connection name, or use --tmux-session")
+		}
+		if len(args) == 1 && connectFilter != "" {
+			return fmt.Errorf("give either a connection name or --filter")
+		}
+		if connectReveal && !connectDryRun {
+			return fmt.Errorf("--reveal only applies to --dry-run")
+		}
+
+		overrides, err := config.ParseVariables(connectVars)
+		if err != nil {
+			return err
+		}
 
 		manager, err := getConfigManager()
 		if err != nil {
@@ -21,13 +85,36 @@ var connectCmd = &cobra.Command{
 		}
 
 		// Find the connection
-		conn, err := manager.FindConnection(connectionName)
+		conn, err := chooseConnection(manager, args)
+		if errors.Is(err, tui.ErrCancelled) {
+			return nil
+		}
 		if err != nil {
-			return fmt.Errorf("connection not found: %w", err)
+			return err
+		}
+
+		// Expand variables into the effective values
+		conn, err = manager.ResolveConnection(conn, overrides)
+		if err != nil {
+			return err
 		}
 
-		// Launch
 		l := launcher.NewLauncher()
+		if connectTarget != "" {
+			if err := l.SetTarget(connectTarget); err != nil {
+				return err
+			}
+		}
+		if connectDryRun {
+			command, err := l.LaunchCommand(conn, connectReveal)
+			if err != nil {
+				return err
+			}
+			fmt.Println(command)
+			return nil
+		}
+
+		// Launch
 		if err := l.Launch(conn); err != nil {
 			return fmt.Errorf("failed to launch connection: %w", err)
 		}
@@ -39,6 +126,116 @@ var connectCmd = &cobra.Command{
 	},
 }
 
+// connectTmuxSession opens the connections given by name and --filter in one
+// tmux session
+func connectTmuxSession(names []string) error {
+	if connectDryRun || connectTarget != "" {
+		return fmt.Errorf("--tmux-session can't be combined with --dry-run or --target")
+	}
+
+	overrides, err := config.ParseVariables(connectVars)
+	if err != nil {
+		return err
+	}
+
+	manager, err := getConfigManager()
+	if err != nil {
+		return err
+	}
+
+	var conns []*models.Connection
+	for _, name := range names {
+		conn, err := manager.FindConnection(name)
+		if err != nil {
+			return fmt.Errorf("connection not found: %w", err)
+		}
+		conns = append(conns, conn)
+	}
+	if connectFilter != "" {
+		results, err := selectConnections(manager, connectFilter)
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			conns = append(conns, result.Connection)
+		}
+	}
+	if len(conns) == 0 {
+		return fmt.Errorf("give connection names or --filter")
+	}
+
+	for i, conn := range conns {
+		if conns[i], err = manager.ResolveConnection(conn, overrides); err != nil {
+			return err
+		}
+	}
+
+	if err := launcher.NewLauncher().LaunchTmuxSession(connectTmux, conns); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Opened %d connection(s) in tmux session '%s'\n", len(conns), connectTmux)
+	return nil
+}
+
+// chooseConnection finds the connection to launch by name or --filter, or
+// lets the user pick it when that's ambiguous and a terminal is attached
+func chooseConnection(manager *config.Manager, args []string) (*models.Connection, error) {
+	interactive := tui.IsTerminal()
+
+	if connectFilter != "" {
+		results, err := selectConnections(manager, connectFilter)
+		if err != nil {
+			return nil, err
+		}
+		if len(results) > 1 && interactive {
+			return pickConnection(manager, results, "")
+		}
+		return selectOneConnection(manager, connectFilter)
+	}
+
+	if len(args) == 0 {
+		if !interactive {
+			return nil, fmt.Errorf("give a connection name or --filter")
+		}
+		return pickConnection(manager, nil, "")
+	}
+
+	conn, err := manager.FindConnection(args[0])
+	if err != nil {
+		if !interactive {
+			return nil, fmt.Errorf("connection not found: %w", err)
+		}
+		return pickConnection(manager, nil, args[0])
+	}
+	return conn, nil
+}
+
+// pickConnection opens the fuzzy picker over the given connections, or every
+// connection when results is nil, with initial as the search text
+func pickConnection(manager *config.Manager, results []query.Result, initial string) (*models.Connection, error) {
+	cfg := manager.GetConfig()
+	if results == nil {
+		results = (&query.Query{}).Filter(cfg.Connections)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no connections configured. Use 'mremotego add' to create one")
+	}
+
+	index, err := tui.Pick(tui.ConnectionItems(results, cfg), "connect> ", initial)
+	if err != nil {
+		return nil, err
+	}
+	return results[index].Connection, nil
+}
+
 func init() {
 	rootCmd.AddCommand(connectCmd)
+
+	connectCmd.Flags().StringVarP(&connectFilter, "filter", "f", "", "Connect to the one connection matching a query")
+	connectCmd.Flags().BoolVar(&connectDryRun, "dry-run", false, "Print the launch command instead of running it")
+	connectCmd.Flags().BoolVar(&connectReveal, "reveal", false, "Show the password in the --dry-run output")
+	connectCmd.Flags().StringVar(&connectTarget, "target", "", "Where console sessions open: auto, terminal, current, tmux, tmux-pane or screen")
+	connectCmd.Flags().StringVar(&connectTmux, "tmux-session", "", "Open the connections as synchronized panes of a tmux session")
+	connectCmd.Flags().StringArrayVar(&connectVars, "var", []string{}, "Set a variable for this connection (key=value, repeatable)")
 }
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var copyFolder string
+
+var copyCmd = &cobra.Command{
+	Use:     "cp <name> <new name>",
+	Aliases: []string{"copy"},
+	Short:   "Duplicate a connection or folder",
+	Long: `Duplicate a connection or folder (including everything in it) under a new
+name. The copy is placed after the original unless --folder is given, and
+gets new ids so it is tracked separately from the original.`,
+	Example: `  mremotego cp web1 web2
+  mremotego cp "Prod Servers" "Staging Servers" --folder Staging`,
+	Args:          cobra.ExactArgs(2),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, newName := args[0], args[1]
+
+		manager, err := getConfigManager()
+		if err != nil {
+			return err
+		}
+
+		if _, err := manager.CopyConnection(name, newName); err != nil {
+			return fmt.Errorf("failed to copy '%s': %w", name, err)
+		}
+
+		if cmd.Flags().Changed("folder") {
+			if err := manager.MoveConnection(newName, copyFolder); err != nil {
+				return fmt.Errorf("failed to move '%s': %w", newName, err)
+			}
+		}
+
+		if err := manager.Save(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+
+		fmt.Printf("✓ Copied '%s' to '%s'\n", name, newName)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(copyCmd)
+
+	copyCmd.Flags().StringVar(&copyFolder, "folder", "", "Folder to place the copy in ('/' for the top level)")
+}
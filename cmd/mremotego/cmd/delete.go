@@ -3,22 +3,44 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/jaydenthorup/mremotego/internal/config"
 	"github.com/spf13/cobra"
 )
 
+var (
+	deleteFilter string
+	deleteYes    bool
+)
+
 var deleteCmd = &cobra.Command{
 	Use:   "delete [connection name]",
 	Short: "Delete a connection",
-	Long:  `Remove a connection from the configuration.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Remove a connection from the configuration.
+
+Delete every connection matching a query with --filter (see 'mremotego list
+--help' for the syntax). The matches are listed first; add --yes to delete them.`,
+	Example: `  mremotego delete web1
+  mremotego delete --filter "tag:decom"
+  mremotego delete --filter "tag:decom" --yes`,
+	Args:          cobra.MaximumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		connectionName := args[0]
+		if (len(args) == 0) == (deleteFilter == "") {
+			return fmt.Errorf("give either a connection name or --filter")
+		}
 
 		manager, err := getConfigManager()
 		if err != nil {
 			return err
 		}
 
+		if deleteFilter != "" {
+			return deleteMatching(manager)
+		}
+
+		connectionName := args[0]
+
 		// Delete
 		if err := manager.DeleteConnection(connectionName); err != nil {
 			return fmt.Errorf("failed to delete connection: %w", err)
@@ -35,6 +57,39 @@ var deleteCmd = &cobra.Command{
 	},
 }
 
+// deleteMatching deletes every connection matching --filter once confirmed with --yes
+func deleteMatching(manager *config.Manager) error {
+	results, err := selectConnections(manager, deleteFilter)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("no connection matches '%s'", deleteFilter)
+	}
+
+	for _, result := range results {
+		fmt.Printf("  %s\n", result.Path())
+	}
+	if !deleteYes {
+		return fmt.Errorf("%d connection(s) match; add --yes to delete them", len(results))
+	}
+
+	for _, result := range results {
+		if err := manager.DeleteNode(result.Connection); err != nil {
+			return fmt.Errorf("failed to delete '%s': %w", result.Path(), err)
+		}
+	}
+	if err := manager.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	fmt.Printf("✓ Deleted %d connection(s)\n", len(results))
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(deleteCmd)
+
+	deleteCmd.Flags().StringVarP(&deleteFilter, "filter", "f", "", "Delete every connection matching a query")
+	deleteCmd.Flags().BoolVarP(&deleteYes, "yes", "y", false, "Confirm deleting the connections matching --filter")
 }
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/jaydenthorup/mremotego/internal/config"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffExitCode bool
+	diffJSON     bool
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old.yaml> <new.yaml>",
+	Short: "Show the differences between two connection files",
+	Long: `Compare two connection files entry by entry rather than line by line.
+Entries are matched by their id, so a connection that was moved to another
+folder or renamed shows up as such instead of as a removal plus an addition.
+
+Output lines start with:
+  +  added        -  removed
+  >  moved        ~  modified
+
+Passwords are never printed. Files that aren't connection files are refused,
+or shown as a plain text diff when git runs the command. To use it as a git
+diff driver for your connection file:
+
+  git config diff.mremotego.command "mremotego diff"
+  echo "connections.yaml diff=mremotego merge=mremotego" >> .gitattributes`,
+	Args:          diffArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		oldFile, newFile := args[0], args[1]
+		byGit := len(args) == 7
+		if byGit {
+			// Invoked by git: path old-file old-hex old-mode new-file new-hex new-mode
+			oldFile, newFile = args[1], args[4]
+		}
+
+		oldConfig, oldErr := loadDiffFile(oldFile)
+		newConfig, newErr := loadDiffFile(newFile)
+		if byGit && (errors.Is(oldErr, config.ErrNotConnectionFile) || errors.Is(newErr, config.ErrNotConnectionFile)) {
+			// Matched by a broad .gitattributes pattern; don't hide its changes
+			return textDiff(oldFile, newFile)
+		}
+		if oldErr != nil {
+			return oldErr
+		}
+		if newErr != nil {
+			return newErr
+		}
+		if byGit && !diffJSON {
+			fmt.Printf("mremotego diff %s\n", args[0])
+		}
+
+		changes := config.Diff(oldConfig, newConfig)
+
+		if diffJSON {
+			if changes == nil {
+				changes = []config.Change{}
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(changes); err != nil {
+				return err
+			}
+		} else {
+			printChanges(changes)
+		}
+
+		if diffExitCode && len(changes) > 0 {
+			return fmt.Errorf("%d difference(s) found", len(changes))
+		}
+		return nil
+	},
+}
+
+// diffArgs accepts two files, or the seven arguments git passes to diff drivers
+func diffArgs(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 && len(args) != 7 {
+		return fmt.Errorf("expected two files, got %d arguments", len(args))
+	}
+	return nil
+}
+
+// loadDiffFile loads a connection file, treating /dev/null as an empty file
+func loadDiffFile(path string) (*models.Config, error) {
+	if path == os.DevNull {
+		return models.NewConfig(), nil
+	}
+	return config.LoadFile(path)
+}
+
+// textDiff prints a line by line diff of two files with git
+func textDiff(oldFile, newFile string) error {
+	cmd := exec.Command("git", "diff", "--no-index", "--no-ext-diff", "--", oldFile, newFile)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// The files differ
+		return nil
+	}
+	return err
+}
+
+func printChanges(changes []config.Change) {
+	for _, change := range changes {
+		kind := change.Noun()
+
+		switch change.Kind {
+		case config.ChangeAdded:
+			fmt.Printf("+ %s (%s)\n", change.Path, kind)
+		case config.ChangeRemoved:
+			fmt.Printf("- %s (%s)\n", change.Path, kind)
+		case config.ChangeMoved:
+			fmt.Printf("> %s -> %s (%s)\n", change.OldPath, change.Path, kind)
+		case config.ChangeModified:
+			fmt.Printf("~ %s (%s)\n", change.Path, kind)
+		}
+
+		for _, field := range change.Fields {
+			fmt.Printf("    %s: %s -> %s\n", field.Field, field.Old, field.New)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().BoolVar(&diffExitCode, "exit-code", false, "Exit with a non-zero status if the files differ")
+	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "Print changes as JSON")
+}
@@ -2,12 +2,17 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/jaydenthorup/mremotego/internal/config"
+	"github.com/jaydenthorup/mremotego/internal/launcher"
 	"github.com/jaydenthorup/mremotego/pkg/models"
 )
 
 var (
+	editRename      string
 	editHost        string
 	editPort        int
 	editUsername    string
@@ -15,34 +20,99 @@ var (
 	editDomain      string
 	editDescription string
 	editProtocol    string
+	editCredSSP     bool
+	editColorDepth  int
+	editResolution  string
+	editExtraArgs   string
+	editSSH         sshOptions
+	editJumpHost    string
+	editNotes       string
+	editTags        []string
+	editAddTags     []string
+	editRemoveTags  []string
+	editFields      []string
+	editVars        []string
+	editOverrides   []string
+	editUnset       []string
+	editFilter      string
 )
 
+// editFlagFields maps edit flags to the connection fields they set
+var editFlagFields = map[string]string{
+	"rename":      "name",
+	"protocol":    "protocol",
+	"host":        "host",
+	"port":        "port",
+	"username":    "username",
+	"password":    "password",
+	"domain":      "domain",
+	"description": "description",
+	"credssp":     "use_credssp",
+	"color-depth": "color_depth",
+	"resolution":  "resolution",
+	"extra-args":  "extra_args",
+	"jump-host":   "jump_host",
+	"notes":       "notes",
+	"tags":        "tags",
+}
+
 var editCmd = &cobra.Command{
 	Use:   "edit [connection name]",
 	Short: "Edit an existing connection",
-	Long:  `Modify properties of an existing connection.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Modify properties of an existing connection or folder.
+
+Only the settings given on the command line are changed. Use --unset to
+clear a field (e.g. --unset domain), a single custom field
+(--unset custom_fields.asset_id), a single variable (--unset variables.env)
+or one profile's overrides (--unset profiles.staging).
+
+Instead of a name, --filter applies the changes to every connection matching
+a query (see 'mremotego list --help' for the syntax).
+
+Fields: ` + strings.Join(models.EditableFieldNames(), ", "),
+	Example: `  mremotego edit web1 --host web1.example.com --unset domain
+  mremotego edit web1 --identity-file ~/.ssh/id_ed25519 --identities-only --local-forward 8080:localhost:80
+  mremotego edit db1 --jump-host Production/bastion
+  mremotego edit web1 --profile-override staging.host=web1.staging.local
+  mremotego edit --filter "tag:prod protocol:ssh" --add-tag audited`,
+	Args:          cobra.MaximumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		connectionName := args[0]
+		if (len(args) == 1) == (editFilter != "") {
+			return fmt.Errorf("give either a connection name or --filter")
+		}
 
 		manager, err := getConfigManager()
 		if err != nil {
 			return err
 		}
 
-		// Create updates object
-		updates := &models.Connection{
-			Host:        editHost,
-			Port:        editPort,
-			Username:    editUsername,
-			Password:    editPassword,
-			Domain:      editDomain,
-			Description: editDescription,
-			Protocol:    models.Protocol(editProtocol),
+		if editFilter != "" {
+			return editMatching(cmd, manager)
+		}
+
+		connectionName := args[0]
+		conn, err := manager.FindConnection(connectionName)
+		if err != nil {
+			return err
+		}
+
+		updates, mask, err := buildEditUpdates(cmd, manager.GetConfig(), conn)
+		if err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("jump-host") {
+			if err := checkJumpHost(manager, conn, updates.JumpHost); err != nil {
+				return err
+			}
+		}
+		if len(mask) == 0 {
+			return fmt.Errorf("nothing to change (see 'mremotego edit --help')")
 		}
 
 		// Update
-		if err := manager.UpdateConnection(connectionName, updates); err != nil {
+		if err := manager.UpdateNodeFields(conn, updates, mask); err != nil {
 			return fmt.Errorf("failed to update connection: %w", err)
 		}
 
@@ -51,20 +121,317 @@ var editCmd = &cobra.Command{
 			return fmt.Errorf("failed to save config: %w", err)
 		}
 
-		fmt.Printf("✓ Updated connection '%s'\n", connectionName)
+		if updates.Name != connectionName {
+			fmt.Printf("✓ Renamed '%s' to '%s'\n", connectionName, updates.Name)
+		}
+		fmt.Printf("✓ Updated connection '%s' (%s)\n", updates.Name, strings.Join(mask, ", "))
 
 		return nil
 	},
 }
 
+// editMatching applies the edit flags to every connection matching --filter
+func editMatching(cmd *cobra.Command, manager *config.Manager) error {
+	if cmd.Flags().Changed("rename") {
+		return fmt.Errorf("--rename can't be used with --filter")
+	}
+
+	results, err := selectConnections(manager, editFilter)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("no connection matches '%s'", editFilter)
+	}
+
+	var updated []string
+	for _, result := range results {
+		updates, mask, err := buildEditUpdates(cmd, manager.GetConfig(), result.Connection)
+		if err == nil && cmd.Flags().Changed("jump-host") {
+			err = checkJumpHost(manager, result.Connection, updates.JumpHost)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", result.Path(), err)
+		}
+		if len(mask) == 0 {
+			continue
+		}
+		if err := manager.UpdateNodeFields(result.Connection, updates, mask); err != nil {
+			return fmt.Errorf("failed to update '%s': %w", result.Path(), err)
+		}
+		updated = append(updated, fmt.Sprintf("%s (%s)", result.Path(), strings.Join(mask, ", ")))
+	}
+	if len(updated) == 0 {
+		return fmt.Errorf("nothing to change in the %d matching connection(s)", len(results))
+	}
+
+	if err := manager.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	for _, line := range updated {
+		fmt.Printf("  %s\n", line)
+	}
+	fmt.Printf("✓ Updated %d connection(s)\n", len(updated))
+	return nil
+}
+
+// buildEditUpdates turns the edit flags into the new field values and the
+// mask of fields to change
+func buildEditUpdates(cmd *cobra.Command, cfg *models.Config, conn *models.Connection) (*models.Connection, []string, error) {
+	updates := conn.DeepCopy()
+	updates.Children = nil
+
+	var mask []string
+	masked := make(map[string]bool)
+	addMask := func(field string) {
+		if !masked[field] {
+			masked[field] = true
+			mask = append(mask, field)
+		}
+	}
+
+	flags := cmd.Flags()
+	for _, field := range models.EditableFields {
+		for flag, name := range editFlagFields {
+			if name == field.Name && flags.Changed(flag) {
+				addMask(name)
+			}
+		}
+	}
+
+	if flags.Changed("tags") && (len(editAddTags) > 0 || len(editRemoveTags) > 0) {
+		return nil, nil, fmt.Errorf("--tags can't be combined with --add-tag or --remove-tag")
+	}
+
+	updates.Name = editRename
+	updates.Protocol = models.NormalizeProtocol(editProtocol)
+	updates.Host = editHost
+	updates.Port = editPort
+	updates.Username = editUsername
+	updates.Password = editPassword
+	updates.Domain = editDomain
+	updates.Description = editDescription
+	updates.UseCredSSP = editCredSSP
+	updates.ColorDepth = editColorDepth
+	updates.Resolution = editResolution
+	updates.ExtraArgs = editExtraArgs
+	if err := editSSH.apply(cmd, updates); err != nil {
+		return nil, nil, err
+	}
+	updates.JumpHost = editJumpHost
+	updates.Notes = editNotes
+	updates.Tags = editTags
+	if !masked["tags"] {
+		updates.Tags = conn.DeepCopy().Tags
+	}
+
+	// Tags
+	for _, tag := range editAddTags {
+		if !containsString(updates.Tags, tag) {
+			updates.Tags = append(updates.Tags, tag)
+		}
+		addMask("tags")
+	}
+	for _, tag := range editRemoveTags {
+		if !containsString(updates.Tags, tag) {
+			if editFilter != "" {
+				// Batch edits skip connections without the tag
+				continue
+			}
+			return nil, nil, fmt.Errorf("'%s' has no tag '%s'", conn.Name, tag)
+		}
+		kept := updates.Tags[:0]
+		for _, t := range updates.Tags {
+			if t != tag {
+				kept = append(kept, t)
+			}
+		}
+		updates.Tags = kept
+		addMask("tags")
+	}
+
+	// Custom fields, typed by the schema when they are declared
+	for _, pair := range editFields {
+		name, text, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, nil, fmt.Errorf("invalid custom field '%s' (expected name=value)", pair)
+		}
+		fieldType := models.FieldTypeString
+		if schema := cfg.FindCustomField(name); schema != nil {
+			fieldType = schema.FieldType()
+		}
+		value, err := models.ParseFieldValue(fieldType, text)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid value for custom field '%s': %w", name, err)
+		}
+		if updates.CustomFields == nil {
+			updates.CustomFields = make(models.CustomFields)
+		}
+		updates.CustomFields[name] = value
+		addMask(models.CustomFieldPrefix + name)
+	}
+
+	// Variables
+	vars, err := config.ParseVariables(editVars)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, value := range vars {
+		if updates.Variables == nil {
+			updates.Variables = make(map[string]string)
+		}
+		updates.Variables[key] = value
+		addMask("variables")
+	}
+
+	// Profile overrides
+	for _, pair := range editOverrides {
+		profile, err := parseProfileOverride(updates, pair)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(cfg.Profiles) > 0 && cfg.FindProfile(profile) == nil {
+			return nil, nil, fmt.Errorf("profile '%s' is not declared in the profiles section", profile)
+		}
+		addMask("profiles")
+	}
+
+	// Cleared fields
+	for _, field := range editUnset {
+		switch {
+		case strings.HasPrefix(field, "profiles."):
+			profile := strings.TrimPrefix(field, "profiles.")
+			if _, exists := updates.Profiles[profile]; !exists {
+				if editFilter != "" {
+					continue
+				}
+				return nil, nil, fmt.Errorf("'%s' has no overrides for profile '%s'", conn.Name, profile)
+			}
+			delete(updates.Profiles, profile)
+			addMask("profiles")
+		case strings.HasPrefix(field, "variables."):
+			key := strings.TrimPrefix(field, "variables.")
+			if _, exists := updates.Variables[key]; !exists {
+				if editFilter != "" {
+					continue
+				}
+				return nil, nil, fmt.Errorf("'%s' has no variable '%s'", conn.Name, key)
+			}
+			delete(updates.Variables, key)
+			addMask("variables")
+		case strings.HasPrefix(field, models.CustomFieldPrefix):
+			delete(updates.CustomFields, strings.TrimPrefix(field, models.CustomFieldPrefix))
+			addMask(field)
+		case field == "name":
+			return nil, nil, fmt.Errorf("the name can't be unset (use --rename)")
+		default:
+			// Flag names such as color-depth work as well as field names
+			if name, exists := editFlagFields[field]; exists && name != "name" {
+				field = name
+			}
+			if masked[field] {
+				return nil, nil, fmt.Errorf("'%s' can't be both set and unset", field)
+			}
+			editable, err := models.FindEditableField(field)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w (fields: %s)", err, strings.Join(models.EditableFieldNames(), ", "))
+			}
+			clearField(updates, editable.Name)
+			addMask(editable.Name)
+		}
+	}
+
+	if !masked["name"] {
+		updates.Name = conn.Name
+	}
+
+	return updates, mask, nil
+}
+
+// parseProfileOverride applies a profile.field=value override to a
+// connection and returns the profile name
+func parseProfileOverride(conn *models.Connection, pair string) (string, error) {
+	key, value, found := strings.Cut(pair, "=")
+	profile, field, hasField := strings.Cut(strings.TrimSpace(key), ".")
+	if !found || !hasField || profile == "" {
+		return "", fmt.Errorf("invalid profile override '%s' (expected profile.field=value)", pair)
+	}
+
+	override := conn.Profiles[profile]
+	if override == nil {
+		override = &models.ProfileOverride{}
+	}
+	switch field {
+	case "host":
+		override.Host = value
+	case "port":
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 0 || port > 65535 {
+			return "", fmt.Errorf("invalid port in profile override '%s'", pair)
+		}
+		override.Port = port
+	case "username":
+		override.Username = value
+	default:
+		return "", fmt.Errorf("unknown profile override field '%s' (expected host, port or username)", field)
+	}
+
+	if conn.Profiles == nil {
+		conn.Profiles = make(map[string]*models.ProfileOverride)
+	}
+	if *override == (models.ProfileOverride{}) {
+		delete(conn.Profiles, profile)
+	} else {
+		conn.Profiles[profile] = override
+	}
+	return profile, nil
+}
+
+// clearField resets one field of a connection to its empty value
+func clearField(conn *models.Connection, field string) {
+	// Copying from an empty connection clears the field
+	_ = conn.CopyField(&models.Connection{}, field)
+}
+
+// containsString reports whether a slice contains a string
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func init() {
 	rootCmd.AddCommand(editCmd)
 
+	editCmd.Flags().StringVar(&editRename, "rename", "", "New connection name")
 	editCmd.Flags().StringVar(&editHost, "host", "", "New host address")
 	editCmd.Flags().IntVar(&editPort, "port", 0, "New port number")
 	editCmd.Flags().StringVar(&editUsername, "username", "", "New username")
 	editCmd.Flags().StringVar(&editPassword, "password", "", "New password")
 	editCmd.Flags().StringVar(&editDomain, "domain", "", "New domain")
 	editCmd.Flags().StringVar(&editDescription, "description", "", "New description")
-	editCmd.Flags().StringVar(&editProtocol, "protocol", "", "New protocol")
+	editCmd.Flags().StringVar(&editProtocol, "protocol", "", "New protocol: "+launcher.ProtocolList())
+	editCmd.Flags().BoolVar(&editCredSSP, "credssp", false, "Use CredSSP (RDP, --credssp=false to turn off)")
+	editCmd.Flags().IntVar(&editColorDepth, "color-depth", 0, "Color depth (RDP)")
+	editCmd.Flags().StringVar(&editResolution, "resolution", "", "Resolution, e.g. 1920x1080 (RDP)")
+	editCmd.Flags().StringVar(&editExtraArgs, "extra-args", "", "Additional protocol-specific arguments")
+	editSSH.register(editCmd)
+	for flag, field := range sshOptionFlagFields {
+		editFlagFields[flag] = field
+	}
+	editCmd.Flags().StringVar(&editJumpHost, "jump-host", "", jumpHostFlagHelp)
+	editCmd.Flags().StringVar(&editNotes, "notes", "", "Notes")
+	editCmd.Flags().StringSliceVar(&editTags, "tags", []string{}, "Replace all tags (comma-separated)")
+	editCmd.Flags().StringArrayVar(&editAddTags, "add-tag", []string{}, "Add a tag (repeatable)")
+	editCmd.Flags().StringArrayVar(&editRemoveTags, "remove-tag", []string{}, "Remove a tag (repeatable)")
+	editCmd.Flags().StringArrayVar(&editFields, "field", []string{}, "Set a custom field (name=value, repeatable)")
+	editCmd.Flags().StringArrayVar(&editVars, "var", []string{}, "Set a variable (key=value, repeatable)")
+	editCmd.Flags().StringArrayVar(&editOverrides, "profile-override", []string{}, "Override host, port or username for a profile (profile.field=value, repeatable)")
+	editCmd.Flags().StringVarP(&editFilter, "filter", "f", "", "Edit every connection matching a query instead of a named one")
+	editCmd.Flags().StringArrayVar(&editUnset, "unset", []string{}, "Clear a field, custom_fields.<name>, variables.<name> or profiles.<name> (repeatable)")
 }
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/internal/config"
+	"github.com/jaydenthorup/mremotego/internal/query"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// selectConnections returns the connections matching a query expression.
+// Secret custom fields are not searched.
+func selectConnections(manager *config.Manager, expr string) ([]query.Result, error) {
+	q, err := query.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	cfg := manager.GetConfig()
+	q.IsSecret = cfg.IsSecretField
+	return q.Filter(cfg.Connections), nil
+}
+
+// maxListedMatches limits how many matches are named when a query is ambiguous
+const maxListedMatches = 10
+
+// selectOneConnection returns the single connection matching a query
+func selectOneConnection(manager *config.Manager, expr string) (*models.Connection, error) {
+	results, err := selectConnections(manager, expr)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(results) {
+	case 0:
+		return nil, fmt.Errorf("no connection matches '%s'", expr)
+	case 1:
+		return results[0].Connection, nil
+	}
+
+	paths := make([]string, 0, maxListedMatches)
+	for i, result := range results {
+		if i == maxListedMatches {
+			paths = append(paths, fmt.Sprintf("  ... and %d more", len(results)-maxListedMatches))
+			break
+		}
+		paths = append(paths, "  "+result.Path())
+	}
+	return nil, fmt.Errorf("%d connections match '%s'; narrow the filter:\n%s", len(results), expr, strings.Join(paths, "\n"))
+}
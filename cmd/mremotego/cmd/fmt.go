@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jaydenthorup/mremotego/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fmtCheck        bool
+	fmtStdout       bool
+	fmtSort         bool
+	fmtDefaultPorts string
+	fmtAssignIDs    bool
+)
+
+var fmtCmd = &cobra.Command{
+	Use:   "fmt [file...]",
+	Short: "Rewrite connection files in canonical form",
+	Long: `Rewrite connection files in a canonical form so that changes are easy to
+review: stable key order, consistent quoting and indentation, normalized
+protocol names and consistently handled default ports. Comments are kept.
+
+Use --check in CI to fail when a file is not canonical.
+
+If no file is given, the current config file is formatted.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files := args
+		if len(files) == 0 {
+			if cfgFile == "" {
+				initConfig()
+			}
+			files = []string{cfgFile}
+		}
+
+		opts := config.FormatOptions{
+			Sort:         fmtSort,
+			DefaultPorts: config.DefaultPortMode(fmtDefaultPorts),
+			AssignIDs:    fmtAssignIDs,
+		}
+
+		unformatted := 0
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", file, err)
+			}
+
+			formatted, err := config.Format(data, opts)
+			if err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+
+			switch {
+			case fmtCheck:
+				if string(formatted) != string(data) {
+					fmt.Println(file)
+					unformatted++
+				}
+			case fmtStdout:
+				os.Stdout.Write(formatted)
+			case string(formatted) != string(data):
+				if err := os.WriteFile(file, formatted, 0600); err != nil {
+					return fmt.Errorf("failed to write %s: %w", file, err)
+				}
+				fmt.Printf("✓ Formatted '%s'\n", file)
+			}
+		}
+
+		if unformatted > 0 {
+			return fmt.Errorf("%d file(s) are not canonically formatted (run 'mremotego fmt')", unformatted)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fmtCmd)
+
+	fmtCmd.Flags().BoolVar(&fmtCheck, "check", false, "Don't write files; exit non-zero if any file is not canonical")
+	fmtCmd.Flags().BoolVar(&fmtStdout, "stdout", false, "Print the formatted output instead of rewriting files")
+	fmtCmd.Flags().BoolVar(&fmtSort, "sort", false, "Sort folders and connections by name (folders first)")
+	fmtCmd.Flags().BoolVar(&fmtAssignIDs, "assign-ids", false, "Give every entry without an id a new one (used by diff and merge-driver)")
+	fmtCmd.Flags().StringVar(&fmtDefaultPorts, "default-ports", string(config.DefaultPortsFill), "How to handle default ports: fill or omit")
+}
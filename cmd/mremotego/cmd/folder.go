@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var (
+	folderParents     bool
+	folderDescription string
+	folderNotes       string
+	folderTags        []string
+	folderRecursive   bool
+	folderTreeDepth   int
+)
+
+var folderCmd = &cobra.Command{
+	Use:   "folder",
+	Short: "Create, remove and list folders",
+	Long: `Manage the folder structure of the connection tree. Folder paths use '/'
+between levels (e.g. 'Production/Servers'). Folders are renamed, moved and
+edited like connections, with 'rename', 'mv' and 'edit'.`,
+}
+
+var folderMkdirCmd = &cobra.Command{
+	Use:   "mkdir <path>",
+	Short: "Create an empty folder",
+	Example: `  mremotego folder mkdir Production
+  mremotego folder mkdir -p Production/Web/Frontend --description "Public web servers"`,
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := args[0]
+
+		manager, err := getConfigManager()
+		if err != nil {
+			return err
+		}
+
+		folder, err := manager.CreateFolder(path, folderParents)
+		if err != nil {
+			return fmt.Errorf("failed to create folder: %w", err)
+		}
+		folder.Description = folderDescription
+		folder.Notes = folderNotes
+		if len(folderTags) > 0 {
+			folder.Tags = folderTags
+		}
+
+		if err := manager.Save(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+
+		fmt.Printf("✓ Created folder '%s'\n", path)
+		return nil
+	},
+}
+
+var folderRmdirCmd = &cobra.Command{
+	Use:   "rmdir <path>",
+	Short: "Remove a folder",
+	Long: `Remove a folder. Folders that still contain connections or folders are
+only removed with --recursive, which deletes everything inside them.`,
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := args[0]
+
+		manager, err := getConfigManager()
+		if err != nil {
+			return err
+		}
+
+		folder, err := manager.FindFolder(path)
+		if err != nil {
+			return err
+		}
+		var removed int
+		if folder != nil {
+			removed = countEntries(folder.Children)
+		}
+
+		if err := manager.DeleteFolder(path, folderRecursive); err != nil {
+			if !folderRecursive && removed > 0 {
+				return fmt.Errorf("failed to remove folder: %w (use --recursive to remove it anyway)", err)
+			}
+			return fmt.Errorf("failed to remove folder: %w", err)
+		}
+
+		if err := manager.Save(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+
+		if removed > 0 {
+			fmt.Printf("✓ Removed folder '%s' and %d item(s) in it\n", path, removed)
+		} else {
+			fmt.Printf("✓ Removed folder '%s'\n", path)
+		}
+		return nil
+	},
+}
+
+var folderLsCmd = &cobra.Command{
+	Use:           "ls [path]",
+	Short:         "List the contents of a folder",
+	Long:          `List the folders and connections directly inside a folder (the top level by default).`,
+	Args:          cobra.MaximumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := ""
+		if len(args) == 1 {
+			path = args[0]
+		}
+
+		manager, err := getConfigManager()
+		if err != nil {
+			return err
+		}
+
+		folder, err := manager.FindFolder(path)
+		if err != nil {
+			return err
+		}
+		entries := manager.GetConfig().Connections
+		if folder != nil {
+			entries = folder.Children
+		}
+
+		if len(entries) == 0 {
+			fmt.Println("(empty)")
+			return nil
+		}
+
+		for _, entry := range entries {
+			if entry.IsFolder() {
+				fmt.Printf("📁 %s/  (%d item(s))", entry.Name, len(entry.Children))
+			} else {
+				fmt.Printf("%s %s (%s://%s", getProtocolIcon(entry.Protocol), entry.Name, entry.Protocol, entry.Host)
+				if entry.Port != 0 {
+					fmt.Printf(":%d", entry.Port)
+				}
+				fmt.Print(")")
+			}
+			if entry.Description != "" {
+				fmt.Printf(" - %s", firstLine(entry.Description))
+			}
+			fmt.Println()
+		}
+		return nil
+	},
+}
+
+var folderTreeCmd = &cobra.Command{
+	Use:           "tree [path]",
+	Short:         "Show the folder structure",
+	Long:          `Show the folders below a folder (the top level by default) with the number of connections in each.`,
+	Args:          cobra.MaximumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := ""
+		if len(args) == 1 {
+			path = args[0]
+		}
+
+		manager, err := getConfigManager()
+		if err != nil {
+			return err
+		}
+
+		folder, err := manager.FindFolder(path)
+		if err != nil {
+			return err
+		}
+		entries := manager.GetConfig().Connections
+		if folder != nil {
+			fmt.Printf("📁 %s (%d connections)\n", folder.Name, countConnections(folder.Children))
+			entries = folder.Children
+		}
+
+		printFolderTree(entries, "", 1)
+		return nil
+	},
+}
+
+// printFolderTree prints the folders in entries with tree guides, down to folderTreeDepth levels
+func printFolderTree(entries []*models.Connection, prefix string, depth int) {
+	if folderTreeDepth > 0 && depth > folderTreeDepth {
+		return
+	}
+
+	var folders []*models.Connection
+	for _, entry := range entries {
+		if entry.IsFolder() {
+			folders = append(folders, entry)
+		}
+	}
+
+	for i, folder := range folders {
+		branch, indent := "├── ", "│   "
+		if i == len(folders)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		fmt.Printf("%s%s📁 %s (%d connections)", prefix, branch, folder.Name, countConnections(folder.Children))
+		if folder.Description != "" {
+			fmt.Printf(" - %s", firstLine(folder.Description))
+		}
+		fmt.Println()
+
+		printFolderTree(folder.Children, prefix+indent, depth+1)
+	}
+}
+
+// countConnections counts the connections in entries and all folders below them
+func countConnections(entries []*models.Connection) int {
+	count := 0
+	for _, entry := range entries {
+		if entry.IsFolder() {
+			count += countConnections(entry.Children)
+		} else {
+			count++
+		}
+	}
+	return count
+}
+
+// countEntries counts the connections and folders in entries and below them
+func countEntries(entries []*models.Connection) int {
+	count := len(entries)
+	for _, entry := range entries {
+		count += countEntries(entry.Children)
+	}
+	return count
+}
+
+// firstLine returns the first line of a possibly multi-line text
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return line
+}
+
+func init() {
+	rootCmd.AddCommand(folderCmd)
+	folderCmd.AddCommand(folderMkdirCmd, folderRmdirCmd, folderLsCmd, folderTreeCmd)
+
+	folderMkdirCmd.Flags().BoolVarP(&folderParents, "parents", "p", false, "Create missing parent folders")
+	folderMkdirCmd.Flags().StringVar(&folderDescription, "description", "", "Folder description")
+	folderMkdirCmd.Flags().StringVar(&folderNotes, "notes", "", "Folder notes")
+	folderMkdirCmd.Flags().StringSliceVar(&folderTags, "tags", []string{}, "Tags (comma-separated)")
+	folderRmdirCmd.Flags().BoolVarP(&folderRecursive, "recursive", "r", false, "Remove the folder even if it isn't empty")
+	folderTreeCmd.Flags().IntVar(&folderTreeDepth, "depth", 0, "Maximum number of folder levels to show (0 for all)")
+}
@@ -2,31 +2,106 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/jaydenthorup/mremotego/internal/query"
 	"github.com/jaydenthorup/mremotego/pkg/models"
 )
 
+var (
+	listOutput string
+	listFields string
+	listFilter string
+)
+
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all connections",
-	Long:  `Display all configured connections in a tree format.`,
+	Long: `Display all configured connections in a tree format, or as a table, CSV,
+JSON or YAML for scripts (--output). Select the fields to show with --fields,
+e.g. --fields name,host,tags,custom_fields.asset_id. Passwords and secret
+custom fields are hidden.
+
+Show only some connections with --filter:
+
+` + query.Help,
+	Example: `  mremotego list --output table
+  mremotego list --filter "tag:prod protocol:rdp -tag:decom"
+  mremotego list -o json | jq -r '.[].host'
+  mremotego list -o csv --fields path,host,port`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		manager, err := getConfigManager()
 		if err != nil {
 			return err
 		}
 
-		connections := manager.ListConnections()
-		if len(connections) == 0 {
+		switch listOutput {
+		case outputTree, outputTable, outputCSV, outputJSON, outputYAML:
+		default:
+			return fmt.Errorf("unknown output format '%s' (expected tree, table, csv, json or yaml)", listOutput)
+		}
+
+		fields, err := parseFields(listFields)
+		if err != nil {
+			return err
+		}
+
+		if listOutput == outputTree && len(manager.ListConnections()) == 0 {
 			fmt.Println("No connections configured. Use 'mremotego add' to create one.")
 			return nil
 		}
 
+		results, err := selectConnections(manager, listFilter)
+		if err != nil {
+			return err
+		}
+
+		if listOutput != outputTree {
+			omitEmpty := false
+			if len(fields) == 0 {
+				switch listOutput {
+				case outputJSON, outputYAML:
+					fields, omitEmpty = outputFieldNames(), true
+				default:
+					fields = defaultTableFields
+				}
+			}
+
+			var records []record
+			for _, result := range results {
+				r, err := newRecord(manager, result.Connection, result.Connection, fields, false, omitEmpty)
+				if err != nil {
+					return err
+				}
+				records = append(records, r)
+			}
+			return writeRecords(os.Stdout, listOutput, fields, records)
+		}
+
+		if len(fields) > 0 {
+			return fmt.Errorf("--fields can't be used with the tree output")
+		}
+
 		// Print connections
 		cfg := manager.GetConfig()
-		printConnectionTree(cfg.Connections, 0)
+		if listFilter == "" {
+			printConnectionTree(cfg.Connections, 0)
+			return nil
+		}
+
+		if len(results) == 0 {
+			fmt.Println("No connections match the filter.")
+			return nil
+		}
+		matched := make(map[*models.Connection]bool)
+		for _, result := range results {
+			matched[result.Connection] = true
+		}
+		printConnectionTree(pruneTree(cfg.Connections, matched), 0)
 
 		return nil
 	},
@@ -38,6 +113,9 @@ func printConnectionTree(connections []*models.Connection, level int) {
 	for _, conn := range connections {
 		if conn.IsFolder() {
 			fmt.Printf("%s📁 %s\n", indent, conn.Name)
+			if conn.Description != "" {
+				fmt.Printf("%s   └─ %s\n", indent, conn.Description)
+			}
 			printConnectionTree(conn.Children, level+1)
 		} else {
 			icon := getProtocolIcon(conn.Protocol)
@@ -54,6 +132,26 @@ func printConnectionTree(connections []*models.Connection, level int) {
 	}
 }
 
+// pruneTree returns a copy of the tree holding only the matched connections
+// and the folders above them
+func pruneTree(connections []*models.Connection, matched map[*models.Connection]bool) []*models.Connection {
+	var pruned []*models.Connection
+	for _, conn := range connections {
+		if !conn.IsFolder() {
+			if matched[conn] {
+				pruned = append(pruned, conn)
+			}
+			continue
+		}
+		if children := pruneTree(conn.Children, matched); len(children) > 0 {
+			folder := *conn
+			folder.Children = children
+			pruned = append(pruned, &folder)
+		}
+	}
+	return pruned
+}
+
 func getProtocolIcon(protocol models.Protocol) string {
 	switch protocol {
 	case models.ProtocolSSH:
@@ -73,4 +171,8 @@ func getProtocolIcon(protocol models.Protocol) string {
 
 func init() {
 	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", outputTree, "Output format: tree, table, csv, json, yaml")
+	listCmd.Flags().StringVar(&listFields, "fields", "", "Comma-separated fields to show (table, csv, json, yaml)")
+	listCmd.Flags().StringVarP(&listFilter, "filter", "f", "", "Only list connections matching a query (e.g. 'tag:prod protocol:ssh')")
 }
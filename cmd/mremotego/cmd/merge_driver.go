@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jaydenthorup/mremotego/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var mergeDriverCmd = &cobra.Command{
+	Use:   "merge-driver <base> <ours> <theirs> [path]",
+	Short: "Three-way merge connection files (git merge driver)",
+	Long: `Merge connection files structurally. Connections are matched by id, so
+edits to different fields, moves and additions on both branches merge
+cleanly. Conflict markers are only written for real conflicts: the same field
+changed differently on both sides, or an entry edited on one side and deleted
+on the other.
+
+The result is written to <ours>. The exit status is non-zero if conflicts
+remain, as git expects, or if an input is not a connection file, in which
+case nothing is written. To use it as a merge driver for your connection
+file:
+
+  git config merge.mremotego.name "mremotego connection files"
+  git config merge.mremotego.driver "mremotego merge-driver %O %A %B %P"
+  echo "connections.yaml diff=mremotego merge=mremotego" >> .gitattributes`,
+	Args:          cobra.RangeArgs(3, 4),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		basePath, oursPath, theirsPath := args[0], args[1], args[2]
+		displayPath := oursPath
+		if len(args) == 4 {
+			displayPath = args[3]
+		}
+
+		base, err := os.ReadFile(basePath)
+		if err != nil {
+			return fmt.Errorf("failed to read base: %w", err)
+		}
+		ours, err := os.ReadFile(oursPath)
+		if err != nil {
+			return fmt.Errorf("failed to read ours: %w", err)
+		}
+		theirs, err := os.ReadFile(theirsPath)
+		if err != nil {
+			return fmt.Errorf("failed to read theirs: %w", err)
+		}
+
+		result, err := config.Merge(base, ours, theirs)
+		if err != nil {
+			return fmt.Errorf("%s: %w", displayPath, err)
+		}
+
+		if err := os.WriteFile(oursPath, result.Data, 0600); err != nil {
+			return fmt.Errorf("failed to write merge result: %w", err)
+		}
+
+		if len(result.Conflicts) > 0 {
+			for _, c := range result.Conflicts {
+				if c.Field != "" {
+					fmt.Fprintf(os.Stderr, "CONFLICT %s: %s: %s: %s\n", displayPath, c.Path, c.Field, c.Reason)
+				} else {
+					fmt.Fprintf(os.Stderr, "CONFLICT %s: %s: %s\n", displayPath, c.Path, c.Reason)
+				}
+			}
+			return fmt.Errorf("%d conflict(s) in %s", len(result.Conflicts), displayPath)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mergeDriverCmd)
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var moveCmd = &cobra.Command{
+	Use:     "mv <name> <folder>",
+	Aliases: []string{"move"},
+	Short:   "Move a connection or folder into another folder",
+	Long: `Move a connection or folder, given by ID, path (Folder/name) or name, to
+the end of another folder. Folder paths use '/' between levels (e.g.
+'Production/Servers'); use '/' on its own for the top level. A folder can't be
+moved into itself or one of its subfolders, and the target folder can't
+already hold an entry of the same name.`,
+	Example: `  mremotego mv web1 Production/Servers
+  mremotego mv Legacy /`,
+	Args:          cobra.ExactArgs(2),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, folder := args[0], args[1]
+
+		manager, err := getConfigManager()
+		if err != nil {
+			return err
+		}
+
+		if err := manager.MoveConnection(name, folder); err != nil {
+			return fmt.Errorf("failed to move '%s': %w", name, err)
+		}
+
+		if err := manager.Save(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+
+		fmt.Printf("✓ Moved '%s' to %s\n", name, describeFolder(folder))
+		return nil
+	},
+}
+
+// describeFolder names a folder path for messages
+func describeFolder(path string) string {
+	if strings.Trim(path, "/\\") == "" {
+		return "the top level"
+	}
+	return fmt.Sprintf("'%s'", path)
+}
+
+func init() {
+	rootCmd.AddCommand(moveCmd)
+}
@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jaydenthorup/mremotego/internal/config"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats for list and show
+const (
+	outputTree  = "tree"
+	outputTable = "table"
+	outputCSV   = "csv"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputText  = "text"
+)
+
+// defaultTableFields are the columns shown by the table and csv formats
+var defaultTableFields = []string{"name", "folder", "protocol", "host", "port", "username"}
+
+// outputFieldNames lists every field that can be selected with --fields
+func outputFieldNames() []string {
+	names := []string{"path", "folder", "id", "type"}
+	names = append(names, models.EditableFieldNames()...)
+	return append(names, "created", "modified")
+}
+
+// parseFields splits a --fields value and checks the names
+func parseFields(value string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !strings.HasPrefix(field, models.CustomFieldPrefix) && !containsString(outputFieldNames(), field) {
+			return nil, fmt.Errorf("unknown field '%s' (fields: %s, custom_fields.<name>)", field, strings.Join(outputFieldNames(), ", "))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// record is one connection's selected fields, in order
+type record struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// MarshalJSON writes the fields in their selected order
+func (r record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(r.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalYAML writes the fields in their selected order
+func (r record) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range r.keys {
+		var value yaml.Node
+		if err := value.Encode(r.values[key]); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &value)
+	}
+	return node, nil
+}
+
+// newRecord collects the given fields of a connection. entry is the
+// connection's node in the tree, used for its path; conn supplies the values
+// (e.g. a resolved copy of entry). Passwords and secret custom fields are
+// hidden unless reveal is set. With omitEmpty, fields without a value are
+// left out.
+func newRecord(manager *config.Manager, entry, conn *models.Connection, fields []string, reveal, omitEmpty bool) (record, error) {
+	r := record{values: make(map[string]interface{})}
+	cfg := manager.GetConfig()
+
+	for _, field := range fields {
+		var value interface{}
+		switch field {
+		case "path":
+			value = manager.NodePath(entry)
+		case "folder":
+			value = ""
+			if parent := manager.ParentOf(entry); parent != nil {
+				value = manager.NodePath(parent)
+			}
+		case "id":
+			value = conn.ID
+		case "type":
+			value = string(conn.Type)
+		case "created":
+			value = conn.Created
+		case "modified":
+			value = conn.Modified
+		default:
+			v, err := conn.FieldValue(field)
+			if err != nil {
+				return r, err
+			}
+			value = v
+		}
+
+		if !reveal {
+			value = redactField(cfg, field, value)
+		}
+		if omitEmpty && isEmptyValue(value) {
+			continue
+		}
+		// Plain types keep the JSON and YAML output free of Go type names
+		switch v := value.(type) {
+		case models.Protocol:
+			value = string(v)
+		case models.CustomFields:
+			value = map[string]interface{}(v)
+		}
+
+		r.keys = append(r.keys, field)
+		r.values[field] = value
+	}
+	return r, nil
+}
+
+// redactField hides a password or secret custom field value
+func redactField(cfg *models.Config, field string, value interface{}) interface{} {
+	switch {
+	case field == "password":
+		if password, _ := value.(string); password != "" && !strings.HasPrefix(password, "op://") {
+			return models.Redacted
+		}
+	case field == "custom_fields":
+		fields, _ := value.(models.CustomFields)
+		redacted := fields.DeepCopy()
+		for name := range redacted {
+			if cfg.IsSecretField(name) {
+				redacted[name] = models.Redacted
+			}
+		}
+		return redacted
+	case strings.HasPrefix(field, models.CustomFieldPrefix):
+		if value != nil && cfg.IsSecretField(strings.TrimPrefix(field, models.CustomFieldPrefix)) {
+			return models.Redacted
+		}
+	}
+	return value
+}
+
+// isEmptyValue reports whether a field has no value
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// formatCell renders a value for table and csv output
+func formatCell(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(parts, ",")
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, fmt.Sprint(key.Interface()))
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			parts[i] = key + "=" + fmt.Sprint(v.MapIndex(reflect.ValueOf(key)).Interface())
+		}
+		return strings.Join(parts, ";")
+	case reflect.Int:
+		if v.Int() == 0 {
+			return ""
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// writeRecords prints records as a table, CSV, JSON or YAML
+func writeRecords(out io.Writer, format string, fields []string, records []record) error {
+	switch format {
+	case outputJSON:
+		if records == nil {
+			records = []record{}
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case outputYAML:
+		encoder := yaml.NewEncoder(out)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(records); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case outputCSV:
+		writer := csv.NewWriter(out)
+		if err := writer.Write(fields); err != nil {
+			return err
+		}
+		for _, r := range records {
+			row := make([]string, len(fields))
+			for i, field := range fields {
+				row[i] = formatCell(r.values[field])
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case outputTable:
+		writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, strings.ToUpper(strings.Join(fields, "\t")))
+		for _, r := range records {
+			row := make([]string, len(fields))
+			for i, field := range fields {
+				row[i] = strings.ReplaceAll(formatCell(r.values[field]), "\n", " ")
+			}
+			fmt.Fprintln(writer, strings.Join(row, "\t"))
+		}
+		return writer.Flush()
+	}
+	return fmt.Errorf("unknown output format '%s'", format)
+}
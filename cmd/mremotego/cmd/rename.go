@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var renameCmd = &cobra.Command{
+	Use:           "rename <name> <new name>",
+	Short:         "Rename a connection or folder",
+	Args:          cobra.ExactArgs(2),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, newName := args[0], args[1]
+
+		manager, err := getConfigManager()
+		if err != nil {
+			return err
+		}
+
+		if err := manager.RenameConnection(name, newName); err != nil {
+			return fmt.Errorf("failed to rename '%s': %w", name, err)
+		}
+
+		if err := manager.Save(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+
+		fmt.Printf("✓ Renamed '%s' to '%s'\n", name, newName)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renameCmd)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	reorderBefore string
+	reorderAfter  string
+)
+
+var reorderCmd = &cobra.Command{
+	Use:   "reorder <name> [position]",
+	Short: "Change the position of a connection or folder among its siblings",
+	Long: `Move a connection or folder to a position (starting at 1) within its
+folder, or next to another entry with --before or --after. Entries are given
+by ID, path (Folder/name) or name. An entry placed next to one in a different
+folder moves to that folder.`,
+	Example: `  mremotego reorder web1 1
+  mremotego reorder web1 --after web2`,
+	Args:          cobra.RangeArgs(1, 2),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		given := 0
+		for _, set := range []bool{len(args) == 2, reorderBefore != "", reorderAfter != ""} {
+			if set {
+				given++
+			}
+		}
+		if given != 1 {
+			return fmt.Errorf("give exactly one of a position, --before or --after")
+		}
+
+		manager, err := getConfigManager()
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 2 {
+			position, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid position '%s'", args[1])
+			}
+			err = manager.ReorderConnection(name, position-1)
+			if err != nil {
+				return fmt.Errorf("failed to reorder '%s': %w", name, err)
+			}
+		} else {
+			node, err := manager.FindConnectionRef(name)
+			if err != nil {
+				return err
+			}
+			siblingName := reorderBefore
+			if reorderAfter != "" {
+				siblingName = reorderAfter
+			}
+			sibling, err := manager.FindConnectionRef(siblingName)
+			if err != nil {
+				return err
+			}
+			if err := manager.PlaceNode(node, sibling, reorderAfter != ""); err != nil {
+				return fmt.Errorf("failed to reorder '%s': %w", name, err)
+			}
+		}
+
+		if err := manager.Save(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+
+		fmt.Printf("✓ Reordered '%s'\n", name)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reorderCmd)
+
+	reorderCmd.Flags().StringVar(&reorderBefore, "before", "", "Place the entry before this one")
+	reorderCmd.Flags().StringVar(&reorderAfter, "after", "", "Place the entry after this one")
+}
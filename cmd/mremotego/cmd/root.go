@@ -9,7 +9,8 @@ import (
 )
 
 var (
-	cfgFile string
+	cfgFile     string
+	profileName string
 	rootCmd = &cobra.Command{
 		Use:   "mremotego",
 		Short: "A git-compatible remote connection manager",
@@ -30,6 +31,7 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.config/mremotego/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "environment profile to use (e.g. dev, staging, prod)")
 }
 
 func initConfig() {
@@ -54,5 +56,9 @@ func getConfigManager() (*config.Manager, error) {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
+	if err := manager.SetProfile(profileName); err != nil {
+		return nil, err
+	}
+
 	return manager, nil
 }
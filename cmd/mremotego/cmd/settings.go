@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jaydenthorup/mremotego/internal/settings"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var settingsCmd = &cobra.Command{
+	Use:   "settings",
+	Short: "Show the application settings file",
+	Long: `Show where the application settings file is and what it sets, and check it
+for mistakes. The settings describe this machine rather than the connections,
+so they live apart from the connection file:
+
+  # Open console sessions (SSH) in this terminal; {args} is replaced by the
+  # command and its arguments, {command} by the command as one shell string.
+  # Without it, Terminal.app (macOS) or the first terminal emulator found
+  # (Linux) is used.
+  terminal: kitty -- {args}            # or: alacritty -e {args}, foot {args},
+                                       # wezterm start -- {args},
+                                       # tmux new-window {command}
+
+  # When no terminal window can be opened: run in the current terminal
+  # ("current", the default) or fail ("error")
+  terminal_fallback: error
+
+  # Where console sessions open: "auto" (the default: a tmux window inside
+  # tmux, a screen window inside screen, a terminal window otherwise),
+  # "terminal", "current", "tmux", "tmux-pane" or "screen"
+  session_target: tmux-pane
+
+  # Programs to launch per protocol. Without args, the program gets the
+  # built-in arguments; args may use the variables of external tools
+  # (%HOST%, %PORT%, %USERNAME%, %PASSWORD%, ...).
+  clients:
+    rdp:
+      command: remmina
+      args: -c rdp://%USERNAME%@%HOST%:%PORT%
+    https:
+      command: firefox
+
+The CLI, the terminal UI and the GUI all read this file.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := settings.DefaultPath()
+		if err != nil {
+			return err
+		}
+
+		s, err := settings.Load(path)
+		if err != nil {
+			return err
+		}
+
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			fmt.Printf("Settings file: %s (not created yet; using defaults)\n", path)
+			return nil
+		}
+		fmt.Printf("Settings file: %s\n\n", path)
+
+		data, err := yaml.Marshal(s)
+		if err != nil {
+			return fmt.Errorf("failed to format settings: %w", err)
+		}
+		fmt.Print(string(data))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(settingsCmd)
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jaydenthorup/mremotego/internal/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	showOutput string
+	showFields string
+	showReveal bool
+	showVars   []string
+)
+
+var showCmd = &cobra.Command{
+	Use:   "show <connection>",
+	Short: "Show a connection as it will be used",
+	Long: `Print a connection with the active profile's overrides applied and its
+variables expanded, exactly as 'connect' would use it. The connection is
+given by ID, path (Folder/name) or name. Passwords and secret custom fields
+are hidden unless --reveal is given.`,
+	Example: `  mremotego show web1
+  mremotego show Production/Web/web1
+  mremotego --profile prod show web1 -o json
+  mremotego show web1 --fields host,port,username`,
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := getConfigManager()
+		if err != nil {
+			return err
+		}
+
+		fields, err := parseFields(showFields)
+		if err != nil {
+			return err
+		}
+		omitEmpty := len(fields) == 0
+		if omitEmpty {
+			fields = outputFieldNames()
+		}
+
+		overrides, err := config.ParseVariables(showVars)
+		if err != nil {
+			return err
+		}
+
+		conn, err := manager.FindConnectionRef(args[0])
+		if err != nil {
+			return err
+		}
+		resolved, err := manager.ResolveConnection(conn, overrides)
+		if err != nil {
+			return err
+		}
+
+		r, err := newRecord(manager, conn, resolved, fields, showReveal, omitEmpty)
+		if err != nil {
+			return err
+		}
+
+		switch showOutput {
+		case outputJSON:
+			data, err := json.MarshalIndent(r, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal connection: %w", err)
+			}
+			fmt.Println(string(data))
+		case outputYAML:
+			encoder := yaml.NewEncoder(os.Stdout)
+			encoder.SetIndent(2)
+			if err := encoder.Encode(r); err != nil {
+				return fmt.Errorf("failed to marshal connection: %w", err)
+			}
+			return encoder.Close()
+		case outputText:
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, field := range r.keys {
+				value := formatCell(r.values[field])
+				fmt.Fprintf(writer, "%s:\t%s\n", field, strings.ReplaceAll(value, "\n", "\n\t"))
+			}
+			return writer.Flush()
+		default:
+			return fmt.Errorf("unknown output format '%s' (expected text, json or yaml)", showOutput)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+
+	showCmd.Flags().StringVarP(&showOutput, "output", "o", outputText, "Output format: text, json, yaml")
+	showCmd.Flags().StringVar(&showFields, "fields", "", "Comma-separated fields to show")
+	showCmd.Flags().BoolVar(&showReveal, "reveal", false, "Show passwords and secret custom fields")
+	showCmd.Flags().StringArrayVar(&showVars, "var", []string{}, "Set a variable for this connection (key=value, repeatable)")
+}
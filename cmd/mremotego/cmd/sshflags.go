@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/internal/config"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+// sshOptions holds the SSH option flags shared by add and edit
+type sshOptions struct {
+	identityFile    string
+	certificateFile string
+	forwardAgent    bool
+	identitiesOnly  bool
+	preferredAuth   string
+	localForwards   []string
+	remoteForwards  []string
+	dynamicForwards []string
+	remoteCommand   string
+	requestTTY      string
+}
+
+// sshOptionFlagFields maps the SSH option flags to the connection fields they set
+var sshOptionFlagFields = map[string]string{
+	"identity-file":    "identity_file",
+	"certificate-file": "certificate_file",
+	"forward-agent":    "forward_agent",
+	"identities-only":  "identities_only",
+	"preferred-auth":   "preferred_auth",
+	"local-forward":    "local_forwards",
+	"remote-forward":   "remote_forwards",
+	"dynamic-forward":  "dynamic_forwards",
+	"remote-command":   "remote_command",
+	"request-tty":      "request_tty",
+}
+
+// register adds the SSH option flags to a command
+func (o *sshOptions) register(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringVar(&o.identityFile, "identity-file", "", "Private key file (SSH)")
+	flags.StringVar(&o.certificateFile, "certificate-file", "", "Certificate file for the key (SSH)")
+	flags.BoolVar(&o.forwardAgent, "forward-agent", false, "Forward the SSH agent (SSH, --forward-agent=false to turn off)")
+	flags.BoolVar(&o.identitiesOnly, "identities-only", false, "Only offer the identity file, not every agent key (SSH)")
+	flags.StringVar(&o.preferredAuth, "preferred-auth", "", "Authentication methods in order, e.g. publickey,password (SSH)")
+	flags.StringArrayVar(&o.localForwards, "local-forward", []string{}, "Forward a local port, e.g. 8080:localhost:80 (SSH, repeatable)")
+	flags.StringArrayVar(&o.remoteForwards, "remote-forward", []string{}, "Forward a remote port, e.g. 9000:localhost:9000 (SSH, repeatable)")
+	flags.StringArrayVar(&o.dynamicForwards, "dynamic-forward", []string{}, "Open a SOCKS proxy on a local port (SSH, repeatable)")
+	flags.StringVar(&o.remoteCommand, "remote-command", "", "Command to run instead of the login shell (SSH)")
+	flags.StringVar(&o.requestTTY, "request-tty", "", "Request a terminal: "+strings.Join(models.RequestTTYValues, ", ")+" (SSH)")
+}
+
+// apply copies the SSH option flags given on the command line to a connection
+func (o *sshOptions) apply(cmd *cobra.Command, conn *models.Connection) error {
+	flags := cmd.Flags()
+	if flags.Changed("request-tty") && o.requestTTY != "" && !containsString(models.RequestTTYValues, o.requestTTY) {
+		return fmt.Errorf("invalid --request-tty '%s' (use %s)", o.requestTTY, strings.Join(models.RequestTTYValues, ", "))
+	}
+
+	if flags.Changed("identity-file") {
+		conn.IdentityFile = o.identityFile
+	}
+	if flags.Changed("certificate-file") {
+		conn.CertificateFile = o.certificateFile
+	}
+	if flags.Changed("forward-agent") {
+		conn.ForwardAgent = o.forwardAgent
+	}
+	if flags.Changed("identities-only") {
+		conn.IdentitiesOnly = o.identitiesOnly
+	}
+	if flags.Changed("preferred-auth") {
+		conn.PreferredAuth = o.preferredAuth
+	}
+	if flags.Changed("local-forward") {
+		conn.LocalForwards = o.localForwards
+	}
+	if flags.Changed("remote-forward") {
+		conn.RemoteForwards = o.remoteForwards
+	}
+	if flags.Changed("dynamic-forward") {
+		conn.DynamicForwards = o.dynamicForwards
+	}
+	if flags.Changed("remote-command") {
+		conn.RemoteCommand = o.remoteCommand
+	}
+	if flags.Changed("request-tty") {
+		conn.RequestTTY = o.requestTTY
+	}
+	return nil
+}
+
+// jumpHostFlagHelp describes the --jump-host flag of add and edit
+const jumpHostFlagHelp = "SSH connection to go through, by ID, path (Folder/name) or name"
+
+// checkJumpHost reports a jump host reference for conn that isn't another SSH
+// connection of the config; references with variables are checked when they
+// are expanded
+func checkJumpHost(manager *config.Manager, conn *models.Connection, ref string) error {
+	if ref == "" || strings.Contains(ref, "{{") || strings.Contains(ref, "${") {
+		return nil
+	}
+	jump, err := manager.FindConnectionRef(ref)
+	if err != nil {
+		return fmt.Errorf("invalid jump host: %w", err)
+	}
+	if jump.IsFolder() || jump.Protocol != models.ProtocolSSH {
+		return fmt.Errorf("invalid jump host: '%s' is not an ssh connection", jump.Name)
+	}
+	if jump == conn {
+		return fmt.Errorf("invalid jump host: '%s' can't be its own jump host", conn.Name)
+	}
+	return nil
+}
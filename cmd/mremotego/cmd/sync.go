@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/internal/gitsync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncMessage string
+	syncNoPush  bool
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Commit, pull and push the connection file with git",
+	Long: `Synchronize the connection file with its git repository: commit any
+changes with a message describing the changed connections, rebase onto the
+upstream branch and push.
+
+The config file must live in a git working tree. Use the subcommands to run
+individual steps.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openSyncRepo()
+		if err != nil {
+			return err
+		}
+
+		if syncNoPush {
+			committed, message, err := repo.Commit(syncMessage)
+			if err != nil {
+				return err
+			}
+			printCommit(committed, message)
+			if err := repo.Pull(); err != nil {
+				if errors.Is(err, gitsync.ErrNoUpstream) {
+					fmt.Println("No upstream branch; skipped pull")
+					return nil
+				}
+				return err
+			}
+			fmt.Println("✓ Pulled")
+			return nil
+		}
+
+		result, err := repo.Sync(syncMessage)
+		printCommit(result.Committed, result.Message)
+		if result.Pulled {
+			fmt.Println("✓ Pulled")
+		}
+		if result.Pushed {
+			fmt.Println("✓ Pushed")
+		}
+		if err != nil {
+			return err
+		}
+		if !result.Pulled {
+			fmt.Println("No upstream branch; skipped pull and push")
+		}
+		return nil
+	},
+}
+
+var syncStatusCmd = &cobra.Command{
+	Use:           "status",
+	Short:         "Show the git status of the connection file",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openSyncRepo()
+		if err != nil {
+			return err
+		}
+
+		status, err := repo.Status()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Repository: %s\n", repo.Root())
+		fmt.Printf("File:       %s\n", repo.File())
+		if status.Upstream != "" {
+			fmt.Printf("Branch:     %s -> %s (ahead %d, behind %d)\n", status.Branch, status.Upstream, status.Ahead, status.Behind)
+		} else {
+			fmt.Printf("Branch:     %s (no upstream)\n", status.Branch)
+		}
+
+		if !status.Dirty {
+			fmt.Println("\nNo uncommitted changes")
+			return nil
+		}
+
+		fmt.Println("\nUncommitted changes:")
+		printChanges(status.Changes)
+		return nil
+	},
+}
+
+var syncCommitCmd = &cobra.Command{
+	Use:           "commit",
+	Short:         "Commit changes to the connection file",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openSyncRepo()
+		if err != nil {
+			return err
+		}
+
+		committed, message, err := repo.Commit(syncMessage)
+		if err != nil {
+			return err
+		}
+		printCommit(committed, message)
+		return nil
+	},
+}
+
+var syncPullCmd = &cobra.Command{
+	Use:           "pull",
+	Short:         "Rebase the connection repository onto its upstream branch",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openSyncRepo()
+		if err != nil {
+			return err
+		}
+
+		if err := repo.Pull(); err != nil {
+			return err
+		}
+		fmt.Println("✓ Pulled")
+		return nil
+	},
+}
+
+var syncPushCmd = &cobra.Command{
+	Use:           "push",
+	Short:         "Push committed changes to the upstream branch",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := openSyncRepo()
+		if err != nil {
+			return err
+		}
+
+		if err := repo.Push(); err != nil {
+			return err
+		}
+		fmt.Println("✓ Pushed")
+		return nil
+	},
+}
+
+// openSyncRepo opens the git repository containing the config file
+func openSyncRepo() (*gitsync.Repo, error) {
+	if cfgFile == "" {
+		initConfig()
+	}
+
+	repo, err := gitsync.Open(cfgFile)
+	if errors.Is(err, gitsync.ErrNotRepository) {
+		return nil, fmt.Errorf("%s is not in a git working tree (run 'git init' in its directory first)", cfgFile)
+	}
+	return repo, err
+}
+
+func printCommit(committed bool, message string) {
+	if !committed {
+		fmt.Println("Nothing to commit")
+		return
+	}
+	fmt.Printf("✓ Committed: %s\n", strings.SplitN(message, "\n", 2)[0])
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.AddCommand(syncStatusCmd, syncCommitCmd, syncPullCmd, syncPushCmd)
+
+	syncCmd.Flags().StringVarP(&syncMessage, "message", "m", "", "Commit message (default: generated from the changes)")
+	syncCmd.Flags().BoolVar(&syncNoPush, "no-push", false, "Commit and pull, but don't push")
+	syncCommitCmd.Flags().StringVarP(&syncMessage, "message", "m", "", "Commit message (default: generated from the changes)")
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jaydenthorup/mremotego/internal/config"
+	"github.com/jaydenthorup/mremotego/internal/launcher"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var toolVars []string
+
+var toolCmd = &cobra.Command{
+	Use:   "tool [tool name] [connection name]",
+	Short: "Run an external tool against a connection",
+	Long: `Run a command from the config's 'external_tools' section against a
+connection, like mRemoteNG's External Tools. Without arguments the configured
+tools are listed.
+
+The command and its arguments may reference the connection:
+  %NAME%  %HOST%  %PORT%  %PROTOCOL%  %USERNAME%  %PASSWORD%  %DOMAIN%
+  %DESCRIPTION%  %CUSTOM:field%
+
+%PORT% falls back to the protocol's default port and %PASSWORD% is resolved
+from 1Password when it holds a reference. Variables ({{name}}) are expanded
+first, as for connect.
+
+  external_tools:
+    - name: ping
+      command: ping
+      args: -c 4 %HOST%
+      capture_output: true
+    - name: winscp
+      command: C:\Program Files (x86)\WinSCP\WinSCP.exe
+      args: sftp://%USERNAME%:%PASSWORD%@%HOST%:%PORT%
+
+Tools start in the background by default; run_in_terminal opens a terminal
+window, wait waits for the tool to exit and capture_output prints its output.`,
+	Example: `  mremotego tool
+  mremotego tool ping web1`,
+	Args:          cobra.RangeArgs(0, 2),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			return fmt.Errorf("give a tool name and a connection name")
+		}
+
+		overrides, err := config.ParseVariables(toolVars)
+		if err != nil {
+			return err
+		}
+
+		manager, err := getConfigManager()
+		if err != nil {
+			return err
+		}
+
+		cfg := manager.GetConfig()
+		if len(args) == 0 {
+			listTools(cfg.ExternalTools)
+			return nil
+		}
+
+		tool, err := cfg.FindExternalTool(args[0])
+		if err != nil {
+			return err
+		}
+
+		conn, err := manager.FindConnection(args[1])
+		if err != nil {
+			return fmt.Errorf("connection not found: %w", err)
+		}
+
+		conn, err = manager.ResolveConnection(conn, overrides)
+		if err != nil {
+			return err
+		}
+
+		output, err := launcher.NewAttachedLauncher().RunTool(tool, conn)
+		fmt.Print(output)
+		if err != nil {
+			return err
+		}
+
+		if !tool.CaptureOutput && !tool.Wait {
+			fmt.Printf("✓ Started '%s' for '%s'\n", tool.Name, conn.Name)
+		}
+		return nil
+	},
+}
+
+// listTools prints the configured external tools
+func listTools(tools []*models.ExternalTool) {
+	if len(tools) == 0 {
+		fmt.Println("No external tools configured. Add them to the 'external_tools' section of the config.")
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tCOMMAND\tDESCRIPTION")
+	for _, tool := range tools {
+		command := tool.Command
+		if tool.Args != "" {
+			command += " " + tool.Args
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", tool.Name, command, tool.Description)
+	}
+	writer.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(toolCmd)
+
+	toolCmd.Flags().StringArrayVar(&toolVars, "var", []string{}, "Set a variable for this connection (key=value, repeatable)")
+}
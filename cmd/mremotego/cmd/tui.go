@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/jaydenthorup/mremotego/internal/tui"
+	"github.com/spf13/cobra"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Manage connections in a full-screen terminal UI",
+	Long: `Browse, search, launch, add, edit and delete connections in a full-screen
+terminal interface, for machines without a graphical desktop (e.g. over SSH).
+
+The folder tree is on the left and the selected entry's details on the right.
+Press / to search with the same syntax as 'list --filter', Enter to connect
+and ? for every key binding. Changes are saved immediately.
+
+Without a graphical display, SSH sessions open in this terminal and the UI
+returns when they end.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := getConfigManager()
+		if err != nil {
+			return err
+		}
+		return tui.Run(manager)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tuiCmd)
+}
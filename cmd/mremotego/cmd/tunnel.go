@@ -0,0 +1,420 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jaydenthorup/mremotego/internal/config"
+	"github.com/jaydenthorup/mremotego/internal/tunnel"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+// tunnelStartTimeout is how long 'tunnel up' waits for a tunnel to connect
+const tunnelStartTimeout = 15 * time.Second
+
+var (
+	tunnelForeground bool
+	tunnelDownAll    bool
+)
+
+var tunnelCmd = &cobra.Command{
+	Use:   "tunnel",
+	Short: "Start, stop and list SSH tunnels",
+	Long: `Run the named port forwards of SSH connections with the built-in SSH
+client. Tunnels are defined in a connection's 'tunnels' list:
+
+  - name: db1
+    protocol: ssh
+    host: db1.example.com
+    username: admin
+    tunnels:
+      - name: postgres
+        type: local          # local, remote or dynamic (SOCKS)
+        listen: 5432         # [address:]port, 127.0.0.1 by default
+        target: localhost:5432
+      - name: socks
+        type: dynamic
+        listen: 1080
+
+'tunnel up' starts each tunnel in a background process that reconnects with
+backoff when the connection drops, until 'tunnel down'. Connections log in
+with their identity file, the SSH agent or their password (from 1Password
+too), through their jump hosts; new host keys are added to
+~/.ssh/known_hosts and changed ones are refused.`,
+}
+
+var tunnelLsCmd = &cobra.Command{
+	Use:           "ls [connection]",
+	Short:         "List tunnels and their status",
+	Long:          `List the tunnels of every connection, or of one, with the status and traffic of those running.`,
+	Args:          cobra.MaximumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := getConfigManager()
+		if err != nil {
+			return err
+		}
+
+		connections := manager.ListConnections()
+		if len(args) == 1 {
+			conn, err := findTunnelConnection(manager, args[0])
+			if err != nil {
+				return err
+			}
+			connections = []*models.Connection{conn}
+		}
+
+		configPath, records, err := tunnelRecords(manager)
+		if err != nil {
+			return err
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "CONNECTION\tTUNNEL\tFORWARD\tSTATUS\tSENT\tRECEIVED\tPID")
+		found := false
+		for _, conn := range connections {
+			path := manager.NodePath(conn)
+			for _, spec := range conn.Tunnels {
+				found = true
+				r := tunnel.FindRecord(records, configPath, tunnel.Key(path, spec.Name))
+				if r == nil {
+					fmt.Fprintf(writer, "%s\t%s\t%s\tstopped\t-\t-\t-\n", path, spec.Name, spec)
+					continue
+				}
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n", path, spec.Name, r.Forward, tunnelStatus(r.Stats),
+					tunnel.FormatBytes(r.Sent), tunnel.FormatBytes(r.Received), r.PID)
+			}
+		}
+		if !found {
+			fmt.Println("No tunnels defined")
+			return nil
+		}
+		return writer.Flush()
+	},
+}
+
+var tunnelUpCmd = &cobra.Command{
+	Use:   "up <connection> [tunnel...]",
+	Short: "Start tunnels in the background",
+	Long: `Start the named tunnels of a connection, or all of them, each in a
+background process that keeps it up until 'tunnel down'. With --foreground
+the tunnels run in this process until Ctrl-C instead.`,
+	Example: `  mremotego tunnel up db1
+  mremotego tunnel up Production/db1 postgres
+  mremotego tunnel up db1 socks --foreground`,
+	Args:          cobra.MinimumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := getConfigManager()
+		if err != nil {
+			return err
+		}
+
+		conn, err := findTunnelConnection(manager, args[0])
+		if err != nil {
+			return err
+		}
+		resolved, err := manager.ResolveConnection(conn, nil)
+		if err != nil {
+			return err
+		}
+		specs, err := selectTunnels(resolved, args[1:])
+		if err != nil {
+			return err
+		}
+		for _, spec := range specs {
+			if err := spec.Validate(); err != nil {
+				return err
+			}
+		}
+
+		path := manager.NodePath(conn)
+		if tunnelForeground {
+			if err := manager.ResolvePasswords(resolved); err != nil {
+				return err
+			}
+			return runTunnelsForeground(path, resolved, specs)
+		}
+
+		configPath, records, err := tunnelRecords(manager)
+		if err != nil {
+			return err
+		}
+		dir, err := tunnel.StateDir()
+		if err != nil {
+			return err
+		}
+		program, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to find mremotego executable: %w", err)
+		}
+
+		for _, spec := range specs {
+			if r := tunnel.FindRecord(records, configPath, tunnel.Key(path, spec.Name)); r != nil {
+				fmt.Printf("Tunnel '%s' of '%s' is already running (pid %d)\n", spec.Name, path, r.PID)
+				continue
+			}
+
+			serveArgs := []string{"--config", configPath}
+			if profileName != "" {
+				serveArgs = append(serveArgs, "--profile", profileName)
+			}
+			serveArgs = append(serveArgs, "tunnel", "serve", path, spec.Name)
+			pid, err := tunnel.StartBackground(dir, program, serveArgs...)
+			if err != nil {
+				return err
+			}
+
+			r := tunnel.WaitForRecord(dir, pid, tunnelStartTimeout, func(r *tunnel.Record) bool {
+				return r.State == tunnel.StateUp || r.Error != ""
+			})
+			switch {
+			case r == nil:
+				return fmt.Errorf("tunnel '%s' of '%s' didn't start (see %s)", spec.Name, path, filepath.Join(dir, "tunnels.log"))
+			case r.State == tunnel.StateUp:
+				fmt.Printf("✓ Tunnel '%s' of '%s' is up: %s (pid %d)\n", spec.Name, path, r.Forward, pid)
+			default:
+				fmt.Printf("Tunnel '%s' of '%s' is %s (pid %d)\n", spec.Name, path, tunnelStatus(r.Stats), pid)
+			}
+		}
+		return nil
+	},
+}
+
+var tunnelDownCmd = &cobra.Command{
+	Use:   "down [connection] [tunnel...]",
+	Short: "Stop background tunnels",
+	Long:  `Stop the named tunnels of a connection, all of its tunnels, or with --all every tunnel of the connection file.`,
+	Example: `  mremotego tunnel down db1 postgres
+  mremotego tunnel down db1
+  mremotego tunnel down --all`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if tunnelDownAll == (len(args) > 0) {
+			return fmt.Errorf("give a connection or --all")
+		}
+
+		manager, err := getConfigManager()
+		if err != nil {
+			return err
+		}
+		configPath, records, err := tunnelRecords(manager)
+		if err != nil {
+			return err
+		}
+
+		var path string
+		if len(args) > 0 {
+			conn, err := findTunnelConnection(manager, args[0])
+			if err != nil {
+				return err
+			}
+			if _, err := selectTunnels(conn, args[1:]); err != nil {
+				return err
+			}
+			path = manager.NodePath(conn)
+		}
+
+		dir, err := tunnel.StateDir()
+		if err != nil {
+			return err
+		}
+		stopped := 0
+		for _, r := range records {
+			if r.Config != configPath || (path != "" && r.Connection != path) {
+				continue
+			}
+			if len(args) > 1 && !containsString(args[1:], r.Name) {
+				continue
+			}
+			if err := tunnel.StopBackground(dir, r); err != nil {
+				return err
+			}
+			fmt.Printf("✓ Stopped tunnel '%s' of '%s'\n", r.Name, r.Connection)
+			stopped++
+		}
+		if stopped == 0 {
+			fmt.Println("No matching tunnels are running")
+		}
+		return nil
+	},
+}
+
+// tunnelServeCmd runs one tunnel in the background process 'tunnel up' starts
+var tunnelServeCmd = &cobra.Command{
+	Use:           "serve <connection> <tunnel>",
+	Hidden:        true,
+	Args:          cobra.ExactArgs(2),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := getConfigManager()
+		if err != nil {
+			return err
+		}
+
+		conn, err := findTunnelConnection(manager, args[0])
+		if err != nil {
+			return err
+		}
+		resolved, err := manager.ResolveConnection(conn, nil)
+		if err != nil {
+			return err
+		}
+		if err := manager.ResolvePasswords(resolved); err != nil {
+			return err
+		}
+		spec, err := resolved.FindTunnel(args[1])
+		if err != nil {
+			return err
+		}
+		t, err := tunnel.New(resolved, spec)
+		if err != nil {
+			return err
+		}
+
+		dir, err := tunnel.StateDir()
+		if err != nil {
+			return err
+		}
+		configPath, err := filepath.Abs(manager.GetConfigPath())
+		if err != nil {
+			return err
+		}
+
+		stop := make(chan struct{})
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			close(stop)
+		}()
+
+		record := tunnel.Record{Config: configPath, Connection: manager.NodePath(conn), Name: spec.Name, Forward: spec.String()}
+		return tunnel.Serve(dir, record, t, stop)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tunnelCmd)
+	tunnelCmd.AddCommand(tunnelLsCmd, tunnelUpCmd, tunnelDownCmd, tunnelServeCmd)
+
+	tunnelUpCmd.Flags().BoolVar(&tunnelForeground, "foreground", false, "Run the tunnels in this process until Ctrl-C")
+	tunnelDownCmd.Flags().BoolVar(&tunnelDownAll, "all", false, "Stop every tunnel of the connection file")
+}
+
+// findTunnelConnection finds an SSH connection by ID, path or name
+func findTunnelConnection(manager *config.Manager, ref string) (*models.Connection, error) {
+	conn, err := manager.FindConnectionRef(ref)
+	if err != nil {
+		return nil, fmt.Errorf("connection not found: %w", err)
+	}
+	if conn.IsFolder() || conn.Protocol != models.ProtocolSSH {
+		return nil, fmt.Errorf("'%s' is not an ssh connection", ref)
+	}
+	return conn, nil
+}
+
+// selectTunnels returns the named tunnels of a connection, or all of them
+func selectTunnels(conn *models.Connection, names []string) ([]*models.Tunnel, error) {
+	if len(conn.Tunnels) == 0 {
+		return nil, fmt.Errorf("'%s' has no tunnels", conn.Name)
+	}
+	if len(names) == 0 {
+		return conn.Tunnels, nil
+	}
+
+	specs := make([]*models.Tunnel, 0, len(names))
+	for _, name := range names {
+		spec, err := conn.FindTunnel(name)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// tunnelRecords returns the absolute path of the connection file and the
+// background tunnels running on this machine
+func tunnelRecords(manager *config.Manager) (string, []*tunnel.Record, error) {
+	configPath, err := filepath.Abs(manager.GetConfigPath())
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := tunnel.StateDir()
+	if err != nil {
+		return "", nil, err
+	}
+	records, err := tunnel.Records(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	return configPath, records, nil
+}
+
+// tunnelStatus describes a tunnel's state, with the error it is retrying after
+func tunnelStatus(stats tunnel.Stats) string {
+	if stats.State != tunnel.StateUp && stats.Error != "" {
+		return fmt.Sprintf("%s (%s)", stats.State, stats.Error)
+	}
+	return string(stats.State)
+}
+
+// runTunnelsForeground runs tunnels in this process, printing their changes of
+// state, until Ctrl-C
+func runTunnelsForeground(path string, conn *models.Connection, specs []*models.Tunnel) error {
+	tunnels := make([]*tunnel.Tunnel, 0, len(specs))
+	for _, spec := range specs {
+		t, err := tunnel.New(conn, spec)
+		if err != nil {
+			return err
+		}
+		tunnels = append(tunnels, t)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	states := make([]tunnel.State, len(tunnels))
+	for _, t := range tunnels {
+		t.Start()
+		defer t.Stop()
+	}
+	fmt.Printf("Running %d tunnel(s) of '%s' (Ctrl-C to stop)\n", len(tunnels), path)
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-signals:
+			fmt.Println("Stopping tunnels")
+			return nil
+		case <-ticker.C:
+			for i, t := range tunnels {
+				stats := t.Stats()
+				if stats.State == states[i] {
+					continue
+				}
+				states[i] = stats.State
+				spec := t.Spec()
+				switch stats.State {
+				case tunnel.StateUp:
+					fmt.Printf("✓ Tunnel '%s' is up: %s\n", spec.Name, spec.String())
+				case tunnel.StateRetrying:
+					fmt.Printf("Tunnel '%s' is %s\n", spec.Name, tunnelStatus(stats))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/jaydenthorup/mremotego/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	validateStrict bool
+	validateJSON   bool
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [file...]",
+	Short: "Check connection files for mistakes",
+	Long: `Lint one or more connection files and report problems such as unknown
+protocols, missing hosts, duplicate names or IDs, invalid ports and malformed
+1Password references. Exits with a non-zero status when errors are found,
+which makes it suitable for CI pipelines.
+
+If no file is given, the current config file is checked.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files := args
+		if len(files) == 0 {
+			if cfgFile == "" {
+				initConfig()
+			}
+			files = []string{cfgFile}
+		}
+
+		results := make(map[string][]config.Diagnostic)
+		errorCount, warningCount := 0, 0
+
+		for _, file := range files {
+			diagnostics, err := config.ValidateFile(file)
+			if err != nil {
+				return err
+			}
+			results[file] = diagnostics
+
+			for _, d := range diagnostics {
+				if d.Severity == config.SeverityError {
+					errorCount++
+				} else {
+					warningCount++
+				}
+			}
+		}
+
+		if validateJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(results); err != nil {
+				return err
+			}
+		} else {
+			for _, file := range files {
+				for _, d := range results[file] {
+					fmt.Printf("%s:%s\n", file, d)
+				}
+			}
+		}
+
+		if errorCount > 0 || (validateStrict && warningCount > 0) {
+			return fmt.Errorf("validation failed: %d error(s), %d warning(s)", errorCount, warningCount)
+		}
+
+		if !validateJSON {
+			if warningCount > 0 {
+				fmt.Printf("✓ No errors (%d warning(s))\n", warningCount)
+			} else {
+				fmt.Println("✓ No problems found")
+			}
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+
+	validateCmd.Flags().BoolVar(&validateStrict, "strict", false, "Treat warnings as errors")
+	validateCmd.Flags().BoolVar(&validateJSON, "json", false, "Print diagnostics as JSON")
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateExitStatus(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	valid := write("valid.yaml", "version: \"1.0\"\nconnections:\n  - name: web1\n    type: connection\n    protocol: ssh\n    host: web1\n")
+	warning := write("warning.yaml", "version: \"1.0\"\nconnections:\n  - name: web1\n    protocol: ssh\n    host: web1\n")
+	invalid := write("invalid.yaml", "version: \"1.0\"\nconnections:\n  - name: web1\n    type: connection\n    protocol: ssh\n    port: 0\n")
+
+	tests := []struct {
+		files  []string
+		strict bool
+		fail   bool
+	}{
+		{[]string{valid}, false, false},
+		{[]string{warning}, false, false},
+		{[]string{warning}, true, true},
+		{[]string{invalid}, false, true},
+		{[]string{valid, invalid}, false, true},
+		{[]string{filepath.Join(dir, "missing.yaml")}, false, true},
+	}
+
+	defer func() { validateStrict = false }()
+	for _, tt := range tests {
+		validateStrict = tt.strict
+		err := validateCmd.RunE(validateCmd, tt.files)
+		if (err != nil) != tt.fail {
+			t.Errorf("validate %v (strict %v): error = %v, want failure %v", tt.files, tt.strict, err, tt.fail)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// ChangeKind describes how an entry differs between two configurations
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeMoved    ChangeKind = "moved"
+	ChangeModified ChangeKind = "modified"
+)
+
+// FieldChange is a single field that differs between two versions of an entry
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Change describes how one connection or folder differs between two configurations.
+// A moved entry may also carry field changes.
+type Change struct {
+	Kind     ChangeKind    `json:"kind"`
+	Section  string        `json:"section,omitempty"`  // Top-level section such as "templates"; empty for connections
+	Path     string        `json:"path"`               // Path in the new configuration (old path for removals); the name for section items
+	OldPath  string        `json:"old_path,omitempty"` // Previous path, for moves
+	IsFolder bool          `json:"is_folder"`
+	Fields   []FieldChange `json:"fields,omitempty"`
+}
+
+// Noun names what changed, e.g. "connection", "folder" or "template"
+func (c Change) Noun() string {
+	switch {
+	case c.Section != "":
+		return sectionNouns[c.Section]
+	case c.IsFolder:
+		return "folder"
+	}
+	return "connection"
+}
+
+// LoadFile reads a connection file without decrypting passwords, for
+// tools such as diff and merge that compare files as stored
+func LoadFile(path string) (*models.Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	config, err := ParseFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ParseFile parses connection file data without decrypting passwords. Other
+// YAML documents are refused with ErrNotConnectionFile.
+func ParseFile(data []byte) (*models.Config, error) {
+	config, _, err := parseConnectionFile(data)
+	return config, err
+}
+
+// Diff compares two configurations entry by entry. Entries are matched by id
+// when they have one, so moves and renames are reported as such rather than
+// as a removal plus an addition; entries without an id are matched by path,
+// or by name when that name is unique on both sides. Changes to the other
+// top-level sections follow, with their items matched by name.
+func Diff(oldConfig, newConfig *models.Config) []Change {
+	oldIndex := indexConfig(oldConfig)
+	newIndex := indexConfig(newConfig)
+	pairs := matchEntries(oldIndex, newIndex)
+	isSecret := func(field string) bool {
+		return oldConfig.IsSecretField(field) || newConfig.IsSecretField(field)
+	}
+
+	var changes []Change
+	for _, key := range newIndex.order {
+		newEntry := newIndex.entries[key]
+		oldEntry, exists := pairs.newToOld[key]
+		if !exists {
+			changes = append(changes, Change{
+				Kind:     ChangeAdded,
+				Path:     newEntry.path,
+				IsFolder: newEntry.conn.IsFolder(),
+			})
+			continue
+		}
+
+		change := Change{
+			Path:     newEntry.path,
+			IsFolder: newEntry.conn.IsFolder(),
+			Fields:   compareFields(oldEntry.conn, newEntry.conn, isSecret),
+		}
+		if !pairs.sameParent(oldEntry, newEntry) {
+			change.Kind = ChangeMoved
+			change.OldPath = oldEntry.path
+		} else if len(change.Fields) > 0 {
+			change.Kind = ChangeModified
+		} else {
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	for _, key := range oldIndex.order {
+		if _, matched := pairs.oldToNew[key]; !matched {
+			oldEntry := oldIndex.entries[key]
+			changes = append(changes, Change{
+				Kind:     ChangeRemoved,
+				Path:     oldEntry.path,
+				IsFolder: oldEntry.conn.IsFolder(),
+			})
+		}
+	}
+
+	return append(changes, diffSections(oldConfig, newConfig)...)
+}
+
+// entry is a connection or folder flattened out of the tree
+type entry struct {
+	key    string
+	conn   *models.Connection
+	parent string // Key of the parent folder, "" for the root
+	path   string
+}
+
+// configIndex is a flattened view of a configuration tree
+type configIndex struct {
+	entries map[string]*entry
+	order   []string // Keys in depth-first tree order
+}
+
+// indexConfig flattens a configuration, keying each entry by its id or path
+func indexConfig(cfg *models.Config) *configIndex {
+	idx := &configIndex{entries: make(map[string]*entry)}
+	idx.add(cfg.Connections, "", "")
+	return idx
+}
+
+func (idx *configIndex) add(connections []*models.Connection, parentKey, parentPath string) {
+	for _, conn := range connections {
+		path := conn.Name
+		if parentPath != "" {
+			path = parentPath + "/" + conn.Name
+		}
+
+		key := "path:" + path
+		if conn.ID != "" {
+			key = "id:" + conn.ID
+		}
+		// Disambiguate duplicate ids or names
+		for n := 2; idx.entries[key] != nil; n++ {
+			key = fmt.Sprintf("%s#%d", strings.SplitN(key, "#", 2)[0], n)
+		}
+
+		idx.entries[key] = &entry{key: key, conn: conn, parent: parentKey, path: path}
+		idx.order = append(idx.order, key)
+
+		if conn.IsFolder() {
+			idx.add(conn.Children, key, path)
+		}
+	}
+}
+
+// entryPairs maps entries of one index to the matching entries of another
+type entryPairs struct {
+	oldToNew map[string]*entry
+	newToOld map[string]*entry
+}
+
+// sameParent reports whether two paired entries live in matching folders
+func (p entryPairs) sameParent(oldEntry, newEntry *entry) bool {
+	if oldEntry.parent == "" || newEntry.parent == "" {
+		return oldEntry.parent == newEntry.parent
+	}
+	matched, exists := p.oldToNew[oldEntry.parent]
+	return exists && matched.key == newEntry.parent
+}
+
+// matchEntries pairs up entries with the same key, then pairs leftover
+// id-less entries whose name and type are unique among the leftovers
+func matchEntries(oldIndex, newIndex *configIndex) entryPairs {
+	pairs := entryPairs{oldToNew: make(map[string]*entry), newToOld: make(map[string]*entry)}
+
+	for _, key := range newIndex.order {
+		if oldEntry, exists := oldIndex.entries[key]; exists {
+			pairs.oldToNew[key] = newIndex.entries[key]
+			pairs.newToOld[key] = oldEntry
+		}
+	}
+
+	leftovers := func(idx *configIndex, matched map[string]*entry) map[string][]*entry {
+		byName := make(map[string][]*entry)
+		for _, key := range idx.order {
+			e := idx.entries[key]
+			if _, done := matched[key]; !done && e.conn.ID == "" {
+				name := string(e.conn.Type) + "/" + e.conn.Name
+				byName[name] = append(byName[name], e)
+			}
+		}
+		return byName
+	}
+
+	oldLeft := leftovers(oldIndex, pairs.oldToNew)
+	newLeft := leftovers(newIndex, pairs.newToOld)
+	for name, oldEntries := range oldLeft {
+		newEntries := newLeft[name]
+		if len(oldEntries) == 1 && len(newEntries) == 1 {
+			pairs.oldToNew[oldEntries[0].key] = newEntries[0]
+			pairs.newToOld[newEntries[0].key] = oldEntries[0]
+		}
+	}
+
+	return pairs
+}
+
+// entryField describes a YAML field of models.Connection
+type entryField struct {
+	name  string
+	index int
+}
+
+// diffFields lists the fields compared between two versions of an entry.
+// Children are compared structurally and the modification timestamp is noise.
+var diffFields = yamlFields(reflect.TypeOf(models.Connection{}), "children", "modified")
+
+// yamlFields lists the YAML fields of a struct type, except those named in skip
+func yamlFields(t reflect.Type, skip ...string) []entryField {
+	var fields []entryField
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" || containsName(skip, name) {
+			continue
+		}
+		fields = append(fields, entryField{name: name, index: i})
+	}
+	return fields
+}
+
+// containsName reports whether a list of names contains one
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// compareFields lists the fields that differ between two versions of an entry.
+// Custom fields are compared one by one; isSecret (which may be nil) reports
+// which of them must not be shown.
+func compareFields(oldConn, newConn *models.Connection, isSecret func(string) bool) []FieldChange {
+	var changes []FieldChange
+	oldValue := reflect.ValueOf(oldConn).Elem()
+	newValue := reflect.ValueOf(newConn).Elem()
+
+	for _, field := range diffFields {
+		a := oldValue.Field(field.index).Interface()
+		b := newValue.Field(field.index).Interface()
+		if fieldsEqual(a, b) {
+			continue
+		}
+
+		if field.name == "custom_fields" {
+			changes = append(changes, compareCustomFields(oldConn.CustomFields, newConn.CustomFields, isSecret)...)
+			continue
+		}
+
+		change := FieldChange{Field: field.name, Old: formatFieldValue(a), New: formatFieldValue(b)}
+		if field.name == "password" {
+			change.Old, change.New = redactPassword(a.(string)), redactPassword(b.(string))
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// compareCustomFields lists the custom fields that differ, as "custom_fields.<name>"
+func compareCustomFields(oldFields, newFields models.CustomFields, isSecret func(string) bool) []FieldChange {
+	names := oldFields.Keys()
+	for _, name := range newFields.Keys() {
+		if _, exists := oldFields[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []FieldChange
+	for _, name := range names {
+		a, b := oldFields[name], newFields[name]
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+		change := FieldChange{Field: "custom_fields." + name, Old: "(unset)", New: "(unset)"}
+		if a != nil {
+			change.Old = fmt.Sprint(a)
+		}
+		if b != nil {
+			change.New = fmt.Sprint(b)
+		}
+		if isSecret != nil && isSecret(name) {
+			change.Old, change.New = redactPassword(oldFields.String(name)), redactPassword(newFields.String(name))
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// fieldsEqual compares two field values, treating nil and empty slices alike
+func fieldsEqual(a, b interface{}) bool {
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	if av.Kind() == reflect.Slice && av.Len() == 0 && bv.Len() == 0 {
+		return true
+	}
+	if av.Kind() == reflect.Map && av.Len() == 0 && bv.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// formatFieldValue renders a field value for display
+func formatFieldValue(value interface{}) string {
+	v := reflect.ValueOf(value)
+	if v.IsZero() || (v.Kind() == reflect.Slice && v.Len() == 0) {
+		return "(unset)"
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = formatFieldValue(v.Index(i).Interface())
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case reflect.Map, reflect.Struct, reflect.Ptr:
+		// Render nested values as compact YAML
+		data, err := yaml.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return "{" + strings.ReplaceAll(strings.TrimSpace(string(data)), "\n", ", ") + "}"
+	}
+	return fmt.Sprint(value)
+}
+
+// redactPassword describes a password without revealing it
+func redactPassword(password string) string {
+	switch {
+	case password == "":
+		return "(unset)"
+	case strings.HasPrefix(password, "op://"):
+		return password
+	default:
+		return models.Redacted
+	}
+}
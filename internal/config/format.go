@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// DefaultPortMode controls how Format treats ports equal to the protocol default
+type DefaultPortMode string
+
+const (
+	// DefaultPortsFill writes the default port on every connection that has none
+	DefaultPortsFill DefaultPortMode = "fill"
+	// DefaultPortsOmit removes ports that match the protocol default
+	DefaultPortsOmit DefaultPortMode = "omit"
+)
+
+// FormatOptions controls the canonical form produced by Format
+type FormatOptions struct {
+	// Sort orders every folder's entries: folders first, then connections, by name
+	Sort bool
+	// DefaultPorts selects whether default ports are filled in or omitted
+	DefaultPorts DefaultPortMode
+	// AssignIDs gives every entry without an id a new one
+	AssignIDs bool
+}
+
+// Format rewrites connection file data into its canonical form: keys in a
+// stable order, consistent quoting and indentation, normalized protocol names
+// and consistently handled default ports. Comments are preserved and
+// encrypted passwords are left untouched.
+func Format(data []byte, opts FormatOptions) ([]byte, error) {
+	config, document, err := parseDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	switch opts.DefaultPorts {
+	case "", DefaultPortsFill, DefaultPortsOmit:
+	default:
+		return nil, fmt.Errorf("invalid default port mode '%s' (expected 'fill' or 'omit')", opts.DefaultPorts)
+	}
+
+	canonicalize(config, opts)
+
+	formatted, _, err := encodeConfig(config, document, encodeOptions{canonical: true})
+	if err != nil {
+		return nil, err
+	}
+	return formatted, nil
+}
+
+// canonicalize normalizes a configuration in place
+func canonicalize(config *models.Config, opts FormatOptions) {
+	if config.Version == "" {
+		config.Version = models.NewConfig().Version
+	}
+	canonicalizeList(config.Connections, opts)
+	if opts.Sort {
+		sortEntries(config.Connections)
+	}
+}
+
+func canonicalizeList(connections []*models.Connection, opts FormatOptions) {
+	for _, conn := range connections {
+		if opts.AssignIDs && conn.ID == "" {
+			conn.ID = models.NewID()
+		}
+
+		if conn.Type == "" {
+			if len(conn.Children) > 0 {
+				conn.Type = models.NodeTypeFolder
+			} else {
+				conn.Type = models.NodeTypeConnection
+			}
+		}
+
+		if conn.IsFolder() {
+			canonicalizeList(conn.Children, opts)
+			if opts.Sort {
+				sortEntries(conn.Children)
+			}
+			continue
+		}
+
+		if conn.Protocol != "" {
+			conn.Protocol = models.NormalizeProtocol(string(conn.Protocol))
+		}
+		conn.Host = strings.TrimSpace(conn.Host)
+
+		defaultPort := conn.Protocol.GetDefaultPort()
+		switch opts.DefaultPorts {
+		case DefaultPortsOmit:
+			if conn.Port == defaultPort {
+				conn.Port = 0
+			}
+		default:
+			if conn.Port == 0 {
+				conn.Port = defaultPort
+			}
+		}
+	}
+}
+
+// sortEntries orders folders before connections, each alphabetically by name
+func sortEntries(connections []*models.Connection) {
+	sort.SliceStable(connections, func(i, j int) bool {
+		a, b := connections[i], connections[j]
+		if a.IsFolder() != b.IsFolder() {
+			return a.IsFolder()
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+}
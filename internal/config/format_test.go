@@ -0,0 +1,63 @@
+package config
+
+import "testing"
+
+const formatCanonical = `version: "1.0"
+connections:
+  - name: web1
+    type: connection
+    protocol: ssh
+    host: web1.example.com
+    port: 22
+    tags:
+      - linux
+      - production
+    custom_fields:
+      owner: ops
+`
+
+func TestFormat(t *testing.T) {
+	for name, input := range map[string]string{
+		"canonical": formatCanonical,
+		"reordered and quoted": `connections:
+  - host: " web1.example.com"
+    "name": 'web1'
+    protocol: SSH
+    tags:
+      - linux
+      - production
+    custom_fields:
+      owner: "ops"
+version: "1.0"
+`,
+		"flow style": `version: "1.0"
+connections: [{name: web1, protocol: ssh2, host: web1.example.com, tags: [linux, production], custom_fields: {owner: ops}}]
+`,
+	} {
+		formatted, err := Format([]byte(input), FormatOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if string(formatted) != formatCanonical {
+			t.Errorf("%s: got\n%s\nwant\n%s", name, formatted, formatCanonical)
+		}
+	}
+}
+
+func TestFormatOmitDefaultPorts(t *testing.T) {
+	formatted, err := Format([]byte(formatCanonical), FormatOptions{DefaultPorts: DefaultPortsOmit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Format(formatted, FormatOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != formatCanonical {
+		t.Errorf("port not filled in again:\n%s", again)
+	}
+	config := mustParse(t, string(formatted))
+	if port := config.Connections[0].Port; port != 0 {
+		t.Errorf("port = %d, want it omitted", port)
+	}
+}
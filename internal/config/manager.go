@@ -18,6 +18,32 @@ type Manager struct {
 	config              *models.Config
 	onePasswordProvider *secrets.OnePasswordProvider
 	encryptionProvider  *crypto.EncryptionProvider
+
+	// document is the YAML tree read from disk; saves are patched into it so
+	// comments, key order and formatting of untouched entries are preserved
+	document *yaml.Node
+	indent   int
+
+	// profile is the active environment profile, "" for none
+	profile string
+
+	// sealed remembers the ciphertext each password (and secret custom field)
+	// was loaded with, so an unchanged value isn't re-encrypted (with a new
+	// salt) on every save
+	sealed map[sealedKey]sealedPassword
+}
+
+// sealedKey identifies an encrypted value: a connection's password when field
+// is empty, otherwise the named custom field
+type sealedKey struct {
+	conn  *models.Connection
+	field string
+}
+
+// sealedPassword pairs an encrypted password with its plaintext
+type sealedPassword struct {
+	ciphertext string
+	plaintext  string
 }
 
 // NewManager creates a new configuration manager
@@ -26,6 +52,7 @@ func NewManager(configPath string) *Manager {
 		configPath:          configPath,
 		onePasswordProvider: secrets.NewOnePasswordProvider(),
 		encryptionProvider:  nil, // Will be set when master password is provided
+		sealed:              make(map[sealedKey]sealedPassword),
 	}
 }
 
@@ -34,6 +61,30 @@ func (m *Manager) SetMasterPassword(password string) {
 	m.encryptionProvider = crypto.NewEncryptionProvider(password)
 }
 
+// SetProfile selects the environment profile used when resolving connections.
+// An empty name clears the selection.
+func (m *Manager) SetProfile(name string) error {
+	if name != "" {
+		known := false
+		for _, profile := range m.GetConfig().ProfileNames() {
+			if profile == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("profile not found: %s", name)
+		}
+	}
+	m.profile = name
+	return nil
+}
+
+// GetProfile returns the active environment profile, "" for none
+func (m *Manager) GetProfile() string {
+	return m.profile
+}
+
 // GetConfigPath returns the current config file path
 func (m *Manager) GetConfigPath() string {
 	return m.configPath
@@ -82,19 +133,22 @@ func (m *Manager) Load() error {
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var config models.Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	config, document, err := parseDocument(data)
+	if err != nil {
 		return fmt.Errorf("failed to parse config file: %w", err)
 	}
 
 	// Decrypt passwords if encryption is enabled
+	m.sealed = make(map[sealedKey]sealedPassword)
 	if m.encryptionProvider != nil && m.encryptionProvider.IsEnabled() {
-		if err := m.decryptPasswords(&config); err != nil {
+		if err := m.decryptPasswords(config); err != nil {
 			return fmt.Errorf("failed to decrypt passwords: %w", err)
 		}
 	}
 
-	m.config = &config
+	m.config = config
+	m.document = document
+	m.indent = detectIndent(data)
 
 	// Save this as the most recently used config file
 	m.saveRecentFile()
@@ -114,9 +168,22 @@ func (m *Manager) decryptPasswordsRecursive(connections []*models.Connection) er
 			if err != nil {
 				return fmt.Errorf("failed to decrypt password for '%s': %w", conn.Name, err)
 			}
+			m.sealed[sealedKey{conn: conn}] = sealedPassword{ciphertext: conn.Password, plaintext: decrypted}
 			conn.Password = decrypted
 		}
 
+		// Secret custom fields are encrypted the same way
+		for name, value := range conn.CustomFields {
+			if text, ok := value.(string); ok && m.encryptionProvider.IsEncrypted(text) {
+				decrypted, err := m.encryptionProvider.Decrypt(text)
+				if err != nil {
+					return fmt.Errorf("failed to decrypt field '%s' of '%s': %w", name, conn.Name, err)
+				}
+				m.sealed[sealedKey{conn: conn, field: name}] = sealedPassword{ciphertext: text, plaintext: decrypted}
+				conn.CustomFields[name] = decrypted
+			}
+		}
+
 		// Recursively decrypt children
 		if conn.IsFolder() && len(conn.Children) > 0 {
 			if err := m.decryptPasswordsRecursive(conn.Children); err != nil {
@@ -144,41 +211,57 @@ func (m *Manager) Save() error {
 
 	// Encrypt passwords if encryption is enabled
 	if m.encryptionProvider != nil && m.encryptionProvider.IsEnabled() {
-		if err := m.encryptPasswords(configCopy); err != nil {
+		if err := m.encryptPasswords(m.config, configCopy); err != nil {
 			return fmt.Errorf("failed to encrypt passwords: %w", err)
 		}
 	}
 
-	data, err := yaml.Marshal(configCopy)
+	data, document, err := encodeConfig(configCopy, m.document, encodeOptions{indent: m.indent})
 	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+		return err
 	}
 
 	if err := os.WriteFile(m.configPath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
+	m.document = document
 	return nil
 }
 
-// encryptPasswords recursively encrypts all passwords that should be encrypted
-func (m *Manager) encryptPasswords(config *models.Config) error {
-	return m.encryptPasswordsRecursive(config.Connections)
+// encryptPasswords recursively encrypts all passwords and secret custom fields
+// in the copy that should be encrypted
+func (m *Manager) encryptPasswords(original, config *models.Config) error {
+	return m.encryptPasswordsRecursive(original, original.Connections, config.Connections)
 }
 
-func (m *Manager) encryptPasswordsRecursive(connections []*models.Connection) error {
-	for _, conn := range connections {
+func (m *Manager) encryptPasswordsRecursive(cfg *models.Config, originals, connections []*models.Connection) error {
+	for i, conn := range connections {
+		original := originals[i]
+
 		if m.encryptionProvider.ShouldEncrypt(conn.Password) {
-			encrypted, err := m.encryptionProvider.Encrypt(conn.Password)
+			encrypted, err := m.seal(sealedKey{conn: original}, conn.Password)
 			if err != nil {
 				return fmt.Errorf("failed to encrypt password for '%s': %w", conn.Name, err)
 			}
 			conn.Password = encrypted
 		}
 
+		for name, value := range conn.CustomFields {
+			text, ok := value.(string)
+			if !ok || !cfg.IsSecretField(name) || !m.encryptionProvider.ShouldEncrypt(text) {
+				continue
+			}
+			encrypted, err := m.seal(sealedKey{conn: original, field: name}, text)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt field '%s' of '%s': %w", name, conn.Name, err)
+			}
+			conn.CustomFields[name] = encrypted
+		}
+
 		// Recursively encrypt children
 		if conn.IsFolder() && len(conn.Children) > 0 {
-			if err := m.encryptPasswordsRecursive(conn.Children); err != nil {
+			if err := m.encryptPasswordsRecursive(cfg, original.Children, conn.Children); err != nil {
 				return err
 			}
 		}
@@ -186,6 +269,19 @@ func (m *Manager) encryptPasswordsRecursive(connections []*models.Connection) er
 	return nil
 }
 
+// seal encrypts a value, reusing the existing ciphertext if it hasn't changed
+func (m *Manager) seal(key sealedKey, plaintext string) (string, error) {
+	if sealed, exists := m.sealed[key]; exists && sealed.plaintext == plaintext {
+		return sealed.ciphertext, nil
+	}
+	encrypted, err := m.encryptionProvider.Encrypt(plaintext)
+	if err != nil {
+		return "", err
+	}
+	m.sealed[key] = sealedPassword{ciphertext: encrypted, plaintext: plaintext}
+	return encrypted, nil
+}
+
 // GetConfig returns the current configuration
 func (m *Manager) GetConfig() *models.Config {
 	if m.config == nil {
@@ -243,6 +339,41 @@ func (m *Manager) findConnectionRecursive(name string, connections []*models.Con
 	return nil, fmt.Errorf("connection '%s' not found", name)
 }
 
+// FindConnectionRef finds a connection by ID, by path such as
+// "Production/Web/web1" or by name, in that order
+func (m *Manager) FindConnectionRef(ref string) (*models.Connection, error) {
+	if m.config == nil {
+		return nil, fmt.Errorf("config not loaded")
+	}
+
+	var byPath *models.Connection
+	var byID *models.Connection
+	var walk func(connections []*models.Connection, prefix string)
+	walk = func(connections []*models.Connection, prefix string) {
+		for _, conn := range connections {
+			path := prefix + conn.Name
+			if conn.ID != "" && conn.ID == ref && byID == nil {
+				byID = conn
+			}
+			if path == ref && byPath == nil {
+				byPath = conn
+			}
+			if conn.IsFolder() {
+				walk(conn.Children, path+"/")
+			}
+		}
+	}
+	walk(m.config.Connections, "")
+
+	switch {
+	case byID != nil:
+		return byID, nil
+	case byPath != nil:
+		return byPath, nil
+	}
+	return m.FindConnection(ref)
+}
+
 // DeleteConnection removes a connection by name
 func (m *Manager) DeleteConnection(name string) error {
 	if m.config == nil {
@@ -302,22 +433,7 @@ func (m *Manager) collectConnectionsRecursive(connections []*models.Connection,
 // findOrCreateFolder finds or creates a folder path
 func (m *Manager) findOrCreateFolder(path string) (*models.Connection, error) {
 	// Split by both forward slash and backslash
-	parts := make([]string, 0)
-	current := ""
-	for _, ch := range path {
-		if ch == '/' || ch == '\\' {
-			if current != "" {
-				parts = append(parts, current)
-				current = ""
-			}
-		} else {
-			current += string(ch)
-		}
-	}
-	if current != "" {
-		parts = append(parts, current)
-	}
-
+	parts := splitFolderPath(path)
 	if len(parts) == 0 {
 		return nil, fmt.Errorf("invalid folder path")
 	}
@@ -348,34 +464,72 @@ func (m *Manager) findOrCreateFolder(path string) (*models.Connection, error) {
 	return parent, nil
 }
 
-// UpdateConnection updates an existing connection
+// UpdateConnection updates an existing connection. Only the basic fields
+// that are set in updates are changed; use UpdateConnectionFields to clear
+// fields or change any other setting.
 func (m *Manager) UpdateConnection(name string, updates *models.Connection) error {
-	conn, err := m.FindConnection(name)
-	if err != nil {
-		return err
-	}
-
-	// Update fields if provided
+	var mask []string
 	if updates.Host != "" {
-		conn.Host = updates.Host
+		mask = append(mask, "host")
 	}
 	if updates.Port != 0 {
-		conn.Port = updates.Port
+		mask = append(mask, "port")
 	}
 	if updates.Username != "" {
-		conn.Username = updates.Username
+		mask = append(mask, "username")
 	}
 	if updates.Password != "" {
-		conn.Password = updates.Password
+		mask = append(mask, "password")
 	}
 	if updates.Domain != "" {
-		conn.Domain = updates.Domain
+		mask = append(mask, "domain")
 	}
 	if updates.Description != "" {
-		conn.Description = updates.Description
+		mask = append(mask, "description")
 	}
 	if updates.Protocol != "" {
-		conn.Protocol = updates.Protocol
+		mask = append(mask, "protocol")
+	}
+
+	return m.UpdateConnectionFields(name, updates, mask)
+}
+
+// UpdateConnectionFields copies the fields named in mask (by YAML key, see
+// models.EditableFields) from updates to an existing connection or folder.
+// Masked fields that are empty in updates are cleared. Single custom fields
+// can be masked as "custom_fields.<name>"; they are removed if updates
+// doesn't set them. Nothing is changed if the mask is invalid.
+func (m *Manager) UpdateConnectionFields(name string, updates *models.Connection, mask []string) error {
+	conn, err := m.FindConnection(name)
+	if err != nil {
+		return err
+	}
+	return m.UpdateNodeFields(conn, updates, mask)
+}
+
+// UpdateNodeFields is UpdateConnectionFields for a connection or folder that
+// was already looked up, e.g. one selected by a query
+func (m *Manager) UpdateNodeFields(conn *models.Connection, updates *models.Connection, mask []string) error {
+	if err := models.CheckFieldMask(mask); err != nil {
+		return err
+	}
+
+	for _, field := range mask {
+		if field != "name" || updates.Name == conn.Name {
+			continue
+		}
+		if updates.Name == "" {
+			return fmt.Errorf("name cannot be empty")
+		}
+		if _, err := m.FindConnection(updates.Name); err == nil {
+			return fmt.Errorf("connection '%s' already exists", updates.Name)
+		}
+	}
+
+	for _, field := range mask {
+		if err := conn.CopyField(updates, field); err != nil {
+			return err
+		}
 	}
 
 	conn.Modified = time.Now().Format(time.RFC3339)
@@ -387,6 +541,22 @@ func (m *Manager) IsOnePasswordReference(password string) bool {
 	return m.onePasswordProvider.IsReference(password)
 }
 
+// ResolvePasswords replaces 1Password references in the password of a
+// resolved connection and those of its jump hosts with the secrets they point to
+func (m *Manager) ResolvePasswords(conn *models.Connection) error {
+	for _, c := range append(append([]*models.Connection{}, conn.Jumps...), conn) {
+		if !m.onePasswordProvider.IsReference(c.Password) {
+			continue
+		}
+		password, err := m.onePasswordProvider.ResolveSecret(c.Password)
+		if err != nil {
+			return fmt.Errorf("failed to resolve password of '%s' from 1Password: %w", c.Name, err)
+		}
+		c.Password = password
+	}
+	return nil
+}
+
 // CreateOnePasswordItem creates a new 1Password item and returns the reference
 func (m *Manager) CreateOnePasswordItem(vault, title, username, password string) (string, error) {
 	return m.onePasswordProvider.CreateItem(vault, title, username, password)
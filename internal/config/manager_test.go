@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+const updateFieldsFile = `# Team connections
+version: "1.0"
+profiles:
+  - name: staging
+connections:
+  # Web tier
+  - name: web1
+    type: connection
+    protocol: ssh
+    host: web1.example.com # primary
+    port: 22
+    username: deploy
+    tags: [linux, prod]
+    custom_fields:
+      owner: ops
+      rack: r12
+    notes: Keep this one
+`
+
+func TestUpdateNodeFields(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	path := filepath.Join(dir, "connections.yaml")
+	if err := os.WriteFile(path, []byte(updateFieldsFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(path)
+	if err := m.Load(); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := m.FindConnection("web1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unmasked values in updates are ignored; new keys go in field order
+	updates := &models.Connection{
+		Name:         "ignored",
+		Host:         "web1.example.org",
+		Port:         2222,
+		Username:     "ignored",
+		Notes:        "",
+		CustomFields: models.CustomFields{"owner": "dba", "rack": "ignored"},
+		Profiles:     map[string]*models.ProfileOverride{"staging": {Host: "web1.staging"}},
+	}
+	mask := []string{"host", "port", "notes", "custom_fields.owner", "profiles"}
+	if err := m.UpdateNodeFields(conn, updates, mask); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# Team connections
+version: "1.0"
+profiles:
+  - name: staging
+connections:
+  # Web tier
+  - name: web1
+    type: connection
+    protocol: ssh
+    host: web1.example.org # primary
+    port: 2222
+    username: deploy
+    profiles:
+      staging:
+        host: web1.staging
+    tags: [linux, prod]
+    custom_fields:
+      owner: dba
+      rack: r12
+    modified: "` + conn.Modified + `"
+`
+	if string(data) != want {
+		t.Errorf("saved file:\n%s\nwant:\n%s", data, want)
+	}
+
+	// Invalid masks change nothing
+	before := conn.DeepCopy()
+	if err := m.UpdateNodeFields(conn, updates, []string{"host", "children"}); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+	if err := m.UpdateNodeFields(conn, &models.Connection{}, []string{"host", "name"}); err == nil || !strings.Contains(err.Error(), "name cannot be empty") {
+		t.Errorf("error = %v, want an empty name error", err)
+	}
+	if conn.Host != before.Host || conn.Modified != before.Modified {
+		t.Errorf("connection changed to %+v", conn)
+	}
+}
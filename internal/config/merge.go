@@ -0,0 +1,681 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Conflict describes a change that could not be merged automatically
+type Conflict struct {
+	Path   string `json:"path"`
+	Field  string `json:"field,omitempty"` // Empty when the conflict concerns the whole entry
+	Reason string `json:"reason"`
+
+	conn       *models.Connection // Entry in the merged tree the markers are placed around
+	section    string             // Or the top-level section, such as "templates", ...
+	item       string             // ... and the name of the item in it
+	keys       []string           // Keys leading to the marked value when Field is nested, e.g. profiles, prod
+	theirs     interface{}        // Their value of Field
+	oursAbsent bool               // Our side of the markers is empty
+
+	theirsAbsent bool // Their side of the markers is empty
+}
+
+// MergeResult holds the outcome of a three-way merge
+type MergeResult struct {
+	Data      []byte
+	Conflicts []Conflict
+}
+
+// Merge performs a structural three-way merge of connection files. Entries
+// are matched by id (or path) rather than by line, so moves and edits of
+// different fields merge cleanly. Only true conflicts - the same field changed
+// differently on both sides, or an entry edited on one side and deleted on the
+// other - are left behind as git-style conflict markers. The other top-level
+// sections, such as templates, are merged the same way with their items
+// matched by name. The formatting and comments of ours are preserved.
+func Merge(base, ours, theirs []byte) (*MergeResult, error) {
+	baseConfig, _, err := parseConnectionFile(base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base: %w", err)
+	}
+	oursConfig, oursDocument, err := parseConnectionFile(ours)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ours: %w", err)
+	}
+	theirsConfig, _, err := parseConnectionFile(theirs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse theirs: %w", err)
+	}
+
+	m := newMerger(baseConfig, oursConfig, theirsConfig)
+	m.merge()
+
+	data, _, err := encodeConfig(m.result, oursDocument, encodeOptions{indent: detectIndent(ours)})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = insertConflictMarkers(data, m.result, m.conflicts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MergeResult{Data: data, Conflicts: m.conflicts}, nil
+}
+
+// ErrNotConnectionFile is returned for YAML documents that aren't connection files
+var ErrNotConnectionFile = errors.New("not a connection file")
+
+// parseConnectionFile parses a connection file, refusing documents that are
+// not connection files: a mapping with a connections key and no keys a
+// connection file does not have. An empty file, such as the base of a file
+// added on both sides, is accepted.
+func parseConnectionFile(data []byte) (*models.Config, *yaml.Node, error) {
+	config, doc, err := parseDocument(data)
+	if err != nil || doc == nil {
+		return config, doc, err
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode || mappingValue(root, "connections") == nil {
+		return nil, nil, fmt.Errorf("%w: no top-level 'connections' key", ErrNotConnectionFile)
+	}
+	var known []string
+	for _, field := range yamlFields(reflect.TypeOf(models.Config{})) {
+		known = append(known, field.name)
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if key := root.Content[i].Value; !containsName(known, key) {
+			return nil, nil, fmt.Errorf("%w: unknown top-level key '%s'", ErrNotConnectionFile, key)
+		}
+	}
+	return config, doc, nil
+}
+
+// merger holds the state of a three-way merge. The result starts as a copy of
+// ours and their changes relative to base are applied on top of it.
+type merger struct {
+	baseConfig, theirsConfig *models.Config
+
+	base, ours, theirs *configIndex
+	baseOurs           entryPairs
+	baseTheirs         entryPairs
+
+	result    *models.Config
+	nodes     map[string]*models.Connection // Result entries by key
+	parents   map[string]string             // Result parent key by key, "" for the root
+	conflicts []Conflict
+
+	theirsToResult map[string]string // Their key -> result key
+}
+
+func newMerger(baseConfig, oursConfig, theirsConfig *models.Config) *merger {
+	m := &merger{
+		baseConfig:     baseConfig,
+		theirsConfig:   theirsConfig,
+		base:           indexConfig(baseConfig),
+		ours:           indexConfig(oursConfig),
+		theirs:         indexConfig(theirsConfig),
+		result:         oursConfig.DeepCopy(),
+		nodes:          make(map[string]*models.Connection),
+		parents:        make(map[string]string),
+		theirsToResult: make(map[string]string),
+	}
+	m.baseOurs = matchEntries(m.base, m.ours)
+	m.baseTheirs = matchEntries(m.base, m.theirs)
+
+	// The result is a copy of ours, so it indexes to the same keys
+	resultIndex := indexConfig(m.result)
+	for key, e := range resultIndex.entries {
+		m.nodes[key] = e.conn
+		m.parents[key] = e.parent
+	}
+
+	// Map their entries onto result entries
+	for _, key := range m.theirs.order {
+		if baseEntry, exists := m.baseTheirs.newToOld[key]; exists {
+			if oursEntry, exists := m.baseOurs.oldToNew[baseEntry.key]; exists {
+				m.theirsToResult[key] = oursEntry.key
+			}
+		} else if _, exists := m.ours.entries[key]; exists {
+			if _, paired := m.baseOurs.newToOld[key]; !paired {
+				// Added on both sides
+				m.theirsToResult[key] = key
+			}
+		}
+	}
+
+	return m
+}
+
+func (m *merger) merge() {
+	m.applyAdditions()
+	m.applyRestores()
+	m.applyMoves()
+	m.applyFieldChanges()
+	m.applyDeletions()
+	m.mergeSections()
+}
+
+// applyAdditions adds entries that only exist in theirs, parents first
+func (m *merger) applyAdditions() {
+	for _, key := range m.theirs.order {
+		if _, paired := m.baseTheirs.newToOld[key]; paired {
+			continue
+		}
+		if _, mapped := m.theirsToResult[key]; mapped {
+			// Added on both sides; merged like any other pair of versions
+			continue
+		}
+		m.insertFromTheirs(key)
+	}
+}
+
+// applyRestores brings back entries we deleted but they modified
+func (m *merger) applyRestores() {
+	for _, key := range m.base.order {
+		baseEntry := m.base.entries[key]
+		theirsEntry, inTheirs := m.baseTheirs.oldToNew[key]
+		if _, inOurs := m.baseOurs.oldToNew[key]; inOurs || !inTheirs {
+			continue
+		}
+		if m.unchanged(baseEntry, theirsEntry, m.baseTheirs) {
+			continue
+		}
+		resultKey := m.insertFromTheirs(theirsEntry.key)
+		m.conflicts = append(m.conflicts, Conflict{
+			Path:       theirsEntry.path,
+			Reason:     "deleted in ours, modified in theirs",
+			conn:       m.nodes[resultKey],
+			oursAbsent: true,
+		})
+	}
+}
+
+// applyMoves moves entries that only they moved
+func (m *merger) applyMoves() {
+	for _, key := range m.base.order {
+		baseEntry := m.base.entries[key]
+		oursEntry, inOurs := m.baseOurs.oldToNew[key]
+		theirsEntry, inTheirs := m.baseTheirs.oldToNew[key]
+		if !inOurs || !inTheirs {
+			continue
+		}
+
+		movedByUs := !m.baseOurs.sameParent(baseEntry, oursEntry)
+		movedByThem := !m.baseTheirs.sameParent(baseEntry, theirsEntry)
+		if !movedByThem {
+			continue
+		}
+
+		target := m.resultParentForTheirs(theirsEntry)
+		if movedByUs {
+			if target != m.parents[oursEntry.key] {
+				m.conflicts = append(m.conflicts, Conflict{
+					Path:   oursEntry.path,
+					Reason: fmt.Sprintf("moved differently in ours and theirs (theirs: %s)", theirsEntry.path),
+					conn:   m.nodes[oursEntry.key],
+				})
+			}
+			continue
+		}
+
+		m.detach(oursEntry.key)
+		m.attach(oursEntry.key, target, m.resultSiblingForTheirs(theirsEntry))
+	}
+}
+
+// applyFieldChanges merges the fields of entries present on both sides
+func (m *merger) applyFieldChanges() {
+	for _, key := range m.theirs.order {
+		resultKey, mapped := m.theirsToResult[key]
+		if !mapped {
+			continue
+		}
+		theirsEntry := m.theirs.entries[key]
+		oursEntry, exists := m.ours.entries[resultKey]
+		if !exists {
+			// Inserted from theirs, so already up to date
+			continue
+		}
+		result := m.nodes[resultKey]
+
+		var baseConn *models.Connection
+		if baseEntry, exists := m.baseTheirs.newToOld[key]; exists {
+			baseConn = baseEntry.conn
+		} else {
+			// Added on both sides: there is no common ancestor
+			baseConn = &models.Connection{}
+		}
+
+		m.mergeFields(baseConn, oursEntry, theirsEntry.conn, result)
+	}
+}
+
+// mergeFields performs a field-by-field three-way merge into result
+func (m *merger) mergeFields(base *models.Connection, oursEntry *entry, theirs, result *models.Connection) {
+	baseValue := reflect.ValueOf(base).Elem()
+	oursValue := reflect.ValueOf(oursEntry.conn).Elem()
+	theirsValue := reflect.ValueOf(theirs).Elem()
+	resultValue := reflect.ValueOf(result).Elem()
+
+	for _, field := range diffFields {
+		// Profile overrides and variables are merged key by key
+		switch field.name {
+		case "profiles":
+			result.Profiles = mergeMap(base.Profiles, result.Profiles, theirs.Profiles,
+				m.mapConflict(Conflict{Path: oursEntry.path, conn: result}, field.name))
+			continue
+		case "variables":
+			result.Variables = mergeMap(base.Variables, result.Variables, theirs.Variables,
+				m.mapConflict(Conflict{Path: oursEntry.path, conn: result}, field.name))
+			continue
+		}
+
+		b := baseValue.Field(field.index).Interface()
+		o := oursValue.Field(field.index).Interface()
+		t := theirsValue.Field(field.index).Interface()
+
+		switch {
+		case fieldsEqual(o, t), fieldsEqual(b, t):
+			// Nothing to take from theirs
+		case fieldsEqual(b, o):
+			resultValue.Field(field.index).Set(reflect.ValueOf(t))
+		default:
+			conflict := Conflict{
+				Path:   oursEntry.path,
+				Field:  field.name,
+				Reason: "changed differently in ours and theirs",
+				conn:   result,
+				theirs: t,
+			}
+			if reflect.ValueOf(o).IsZero() {
+				// Show their value in the file so the field has a line to mark
+				resultValue.Field(field.index).Set(reflect.ValueOf(t))
+				conflict.oursAbsent = true
+			}
+			m.conflicts = append(m.conflicts, conflict)
+		}
+	}
+
+	// Keep the most recent modification time
+	if theirs.Modified > result.Modified {
+		result.Modified = theirs.Modified
+	}
+}
+
+// applyDeletions removes entries they deleted, children before parents
+func (m *merger) applyDeletions() {
+	for i := len(m.base.order) - 1; i >= 0; i-- {
+		key := m.base.order[i]
+		baseEntry := m.base.entries[key]
+		oursEntry, inOurs := m.baseOurs.oldToNew[key]
+		if _, inTheirs := m.baseTheirs.oldToNew[key]; inTheirs || !inOurs {
+			continue
+		}
+
+		conn := m.nodes[oursEntry.key]
+		switch {
+		case !m.unchanged(baseEntry, oursEntry, m.baseOurs):
+			m.conflicts = append(m.conflicts, Conflict{
+				Path:   oursEntry.path,
+				Reason: "modified in ours, deleted in theirs",
+				conn:   conn,
+			})
+		case conn.IsFolder() && len(conn.Children) > 0:
+			m.conflicts = append(m.conflicts, Conflict{
+				Path:   oursEntry.path,
+				Reason: "deleted in theirs, but still has entries in ours",
+				conn:   conn,
+			})
+		default:
+			m.detach(oursEntry.key)
+			delete(m.nodes, oursEntry.key)
+		}
+	}
+}
+
+// unchanged reports whether an entry is identical to its base version
+func (m *merger) unchanged(baseEntry, other *entry, pairs entryPairs) bool {
+	return len(compareFields(baseEntry.conn, other.conn, nil)) == 0 && pairs.sameParent(baseEntry, other)
+}
+
+// insertFromTheirs copies one of their entries (without children) into the
+// result and returns its result key
+func (m *merger) insertFromTheirs(theirsKey string) string {
+	theirsEntry := m.theirs.entries[theirsKey]
+	conn := theirsEntry.conn.DeepCopy()
+	if conn.IsFolder() {
+		conn.Children = make([]*models.Connection, 0)
+	}
+
+	resultKey := theirsKey
+	for n := 2; m.nodes[resultKey] != nil; n++ {
+		resultKey = fmt.Sprintf("%s#merged%d", theirsKey, n)
+	}
+
+	m.nodes[resultKey] = conn
+	m.theirsToResult[theirsKey] = resultKey
+	m.attach(resultKey, m.resultParentForTheirs(theirsEntry), m.resultSiblingForTheirs(theirsEntry))
+	return resultKey
+}
+
+// resultParentForTheirs returns the result key of the folder one of their entries lives in
+func (m *merger) resultParentForTheirs(theirsEntry *entry) string {
+	if theirsEntry.parent == "" {
+		return ""
+	}
+	if parent, exists := m.theirsToResult[theirsEntry.parent]; exists && m.nodes[parent] != nil {
+		return parent
+	}
+	// Their folder no longer exists on our side; fall back to the root
+	return ""
+}
+
+// resultSiblingForTheirs returns the result key of the entry that precedes one
+// of their entries, or "" if it should go first
+func (m *merger) resultSiblingForTheirs(theirsEntry *entry) string {
+	var siblings []*models.Connection
+	if theirsEntry.parent == "" {
+		for _, key := range m.theirs.order {
+			if m.theirs.entries[key].parent == "" {
+				siblings = append(siblings, m.theirs.entries[key].conn)
+			}
+		}
+	} else {
+		siblings = m.theirs.entries[theirsEntry.parent].conn.Children
+	}
+
+	var previous *models.Connection
+	for _, sibling := range siblings {
+		if sibling == theirsEntry.conn {
+			break
+		}
+		previous = sibling
+	}
+	if previous == nil {
+		return ""
+	}
+
+	for key, e := range m.theirs.entries {
+		if e.conn == previous {
+			return m.theirsToResult[key]
+		}
+	}
+	return ""
+}
+
+// children returns the child list of a result folder ("" for the root)
+func (m *merger) children(parentKey string) *[]*models.Connection {
+	if parentKey == "" {
+		return &m.result.Connections
+	}
+	return &m.nodes[parentKey].Children
+}
+
+// detach removes an entry from its parent in the result
+func (m *merger) detach(key string) {
+	list := m.children(m.parents[key])
+	for i, conn := range *list {
+		if conn == m.nodes[key] {
+			*list = append((*list)[:i], (*list)[i+1:]...)
+			return
+		}
+	}
+}
+
+// attach inserts an entry into a result folder after the given sibling,
+// at the start if afterKey is "", or at the end if the sibling isn't there
+func (m *merger) attach(key, parentKey, afterKey string) {
+	list := m.children(parentKey)
+	m.parents[key] = parentKey
+
+	position := len(*list)
+	if afterKey == "" {
+		position = 0
+	} else if after := m.nodes[afterKey]; after != nil {
+		for i, conn := range *list {
+			if conn == after {
+				position = i + 1
+				break
+			}
+		}
+	}
+
+	*list = append(*list, nil)
+	copy((*list)[position+1:], (*list)[position:])
+	(*list)[position] = m.nodes[key]
+}
+
+// insertConflictMarkers surrounds conflicting fields and entries in the
+// encoded output with git-style conflict markers
+func insertConflictMarkers(data []byte, result *models.Config, conflicts []Conflict) ([]byte, error) {
+	if len(conflicts) == 0 {
+		return data, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to locate conflicts: %w", err)
+	}
+	nodes := locateEntries(&doc, result)
+	var root *yaml.Node
+	if len(doc.Content) > 0 {
+		root = doc.Content[0]
+	}
+	starts := nodeStartLines(&doc)
+	lines := strings.Split(string(data), "\n")
+
+	type region struct {
+		start, end   int // 1-based, inclusive
+		ours, theirs []string
+		note         string
+	}
+	var regions []region
+
+	for _, c := range conflicts {
+		node := nodes[c.conn]
+		if c.section != "" {
+			node = sectionItem(mappingValue(root, c.section), c.item)
+		}
+		if node == nil {
+			continue
+		}
+
+		if c.Field == "" {
+			start, end := node.Line, regionEnd(node, starts, lines)
+			entryLines := lines[start-1 : end]
+			r := region{start: start, end: end, ours: entryLines, note: c.Reason}
+			if c.oursAbsent {
+				r.ours, r.theirs = nil, entryLines
+			}
+			regions = append(regions, r)
+			continue
+		}
+
+		keys := c.keys
+		if keys == nil {
+			keys = []string{c.Field}
+		}
+		for _, key := range keys[:len(keys)-1] {
+			node = mappingValue(node, key)
+		}
+		if node == nil {
+			continue
+		}
+		field := keys[len(keys)-1]
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Value != field {
+				continue
+			}
+			start, end := key.Line, regionEnd(node.Content[i+1], starts, lines)
+			existing := lines[start-1 : end]
+			r := region{start: start, end: end, ours: existing, theirs: renderField(field, c.theirs, key.Column-1)}
+			switch {
+			case c.oursAbsent:
+				r.ours, r.theirs = nil, existing
+			case c.theirsAbsent:
+				r.theirs = nil
+			}
+			regions = append(regions, r)
+		}
+	}
+
+	// Apply from the bottom up so earlier line numbers stay valid, skipping
+	// regions nested inside one that is already marked
+	sort.Slice(regions, func(i, j int) bool { return regions[i].start > regions[j].start })
+	lastStart := len(lines) + 1
+	for _, r := range regions {
+		if r.end >= lastStart {
+			continue
+		}
+		lastStart = r.start
+
+		block := []string{"<<<<<<< ours"}
+		block = append(block, r.ours...)
+		block = append(block, "=======")
+		block = append(block, r.theirs...)
+		if r.note != "" {
+			block = append(block, ">>>>>>> theirs ("+r.note+")")
+		} else {
+			block = append(block, ">>>>>>> theirs")
+		}
+
+		replaced := append([]string{}, lines[:r.start-1]...)
+		replaced = append(replaced, block...)
+		lines = append(replaced, lines[r.end:]...)
+	}
+
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// locateEntries maps entries of the merged configuration to their mapping
+// nodes in the re-parsed output, relying on both trees having the same shape
+func locateEntries(doc *yaml.Node, result *models.Config) map[*models.Connection]*yaml.Node {
+	located := make(map[*models.Connection]*yaml.Node)
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return located
+	}
+
+	var walk func(list *yaml.Node, connections []*models.Connection)
+	walk = func(list *yaml.Node, connections []*models.Connection) {
+		if list == nil || list.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range list.Content {
+			if i >= len(connections) {
+				return
+			}
+			located[connections[i]] = item
+			walk(mappingValue(item, "children"), connections[i].Children)
+		}
+	}
+	walk(mappingValue(doc.Content[0], "connections"), result.Connections)
+	return located
+}
+
+// sectionItem returns the item with the given name in a top-level section
+// node, or the section node itself when name is empty
+func sectionItem(section *yaml.Node, name string) *yaml.Node {
+	if name == "" || section == nil || section.Kind != yaml.SequenceNode {
+		return section
+	}
+	for _, item := range section.Content {
+		if value := mappingValue(item, "name"); value != nil && value.Value == name {
+			return item
+		}
+	}
+	return nil
+}
+
+// mappingValue returns the value of a key in a mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// nodeStartLines returns the sorted set of lines on which some node starts
+func nodeStartLines(doc *yaml.Node) []int {
+	seen := make(map[int]bool)
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		seen[node.Line] = true
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	starts := make([]int, 0, len(seen))
+	for line := range seen {
+		starts = append(starts, line)
+	}
+	sort.Ints(starts)
+	return starts
+}
+
+// regionEnd returns the last line belonging to a node: the line before the
+// next node starts, not counting trailing blank or comment lines
+func regionEnd(node *yaml.Node, starts []int, lines []string) int {
+	last := node.Line
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Line > last {
+			last = n.Line
+		}
+		for _, child := range n.Content {
+			walk(child)
+		}
+	}
+	walk(node)
+
+	end := len(lines)
+	for _, start := range starts {
+		if start > last {
+			end = start - 1
+			break
+		}
+	}
+	for end > last {
+		trimmed := strings.TrimSpace(lines[end-1])
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+		end--
+	}
+	return end
+}
+
+// renderField renders "field: value" as YAML lines at the given indentation
+func renderField(field string, value interface{}, indent int) []string {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(defaultIndent)
+	if err := encoder.Encode(map[string]interface{}{field: value}); err != nil {
+		return []string{strings.Repeat(" ", indent) + field + ": " + fmt.Sprint(value)}
+	}
+	encoder.Close()
+
+	var rendered []string
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		rendered = append(rendered, strings.Repeat(" ", indent)+line)
+	}
+	return rendered
+}
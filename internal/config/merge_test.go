@@ -0,0 +1,407 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// mergeFiles merges the base, ours and theirs versions of a connection file
+func mergeFiles(t *testing.T, base, ours, theirs string) *MergeResult {
+	t.Helper()
+	result, err := Merge([]byte(base), []byte(ours), []byte(theirs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+const templatesBase = `version: "1.0"
+templates:
+  - name: linux
+    protocol: ssh
+    username: admin
+  - name: windows
+    protocol: rdp
+connections: []
+`
+
+func TestMergeTemplates(t *testing.T) {
+	ours := `version: "1.0"
+templates:
+  - name: linux
+    protocol: ssh
+    username: root
+  - name: windows
+    protocol: rdp
+connections: []
+`
+	theirs := `version: "1.0"
+templates:
+  - name: linux
+    protocol: ssh
+    username: admin
+    port: 2222
+  - name: web
+    protocol: https
+connections: []
+`
+
+	result := mergeFiles(t, templatesBase, ours, theirs)
+	if len(result.Conflicts) > 0 {
+		t.Fatalf("unexpected conflicts: %+v", result.Conflicts)
+	}
+	merged, err := ParseFile(result.Data)
+	if err != nil {
+		t.Fatalf("merged file doesn't parse: %v\n%s", err, result.Data)
+	}
+
+	var names []string
+	for _, template := range merged.Templates {
+		names = append(names, template.Name)
+	}
+	if strings.Join(names, ",") != "linux,web" {
+		t.Errorf("templates = %v, want linux,web (windows deleted in theirs, web added)", names)
+	}
+	linux := merged.Templates[0]
+	if linux.Username != "root" || linux.Port != 2222 {
+		t.Errorf("linux = %+v, want our username and their port", linux)
+	}
+}
+
+func TestMergeTemplateConflicts(t *testing.T) {
+	ours := strings.Replace(templatesBase, "username: admin", "username: root", 1)
+	ours = strings.Replace(ours, "protocol: rdp", "protocol: rdp\n    domain: CORP", 1)
+	theirs := `version: "1.0"
+templates:
+  - name: linux
+    protocol: ssh
+    username: ops
+connections: []
+`
+
+	result := mergeFiles(t, templatesBase, ours, theirs)
+	want := map[string]string{
+		"templates/linux:username": "changed differently in ours and theirs",
+		"templates/windows:":       "modified in ours, deleted in theirs",
+	}
+	if len(result.Conflicts) != len(want) {
+		t.Fatalf("conflicts = %+v, want %d", result.Conflicts, len(want))
+	}
+	for _, c := range result.Conflicts {
+		if want[c.Path+":"+c.Field] != c.Reason {
+			t.Errorf("unexpected conflict %+v", c)
+		}
+	}
+
+	data := string(result.Data)
+	for _, marker := range []string{"<<<<<<< ours\n    username: root\n=======\n    username: ops\n>>>>>>> theirs",
+		">>>>>>> theirs (modified in ours, deleted in theirs)"} {
+		if !strings.Contains(data, marker) {
+			t.Errorf("merged file lacks %q:\n%s", marker, data)
+		}
+	}
+}
+
+func TestDiffTemplates(t *testing.T) {
+	oldConfig, err := ParseFile([]byte(templatesBase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newConfig, err := ParseFile([]byte(`version: "1.0"
+templates:
+  - name: linux
+    protocol: ssh
+    username: root
+    password: secret
+  - name: web
+    protocol: https
+connections: []
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, change := range Diff(oldConfig, newConfig) {
+		line := string(change.Kind) + " " + change.Noun() + " " + change.Path
+		for _, field := range change.Fields {
+			line += " " + field.Field + "=" + field.Old + "->" + field.New
+		}
+		got = append(got, line)
+	}
+	want := []string{
+		"modified template linux username=admin->root password=(unset)->********",
+		"added template web",
+		"removed template windows",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+const profilesBase = `version: "1.0"
+profiles:
+  - name: dev
+    variables:
+      region: eu
+connections:
+  - name: web
+    type: connection
+    protocol: ssh
+    host: web.example.com
+    profiles:
+      prod:
+        host: web.prod.example.com
+`
+
+func TestMergeProfiles(t *testing.T) {
+	ours := `version: "1.0"
+profiles:
+  - name: dev
+    variables:
+      region: eu
+      tier: "1"
+  - name: staging
+connections:
+  - name: web
+    type: connection
+    protocol: ssh
+    host: web.example.com
+    profiles:
+      prod:
+        host: web2.prod.example.com
+`
+	theirs := `version: "1.0"
+profiles:
+  - name: dev
+    variables:
+      region: us
+  - name: qa
+connections:
+  - name: web
+    type: connection
+    protocol: ssh
+    host: web.example.com
+    profiles:
+      prod:
+        host: web.prod.example.com
+      dev:
+        host: web.dev.example.com
+`
+
+	result := mergeFiles(t, profilesBase, ours, theirs)
+	if len(result.Conflicts) > 0 {
+		t.Fatalf("unexpected conflicts: %+v\n%s", result.Conflicts, result.Data)
+	}
+	merged, err := ParseFile(result.Data)
+	if err != nil {
+		t.Fatalf("merged file doesn't parse: %v\n%s", err, result.Data)
+	}
+
+	var names []string
+	for _, profile := range merged.Profiles {
+		names = append(names, profile.Name)
+	}
+	if strings.Join(names, ",") != "dev,qa,staging" {
+		t.Errorf("profiles = %v, want dev,qa,staging (qa after dev, as in theirs)", names)
+	}
+	if vars := merged.Profiles[0].Variables; vars["region"] != "us" || vars["tier"] != "1" {
+		t.Errorf("dev variables = %v, want their region and our tier", vars)
+	}
+	overrides := merged.Connections[0].Profiles
+	if overrides["prod"].Host != "web2.prod.example.com" || overrides["dev"] == nil || overrides["dev"].Host != "web.dev.example.com" {
+		t.Errorf("overrides = prod %+v, dev %+v; want our prod and their dev", overrides["prod"], overrides["dev"])
+	}
+}
+
+func TestMergeProfileConflicts(t *testing.T) {
+	ours := strings.Replace(profilesBase, "region: eu", "region: ap", 1)
+	ours = strings.Replace(ours, "host: web.prod.example.com", "host: ours.prod.example.com", 1)
+	theirs := strings.Replace(profilesBase, "region: eu", "region: us", 1)
+	theirs = strings.Replace(theirs, "host: web.prod.example.com", "host: theirs.prod.example.com", 1)
+
+	result := mergeFiles(t, profilesBase, ours, theirs)
+	want := map[string]bool{"profiles/dev:variables.region": true, "web:profiles.prod": true}
+	if len(result.Conflicts) != len(want) {
+		t.Fatalf("conflicts = %+v, want %d", result.Conflicts, len(want))
+	}
+	for _, c := range result.Conflicts {
+		if !want[c.Path+":"+c.Field] {
+			t.Errorf("unexpected conflict %+v", c)
+		}
+	}
+
+	data := string(result.Data)
+	for _, marker := range []string{
+		"<<<<<<< ours\n      region: ap\n=======\n      region: us\n>>>>>>> theirs",
+		"<<<<<<< ours\n      prod:\n        host: ours.prod.example.com\n=======\n      prod:\n        host: theirs.prod.example.com\n>>>>>>> theirs",
+	} {
+		if !strings.Contains(data, marker) {
+			t.Errorf("merged file lacks %q:\n%s", marker, data)
+		}
+	}
+}
+
+func TestDiffProfiles(t *testing.T) {
+	oldConfig := mustParse(t, profilesBase)
+	newConfig := mustParse(t, strings.Replace(profilesBase, "region: eu", "region: us", 1))
+
+	changes := Diff(oldConfig, newConfig)
+	if len(changes) != 1 || changes[0].Noun() != "profile" || changes[0].Path != "dev" ||
+		len(changes[0].Fields) != 1 || changes[0].Fields[0].Field != "variables" {
+		t.Errorf("changes = %+v, want dev's variables modified", changes)
+	}
+}
+
+func TestMergeVariables(t *testing.T) {
+	base := `version: "1.0"
+variables:
+  domain: example.com
+  user: admin
+  region: eu
+connections: []
+`
+	ours := strings.Replace(base, "user: admin", "user: root", 1)
+	ours = strings.Replace(ours, "region: eu", "region: ap", 1)
+	theirs := strings.Replace(base, "domain: example.com", "domain: example.org\n  port: \"2222\"", 1)
+	theirs = strings.Replace(theirs, "region: eu", "region: us", 1)
+
+	result := mergeFiles(t, base, ours, theirs)
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Path != "variables" || result.Conflicts[0].Field != "region" {
+		t.Fatalf("conflicts = %+v, want one on region", result.Conflicts)
+	}
+	if !strings.Contains(string(result.Data), "<<<<<<< ours\n  region: ap\n=======\n  region: us\n>>>>>>> theirs") {
+		t.Errorf("merged file lacks markers around region:\n%s", result.Data)
+	}
+
+	// Without the conflicting change the rest merges key by key
+	theirs = strings.Replace(theirs, "region: us", "region: eu", 1)
+	result = mergeFiles(t, base, ours, theirs)
+	merged, err := ParseFile(result.Data)
+	if err != nil || len(result.Conflicts) > 0 {
+		t.Fatalf("merge failed: %v %+v", err, result.Conflicts)
+	}
+	want := map[string]string{"domain": "example.org", "user": "root", "region": "ap", "port": "2222"}
+	for name, value := range want {
+		if merged.Variables[name] != value {
+			t.Errorf("variables = %v, want %v", merged.Variables, want)
+			break
+		}
+	}
+
+	var got []string
+	for _, change := range Diff(mustParse(t, base), merged) {
+		got = append(got, string(change.Kind)+" "+change.Noun()+" "+change.Path)
+	}
+	if strings.Join(got, ", ") != "modified variable domain, added variable port, modified variable region, modified variable user" {
+		t.Errorf("changes = %v", got)
+	}
+}
+
+// mustParse parses a connection file
+func mustParse(t *testing.T, data string) *models.Config {
+	t.Helper()
+	config, err := ParseFile([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestMergeCustomFieldSchema(t *testing.T) {
+	base := `version: "1.0"
+custom_field_schema:
+  - name: owner
+  - name: ticket
+connections: []
+`
+	ours := strings.Replace(base, "  - name: owner\n", "  - name: owner\n    required: true\n", 1)
+	theirs := strings.Replace(base, "  - name: ticket\n", "  - name: cost\n    type: number\n", 1)
+	theirs = strings.Replace(theirs, "  - name: owner\n", "  - name: owner\n    label: Owner\n", 1)
+
+	result := mergeFiles(t, base, ours, theirs)
+	if len(result.Conflicts) > 0 {
+		t.Fatalf("unexpected conflicts: %+v", result.Conflicts)
+	}
+	merged := mustParse(t, string(result.Data))
+	if len(merged.CustomFieldSchema) != 2 {
+		t.Fatalf("schema = %+v, want owner and cost", merged.CustomFieldSchema)
+	}
+	owner, cost := merged.FindCustomField("owner"), merged.FindCustomField("cost")
+	if owner == nil || !owner.Required || owner.Label != "Owner" || cost == nil || cost.Type != models.FieldTypeNumber {
+		t.Errorf("schema = owner %+v, cost %+v", owner, cost)
+	}
+
+	var got []string
+	for _, change := range Diff(mustParse(t, base), merged) {
+		got = append(got, string(change.Kind)+" "+change.Noun()+" "+change.Path)
+	}
+	if strings.Join(got, ", ") != "modified custom field owner, added custom field cost, removed custom field ticket" {
+		t.Errorf("changes = %v", got)
+	}
+}
+
+func TestMergeExternalTools(t *testing.T) {
+	base := `version: "1.0"
+external_tools:
+  - name: ping
+    command: ping
+    args: -c 4 %HOST%
+connections: []
+`
+	ours := strings.Replace(base, "args: -c 4 %HOST%", "args: -c 10 %HOST%", 1)
+	theirs := strings.Replace(base, "args: -c 4 %HOST%", "args: -c 1 %HOST%\n    capture_output: true\n  - name: winscp\n    command: winscp", 1)
+
+	result := mergeFiles(t, base, ours, theirs)
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Path != "external_tools/ping" || result.Conflicts[0].Field != "args" {
+		t.Fatalf("conflicts = %+v, want one on ping's args", result.Conflicts)
+	}
+	data := string(result.Data)
+	for _, want := range []string{"<<<<<<< ours\n    args: -c 10 %HOST%\n=======\n    args: -c 1 %HOST%\n>>>>>>> theirs",
+		"capture_output: true", "- name: winscp"} {
+		if !strings.Contains(data, want) {
+			t.Errorf("merged file lacks %q:\n%s", want, data)
+		}
+	}
+
+	var got []string
+	for _, change := range Diff(mustParse(t, base), mustParse(t, theirs)) {
+		got = append(got, string(change.Kind)+" "+change.Noun()+" "+change.Path)
+	}
+	if strings.Join(got, ", ") != "modified external tool ping, added external tool winscp" {
+		t.Errorf("changes = %v", got)
+	}
+}
+
+func TestMergeRejectsOtherFiles(t *testing.T) {
+	conns := "version: \"1.0\"\nconnections: []\n"
+	for _, other := range []string{
+		"name: ci\non: push\n",
+		"version: \"1.0\"\nconnections: []\njobs: {}\n",
+		"- a\n- b\n",
+	} {
+		if _, err := Merge([]byte(conns), []byte(other), []byte(conns)); err == nil {
+			t.Errorf("merging %q: expected an error", other)
+		}
+		if _, err := Merge([]byte(conns), []byte(conns), []byte(other)); err == nil {
+			t.Errorf("merging %q as theirs: expected an error", other)
+		}
+	}
+
+	// A file added on both sides has an empty base
+	if _, err := Merge(nil, []byte(conns), []byte(conns)); err != nil {
+		t.Errorf("empty base: %v", err)
+	}
+}
+
+func TestParseFileRejectsOtherFiles(t *testing.T) {
+	if _, err := ParseFile([]byte("jobs:\n  build: {}\n")); !errors.Is(err, ErrNotConnectionFile) {
+		t.Errorf("error = %v, want ErrNotConnectionFile", err)
+	}
+	if _, err := ParseFile(nil); err != nil {
+		t.Errorf("empty file: %v", err)
+	}
+}
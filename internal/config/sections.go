@@ -0,0 +1,342 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// Top-level sections of a connection file besides connections, by YAML key
+const (
+	sectionTemplates = "templates"
+	sectionProfiles  = "profiles"
+	sectionVariables = "variables"
+	sectionSchema    = "custom_field_schema"
+	sectionTools     = "external_tools"
+)
+
+// sectionNouns names one item of each section, for diff output and commit messages
+var sectionNouns = map[string]string{
+	sectionTemplates: "template",
+	sectionProfiles:  "profile",
+	sectionVariables: "variable",
+	sectionSchema:    "custom field",
+	sectionTools:     "external tool",
+}
+
+// mergeSections merges the top-level sections other than connections
+func (m *merger) mergeSections() {
+	m.result.Templates = mergeNamed(m, sectionTemplates, m.baseConfig.Templates, m.result.Templates,
+		m.theirsConfig.Templates, func(t *models.Template) string { return t.Name })
+	m.result.Profiles = mergeNamed(m, sectionProfiles, m.baseConfig.Profiles, m.result.Profiles,
+		m.theirsConfig.Profiles, func(p *models.Profile) string { return p.Name })
+	m.result.Variables = mergeMap(m.baseConfig.Variables, m.result.Variables, m.theirsConfig.Variables,
+		m.mapConflict(Conflict{Path: sectionVariables, section: sectionVariables}, ""))
+	m.result.CustomFieldSchema = mergeNamed(m, sectionSchema, m.baseConfig.CustomFieldSchema, m.result.CustomFieldSchema,
+		m.theirsConfig.CustomFieldSchema, func(s *models.CustomFieldSchema) string { return s.Name })
+	m.result.ExternalTools = mergeNamed(m, sectionTools, m.baseConfig.ExternalTools, m.result.ExternalTools,
+		m.theirsConfig.ExternalTools, func(t *models.ExternalTool) string { return t.Name })
+}
+
+// diffSections compares the top-level sections other than connections
+func diffSections(oldConfig, newConfig *models.Config) []Change {
+	changes := diffNamed(sectionTemplates, oldConfig.Templates, newConfig.Templates,
+		func(t *models.Template) string { return t.Name })
+	changes = append(changes, diffNamed(sectionProfiles, oldConfig.Profiles, newConfig.Profiles,
+		func(p *models.Profile) string { return p.Name })...)
+	changes = append(changes, diffVariables(oldConfig.Variables, newConfig.Variables)...)
+	changes = append(changes, diffNamed(sectionSchema, oldConfig.CustomFieldSchema, newConfig.CustomFieldSchema,
+		func(s *models.CustomFieldSchema) string { return s.Name })...)
+	changes = append(changes, diffNamed(sectionTools, oldConfig.ExternalTools, newConfig.ExternalTools,
+		func(t *models.ExternalTool) string { return t.Name })...)
+	return changes
+}
+
+// mergeNamed three-way merges a section of named items, such as templates.
+// Items are matched by name and merged field by field; ours is the starting
+// point, and their additions go after the item that precedes them in theirs.
+func mergeNamed[T any](m *merger, section string, base, ours, theirs []*T, name func(*T) string) []*T {
+	find := func(items []*T, n string) int {
+		for i, item := range items {
+			if name(item) == n {
+				return i
+			}
+		}
+		return -1
+	}
+	result := ours
+
+	// insert places one of their items after the item that precedes it in theirs
+	insert := func(item *T, theirsIndex int) {
+		position := 0
+		for i := theirsIndex - 1; i >= 0; i-- {
+			if previous := find(result, name(theirs[i])); previous >= 0 {
+				position = previous + 1
+				break
+			}
+		}
+		result = append(result, nil)
+		copy(result[position+1:], result[position:])
+		result[position] = item
+	}
+
+	for i, t := range theirs {
+		n := name(t)
+		b, o := find(base, n), find(result, n)
+		switch {
+		case o < 0 && b < 0:
+			insert(t, i)
+		case o < 0:
+			if itemsEqual(base[b], t) {
+				continue
+			}
+			insert(t, i)
+			m.conflicts = append(m.conflicts, Conflict{
+				Path:       section + "/" + n,
+				Reason:     "deleted in ours, modified in theirs",
+				section:    section,
+				item:       n,
+				oursAbsent: true,
+			})
+		default:
+			// Items added on both sides have an empty common ancestor
+			baseItem := new(T)
+			if b >= 0 {
+				baseItem = base[b]
+			}
+			m.mergeItemFields(section, n, baseItem, result[o], t)
+		}
+	}
+
+	for _, b := range base {
+		n := name(b)
+		o := find(result, n)
+		if find(theirs, n) >= 0 || o < 0 {
+			continue
+		}
+		if !itemsEqual(b, result[o]) {
+			m.conflicts = append(m.conflicts, Conflict{
+				Path:    section + "/" + n,
+				Reason:  "modified in ours, deleted in theirs",
+				section: section,
+				item:    n,
+			})
+			continue
+		}
+		result = append(result[:o], result[o+1:]...)
+	}
+	return result
+}
+
+// mergeItemFields three-way merges the fields of one item of a section into
+// result, which is ours
+func (m *merger) mergeItemFields(section, name string, base, result, theirs interface{}) {
+	baseValue := reflect.ValueOf(base).Elem()
+	resultValue := reflect.ValueOf(result).Elem()
+	theirsValue := reflect.ValueOf(theirs).Elem()
+
+	for _, field := range yamlFields(resultValue.Type()) {
+		b := baseValue.Field(field.index).Interface()
+		o := resultValue.Field(field.index).Interface()
+		t := theirsValue.Field(field.index).Interface()
+
+		if variables, ok := o.(map[string]string); ok {
+			// Such as a profile's variables, merged key by key
+			merged := mergeMap(b.(map[string]string), variables, t.(map[string]string),
+				m.mapConflict(Conflict{Path: section + "/" + name, section: section, item: name}, field.name))
+			resultValue.Field(field.index).Set(reflect.ValueOf(merged))
+			continue
+		}
+
+		switch {
+		case fieldsEqual(o, t), fieldsEqual(b, t):
+			// Nothing to take from theirs
+		case fieldsEqual(b, o):
+			resultValue.Field(field.index).Set(reflect.ValueOf(t))
+		default:
+			conflict := Conflict{
+				Path:    section + "/" + name,
+				Field:   field.name,
+				Reason:  "changed differently in ours and theirs",
+				section: section,
+				item:    name,
+				theirs:  t,
+			}
+			if reflect.ValueOf(o).IsZero() {
+				// Show their value in the file so the field has a line to mark
+				resultValue.Field(field.index).Set(reflect.ValueOf(t))
+				conflict.oursAbsent = true
+			}
+			m.conflicts = append(m.conflicts, conflict)
+		}
+	}
+}
+
+// mergeMap three-way merges a map key by key, starting from ours, and calls
+// conflict for each key changed differently on both sides
+func mergeMap[V any](base, ours, theirs map[string]V, conflict func(key string, theirs interface{}, oursAbsent, theirsAbsent bool)) map[string]V {
+	keys := make([]string, 0, len(base)+len(theirs))
+	for key := range base {
+		keys = append(keys, key)
+	}
+	for key := range theirs {
+		if _, inBase := base[key]; !inBase {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := ours
+	for _, key := range keys {
+		b, inBase := base[key]
+		o, inOurs := ours[key]
+		t, inTheirs := theirs[key]
+		same := func(x V, inX bool, y V, inY bool) bool {
+			return inX == inY && reflect.DeepEqual(x, y)
+		}
+
+		switch {
+		case same(o, inOurs, t, inTheirs), same(b, inBase, t, inTheirs):
+			// Nothing to take from theirs
+		case same(b, inBase, o, inOurs):
+			if inTheirs {
+				if result == nil {
+					result = make(map[string]V)
+				}
+				result[key] = t
+			} else {
+				delete(result, key)
+			}
+		default:
+			if !inOurs {
+				// Show their value in the file so the key has a line to mark
+				if result == nil {
+					result = make(map[string]V)
+				}
+				result[key] = t
+			}
+			conflict(key, t, !inOurs, !inTheirs)
+		}
+	}
+	return result
+}
+
+// mapConflict returns a conflict callback for mergeMap that records a conflict
+// for a key of the given map field, filling in the location from where. An
+// empty field stands for the node where points to, such as the top-level
+// variables section.
+func (m *merger) mapConflict(where Conflict, field string) func(string, interface{}, bool, bool) {
+	return func(key string, theirs interface{}, oursAbsent, theirsAbsent bool) {
+		c := where
+		c.Field = field + "." + key
+		c.keys = []string{field, key}
+		if field == "" {
+			c.Field = key
+			c.keys = []string{key}
+		}
+		c.Reason = "changed differently in ours and theirs"
+		c.theirs = theirs
+		c.oursAbsent = oursAbsent
+		c.theirsAbsent = theirsAbsent
+		m.conflicts = append(m.conflicts, c)
+	}
+}
+
+// itemsEqual compares two items of a section field by field, treating nil and
+// empty slices and maps alike
+func itemsEqual(a, b interface{}) bool {
+	av, bv := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	for _, field := range yamlFields(av.Type()) {
+		if !fieldsEqual(av.Field(field.index).Interface(), bv.Field(field.index).Interface()) {
+			return false
+		}
+	}
+	return true
+}
+
+// diffNamed compares a section of named items, matching items by name
+func diffNamed[T any](section string, oldItems, newItems []*T, name func(*T) string) []Change {
+	oldByName := make(map[string]*T, len(oldItems))
+	for _, item := range oldItems {
+		oldByName[name(item)] = item
+	}
+	newByName := make(map[string]*T, len(newItems))
+	for _, item := range newItems {
+		newByName[name(item)] = item
+	}
+
+	var changes []Change
+	for _, item := range newItems {
+		n := name(item)
+		old, exists := oldByName[n]
+		if !exists {
+			changes = append(changes, Change{Kind: ChangeAdded, Section: section, Path: n})
+			continue
+		}
+		if fields := compareItemFields(old, item); len(fields) > 0 {
+			changes = append(changes, Change{Kind: ChangeModified, Section: section, Path: n, Fields: fields})
+		}
+	}
+	for _, item := range oldItems {
+		if _, exists := newByName[name(item)]; !exists {
+			changes = append(changes, Change{Kind: ChangeRemoved, Section: section, Path: name(item)})
+		}
+	}
+	return changes
+}
+
+// diffVariables compares the top-level variables, one change per variable
+func diffVariables(oldVariables, newVariables map[string]string) []Change {
+	names := make([]string, 0, len(oldVariables)+len(newVariables))
+	for name := range newVariables {
+		names = append(names, name)
+	}
+	for name := range oldVariables {
+		if _, exists := newVariables[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []Change
+	for _, name := range names {
+		oldValue, inOld := oldVariables[name]
+		newValue, inNew := newVariables[name]
+		change := Change{Section: sectionVariables, Path: name}
+		switch {
+		case !inOld:
+			change.Kind = ChangeAdded
+		case !inNew:
+			change.Kind = ChangeRemoved
+		case oldValue != newValue:
+			change.Kind = ChangeModified
+			change.Fields = []FieldChange{{Field: "value", Old: oldValue, New: newValue}}
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// compareItemFields lists the fields that differ between two versions of an
+// item of a section; passwords are redacted
+func compareItemFields(oldItem, newItem interface{}) []FieldChange {
+	oldValue, newValue := reflect.ValueOf(oldItem).Elem(), reflect.ValueOf(newItem).Elem()
+
+	var changes []FieldChange
+	for _, field := range yamlFields(oldValue.Type()) {
+		a := oldValue.Field(field.index).Interface()
+		b := newValue.Field(field.index).Interface()
+		if fieldsEqual(a, b) {
+			continue
+		}
+		change := FieldChange{Field: field.name, Old: formatFieldValue(a), New: formatFieldValue(b)}
+		if field.name == "password" {
+			change.Old, change.New = redactPassword(a.(string)), redactPassword(b.(string))
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
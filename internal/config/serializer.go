@@ -0,0 +1,470 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultIndent is the indentation used for files that don't have one yet
+const defaultIndent = 2
+
+// blankLineMarker is a placeholder comment standing in for a blank line.
+// yaml.v3 drops blank lines when parsing, so they are recorded as head
+// comments on load and turned back into blank lines after encoding.
+const blankLineMarker = "#mremotego:blank"
+
+// encodeOptions controls how encodeConfig renders a configuration
+type encodeOptions struct {
+	indent int
+
+	// canonical discards the original key order, quoting and blank lines so the
+	// output only depends on the configuration itself. Comments are kept.
+	canonical bool
+}
+
+// encodeConfig renders a configuration as YAML.
+//
+// When base is the document tree that was read from disk, the configuration is
+// patched into it rather than marshaled from scratch: scalars that didn't change
+// keep their original quoting, keys keep their original order, and comments on
+// untouched nodes survive. This keeps the diff of a single-field edit to a
+// single line. The (possibly updated) document tree is returned so it can be
+// reused for the next save.
+func encodeConfig(cfg *models.Config, base *yaml.Node, opts encodeOptions) ([]byte, *yaml.Node, error) {
+	var fresh yaml.Node
+	if err := fresh.Encode(cfg); err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	p := &patcher{canonical: opts.canonical}
+	var doc *yaml.Node
+	if base != nil && base.Kind == yaml.DocumentNode && len(base.Content) == 1 {
+		p.collectEntries(base.Content[0], &fresh)
+		doc = base
+		doc.Content[0] = p.patchNode(doc.Content[0], &fresh)
+	} else {
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&fresh}}
+	}
+
+	indent := opts.indent
+	if indent <= 0 || opts.canonical {
+		indent = defaultIndent
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(indent)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	return restoreBlankLines(buf.Bytes()), doc, nil
+}
+
+// parseDocument parses YAML data into a document tree and the config it describes
+func parseDocument(data []byte) (*models.Config, *yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	config := models.NewConfig()
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		if err := doc.Decode(config); err != nil {
+			return nil, nil, err
+		}
+		markBlankLines(&doc, data)
+		return config, &doc, nil
+	}
+
+	// Empty file
+	return config, nil, nil
+}
+
+// markBlankLines records blank lines that precede sequence items and mapping
+// keys as blankLineMarker head comments, so they survive a round trip
+func markBlankLines(doc *yaml.Node, data []byte) {
+	lines := strings.Split(string(data), "\n")
+	marked := make(map[int]bool)
+
+	mark := func(node *yaml.Node) {
+		start := node.Line
+		if node.HeadComment != "" {
+			start -= strings.Count(node.HeadComment, "\n") + 1
+		}
+		// lines is 0-based, node lines are 1-based; start-2 is the line above
+		if start < 2 || start-2 >= len(lines) || marked[start] || strings.TrimSpace(lines[start-2]) != "" {
+			return
+		}
+		marked[start] = true
+		if node.HeadComment == "" {
+			node.HeadComment = blankLineMarker
+		} else {
+			node.HeadComment = blankLineMarker + "\n" + node.HeadComment
+		}
+	}
+
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		switch node.Kind {
+		case yaml.SequenceNode:
+			for _, item := range node.Content {
+				mark(item)
+				walk(item)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				mark(node.Content[i])
+				walk(node.Content[i+1])
+			}
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+}
+
+// restoreBlankLines turns blankLineMarker comments back into blank lines
+func restoreBlankLines(data []byte) []byte {
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == blankLineMarker {
+			lines[i] = ""
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// stripBlankLineMarkers removes blankLineMarker lines from a comment
+func stripBlankLineMarkers(comment string) string {
+	if !strings.Contains(comment, blankLineMarker) {
+		return comment
+	}
+	var kept []string
+	for _, line := range strings.Split(comment, "\n") {
+		if line != blankLineMarker {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// detectIndent returns the indentation width used by a YAML file
+func detectIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed == line || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		return len(line) - len(trimmed)
+	}
+	return defaultIndent
+}
+
+// patcher merges a freshly encoded node tree into an existing one
+type patcher struct {
+	canonical bool
+
+	// entries holds the existing sequence items that have an id, so an entry
+	// moved to another folder keeps its node (and comments); freshIDs holds
+	// the ids still present after the save
+	entries  map[string]*yaml.Node
+	freshIDs map[string]bool
+	claimed  map[*yaml.Node]bool
+}
+
+// collectEntries records the items with an id in the existing and the fresh tree
+func (p *patcher) collectEntries(old, fresh *yaml.Node) {
+	p.entries = make(map[string]*yaml.Node)
+	p.freshIDs = make(map[string]bool)
+	p.claimed = make(map[*yaml.Node]bool)
+
+	var walk func(node *yaml.Node, visit func(identity string, item *yaml.Node))
+	walk = func(node *yaml.Node, visit func(identity string, item *yaml.Node)) {
+		for _, child := range node.Content {
+			if node.Kind == yaml.SequenceNode {
+				if identity := nodeIdentity(child); strings.HasPrefix(identity, "id:") {
+					visit(identity, child)
+				}
+			}
+			walk(child, visit)
+		}
+	}
+	walk(old, func(identity string, item *yaml.Node) {
+		if _, exists := p.entries[identity]; !exists {
+			p.entries[identity] = item
+		}
+	})
+	walk(fresh, func(identity string, item *yaml.Node) {
+		p.freshIDs[identity] = true
+	})
+}
+
+// movedEntry returns the existing node of an entry that moved here from
+// another sequence, or nil
+func (p *patcher) movedEntry(identity string) *yaml.Node {
+	node, exists := p.entries[identity]
+	if !exists || p.claimed[node] {
+		return nil
+	}
+	return node
+}
+
+// movedAway reports whether an existing item still exists elsewhere in the
+// document, so it must not be reused for a different entry
+func (p *patcher) movedAway(node *yaml.Node) bool {
+	return p.freshIDs[nodeIdentity(node)]
+}
+
+// patchNode merges the freshly encoded node into the existing one and returns
+// the node that should take its place in the document
+func (p *patcher) patchNode(old, fresh *yaml.Node) *yaml.Node {
+	if old == nil {
+		return fresh
+	}
+	if p.canonical {
+		old.HeadComment = stripBlankLineMarkers(old.HeadComment)
+	}
+	if old.Kind != fresh.Kind || old.Kind == yaml.AliasNode {
+		// The shape changed (e.g. an empty value became a list); keep the comments
+		fresh.HeadComment = old.HeadComment
+		fresh.LineComment = old.LineComment
+		fresh.FootComment = old.FootComment
+		return fresh
+	}
+
+	switch old.Kind {
+	case yaml.ScalarNode:
+		p.patchScalar(old, fresh)
+	case yaml.MappingNode:
+		p.patchMapping(old, fresh)
+	case yaml.SequenceNode:
+		p.patchSequence(old, fresh)
+	}
+	return old
+}
+
+// patchScalar updates a scalar in place, keeping its style when the value is unchanged
+func (p *patcher) patchScalar(old, fresh *yaml.Node) {
+	if p.canonical {
+		old.Value = fresh.Value
+		old.Tag = fresh.Tag
+		old.Style = fresh.Style
+		return
+	}
+	if old.Value == fresh.Value && old.ShortTag() == fresh.ShortTag() {
+		return
+	}
+	if old.ShortTag() == fresh.ShortTag() && old.ShortTag() != "!!str" && sameScalarValue(old, fresh) {
+		// Keep the original spelling of equal values, e.g. 0x1F or 1e3
+		return
+	}
+
+	old.Value = fresh.Value
+	old.Tag = fresh.Tag
+	if fresh.ShortTag() != "!!str" || strings.Contains(fresh.Value, "\n") {
+		// Quoting styles only make sense for plain single-line strings
+		old.Style = fresh.Style
+	}
+}
+
+// sameScalarValue reports whether two scalars decode to the same value
+func sameScalarValue(a, b *yaml.Node) bool {
+	var av, bv interface{}
+	if a.Decode(&av) != nil || b.Decode(&bv) != nil {
+		return false
+	}
+	return av == bv
+}
+
+// patchMapping updates a mapping in place: existing keys keep their position,
+// removed keys are dropped and new keys are inserted after their predecessor.
+// In canonical mode keys follow the order of the fresh node instead, and flow
+// mappings become block mappings.
+func (p *patcher) patchMapping(old, fresh *yaml.Node) {
+	freshValues := make(map[string]*yaml.Node)
+	freshOrder := make([]string, 0, len(fresh.Content)/2)
+	freshKeys := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(fresh.Content); i += 2 {
+		key := fresh.Content[i].Value
+		freshValues[key] = fresh.Content[i+1]
+		freshKeys[key] = fresh.Content[i]
+		freshOrder = append(freshOrder, key)
+	}
+
+	if p.canonical {
+		old.Style = fresh.Style
+		oldKeys := make(map[string]int)
+		for i := 0; i+1 < len(old.Content); i += 2 {
+			if _, exists := oldKeys[old.Content[i].Value]; !exists {
+				oldKeys[old.Content[i].Value] = i
+			}
+		}
+
+		content := make([]*yaml.Node, 0, len(fresh.Content))
+		for _, key := range freshOrder {
+			i, exists := oldKeys[key]
+			if !exists {
+				content = append(content, freshKeys[key], freshValues[key])
+				continue
+			}
+			old.Content[i].HeadComment = stripBlankLineMarkers(old.Content[i].HeadComment)
+			old.Content[i].Style = freshKeys[key].Style
+			content = append(content, old.Content[i], p.patchNode(old.Content[i+1], freshValues[key]))
+		}
+		old.Content = content
+		return
+	}
+
+	// Keep existing keys (in their original order) that are still present
+	content := make([]*yaml.Node, 0, len(fresh.Content))
+	present := make(map[string]bool)
+	for i := 0; i+1 < len(old.Content); i += 2 {
+		key := old.Content[i]
+		value, exists := freshValues[key.Value]
+		if !exists || present[key.Value] {
+			continue
+		}
+		content = append(content, key, p.patchNode(old.Content[i+1], value))
+		present[key.Value] = true
+	}
+
+	// Insert new keys right after the key that precedes them in the fresh order
+	for i, key := range freshOrder {
+		if present[key] {
+			continue
+		}
+
+		insertAt := 0
+		for j := i - 1; j >= 0; j-- {
+			if present[freshOrder[j]] {
+				insertAt = mappingIndex(content, freshOrder[j]) + 2
+				break
+			}
+		}
+
+		entry := []*yaml.Node{freshKeys[key], freshValues[key]}
+		content = append(content[:insertAt], append(entry, content[insertAt:]...)...)
+		present[key] = true
+	}
+
+	old.Content = content
+}
+
+// mappingIndex returns the position of a key within mapping content
+func mappingIndex(content []*yaml.Node, key string) int {
+	for i := 0; i+1 < len(content); i += 2 {
+		if content[i].Value == key {
+			return i
+		}
+	}
+	return len(content)
+}
+
+// patchSequence updates a sequence in place. Items are matched by identity
+// (see nodeIdentity) so reordering or inserting entries doesn't disturb the
+// formatting of their neighbours; unmatched items fall back to their position.
+func (p *patcher) patchSequence(old, fresh *yaml.Node) {
+	if p.canonical {
+		// Flow sequences such as [a, b] become block sequences
+		old.Style = fresh.Style
+	}
+	used := make([]bool, len(old.Content))
+	matched := make([]*yaml.Node, len(fresh.Content))
+
+	for i, item := range fresh.Content {
+		identity := nodeIdentity(item)
+		if identity == "" {
+			continue
+		}
+		for j, candidate := range old.Content {
+			if !used[j] && !p.claimed[candidate] && nodeIdentity(candidate) == identity {
+				matched[i] = candidate
+				used[j] = true
+				break
+			}
+		}
+		if matched[i] == nil {
+			// The entry may have moved here from another folder
+			matched[i] = p.movedEntry(identity)
+		}
+		if matched[i] != nil && p.claimed != nil {
+			p.claimed[matched[i]] = true
+		}
+	}
+
+	// Fall back to positional matching, e.g. for a renamed connection
+	for i := range fresh.Content {
+		if matched[i] == nil && i < len(old.Content) && !used[i] && !p.claimed[old.Content[i]] &&
+			!hasIdentityIn(old.Content[i], fresh.Content) && !p.movedAway(old.Content[i]) {
+			matched[i] = old.Content[i]
+			used[i] = true
+			if p.claimed != nil {
+				p.claimed[matched[i]] = true
+			}
+		}
+	}
+
+	content := make([]*yaml.Node, len(fresh.Content))
+	for i, item := range fresh.Content {
+		content[i] = p.patchNode(matched[i], item)
+	}
+	// An entry that moved to the top of the list shouldn't keep the blank
+	// line that separated it from its former predecessor
+	if len(content) > 0 && len(old.Content) > 0 && content[0] != old.Content[0] {
+		content[0].HeadComment = stripBlankLineMarkers(content[0].HeadComment)
+	}
+	old.Content = content
+}
+
+// hasIdentityIn reports whether another node in list shares the identity of node
+func hasIdentityIn(node *yaml.Node, list []*yaml.Node) bool {
+	identity := nodeIdentity(node)
+	if identity == "" {
+		return false
+	}
+	for _, item := range list {
+		if nodeIdentity(item) == identity {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeIdentity returns a key that identifies a sequence item across saves:
+// the value for scalars, and the id (or type and name) for connection mappings
+func nodeIdentity(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return "scalar:" + node.Value
+	case yaml.MappingNode:
+		var id, name, nodeType string
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			switch node.Content[i].Value {
+			case "id":
+				id = node.Content[i+1].Value
+			case "name":
+				name = node.Content[i+1].Value
+			case "type":
+				nodeType = node.Content[i+1].Value
+			}
+		}
+		if id != "" {
+			return "id:" + id
+		}
+		if name == "" {
+			return ""
+		}
+		return "node:" + nodeType + "/" + name
+	}
+	return ""
+}
@@ -0,0 +1,326 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// ParentOf returns the folder containing a connection or folder, or nil if it
+// is at the top level
+func (m *Manager) ParentOf(node *models.Connection) *models.Connection {
+	if path := m.ancestors(node); len(path) > 0 {
+		return path[0]
+	}
+	return nil
+}
+
+// FindFolder finds a folder by its path (e.g. "Production/Servers"). An empty
+// path or "/" is the top level, for which nil is returned.
+func (m *Manager) FindFolder(path string) (*models.Connection, error) {
+	var folder *models.Connection
+	connections := m.GetConfig().Connections
+	for _, part := range splitFolderPath(path) {
+		found := false
+		for _, conn := range connections {
+			if conn.Name == part && conn.IsFolder() {
+				folder = conn
+				connections = conn.Children
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("folder '%s' not found", path)
+		}
+	}
+	return folder, nil
+}
+
+// CreateFolder creates an empty folder at path (e.g. "Production/Servers").
+// With parents set, missing parent folders are created as well; otherwise
+// they must already exist.
+func (m *Manager) CreateFolder(path string, parents bool) (*models.Connection, error) {
+	parts := splitFolderPath(path)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("invalid folder path")
+	}
+	name, parentPath := parts[len(parts)-1], strings.Join(parts[:len(parts)-1], "/")
+
+	var parent *models.Connection
+	var err error
+	if parents && parentPath != "" {
+		parent, err = m.findOrCreateFolder(parentPath)
+	} else {
+		parent, err = m.FindFolder(parentPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := &m.GetConfig().Connections
+	if parent != nil {
+		list = &parent.Children
+	}
+	for _, conn := range *list {
+		if conn.Name == name {
+			return nil, fmt.Errorf("'%s' already exists", path)
+		}
+	}
+
+	folder := models.NewFolder(name)
+	folder.Created = time.Now().Format(time.RFC3339)
+	folder.Modified = folder.Created
+	*list = append(*list, folder)
+	return folder, nil
+}
+
+// DeleteFolder removes the folder at path. A folder that isn't empty is only
+// removed (with everything in it) if recursive is set.
+func (m *Manager) DeleteFolder(path string, recursive bool) error {
+	folder, err := m.FindFolder(path)
+	if err != nil {
+		return err
+	}
+	if folder == nil {
+		return fmt.Errorf("cannot remove the top level")
+	}
+	if len(folder.Children) > 0 && !recursive {
+		return fmt.Errorf("folder '%s' is not empty (%d item(s))", path, len(folder.Children))
+	}
+
+	list, index := m.siblings(folder)
+	removeNode(list, index)
+	return nil
+}
+
+// DeleteNode removes a connection or folder (with everything in it)
+func (m *Manager) DeleteNode(node *models.Connection) error {
+	list, index := m.siblings(node)
+	if list == nil {
+		return fmt.Errorf("connection '%s' not found", node.Name)
+	}
+	removeNode(list, index)
+	return nil
+}
+
+// NodePath returns the path of a connection or folder, e.g. "Production/Servers/web1"
+func (m *Manager) NodePath(node *models.Connection) string {
+	path := m.ancestors(node)
+	parts := make([]string, 0, len(path)+1)
+	for i := len(path) - 1; i >= 0; i-- {
+		parts = append(parts, path[i].Name)
+	}
+	return strings.Join(append(parts, node.Name), "/")
+}
+
+// MoveConnection moves a connection or folder, given by ID, path or name, to
+// the end of the folder at folderPath ("" or "/" for the top level)
+func (m *Manager) MoveConnection(ref, folderPath string) error {
+	node, err := m.FindConnectionRef(ref)
+	if err != nil {
+		return err
+	}
+	folder, err := m.FindFolder(folderPath)
+	if err != nil {
+		return err
+	}
+	return m.MoveNode(node, folder, -1)
+}
+
+// CopyConnection duplicates a connection or folder (with everything in it)
+// under a new name, directly after the original. The copy gets new IDs.
+func (m *Manager) CopyConnection(name, newName string) (*models.Connection, error) {
+	node, err := m.FindConnection(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkNewName(newName); err != nil {
+		return nil, err
+	}
+
+	dup := node.Duplicate()
+	dup.Name = newName
+	dup.Created = time.Now().Format(time.RFC3339)
+	dup.Modified = dup.Created
+
+	list, index := m.siblings(node)
+	insertNode(list, dup, index+1)
+	return dup, nil
+}
+
+// RenameConnection renames a connection or folder. Names must stay unique.
+func (m *Manager) RenameConnection(name, newName string) error {
+	return m.UpdateConnectionFields(name, &models.Connection{Name: newName}, []string{"name"})
+}
+
+// ReorderConnection moves a connection or folder, given by ID, path or name,
+// to position index (0-based) among its siblings
+func (m *Manager) ReorderConnection(ref string, index int) error {
+	node, err := m.FindConnectionRef(ref)
+	if err != nil {
+		return err
+	}
+	list, _ := m.siblings(node)
+	if index < 0 || index >= len(*list) {
+		return fmt.Errorf("position %d is out of range (1-%d)", index+1, len(*list))
+	}
+	return m.MoveNode(node, m.ParentOf(node), index)
+}
+
+// MoveNode moves a connection or folder into parent (nil for the top level)
+// at position index among its new siblings; -1 appends. A folder can't be
+// moved into itself or one of its subfolders, nor next to an entry of the
+// same name.
+func (m *Manager) MoveNode(node, parent *models.Connection, index int) error {
+	if parent != nil && !parent.IsFolder() {
+		return fmt.Errorf("'%s' is not a folder", parent.Name)
+	}
+	if parent == node || containsNode(node, parent) {
+		return fmt.Errorf("cannot move folder '%s' into itself", node.Name)
+	}
+	if err := m.checkSiblingName(node, parent); err != nil {
+		return err
+	}
+
+	list, position := m.siblings(node)
+	if list == nil {
+		return fmt.Errorf("connection '%s' not found", node.Name)
+	}
+	removeNode(list, position)
+
+	target := &m.GetConfig().Connections
+	if parent != nil {
+		target = &parent.Children
+	}
+	insertNode(target, node, index)
+	return nil
+}
+
+// PlaceNode moves a connection or folder next to sibling, before it or (if
+// after is set) after it, changing folders if necessary
+func (m *Manager) PlaceNode(node, sibling *models.Connection, after bool) error {
+	if node == sibling {
+		return nil
+	}
+	if containsNode(node, sibling) {
+		return fmt.Errorf("cannot move folder '%s' into itself", node.Name)
+	}
+	if err := m.checkSiblingName(node, m.ParentOf(sibling)); err != nil {
+		return err
+	}
+
+	list, position := m.siblings(node)
+	if list == nil {
+		return fmt.Errorf("connection '%s' not found", node.Name)
+	}
+	removeNode(list, position)
+
+	target, index := m.siblings(sibling)
+	if target == nil {
+		return fmt.Errorf("connection '%s' not found", sibling.Name)
+	}
+	if after {
+		index++
+	}
+	insertNode(target, node, index)
+	return nil
+}
+
+// siblings returns the list holding a connection or folder and its position
+// in that list, or nil if it isn't in the tree
+func (m *Manager) siblings(node *models.Connection) (*[]*models.Connection, int) {
+	var find func(list *[]*models.Connection) (*[]*models.Connection, int)
+	find = func(list *[]*models.Connection) (*[]*models.Connection, int) {
+		for i, conn := range *list {
+			if conn == node {
+				return list, i
+			}
+			if conn.IsFolder() {
+				if found, index := find(&conn.Children); found != nil {
+					return found, index
+				}
+			}
+		}
+		return nil, -1
+	}
+	return find(&m.GetConfig().Connections)
+}
+
+// checkNewName reports an error if a name is empty or already used
+func (m *Manager) checkNewName(name string) error {
+	if name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+	if _, err := m.FindConnection(name); err == nil {
+		return fmt.Errorf("connection '%s' already exists", name)
+	}
+	return nil
+}
+
+// checkSiblingName reports an error if parent (nil for the top level) holds
+// another entry with the same name as node
+func (m *Manager) checkSiblingName(node, parent *models.Connection) error {
+	list, path := m.GetConfig().Connections, node.Name
+	if parent != nil {
+		list, path = parent.Children, m.NodePath(parent)+"/"+node.Name
+	}
+	for _, conn := range list {
+		if conn != node && conn.Name == node.Name {
+			return fmt.Errorf("'%s' already exists", path)
+		}
+	}
+	return nil
+}
+
+// containsNode reports whether node is somewhere inside folder
+func containsNode(folder, node *models.Connection) bool {
+	if node == nil {
+		return false
+	}
+	for _, child := range folder.Children {
+		if child == node || containsNode(child, node) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeNode removes the entry at index from a list
+func removeNode(list *[]*models.Connection, index int) {
+	*list = append((*list)[:index], (*list)[index+1:]...)
+}
+
+// insertNode inserts a node into a list at index; -1 (or any index past the
+// end) appends
+func insertNode(list *[]*models.Connection, node *models.Connection, index int) {
+	if index < 0 || index > len(*list) {
+		index = len(*list)
+	}
+	*list = append(*list, nil)
+	copy((*list)[index+1:], (*list)[index:])
+	(*list)[index] = node
+}
+
+// splitFolderPath splits a folder path on forward and back slashes, ignoring
+// empty segments
+func splitFolderPath(path string) []string {
+	parts := make([]string, 0)
+	current := ""
+	for _, ch := range path {
+		if ch == '/' || ch == '\\' {
+			if current != "" {
+				parts = append(parts, current)
+				current = ""
+			}
+		} else {
+			current += string(ch)
+		}
+	}
+	if current != "" {
+		parts = append(parts, current)
+	}
+	return parts
+}
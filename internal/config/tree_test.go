@@ -0,0 +1,92 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// treeManager returns a manager holding Production/web1, Staging/web1 and
+// Staging/db1
+func treeManager() *Manager {
+	m := NewManager("")
+	m.GetConfig().Connections = []*models.Connection{
+		{Name: "Production", Type: models.NodeTypeFolder, Children: []*models.Connection{
+			{Name: "web1", Type: models.NodeTypeConnection, ID: "prod-web1"},
+		}},
+		{Name: "Staging", Type: models.NodeTypeFolder, Children: []*models.Connection{
+			{Name: "web1", Type: models.NodeTypeConnection, ID: "staging-web1"},
+			{Name: "db1", Type: models.NodeTypeConnection},
+		}},
+	}
+	return m
+}
+
+func TestMoveConnectionByRef(t *testing.T) {
+	m := treeManager()
+	if err := m.MoveConnection("Staging/web1", "/"); err != nil {
+		t.Fatal(err)
+	}
+	top := m.GetConfig().Connections
+	if len(top) != 3 || top[2].ID != "staging-web1" {
+		t.Errorf("Staging/web1 not moved to the top level: %v", m.NodePath(top[len(top)-1]))
+	}
+
+	if err := m.ReorderConnection("prod-web1", 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMoveRejectsDuplicateNames(t *testing.T) {
+	m := treeManager()
+	err := m.MoveConnection("Staging/web1", "Production")
+	if err == nil || !strings.Contains(err.Error(), "'Production/web1' already exists") {
+		t.Errorf("error = %v, want 'Production/web1' already exists", err)
+	}
+
+	staging, _ := m.FindConnectionRef("Staging/web1")
+	db1, _ := m.FindConnectionRef("Staging/db1")
+	prodWeb1, _ := m.FindConnectionRef("prod-web1")
+	if err := m.PlaceNode(staging, prodWeb1, true); err == nil {
+		t.Error("PlaceNode put two web1 entries in Production")
+	}
+	if len(m.GetConfig().Connections[1].Children) != 2 {
+		t.Error("a refused move changed the tree")
+	}
+
+	// Reordering within a folder isn't a duplicate of the entry itself
+	if err := m.PlaceNode(staging, db1, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.MoveNode(staging, m.ParentOf(staging), 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindConnectionRef(t *testing.T) {
+	m := treeManager()
+	tests := []struct {
+		ref    string
+		wantID string
+	}{
+		{"staging-web1", "staging-web1"},
+		{"Staging/web1", "staging-web1"},
+		{"Production/web1", "prod-web1"},
+		{"web1", "prod-web1"}, // By name, the first in tree order
+	}
+	for _, tt := range tests {
+		conn, err := m.FindConnectionRef(tt.ref)
+		if err != nil {
+			t.Errorf("%s: %v", tt.ref, err)
+			continue
+		}
+		if conn.ID != tt.wantID {
+			t.Errorf("%s found %s, want %s", tt.ref, conn.ID, tt.wantID)
+		}
+	}
+
+	if _, err := m.FindConnectionRef("Production/db1"); err == nil {
+		t.Error("found an entry at a path that doesn't exist")
+	}
+}
@@ -0,0 +1,953 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/internal/secrets"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Severity indicates how serious a validation problem is
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic describes a single problem found in a connection file
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Path     string   `json:"path,omitempty"`  // Folder path of the offending node, e.g. "Production/Web Server"
+	Field    string   `json:"field,omitempty"` // YAML key the problem refers to, if any
+	Message  string   `json:"message"`
+}
+
+// String formats the diagnostic as "line:column: severity: path: message"
+func (d Diagnostic) String() string {
+	location := fmt.Sprintf("%d:%d", d.Line, d.Column)
+	if d.Path != "" {
+		return fmt.Sprintf("%s: %s: %s: %s", location, d.Severity, d.Path, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, d.Severity, d.Message)
+}
+
+// HasErrors returns true if any diagnostic has error severity
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the configuration file on disk and returns any problems found
+func (m *Manager) Validate() ([]Diagnostic, error) {
+	return ValidateFile(m.configPath)
+}
+
+// ValidateFile reads and validates a connection file
+func ValidateFile(path string) ([]Diagnostic, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return Validate(data), nil
+}
+
+// Validate parses YAML connection data and returns diagnostics sorted by position
+func Validate(data []byte) []Diagnostic {
+	v := &validator{
+		names:       make(map[string][]nameRef),
+		ids:         make(map[string]*yaml.Node),
+		connections: make(map[string]*connectionRef),
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		line := 0
+		if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+			line, _ = strconv.Atoi(match[1])
+		}
+		v.diagnostics = append(v.diagnostics, Diagnostic{
+			Severity: SeverityError,
+			Line:     line,
+			Column:   1,
+			Message:  strings.TrimPrefix(err.Error(), "yaml: "),
+		})
+		return v.diagnostics
+	}
+
+	v.validateDocument(&doc)
+
+	sort.SliceStable(v.diagnostics, func(i, j int) bool {
+		if v.diagnostics[i].Line != v.diagnostics[j].Line {
+			return v.diagnostics[i].Line < v.diagnostics[j].Line
+		}
+		return v.diagnostics[i].Column < v.diagnostics[j].Column
+	})
+	return v.diagnostics
+}
+
+// yamlErrorLine extracts the line number from yaml.v3 syntax errors
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// resolutionPattern matches RDP resolutions such as 1920x1080
+var resolutionPattern = regexp.MustCompile(`^\d+x\d+$`)
+
+// leadingZeroPattern matches integers that YAML reads as octal
+var leadingZeroPattern = regexp.MustCompile(`^[-+]?0[0-7]+$`)
+
+// validColorDepths lists the color depths supported by RDP clients
+var validColorDepths = map[int]bool{8: true, 15: true, 16: true, 24: true, 32: true}
+
+// forwardPattern matches ssh -L and -R specs, [bind_address:]port:host:hostport
+var forwardPattern = regexp.MustCompile(`^(\S+:)?\d+:\S+:\d+$`)
+
+// portSpecPattern matches ssh -D specs, [bind_address:]port, which -R also accepts
+var portSpecPattern = regexp.MustCompile(`^(\S+:)?\d+$`)
+
+// sshOptionFields lists the connection fields that only apply to SSH
+var sshOptionFields = []string{
+	"identity_file", "certificate_file", "forward_agent", "identities_only", "preferred_auth",
+	"local_forwards", "remote_forwards", "dynamic_forwards", "remote_command", "request_tty", "tunnels",
+}
+
+// validator accumulates diagnostics while walking a YAML document
+type validator struct {
+	diagnostics []Diagnostic
+	names       map[string][]nameRef                 // connection name -> every place it is defined
+	ids         map[string]*yaml.Node                // id -> where it is first used
+	profiles    map[string]bool                      // Declared profiles; nil if the file has no profiles section
+	schema      map[string]*models.CustomFieldSchema // Declared custom fields; nil if there is no schema
+	connections map[string]*connectionRef            // Connections by ID, path and name, for jump_host references
+}
+
+// connectionRef records a connection that jump_host may refer to
+type connectionRef struct {
+	path     string
+	protocol models.Protocol
+	jumpHost *yaml.Node
+}
+
+// nameRef records where a connection name was defined
+type nameRef struct {
+	node   *yaml.Node
+	parent string
+}
+
+func (v *validator) report(severity Severity, node *yaml.Node, path, field, format string, args ...interface{}) {
+	d := Diagnostic{
+		Severity: severity,
+		Path:     path,
+		Field:    field,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if node != nil {
+		d.Line = node.Line
+		d.Column = node.Column
+	}
+	v.diagnostics = append(v.diagnostics, d)
+}
+
+func (v *validator) validateDocument(doc *yaml.Node) {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		v.report(SeverityError, doc, "", "", "file is empty")
+		return
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		v.report(SeverityError, root, "", "", "top level must be a mapping with 'version' and 'connections'")
+		return
+	}
+
+	known := yamlFieldNames(reflect.TypeOf(models.Config{}))
+	var connections *yaml.Node
+	hasVersion := false
+
+	v.validateSchema(mappingValue(root, "custom_field_schema"))
+
+	// Collect declared profiles first; they may come after the connections
+	if profiles := mappingValue(root, "profiles"); profiles != nil && profiles.Kind == yaml.SequenceNode {
+		v.profiles = make(map[string]bool)
+		for _, item := range profiles.Content {
+			if name := scalarValue(mappingValue(item, "name")); name != "" {
+				v.profiles[name] = true
+			}
+		}
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "version":
+			hasVersion = true
+		case "templates":
+			v.validateTemplates(value)
+		case "external_tools":
+			v.validateExternalTools(value)
+		case "connections":
+			connections = value
+		default:
+			if !known[key.Value] {
+				v.unknownField(key, "", known)
+			}
+		}
+	}
+
+	if !hasVersion {
+		v.report(SeverityWarning, root, "", "version", "missing 'version' field")
+	}
+
+	if connections == nil {
+		v.report(SeverityWarning, root, "", "connections", "missing 'connections' list")
+		return
+	}
+
+	v.validateList(connections, "")
+	v.checkGlobalDuplicates()
+	v.checkJumpHosts()
+	v.checkVariables(root, connections)
+}
+
+// validateList validates a sequence of connections or folders
+func (v *validator) validateList(list *yaml.Node, parentPath string) {
+	if list.Kind == yaml.ScalarNode && list.Tag == "!!null" {
+		return
+	}
+	if list.Kind != yaml.SequenceNode {
+		v.report(SeverityError, list, parentPath, "", "expected a list of connections")
+		return
+	}
+
+	siblings := make(map[string]*yaml.Node)
+	for _, item := range list.Content {
+		name := v.validateNode(item, parentPath)
+		if name == nil {
+			continue
+		}
+		if first, exists := siblings[name.Value]; exists {
+			v.report(SeverityError, name, parentPath, "name",
+				"duplicate name '%s' in the same folder (first defined on line %d)", name.Value, first.Line)
+			continue
+		}
+		siblings[name.Value] = name
+	}
+}
+
+// validateNode validates a single connection or folder and returns its name node
+func (v *validator) validateNode(node *yaml.Node, parentPath string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		v.report(SeverityError, node, parentPath, "", "expected a connection or folder mapping")
+		return nil
+	}
+
+	fields := make(map[string]*yaml.Node)
+	keys := make(map[string]*yaml.Node)
+	known := yamlFieldNames(reflect.TypeOf(models.Connection{}))
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if _, exists := fields[key.Value]; exists {
+			v.report(SeverityError, key, parentPath, key.Value, "field '%s' is defined more than once", key.Value)
+		}
+		fields[key.Value] = value
+		keys[key.Value] = key
+	}
+
+	nameNode := fields["name"]
+	name := ""
+	if nameNode == nil || strings.TrimSpace(nameNode.Value) == "" {
+		v.report(SeverityError, node, parentPath, "name", "missing 'name'")
+		nameNode = nil
+	} else {
+		name = nameNode.Value
+		v.names[name] = append(v.names[name], nameRef{node: nameNode, parent: parentPath})
+	}
+
+	path := name
+	if parentPath != "" {
+		path = parentPath + "/" + name
+	}
+
+	if idNode := fields["id"]; idNode != nil && idNode.Value != "" {
+		if first, exists := v.ids[idNode.Value]; exists {
+			v.report(SeverityError, idNode, path, "id", "duplicate id '%s' (first used on line %d)", idNode.Value, first.Line)
+		} else {
+			v.ids[idNode.Value] = idNode
+		}
+	}
+
+	for key, keyNode := range keys {
+		if !known[key] {
+			v.unknownField(keyNode, path, known)
+		}
+	}
+
+	nodeType := models.NodeType(scalarValue(fields["type"]))
+	switch nodeType {
+	case models.NodeTypeFolder, models.NodeTypeConnection:
+	case "":
+		if fields["children"] != nil {
+			nodeType = models.NodeTypeFolder
+		} else {
+			nodeType = models.NodeTypeConnection
+		}
+		v.report(SeverityWarning, node, path, "type", "missing 'type'; treating as %s", nodeType)
+	default:
+		v.report(SeverityError, fields["type"], path, "type",
+			"invalid type '%s' (expected 'connection' or 'folder')", nodeType)
+		return nameNode
+	}
+
+	if nodeType == models.NodeTypeFolder {
+		for _, field := range []string{"protocol", "host", "port"} {
+			if fields[field] != nil {
+				v.report(SeverityWarning, keys[field], path, field, "'%s' is ignored on folders", field)
+			}
+		}
+		if children := fields["children"]; children != nil {
+			v.validateList(children, path)
+		}
+		return nameNode
+	}
+
+	v.validateConnection(fields, keys, node, path)
+	return nameNode
+}
+
+// validateConnection validates the fields of a leaf connection
+func (v *validator) validateConnection(fields, keys map[string]*yaml.Node, node *yaml.Node, path string) {
+	if fields["children"] != nil {
+		v.report(SeverityError, keys["children"], path, "children", "connections cannot have children (set type: folder)")
+	}
+
+	protocolNode := fields["protocol"]
+	protocol := models.Protocol(scalarValue(protocolNode))
+	if protocolNode == nil || protocol == "" {
+		v.report(SeverityError, node, path, "protocol", "missing 'protocol'")
+	} else if !protocol.IsSupported() {
+		v.report(SeverityError, protocolNode, path, "protocol", "unknown protocol '%s'%s",
+			protocol, suggestion(string(protocol), protocolNames()))
+	}
+
+	if strings.TrimSpace(scalarValue(fields["host"])) == "" {
+		target := fields["host"]
+		if target == nil {
+			target = node
+		}
+		v.report(SeverityError, target, path, "host", "missing 'host'")
+	}
+
+	if portNode := fields["port"]; portNode != nil {
+		port, err := strconv.Atoi(portNode.Value)
+		if portNode.Kind != yaml.ScalarNode || err != nil {
+			v.report(SeverityError, portNode, path, "port", "port must be a number, got '%s'", portNode.Value)
+		} else if port < 1 || port > 65535 {
+			v.report(SeverityError, portNode, path, "port", "port %d is out of range (1-65535)", port)
+		}
+	}
+
+	if passwordNode := fields["password"]; passwordNode != nil && strings.HasPrefix(passwordNode.Value, "op://") {
+		if _, _, _, err := secrets.ParseReference(passwordNode.Value); err != nil {
+			v.report(SeverityError, passwordNode, path, "password", "malformed 1Password reference: %v", err)
+		}
+	}
+
+	if depthNode := fields["color_depth"]; depthNode != nil {
+		depth, err := strconv.Atoi(depthNode.Value)
+		if err != nil || !validColorDepths[depth] {
+			v.report(SeverityWarning, depthNode, path, "color_depth",
+				"unusual color depth '%s' (expected 8, 15, 16, 24 or 32)", depthNode.Value)
+		}
+	}
+
+	if resolutionNode := fields["resolution"]; resolutionNode != nil && !resolutionPattern.MatchString(resolutionNode.Value) {
+		v.report(SeverityWarning, resolutionNode, path, "resolution",
+			"resolution '%s' should look like 1920x1080", resolutionNode.Value)
+	}
+
+	if credSSPNode := fields["use_credssp"]; credSSPNode != nil && credSSPNode.Tag != "!!bool" {
+		v.report(SeverityError, credSSPNode, path, "use_credssp", "use_credssp must be true or false")
+	}
+
+	v.validateSSHOptions(fields, keys, protocol, path)
+	if tunnelsNode := fields["tunnels"]; tunnelsNode != nil {
+		v.validateTunnels(tunnelsNode, path)
+	}
+
+	ref := &connectionRef{path: path, protocol: protocol, jumpHost: fields["jump_host"]}
+	for _, key := range []string{scalarValue(fields["id"]), path, scalarValue(fields["name"])} {
+		if _, exists := v.connections[key]; key != "" && !exists {
+			v.connections[key] = ref
+		}
+	}
+
+	if tagsNode := fields["tags"]; tagsNode != nil && tagsNode.Kind != yaml.SequenceNode {
+		v.report(SeverityError, tagsNode, path, "tags", "tags must be a list")
+	}
+
+	if profilesNode := fields["profiles"]; profilesNode != nil {
+		v.validateProfileOverrides(profilesNode, path)
+	}
+
+	v.validateCustomFields(fields["custom_fields"], node, path)
+}
+
+// validateSSHOptions validates the SSH options of a connection
+func (v *validator) validateSSHOptions(fields, keys map[string]*yaml.Node, protocol models.Protocol, path string) {
+	if protocol.IsSupported() && protocol != models.ProtocolSSH {
+		for _, field := range sshOptionFields {
+			if fields[field] != nil {
+				v.report(SeverityWarning, keys[field], path, field, "'%s' only applies to ssh connections", field)
+			}
+		}
+	}
+
+	for _, field := range []string{"forward_agent", "identities_only"} {
+		if node := fields[field]; node != nil && node.Tag != "!!bool" {
+			v.report(SeverityError, node, path, field, "%s must be true or false", field)
+		}
+	}
+
+	if ttyNode := fields["request_tty"]; ttyNode != nil {
+		valid := false
+		for _, value := range models.RequestTTYValues {
+			valid = valid || ttyNode.Value == value
+		}
+		if !valid {
+			v.report(SeverityError, ttyNode, path, "request_tty", "request_tty must be %s, not '%s'",
+				strings.Join(models.RequestTTYValues, ", "), ttyNode.Value)
+		}
+	}
+
+	for _, field := range []string{"local_forwards", "remote_forwards", "dynamic_forwards"} {
+		node := fields[field]
+		if node == nil {
+			continue
+		}
+		if node.Kind != yaml.SequenceNode {
+			v.report(SeverityError, node, path, field, "%s must be a list", field)
+			continue
+		}
+		for _, item := range node.Content {
+			spec := item.Value
+			valid := strings.Contains(spec, "/") // Unix socket paths
+			switch field {
+			case "local_forwards":
+				valid = valid || forwardPattern.MatchString(spec)
+			case "remote_forwards":
+				valid = valid || forwardPattern.MatchString(spec) || portSpecPattern.MatchString(spec)
+			case "dynamic_forwards":
+				valid = valid || portSpecPattern.MatchString(spec)
+			}
+			if !valid {
+				example := "8080:localhost:80"
+				if field == "dynamic_forwards" {
+					example = "1080"
+				}
+				v.report(SeverityWarning, item, path, field, "forward '%s' should look like %s", spec, example)
+			}
+		}
+	}
+}
+
+// validateTunnels checks the named port forwards of a connection
+func (v *validator) validateTunnels(list *yaml.Node, path string) {
+	if list.Kind != yaml.SequenceNode {
+		v.report(SeverityError, list, path, "tunnels", "tunnels must be a list")
+		return
+	}
+
+	known := yamlFieldNames(reflect.TypeOf(models.Tunnel{}))
+	names := make(map[string]*yaml.Node)
+	for _, item := range list.Content {
+		if item.Kind != yaml.MappingNode {
+			v.report(SeverityError, item, path, "tunnels", "expected a tunnel mapping")
+			continue
+		}
+
+		fields := make(map[string]*yaml.Node)
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			if key := item.Content[i]; !known[key.Value] {
+				v.unknownField(key, path, known)
+			}
+			fields[item.Content[i].Value] = item.Content[i+1]
+		}
+
+		nameNode := fields["name"]
+		if strings.TrimSpace(scalarValue(nameNode)) == "" {
+			v.report(SeverityError, item, path, "tunnels", "tunnel is missing 'name'")
+			continue
+		}
+		if first, exists := names[nameNode.Value]; exists {
+			v.report(SeverityError, nameNode, path, "tunnels",
+				"duplicate tunnel '%s' (first defined on line %d)", nameNode.Value, first.Line)
+		} else {
+			names[nameNode.Value] = nameNode
+		}
+
+		tunnel := &models.Tunnel{
+			Name:   nameNode.Value,
+			Type:   scalarValue(fields["type"]),
+			Listen: scalarValue(fields["listen"]),
+			Target: scalarValue(fields["target"]),
+		}
+		if strings.Contains(tunnel.Listen+tunnel.Target, "{{") || strings.Contains(tunnel.Listen+tunnel.Target, "${") {
+			continue // Only known once variables are expanded
+		}
+		if err := tunnel.Validate(); err != nil {
+			v.report(SeverityError, item, path, "tunnels", "%v", err)
+		}
+	}
+}
+
+// validateSchema validates the custom field schema and records the declared fields
+func (v *validator) validateSchema(node *yaml.Node) {
+	if node == nil || (node.Kind == yaml.ScalarNode && node.Tag == "!!null") {
+		return
+	}
+	if node.Kind != yaml.SequenceNode {
+		v.report(SeverityError, node, "", "custom_field_schema", "expected a list of custom field declarations")
+		return
+	}
+
+	v.schema = make(map[string]*models.CustomFieldSchema)
+	known := yamlFieldNames(reflect.TypeOf(models.CustomFieldSchema{}))
+	types := make([]string, len(models.FieldTypes))
+	for i, t := range models.FieldTypes {
+		types[i] = string(t)
+	}
+
+	for _, item := range node.Content {
+		var schema models.CustomFieldSchema
+		if item.Kind != yaml.MappingNode || item.Decode(&schema) != nil {
+			v.report(SeverityError, item, "custom_field_schema", "", "invalid custom field declaration")
+			continue
+		}
+		path := "custom_field_schema/" + schema.Name
+
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			if key := item.Content[i]; !known[key.Value] {
+				v.unknownField(key, path, known)
+			}
+		}
+
+		if strings.TrimSpace(schema.Name) == "" {
+			v.report(SeverityError, item, "custom_field_schema", "name", "custom field is missing 'name'")
+			continue
+		}
+		if _, exists := v.schema[schema.Name]; exists {
+			v.report(SeverityError, mappingValue(item, "name"), "custom_field_schema", "name",
+				"custom field '%s' is declared more than once", schema.Name)
+		}
+
+		validType := false
+		for _, t := range models.FieldTypes {
+			validType = validType || schema.FieldType() == t
+		}
+		if !validType {
+			v.report(SeverityError, mappingValue(item, "type"), path, "type", "unknown field type '%s' (expected %s)%s",
+				schema.Type, strings.Join(types, ", "), suggestion(string(schema.Type), types))
+		}
+
+		v.schema[schema.Name] = &schema
+	}
+}
+
+// validateCustomFields checks a connection's custom fields against the schema
+func (v *validator) validateCustomFields(node, connection *yaml.Node, path string) {
+	present := make(map[string]bool)
+
+	if node != nil && !(node.Kind == yaml.ScalarNode && node.Tag == "!!null") {
+		if node.Kind != yaml.MappingNode {
+			v.report(SeverityError, node, path, "custom_fields", "custom_fields must be a mapping")
+			return
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, valueNode := node.Content[i], node.Content[i+1]
+			present[key.Value] = true
+
+			var value interface{}
+			if valueNode.Kind != yaml.ScalarNode || valueNode.Decode(&value) != nil {
+				v.report(SeverityError, valueNode, path, "custom_fields", "custom field '%s' must be a single value", key.Value)
+				continue
+			}
+			if valueNode.Tag == "!!int" && leadingZeroPattern.MatchString(valueNode.Value) {
+				v.report(SeverityWarning, valueNode, path, "custom_fields",
+					"custom field '%s' is read as the octal number %v; quote it to keep the leading zero", key.Value, value)
+			}
+
+			if v.schema == nil {
+				continue
+			}
+			schema, declared := v.schema[key.Value]
+			if !declared {
+				candidates := make([]string, 0, len(v.schema))
+				for name := range v.schema {
+					candidates = append(candidates, name)
+				}
+				v.report(SeverityWarning, key, path, "custom_fields", "custom field '%s' is not declared in custom_field_schema%s",
+					key.Value, suggestion(key.Value, candidates))
+				continue
+			}
+			if err := models.CheckFieldValue(schema.FieldType(), value); err != nil {
+				v.report(SeverityError, valueNode, path, "custom_fields", "custom field '%s': %v", key.Value, err)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(v.schema))
+	for name := range v.schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if v.schema[name].Required && !present[name] {
+			v.report(SeverityWarning, connection, path, "custom_fields", "missing required custom field '%s'", name)
+		}
+	}
+}
+
+// validateProfileOverrides validates a connection's per-profile overrides
+func (v *validator) validateProfileOverrides(node *yaml.Node, path string) {
+	if node.Kind != yaml.MappingNode {
+		v.report(SeverityError, node, path, "profiles", "profiles must map profile names to overrides")
+		return
+	}
+
+	known := yamlFieldNames(reflect.TypeOf(models.ProfileOverride{}))
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, override := node.Content[i], node.Content[i+1]
+		if v.profiles != nil && !v.profiles[name.Value] {
+			candidates := make([]string, 0, len(v.profiles))
+			for profile := range v.profiles {
+				candidates = append(candidates, profile)
+			}
+			v.report(SeverityWarning, name, path, "profiles", "profile '%s' is not declared in the profiles section%s",
+				name.Value, suggestion(name.Value, candidates))
+		}
+		if override.Kind != yaml.MappingNode {
+			v.report(SeverityError, override, path, "profiles", "overrides for profile '%s' must be a mapping", name.Value)
+			continue
+		}
+		for j := 0; j+1 < len(override.Content); j += 2 {
+			if key := override.Content[j]; !known[key.Value] {
+				v.unknownField(key, path+" ("+name.Value+")", known)
+			}
+		}
+	}
+}
+
+// validateTemplates validates the templates section
+func (v *validator) validateTemplates(list *yaml.Node) {
+	if list.Kind == yaml.ScalarNode && list.Tag == "!!null" {
+		return
+	}
+	if list.Kind != yaml.SequenceNode {
+		v.report(SeverityError, list, "", "templates", "expected a list of templates")
+		return
+	}
+
+	known := yamlFieldNames(reflect.TypeOf(models.Template{}))
+	names := make(map[string]*yaml.Node)
+	for _, item := range list.Content {
+		if item.Kind != yaml.MappingNode {
+			v.report(SeverityError, item, "", "templates", "expected a template mapping")
+			continue
+		}
+
+		fields := make(map[string]*yaml.Node)
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			fields[item.Content[i].Value] = item.Content[i+1]
+		}
+
+		nameNode := fields["name"]
+		path := "templates/" + scalarValue(nameNode)
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			if key := item.Content[i]; !known[key.Value] {
+				v.unknownField(key, path, known)
+			}
+		}
+
+		if strings.TrimSpace(scalarValue(nameNode)) == "" {
+			v.report(SeverityError, item, "templates", "name", "template is missing 'name'")
+			continue
+		}
+		if first, exists := names[nameNode.Value]; exists {
+			v.report(SeverityError, nameNode, "templates", "name",
+				"duplicate template '%s' (first defined on line %d)", nameNode.Value, first.Line)
+		} else {
+			names[nameNode.Value] = nameNode
+		}
+
+		if protocolNode := fields["protocol"]; protocolNode != nil {
+			protocol := models.Protocol(scalarValue(protocolNode))
+			if !protocol.IsSupported() {
+				v.report(SeverityError, protocolNode, path, "protocol", "unknown protocol '%s'%s",
+					protocol, suggestion(string(protocol), protocolNames()))
+			}
+		}
+	}
+}
+
+// validateExternalTools checks the external tools section
+func (v *validator) validateExternalTools(list *yaml.Node) {
+	if list.Kind == yaml.ScalarNode && list.Tag == "!!null" {
+		return
+	}
+	if list.Kind != yaml.SequenceNode {
+		v.report(SeverityError, list, "", "external_tools", "expected a list of external tools")
+		return
+	}
+
+	known := yamlFieldNames(reflect.TypeOf(models.ExternalTool{}))
+	names := make(map[string]*yaml.Node)
+	for _, item := range list.Content {
+		if item.Kind != yaml.MappingNode {
+			v.report(SeverityError, item, "", "external_tools", "expected an external tool mapping")
+			continue
+		}
+
+		fields := make(map[string]*yaml.Node)
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			fields[item.Content[i].Value] = item.Content[i+1]
+		}
+
+		nameNode := fields["name"]
+		path := "external_tools/" + scalarValue(nameNode)
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			if key := item.Content[i]; !known[key.Value] {
+				v.unknownField(key, path, known)
+			}
+		}
+
+		if strings.TrimSpace(scalarValue(nameNode)) == "" {
+			v.report(SeverityError, item, "external_tools", "name", "external tool is missing 'name'")
+			continue
+		}
+		if first, exists := names[nameNode.Value]; exists {
+			v.report(SeverityError, nameNode, "external_tools", "name",
+				"duplicate external tool '%s' (first defined on line %d)", nameNode.Value, first.Line)
+		} else {
+			names[nameNode.Value] = nameNode
+		}
+
+		if strings.TrimSpace(scalarValue(fields["command"])) == "" {
+			v.report(SeverityError, item, path, "command", "external tool is missing 'command'")
+		}
+		if captureNode := fields["capture_output"]; captureNode != nil && captureNode.Value == "true" {
+			if terminalNode := fields["run_in_terminal"]; terminalNode != nil && terminalNode.Value == "true" {
+				v.report(SeverityWarning, captureNode, path, "capture_output",
+					"'capture_output' is ignored when 'run_in_terminal' is set")
+			}
+		}
+	}
+}
+
+// checkGlobalDuplicates warns about names that appear in more than one folder,
+// since commands that look connections up by name will pick the first match
+func (v *validator) checkGlobalDuplicates() {
+	for name, refs := range v.names {
+		for _, ref := range refs[1:] {
+			// Duplicates within one folder are already reported as errors
+			if ref.parent == refs[0].parent {
+				continue
+			}
+			v.report(SeverityWarning, ref.node, ref.parent, "name",
+				"name '%s' is also used on line %d; lookups by name are ambiguous", name, refs[0].node.Line)
+		}
+	}
+}
+
+// checkJumpHosts reports jump_host references to missing or non-SSH
+// connections and chains that loop
+func (v *validator) checkJumpHosts() {
+	paths := make([]string, 0, len(v.connections))
+	for key, ref := range v.connections {
+		if key == ref.path && ref.jumpHost != nil {
+			paths = append(paths, key)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		node := v.connections[path].jumpHost
+		if strings.Contains(node.Value, "{{") || strings.Contains(node.Value, "${") {
+			continue // Only known once variables are expanded
+		}
+		jump := v.connections[node.Value]
+		if jump == nil {
+			v.report(SeverityError, node, path, "jump_host", "jump host '%s' not found", node.Value)
+			continue
+		}
+		if jump.protocol != models.ProtocolSSH {
+			v.report(SeverityError, node, path, "jump_host", "jump host '%s' is not an ssh connection", node.Value)
+			continue
+		}
+
+		// Follow the chain until it ends or comes back
+		seen := map[string]bool{path: true}
+		for jump != nil && jump.jumpHost != nil {
+			if seen[jump.path] {
+				v.report(SeverityError, node, path, "jump_host", "jump hosts loop back to '%s'", jump.path)
+				break
+			}
+			seen[jump.path] = true
+			jump = v.connections[jump.jumpHost.Value]
+		}
+	}
+}
+
+// checkVariables reports variable references that loop or nest too deeply.
+// Undefined variables aren't reported since they may be given with --var
+func (v *validator) checkVariables(root, connections *yaml.Node) {
+	reported := make(map[string]bool)
+	check := func(node *yaml.Node, scopes []Variables, path string) {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			r := &resolver{scopes: scopes, missing: make(map[string]bool), chain: []string{key.Value}}
+			r.expand(scalarValue(value))
+			for _, problem := range r.problemList() {
+				if !reported[problem] {
+					reported[problem] = true
+					v.report(SeverityError, value, path, "variables", "%s", problem)
+				}
+			}
+		}
+	}
+
+	globalNode := mappingValue(root, "variables")
+	global := nodeVariables(globalNode)
+	check(globalNode, []Variables{global}, "")
+
+	if profiles := mappingValue(root, "profiles"); profiles != nil && profiles.Kind == yaml.SequenceNode {
+		for _, item := range profiles.Content {
+			name := scalarValue(mappingValue(item, "name"))
+			vars := mappingValue(item, "variables")
+			check(vars, []Variables{nodeVariables(vars), {"profile": name}, global}, "")
+		}
+	}
+
+	var walk func(list *yaml.Node, scopes []Variables, parentPath string)
+	walk = func(list *yaml.Node, scopes []Variables, parentPath string) {
+		if list == nil || list.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, item := range list.Content {
+			path := scalarValue(mappingValue(item, "name"))
+			if parentPath != "" {
+				path = parentPath + "/" + path
+			}
+			vars := mappingValue(item, "variables")
+			itemScopes := append([]Variables{nodeVariables(vars)}, scopes...)
+			check(vars, itemScopes, path)
+			walk(mappingValue(item, "children"), itemScopes, path)
+		}
+	}
+	walk(connections, []Variables{global}, "")
+}
+
+// nodeVariables returns the scalar entries of a variables mapping
+func nodeVariables(node *yaml.Node) Variables {
+	vars := make(Variables)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return vars
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i+1].Kind == yaml.ScalarNode {
+			vars[node.Content[i].Value] = node.Content[i+1].Value
+		}
+	}
+	return vars
+}
+
+func (v *validator) unknownField(key *yaml.Node, path string, known map[string]bool) {
+	candidates := make([]string, 0, len(known))
+	for name := range known {
+		candidates = append(candidates, name)
+	}
+	v.report(SeverityWarning, key, path, key.Value, "unknown field '%s'%s", key.Value, suggestion(key.Value, candidates))
+}
+
+// scalarValue returns the value of a scalar node, or "" for nil and non-scalar nodes
+func scalarValue(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+// yamlFieldNames returns the set of YAML keys declared on a struct type
+func yamlFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("yaml")
+		name := strings.Split(tag, ",")[0]
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+func protocolNames() []string {
+	supported := models.SupportedProtocols()
+	names := make([]string, len(supported))
+	for i, p := range supported {
+		names[i] = string(p)
+	}
+	return names
+}
+
+// suggestion returns a " (did you mean 'x'?)" hint for the closest candidate, if any is close enough
+func suggestion(value string, candidates []string) string {
+	best := ""
+	bestDistance := len(value)/2 + 1
+	sort.Strings(candidates)
+	for _, candidate := range candidates {
+		if d := levenshtein(strings.ToLower(value), candidate); d < bestDistance {
+			best = candidate
+			bestDistance = d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean '%s'?)", best)
+}
+
+// levenshtein computes the edit distance between two strings
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validConnections is a file without problems; the tests below break one thing in it
+const validConnections = `version: "1.0"
+connections:
+  - name: Production
+    type: folder
+    id: prod
+    children:
+      - name: web1
+        type: connection
+        id: prod-web1
+        protocol: ssh
+        host: web1.example.com
+        port: 22
+      - name: rdp1
+        type: connection
+        id: prod-rdp1
+        protocol: rdp
+        host: rdp1.example.com
+`
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string // Text in validConnections to replace
+		new  string
+		want []string // Diagnostics as formatted by Diagnostic.String
+	}{
+		{name: "valid"},
+		{
+			name: "duplicate id",
+			old:  "id: prod-rdp1",
+			new:  "id: prod-web1",
+			want: []string{"15:13: error: Production/rdp1: duplicate id 'prod-web1' (first used on line 9)"},
+		},
+		{
+			name: "folder and connection ids clash",
+			old:  "id: prod-web1",
+			new:  "id: prod",
+			want: []string{"9:13: error: Production/web1: duplicate id 'prod' (first used on line 5)"},
+		},
+		{
+			name: "port out of range",
+			old:  "port: 22",
+			new:  "port: 70000",
+			want: []string{"12:15: error: Production/web1: port 70000 is out of range (1-65535)"},
+		},
+		{
+			name: "port not a number",
+			old:  "port: 22",
+			new:  "port: ssh",
+			want: []string{"12:15: error: Production/web1: port must be a number, got 'ssh'"},
+		},
+		{
+			name: "unknown protocol",
+			old:  "protocol: rdp",
+			new:  "protocol: rdb",
+			want: []string{"16:19: error: Production/rdp1: unknown protocol 'rdb' (did you mean 'rdp'?)"},
+		},
+		{
+			name: "missing host",
+			old:  "        host: rdp1.example.com\n",
+			new:  "",
+			want: []string{"13:9: error: Production/rdp1: missing 'host'"},
+		},
+		{
+			name: "empty host",
+			old:  "host: web1.example.com",
+			new:  `host: " "`,
+			want: []string{"11:15: error: Production/web1: missing 'host'"},
+		},
+		{
+			name: "duplicate name",
+			old:  "name: rdp1",
+			new:  "name: web1",
+			want: []string{"13:15: error: Production: duplicate name 'web1' in the same folder (first defined on line 7)"},
+		},
+	}
+
+	for _, tt := range tests {
+		data := strings.Replace(validConnections, tt.old, tt.new, 1)
+		if tt.old != "" && data == validConnections {
+			t.Fatalf("%s: %q not found", tt.name, tt.old)
+		}
+
+		diagnostics := Validate([]byte(data))
+		got := make([]string, len(diagnostics))
+		for i, d := range diagnostics {
+			got[i] = d.String()
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: diagnostics\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+		if HasErrors(diagnostics) != (len(tt.want) > 0) {
+			t.Errorf("%s: HasErrors = %v", tt.name, HasErrors(diagnostics))
+		}
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "connections.yaml")
+	if err := os.WriteFile(path, []byte("version: \"1.0\"\nconnections: [\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	diagnostics, err := ValidateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !HasErrors(diagnostics) || diagnostics[0].Line == 0 {
+		t.Errorf("diagnostics = %v, want a syntax error with its line", diagnostics)
+	}
+
+	if _, err := ValidateFile(path + ".missing"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// variablePattern matches {{name}} variable references. Template placeholders
+// such as {{.Name}} start with a dot and are not matched.
+var variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][\w-]*)\s*\}\}`)
+
+// envPattern matches ${NAME} references to process environment variables
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_]\w*)\}`)
+
+// Variables maps variable names to values
+type Variables map[string]string
+
+// ResolveConnection returns a copy of a connection with the active profile's
+// overrides applied and {{name}} variables and ${ENV_VAR} references expanded
+// in its host, username, domain, resolution, extra arguments, SSH options and
+// tunnels. Passwords are taken literally, so they may contain {{ and ${.
+// Variables are looked up, in order, in overrides, in the active profile, on
+// the connection itself, on each enclosing folder from the innermost outwards,
+// in the config's variables section and finally in the process environment.
+// While a profile is active, {{profile}} expands to its name. The stored connection is not modified.
+// A jump_host is resolved the same way, along with its own jump hosts, into Jumps.
+func (m *Manager) ResolveConnection(conn *models.Connection, overrides Variables) (*models.Connection, error) {
+	return m.resolveWithJumps(conn, overrides, nil)
+}
+
+// resolveWithJumps resolves a connection and the jump hosts in front of it;
+// visited holds the connections whose jump hosts are being resolved
+func (m *Manager) resolveWithJumps(conn *models.Connection, overrides Variables, visited []*models.Connection) (*models.Connection, error) {
+	resolved, err := m.resolve(conn, overrides)
+	if err != nil || resolved.JumpHost == "" {
+		return resolved, err
+	}
+
+	jump, err := m.FindConnectionRef(resolved.JumpHost)
+	if err != nil {
+		return nil, fmt.Errorf("jump host of '%s': %w", conn.Name, err)
+	}
+	if jump.IsFolder() || jump.Protocol != models.ProtocolSSH {
+		return nil, fmt.Errorf("jump host '%s' of '%s' is not an ssh connection", jump.Name, conn.Name)
+	}
+	visited = append(visited, conn)
+	for _, seen := range visited {
+		if seen == jump {
+			return nil, fmt.Errorf("jump hosts of '%s' loop back to '%s'", visited[0].Name, jump.Name)
+		}
+	}
+
+	resolvedJump, err := m.resolveWithJumps(jump, overrides, visited)
+	if err != nil {
+		return nil, err
+	}
+	resolved.Jumps = append(resolvedJump.Jumps, resolvedJump)
+	resolvedJump.Jumps = nil
+	return resolved, nil
+}
+
+// resolve applies the active profile and expands the variables of one connection
+func (m *Manager) resolve(conn *models.Connection, overrides Variables) (*models.Connection, error) {
+	scopes := []Variables{overrides}
+	if profile := m.GetConfig().FindProfile(m.profile); profile != nil {
+		scopes = append(scopes, profile.Variables)
+	}
+	if m.profile != "" {
+		scopes = append(scopes, Variables{"profile": m.profile})
+	}
+	scopes = append(scopes, conn.Variables)
+	for _, folder := range m.ancestors(conn) {
+		scopes = append(scopes, folder.Variables)
+	}
+	scopes = append(scopes, m.GetConfig().Variables)
+
+	profiled := conn
+	if m.profile != "" {
+		profiled = conn.DeepCopy()
+		profiled.ApplyProfile(m.profile)
+	}
+
+	return resolveConnection(profiled, scopes)
+}
+
+// ancestors returns the folders containing a connection, innermost first
+func (m *Manager) ancestors(conn *models.Connection) []*models.Connection {
+	var path []*models.Connection
+	var find func(connections []*models.Connection) bool
+	find = func(connections []*models.Connection) bool {
+		for _, c := range connections {
+			if c == conn {
+				return true
+			}
+			if c.IsFolder() {
+				path = append(path, c)
+				if find(c.Children) {
+					return true
+				}
+				path = path[:len(path)-1]
+			}
+		}
+		return false
+	}
+	find(m.GetConfig().Connections)
+
+	// Reverse so the innermost folder comes first
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// resolveConnection expands the variable references of a connection using
+// the given scopes, earliest scope first
+func resolveConnection(conn *models.Connection, scopes []Variables) (*models.Connection, error) {
+	resolved := conn.DeepCopy()
+	r := &resolver{scopes: scopes, missing: make(map[string]bool)}
+
+	resolved.Host = r.expand(resolved.Host)
+	resolved.Username = r.expand(resolved.Username)
+	resolved.Domain = r.expand(resolved.Domain)
+	resolved.Resolution = r.expand(resolved.Resolution)
+	resolved.ExtraArgs = r.expand(resolved.ExtraArgs)
+	resolved.IdentityFile = r.expand(resolved.IdentityFile)
+	resolved.CertificateFile = r.expand(resolved.CertificateFile)
+	resolved.RemoteCommand = r.expand(resolved.RemoteCommand)
+	resolved.JumpHost = r.expand(resolved.JumpHost)
+	for _, forwards := range [][]string{resolved.LocalForwards, resolved.RemoteForwards, resolved.DynamicForwards} {
+		for i := range forwards {
+			forwards[i] = r.expand(forwards[i])
+		}
+	}
+	for _, tunnel := range resolved.Tunnels {
+		tunnel.Listen = r.expand(tunnel.Listen)
+		tunnel.Target = r.expand(tunnel.Target)
+	}
+
+	if problems := r.problemList(); len(problems) > 0 {
+		return nil, fmt.Errorf("%s in '%s'", strings.Join(problems, "; "), conn.Name)
+	}
+	if len(r.missing) > 0 {
+		names := make([]string, 0, len(r.missing))
+		for name := range r.missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("undefined variable(s) in '%s': %s", conn.Name, strings.Join(names, ", "))
+	}
+
+	return resolved, nil
+}
+
+// maxVariableDepth limits how deeply variables may refer to other variables
+const maxVariableDepth = 10
+
+// resolver expands variable references, recording the names it can't resolve
+// and the references that loop or nest too deeply
+type resolver struct {
+	scopes   []Variables
+	missing  map[string]bool
+	problems map[string]bool
+	chain    []string // Variables being expanded, outermost first
+}
+
+func (r *resolver) expand(value string) string {
+	value = envPattern.ReplaceAllStringFunc(value, func(match string) string {
+		name := envPattern.FindStringSubmatch(match)[1]
+		if env, exists := os.LookupEnv(name); exists {
+			return env
+		}
+		r.missing["$"+name] = true
+		return match
+	})
+
+	return variablePattern.ReplaceAllStringFunc(value, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		if variable, exists := r.lookup(name); exists {
+			// Variables may themselves refer to other variables
+			return r.expandVariable(name, variable, match)
+		}
+		r.missing[name] = true
+		return match
+	})
+}
+
+// expandVariable expands the value of a referenced variable, leaving the
+// reference as it is if it loops back to a variable being expanded or nests
+// more than maxVariableDepth deep
+func (r *resolver) expandVariable(name, value, reference string) string {
+	chain := append(append([]string(nil), r.chain...), name)
+	for i, outer := range r.chain {
+		if outer == name {
+			r.problem("variable cycle " + strings.Join(chain[i:], " -> "))
+			return reference
+		}
+	}
+	if len(r.chain) >= maxVariableDepth {
+		r.problem(fmt.Sprintf("variables nested more than %d deep: %s", maxVariableDepth, strings.Join(chain, " -> ")))
+		return reference
+	}
+
+	r.chain = chain
+	defer func() { r.chain = r.chain[:len(r.chain)-1] }()
+	return r.expand(value)
+}
+
+// problem records a reference that can't be expanded
+func (r *resolver) problem(message string) {
+	if r.problems == nil {
+		r.problems = make(map[string]bool)
+	}
+	r.problems[message] = true
+}
+
+// problemList returns the recorded problems, sorted
+func (r *resolver) problemList() []string {
+	problems := make([]string, 0, len(r.problems))
+	for problem := range r.problems {
+		problems = append(problems, problem)
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// lookup finds a variable in the scopes, falling back to the process environment
+func (r *resolver) lookup(name string) (string, bool) {
+	for _, scope := range r.scopes {
+		if value, exists := scope[name]; exists {
+			return value, true
+		}
+	}
+	return os.LookupEnv(name)
+}
+
+// ParseVariables parses key=value pairs, as given on the command line
+func ParseVariables(pairs []string) (Variables, error) {
+	vars := make(Variables)
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid variable '%s' (expected key=value)", pair)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
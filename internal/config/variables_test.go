@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+func TestResolveConnectionKeepsPassword(t *testing.T) {
+	t.Setenv("MREMOTEGO_TEST_USER", "deploy")
+	m := NewManager("")
+	m.GetConfig().Variables = map[string]string{"domain": "example.com", "secret": "expanded"}
+	conn := &models.Connection{
+		Name:     "web1",
+		Protocol: models.ProtocolSSH,
+		Host:     "web1.{{domain}}",
+		Username: "${MREMOTEGO_TEST_USER}",
+		Password: "p{{secret}}${MREMOTEGO_TEST_USER}",
+	}
+	m.GetConfig().Connections = []*models.Connection{conn}
+
+	resolved, err := m.ResolveConnection(conn, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Host != "web1.example.com" || resolved.Username != "deploy" {
+		t.Errorf("host and username = %q, %q", resolved.Host, resolved.Username)
+	}
+	if resolved.Password != conn.Password {
+		t.Errorf("password = %q, want it unexpanded", resolved.Password)
+	}
+}
+
+func TestResolveConnectionReportsCycles(t *testing.T) {
+	m := NewManager("")
+	m.GetConfig().Variables = map[string]string{"a": "{{b}}", "b": "x{{a}}"}
+	conn := &models.Connection{Name: "web1", Protocol: models.ProtocolSSH, Host: "{{a}}.example.com"}
+	m.GetConfig().Connections = []*models.Connection{conn}
+
+	_, err := m.ResolveConnection(conn, nil)
+	if err == nil || !strings.Contains(err.Error(), "variable cycle a -> b -> a in 'web1'") {
+		t.Errorf("error = %v, want the cycle a -> b -> a", err)
+	}
+
+	// A variable may be used more than once without forming a cycle
+	m.GetConfig().Variables = map[string]string{"a": "{{b}}-{{b}}", "b": "x"}
+	resolved, err := m.ResolveConnection(conn, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Host != "x-x.example.com" {
+		t.Errorf("host = %q", resolved.Host)
+	}
+}
+
+func TestResolveConnectionDepthLimit(t *testing.T) {
+	m := NewManager("")
+	conn := &models.Connection{Name: "web1", Protocol: models.ProtocolSSH, Host: "{{v0}}"}
+	m.GetConfig().Connections = []*models.Connection{conn}
+
+	// chain sets v0 -> v1 -> ... -> vN, where vN is "end"
+	chain := func(n int) {
+		vars := map[string]string{fmt.Sprintf("v%d", n): "end"}
+		for i := 0; i < n; i++ {
+			vars[fmt.Sprintf("v%d", i)] = fmt.Sprintf("{{v%d}}", i+1)
+		}
+		m.GetConfig().Variables = vars
+	}
+
+	chain(maxVariableDepth - 1)
+	resolved, err := m.ResolveConnection(conn, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Host != "end" {
+		t.Errorf("host = %q", resolved.Host)
+	}
+
+	chain(maxVariableDepth)
+	_, err = m.ResolveConnection(conn, nil)
+	if err == nil || !strings.Contains(err.Error(), "variables nested more than 10 deep: v0 -> v1") {
+		t.Errorf("error = %v, want the depth limit", err)
+	}
+}
+
+func TestValidateReportsVariableCycles(t *testing.T) {
+	diagnostics := Validate([]byte(`version: "1.0"
+variables:
+  a: "{{b}}"
+  b: "{{a}}"
+  domain: example.com
+connections:
+  - name: Production
+    type: folder
+    variables:
+      c: "{{c}}"
+    children:
+      - name: web1
+        type: connection
+        protocol: ssh
+        host: "{{a}}.{{domain}}"
+        variables:
+          d: "{{undefined}}"
+`))
+
+	var got []string
+	for _, d := range diagnostics {
+		if d.Field == "variables" {
+			got = append(got, d.String())
+		}
+	}
+	want := []string{
+		"3:6: error: variable cycle a -> b -> a",
+		"4:6: error: variable cycle b -> a -> b",
+		"10:10: error: Production: variable cycle c -> c",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !HasErrors(diagnostics) {
+		t.Error("HasErrors = false")
+	}
+}
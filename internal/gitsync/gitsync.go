@@ -0,0 +1,355 @@
+package gitsync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/internal/config"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// ErrNotRepository is returned when the config file is not inside a git working tree
+var ErrNotRepository = errors.New("config file is not in a git working tree")
+
+// ErrNoUpstream is returned when the current branch has nothing to pull from or push to
+var ErrNoUpstream = errors.New("current branch has no upstream (set one with 'git push -u <remote> <branch>')")
+
+// Repo is the git working tree a connection file lives in
+type Repo struct {
+	root string // Top level of the working tree
+	file string // Config file path relative to root, with forward slashes
+}
+
+// Status describes the state of the connection file in its repository
+type Status struct {
+	Branch   string
+	Upstream string // Empty if the branch doesn't track a remote branch
+	Ahead    int
+	Behind   int
+	Dirty    bool            // The connection file has uncommitted changes
+	Changes  []config.Change // Uncommitted changes to the connection file
+}
+
+// Result summarizes what Sync did
+type Result struct {
+	Committed bool
+	Message   string // Commit message, if a commit was made
+	Pulled    bool
+	Pushed    bool
+}
+
+// Open finds the git working tree containing a connection file
+func Open(configPath string) (*Repo, error) {
+	absPath, err := filepath.Abs(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config path: %w", err)
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git is not installed: %w", err)
+	}
+
+	out, err := runGit(filepath.Dir(absPath), "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, ErrNotRepository
+	}
+	root := strings.TrimSpace(out)
+
+	// Resolve symlinks on both sides so the relative path is correct
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve repository path: %w", err)
+	}
+	resolvedDir, err := filepath.EvalSymlinks(filepath.Dir(absPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config path: %w", err)
+	}
+	rel, err := filepath.Rel(resolvedRoot, filepath.Join(resolvedDir, filepath.Base(absPath)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate config file in repository: %w", err)
+	}
+
+	return &Repo{root: resolvedRoot, file: filepath.ToSlash(rel)}, nil
+}
+
+// Root returns the top level directory of the working tree
+func (r *Repo) Root() string {
+	return r.root
+}
+
+// File returns the path of the connection file relative to the repository root
+func (r *Repo) File() string {
+	return r.file
+}
+
+// Status reports the branch state and the uncommitted changes to the connection file
+func (r *Repo) Status() (*Status, error) {
+	out, err := r.git("status", "--porcelain=v2", "--branch", "--untracked-files=all", "--", r.file)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{}
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "# branch.head "):
+			status.Branch = strings.TrimPrefix(line, "# branch.head ")
+		case strings.HasPrefix(line, "# branch.upstream "):
+			status.Upstream = strings.TrimPrefix(line, "# branch.upstream ")
+		case strings.HasPrefix(line, "# branch.ab "):
+			fields := strings.Fields(strings.TrimPrefix(line, "# branch.ab "))
+			if len(fields) == 2 {
+				status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[0], "+"))
+				status.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[1], "-"))
+			}
+		case line != "" && !strings.HasPrefix(line, "#"):
+			status.Dirty = true
+		}
+	}
+
+	if status.Dirty {
+		status.Changes, err = r.pendingChanges()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return status, nil
+}
+
+// pendingChanges compares the committed connection file with the working copy
+func (r *Repo) pendingChanges() ([]config.Change, error) {
+	committed := models.NewConfig()
+	if data, err := r.git("show", "HEAD:"+r.file); err == nil {
+		committed, err = config.ParseFile([]byte(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse committed config: %w", err)
+		}
+	}
+
+	current := models.NewConfig()
+	if _, err := os.Stat(filepath.Join(r.root, r.file)); err == nil {
+		current, err = config.LoadFile(filepath.Join(r.root, r.file))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return config.Diff(committed, current), nil
+}
+
+// CommitMessage generates a commit message describing the uncommitted changes
+// to the connection file
+func (r *Repo) CommitMessage() (string, error) {
+	changes, err := r.pendingChanges()
+	if err != nil {
+		return "", err
+	}
+	return CommitMessage(changes), nil
+}
+
+// Commit stages and commits the connection file. An empty message is replaced
+// by a generated one. It returns false if there was nothing to commit.
+func (r *Repo) Commit(message string) (bool, string, error) {
+	if message == "" {
+		generated, err := r.CommitMessage()
+		if err != nil {
+			return false, "", err
+		}
+		message = generated
+	}
+
+	if _, err := r.git("add", "--", r.file); err != nil {
+		return false, "", err
+	}
+
+	// Nothing staged for the connection file
+	if _, err := r.git("diff", "--cached", "--quiet", "--", r.file); err == nil {
+		return false, "", nil
+	}
+
+	if _, err := r.git("commit", "--only", "-m", message, "--", r.file); err != nil {
+		return false, "", err
+	}
+	return true, message, nil
+}
+
+// Pull fetches and rebases the current branch onto its upstream. Uncommitted
+// changes are stashed for the duration of the rebase.
+func (r *Repo) Pull() error {
+	if err := r.requireUpstream(); err != nil {
+		return err
+	}
+	if _, err := r.git("pull", "--rebase", "--autostash"); err != nil {
+		// Leave the working tree usable rather than mid-rebase
+		if _, statErr := os.Stat(filepath.Join(r.gitDir(), "rebase-merge")); statErr == nil {
+			r.git("rebase", "--abort")
+			return fmt.Errorf("%w (rebase aborted; resolve the conflict with git and try again)", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// Push pushes the current branch to its upstream
+func (r *Repo) Push() error {
+	if err := r.requireUpstream(); err != nil {
+		return err
+	}
+	_, err := r.git("push")
+	return err
+}
+
+// Sync commits pending changes to the connection file, rebases onto the
+// upstream branch and pushes. Without an upstream only the commit is made.
+func (r *Repo) Sync(message string) (*Result, error) {
+	result := &Result{}
+
+	committed, message, err := r.Commit(message)
+	if err != nil {
+		return result, fmt.Errorf("commit failed: %w", err)
+	}
+	result.Committed, result.Message = committed, message
+
+	if err := r.Pull(); err != nil {
+		if errors.Is(err, ErrNoUpstream) {
+			return result, nil
+		}
+		return result, fmt.Errorf("pull failed: %w", err)
+	}
+	result.Pulled = true
+
+	if err := r.Push(); err != nil {
+		return result, fmt.Errorf("push failed: %w", err)
+	}
+	result.Pushed = true
+
+	return result, nil
+}
+
+// requireUpstream returns ErrNoUpstream if the current branch doesn't track a remote branch
+func (r *Repo) requireUpstream() error {
+	if _, err := r.git("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}"); err != nil {
+		return ErrNoUpstream
+	}
+	return nil
+}
+
+// gitDir returns the path of the repository's .git directory
+func (r *Repo) gitDir() string {
+	out, err := r.git("rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return filepath.Join(r.root, ".git")
+	}
+	return strings.TrimSpace(out)
+}
+
+func (r *Repo) git(args ...string) (string, error) {
+	return runGit(r.root, args...)
+}
+
+// runGit runs git in a directory and returns its standard output. Errors
+// include what git printed to standard error.
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	// Never block waiting for credentials on a terminal that isn't there
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	hideConsoleWindow(cmd)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(withoutHints(stderr.String()))
+		if message == "" {
+			message = strings.TrimSpace(stdout.String())
+		}
+		if message == "" {
+			return "", fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], message)
+	}
+	return stdout.String(), nil
+}
+
+// withoutHints drops git's advice lines from its output
+func withoutHints(output string) string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, "hint:") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CommitMessage describes a set of connection file changes as a commit message:
+// a one line summary followed by one line per change
+func CommitMessage(changes []config.Change) string {
+	if len(changes) == 0 {
+		return "Update connections"
+	}
+
+	var subject string
+	if len(changes) == 1 {
+		subject = describeChange(changes[0])
+	} else {
+		counts := make(map[config.ChangeKind]int)
+		noun := "connections"
+		for _, change := range changes {
+			counts[change.Kind]++
+			if change.Section != "" {
+				// Templates, profiles and the like changed too
+				noun = "entries"
+			}
+		}
+		var parts []string
+		for _, kind := range []config.ChangeKind{config.ChangeAdded, config.ChangeModified, config.ChangeMoved, config.ChangeRemoved} {
+			if counts[kind] > 0 {
+				parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+			}
+		}
+		subject = fmt.Sprintf("Update %d %s (%s)", len(changes), noun, strings.Join(parts, ", "))
+	}
+
+	var body strings.Builder
+	body.WriteString(subject)
+	body.WriteString("\n\n")
+	for _, change := range changes {
+		body.WriteString("- ")
+		body.WriteString(describeChange(change))
+		if len(change.Fields) > 0 {
+			fields := make([]string, len(change.Fields))
+			for i, field := range change.Fields {
+				fields[i] = field.Field
+			}
+			body.WriteString(" (" + strings.Join(fields, ", ") + ")")
+		}
+		body.WriteString("\n")
+	}
+	return strings.TrimRight(body.String(), "\n")
+}
+
+// describeChange describes a single change, e.g. "Add connection Prod/Web"
+func describeChange(change config.Change) string {
+	kind := change.Noun()
+
+	switch change.Kind {
+	case config.ChangeAdded:
+		return fmt.Sprintf("Add %s %s", kind, change.Path)
+	case config.ChangeRemoved:
+		return fmt.Sprintf("Remove %s %s", kind, change.Path)
+	case config.ChangeMoved:
+		return fmt.Sprintf("Move %s %s to %s", kind, change.OldPath, change.Path)
+	default:
+		return fmt.Sprintf("Update %s %s", kind, change.Path)
+	}
+}
@@ -0,0 +1,263 @@
+package gitsync
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const connectionsFile = `version: "1.0"
+connections:
+  - name: web1
+    type: connection
+    protocol: ssh
+    host: web1.example.com
+`
+
+// setupRepos creates a bare remote holding a connection file and two clones
+// of it that track its main branch
+func setupRepos(t *testing.T) (first, second *Repo) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	// Keep the user's git configuration out of the tests
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	remote := filepath.Join(dir, "remote.git")
+	mustGit(t, dir, "init", "--bare", "-b", "main", remote)
+
+	seed := filepath.Join(dir, "seed")
+	mustGit(t, dir, "init", "-b", "main", seed)
+	writeFile(t, filepath.Join(seed, "connections.yaml"), connectionsFile)
+	mustGit(t, seed, "add", "connections.yaml")
+	mustGit(t, seed, "commit", "-m", "Initial connections")
+	mustGit(t, seed, "remote", "add", "origin", remote)
+	mustGit(t, seed, "push", "-u", "origin", "main")
+
+	repos := make([]*Repo, 2)
+	for i, name := range []string{"first", "second"} {
+		clone := filepath.Join(dir, name)
+		mustGit(t, dir, "clone", remote, clone)
+		repo, err := Open(filepath.Join(clone, "connections.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		repos[i] = repo
+	}
+	return repos[0], repos[1]
+}
+
+// mustGit runs git in a directory, failing the test on errors
+func mustGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := runGit(dir, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// writeFile writes a file, failing the test on errors
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// edit replaces text in a repo's connection file
+func edit(t *testing.T, r *Repo, old, new string) {
+	t.Helper()
+	path := filepath.Join(r.Root(), r.File())
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), old) {
+		t.Fatalf("%q not found in %s", old, path)
+	}
+	writeFile(t, path, strings.Replace(string(data), old, new, 1))
+}
+
+// commit commits the connection file with a generated message
+func commit(t *testing.T, r *Repo) string {
+	t.Helper()
+	committed, message, err := r.Commit("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !committed {
+		t.Fatal("nothing committed")
+	}
+	return message
+}
+
+func TestOpen(t *testing.T) {
+	first, _ := setupRepos(t)
+	if first.File() != "connections.yaml" {
+		t.Errorf("file = %q", first.File())
+	}
+
+	if _, err := Open(filepath.Join(t.TempDir(), "connections.yaml")); !errors.Is(err, ErrNotRepository) {
+		t.Errorf("error = %v, want ErrNotRepository", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	first, _ := setupRepos(t)
+
+	status, err := first.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Branch != "main" || status.Upstream != "origin/main" || status.Dirty || status.Ahead != 0 || status.Behind != 0 {
+		t.Errorf("clean clone: %+v", status)
+	}
+
+	edit(t, first, "host: web1.example.com", "host: web1.example.org")
+	if status, err = first.Status(); err != nil {
+		t.Fatal(err)
+	}
+	if !status.Dirty || len(status.Changes) != 1 || status.Changes[0].Path != "web1" {
+		t.Errorf("after an edit: %+v", status)
+	}
+
+	commit(t, first)
+	if status, err = first.Status(); err != nil {
+		t.Fatal(err)
+	}
+	if status.Dirty || status.Ahead != 1 {
+		t.Errorf("after a commit: %+v", status)
+	}
+}
+
+func TestCommit(t *testing.T) {
+	first, _ := setupRepos(t)
+
+	if committed, _, err := first.Commit(""); err != nil || committed {
+		t.Errorf("clean tree: committed = %v, error = %v", committed, err)
+	}
+
+	edit(t, first, "host: web1.example.com", "host: web1.example.org\n  - name: web2\n    type: connection\n    protocol: rdp\n    host: web2")
+	want := "Update 2 connections (1 added, 1 modified)\n\n- Update connection web1 (host)\n- Add connection web2"
+	if message := commit(t, first); message != want {
+		t.Errorf("message = %q, want %q", message, want)
+	}
+	if logged := strings.TrimSpace(mustGit(t, first.Root(), "log", "-1", "--format=%B")); logged != want {
+		t.Errorf("committed message = %q", logged)
+	}
+
+	// A given message is used as is, and other files are left alone
+	writeFile(t, filepath.Join(first.Root(), "notes.txt"), "unrelated")
+	mustGit(t, first.Root(), "add", "notes.txt")
+	edit(t, first, "protocol: rdp", "protocol: vnc")
+	if committed, message, err := first.Commit("Switch web2 to VNC"); err != nil || !committed || message != "Switch web2 to VNC" {
+		t.Errorf("committed = %v, message = %q, error = %v", committed, message, err)
+	}
+	if files := mustGit(t, first.Root(), "show", "--name-only", "--format=", "HEAD"); strings.TrimSpace(files) != "connections.yaml" {
+		t.Errorf("committed files: %q", files)
+	}
+}
+
+func TestPullRebasesAndPush(t *testing.T) {
+	first, second := setupRepos(t)
+
+	edit(t, first, "host: web1.example.com", "host: web1.example.org")
+	commit(t, first)
+	if err := first.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A change to another part of the file rebases cleanly on top
+	edit(t, second, "  - name: web1\n", "  - name: db1\n    type: connection\n    protocol: ssh\n    host: db1\n  - name: web1\n")
+	commit(t, second)
+	if err := second.Pull(); err != nil {
+		t.Fatal(err)
+	}
+	if merges := mustGit(t, second.Root(), "rev-list", "--merges", "HEAD"); merges != "" {
+		t.Errorf("pull made merge commits: %s", merges)
+	}
+	status, err := second.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Ahead != 1 || status.Behind != 0 {
+		t.Errorf("after pull: ahead %d, behind %d", status.Ahead, status.Behind)
+	}
+	if err := second.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Pull(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(first.Root(), first.File()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "host: db1") || !strings.Contains(string(data), "host: web1.example.org") {
+		t.Errorf("both changes expected in:\n%s", data)
+	}
+}
+
+func TestPullAbortsConflictingRebase(t *testing.T) {
+	first, second := setupRepos(t)
+
+	edit(t, first, "host: web1.example.com", "host: web1.example.org")
+	commit(t, first)
+	if err := first.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	edit(t, second, "host: web1.example.com", "host: web1.example.net")
+	commit(t, second)
+	head := mustGit(t, second.Root(), "rev-parse", "HEAD")
+
+	err := second.Pull()
+	if err == nil || !strings.Contains(err.Error(), "rebase aborted") {
+		t.Fatalf("error = %v, want an aborted rebase", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(second.gitDir(), "rebase-merge")); statErr == nil {
+		t.Error("rebase still in progress")
+	}
+	if after := mustGit(t, second.Root(), "rev-parse", "HEAD"); after != head {
+		t.Errorf("HEAD moved from %s to %s", head, after)
+	}
+	status, statusErr := second.Status()
+	if statusErr != nil {
+		t.Fatal(statusErr)
+	}
+	if status.Dirty || status.Ahead != 1 || status.Behind != 1 {
+		t.Errorf("after the aborted pull: %+v", status)
+	}
+}
+
+func TestSyncWithoutUpstream(t *testing.T) {
+	first, _ := setupRepos(t)
+	mustGit(t, first.Root(), "checkout", "-q", "-b", "local")
+
+	if err := first.Push(); !errors.Is(err, ErrNoUpstream) {
+		t.Errorf("push error = %v, want ErrNoUpstream", err)
+	}
+
+	edit(t, first, "host: web1.example.com", "host: web1.example.org")
+	result, err := first.Sync("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Committed || result.Pulled || result.Pushed || result.Message != "Update connection web1\n\n- Update connection web1 (host)" {
+		t.Errorf("result = %+v", result)
+	}
+}
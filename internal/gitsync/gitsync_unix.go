@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package gitsync
+
+import (
+	"os/exec"
+)
+
+// hideConsoleWindow is a no-op on non-Windows platforms
+func hideConsoleWindow(cmd *exec.Cmd) {
+	// Nothing to do on Unix-like systems
+}
@@ -0,0 +1,173 @@
+package gui
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// customFieldsEditor holds the widgets editing a connection's custom fields
+type customFieldsEditor struct {
+	schema   []*models.CustomFieldSchema
+	original models.CustomFields
+	inputs   map[string]fyne.Widget // Declared field name -> input widget
+	other    *widget.Entry          // Undeclared fields, one "name = value" per line
+}
+
+// newCustomFieldsEditor creates inputs for every declared custom field plus a
+// free-form entry for undeclared ones
+func (w *MainWindow) newCustomFieldsEditor(fields models.CustomFields) *customFieldsEditor {
+	e := &customFieldsEditor{
+		schema:   w.manager.GetConfig().CustomFieldSchema,
+		original: fields,
+		inputs:   make(map[string]fyne.Widget),
+	}
+
+	declared := make(map[string]bool)
+	for _, schema := range e.schema {
+		declared[schema.Name] = true
+		switch schema.FieldType() {
+		case models.FieldTypeBool:
+			check := widget.NewCheck("", nil)
+			check.SetChecked(fields[schema.Name] == true)
+			e.inputs[schema.Name] = check
+		case models.FieldTypeSecret:
+			entry := widget.NewPasswordEntry()
+			entry.SetText(fields.String(schema.Name))
+			e.inputs[schema.Name] = entry
+		default:
+			entry := widget.NewEntry()
+			entry.SetPlaceHolder(string(schema.FieldType()))
+			if schema.Description != "" {
+				entry.SetPlaceHolder(schema.Description)
+			}
+			entry.SetText(fields.String(schema.Name))
+			e.inputs[schema.Name] = entry
+		}
+	}
+
+	var lines []string
+	for _, name := range fields.Keys() {
+		if !declared[name] {
+			lines = append(lines, name+" = "+fields.String(name))
+		}
+	}
+	e.other = widget.NewMultiLineEntry()
+	e.other.SetPlaceHolder("name = value (one per line)")
+	e.other.SetText(strings.Join(lines, "\n"))
+
+	return e
+}
+
+// FormItems returns the form rows for the editor
+func (e *customFieldsEditor) FormItems() []*widget.FormItem {
+	var items []*widget.FormItem
+	for _, schema := range e.schema {
+		item := widget.NewFormItem(schema.DisplayLabel(), e.inputs[schema.Name])
+		if schema.Required {
+			item.Text += " *"
+		}
+		items = append(items, item)
+	}
+	items = append(items, widget.NewFormItem("Custom Fields", e.other))
+	return items
+}
+
+// Fields returns the edited custom fields
+func (e *customFieldsEditor) Fields() (models.CustomFields, error) {
+	fields := make(models.CustomFields)
+
+	for _, schema := range e.schema {
+		switch input := e.inputs[schema.Name].(type) {
+		case *widget.Check:
+			// Only store false if the field was set before
+			if _, exists := e.original[schema.Name]; input.Checked || exists {
+				fields[schema.Name] = input.Checked
+			}
+		case *widget.Entry:
+			if strings.TrimSpace(input.Text) == "" {
+				continue
+			}
+			value, err := models.ParseFieldValue(schema.FieldType(), input.Text)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", schema.DisplayLabel(), err)
+			}
+			fields[schema.Name] = value
+		}
+	}
+
+	for _, line := range strings.Split(e.other.Text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name, value, found := strings.Cut(line, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid custom field line '%s' (expected name = value)", line)
+		}
+		// Keep the original type of values that weren't changed
+		if original, exists := e.original[name]; exists && fmt.Sprint(original) == value {
+			fields[name] = original
+		} else {
+			fields[name] = value
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// addCustomFieldDetails adds a connection's custom fields to the details panel
+func (w *MainWindow) addCustomFieldDetails(details *fyne.Container, conn *models.Connection) {
+	if len(conn.CustomFields) == 0 {
+		return
+	}
+
+	cfg := w.manager.GetConfig()
+	details.Add(widget.NewLabel(""))
+
+	// Declared fields first, in schema order
+	var names []string
+	shown := make(map[string]bool)
+	for _, schema := range cfg.CustomFieldSchema {
+		if _, exists := conn.CustomFields[schema.Name]; exists {
+			names = append(names, schema.Name)
+			shown[schema.Name] = true
+		}
+	}
+	for _, name := range conn.CustomFields.Keys() {
+		if !shown[name] {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		label := name
+		fieldType := models.FieldTypeString
+		if schema := cfg.FindCustomField(name); schema != nil {
+			label = schema.DisplayLabel()
+			fieldType = schema.FieldType()
+		}
+
+		value := conn.CustomFields.String(name)
+		switch fieldType {
+		case models.FieldTypeSecret:
+			details.Add(widget.NewLabel(label + ": ••••••••"))
+		case models.FieldTypeURL:
+			if link, err := url.Parse(value); err == nil {
+				details.Add(container.NewHBox(widget.NewLabel(label+":"), widget.NewHyperlink(value, link)))
+				continue
+			}
+			details.Add(widget.NewLabel(label + ": " + value))
+		default:
+			details.Add(widget.NewLabel(label + ": " + value))
+		}
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"time"
 
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+	"github.com/jaydenthorup/mremotego/internal/launcher"
 	"github.com/jaydenthorup/mremotego/pkg/models"
 )
 
@@ -33,6 +35,28 @@ func (w *MainWindow) collectAllFolders(connections []*models.Connection, prefix
 	}
 }
 
+// collectJumpHosts recursively collects the paths of the SSH connections
+// other than exclude, the connections that can be jump hosts
+func (w *MainWindow) collectJumpHosts(connections []*models.Connection, prefix string, exclude *models.Connection, paths *[]string) {
+	for _, conn := range connections {
+		if conn.IsFolder() {
+			w.collectJumpHosts(conn.Children, prefix+conn.Name+"/", exclude, paths)
+		} else if conn.Protocol == models.ProtocolSSH && conn != exclude {
+			*paths = append(*paths, prefix+conn.Name)
+		}
+	}
+}
+
+// newJumpHostEntry creates an input for a jump host that offers the SSH
+// connections and also takes IDs, names and variables
+func (w *MainWindow) newJumpHostEntry(exclude *models.Connection) *widget.SelectEntry {
+	var paths []string
+	w.collectJumpHosts(w.manager.GetConfig().Connections, "", exclude, &paths)
+	entry := widget.NewSelectEntry(paths)
+	entry.SetPlaceHolder("SSH connection to go through (optional)")
+	return entry
+}
+
 // findConnectionParent recursively finds the parent folder and path of a connection
 func (w *MainWindow) findConnectionParent(conn *models.Connection, connections []*models.Connection, prefix string) (string, *models.Connection) {
 	for _, c := range connections {
@@ -97,7 +121,7 @@ func (w *MainWindow) showAddConnectionDialog() {
 	nameEntry := widget.NewEntry()
 	nameEntry.SetPlaceHolder("Connection Name")
 
-	protocolSelect := widget.NewSelect([]string{"ssh", "rdp", "vnc", "http", "https", "telnet"}, nil)
+	protocolSelect := widget.NewSelect(launcher.ProtocolNames(), nil)
 	protocolSelect.SetSelected("ssh")
 
 	hostEntry := widget.NewEntry()
@@ -118,6 +142,11 @@ func (w *MainWindow) showAddConnectionDialog() {
 	descriptionEntry := widget.NewMultiLineEntry()
 	descriptionEntry.SetPlaceHolder("Description")
 
+	jumpHostEntry := w.newJumpHostEntry(nil)
+
+	sshOptions := newSSHOptionsEditor()
+	protocolSelect.OnChanged = sshOptions.ShowFor
+
 	// Folder selection - recursively collect all folders
 	folderNames := []string{"(Root)"}
 	folderMap := make(map[string]*models.Connection)
@@ -141,8 +170,41 @@ func (w *MainWindow) showAddConnectionDialog() {
 		}
 	}
 
+	// Template selection pre-fills the form with the template's values
+	var selectedTemplate *models.Template
+	templateNames := []string{"(None)"}
+	for _, t := range w.manager.GetConfig().Templates {
+		templateNames = append(templateNames, t.Name)
+	}
+	templateSelect := widget.NewSelect(templateNames, func(name string) {
+		selectedTemplate, _ = w.manager.GetConfig().FindTemplate(name)
+		if selectedTemplate == nil {
+			return
+		}
+		if selectedTemplate.Protocol != "" {
+			protocolSelect.SetSelected(string(selectedTemplate.Protocol))
+		}
+		hostEntry.SetText(selectedTemplate.Host)
+		portEntry.SetText("")
+		if selectedTemplate.Port != 0 {
+			portEntry.SetText(strconv.Itoa(selectedTemplate.Port))
+		}
+		usernameEntry.SetText(selectedTemplate.Username)
+		passwordEntry.SetText(selectedTemplate.Password)
+		domainEntry.SetText(selectedTemplate.Domain)
+		descriptionEntry.SetText(selectedTemplate.Description)
+		jumpHostEntry.SetText(selectedTemplate.JumpHost)
+		sshOptions.Load(selectedTemplate.Instantiate(""))
+	})
+	templateSelect.SetSelected("(None)")
+
+	var formItems []*widget.FormItem
+	if len(templateNames) > 1 {
+		formItems = append(formItems, widget.NewFormItem("Template", templateSelect))
+	}
+
 	form := &widget.Form{
-		Items: []*widget.FormItem{
+		Items: append(append(formItems, []*widget.FormItem{
 			{Text: "Name", Widget: nameEntry},
 			{Text: "Protocol", Widget: protocolSelect},
 			{Text: "Host", Widget: hostEntry},
@@ -150,18 +212,28 @@ func (w *MainWindow) showAddConnectionDialog() {
 			{Text: "Username", Widget: usernameEntry},
 			{Text: "Password", Widget: passwordEntry},
 			{Text: "Domain", Widget: domainEntry},
+			{Text: "Jump Host", Widget: jumpHostEntry},
 			{Text: "Description", Widget: descriptionEntry},
 			{Text: "Folder", Widget: folderSelect},
 			{Text: "", Widget: storeTo1PasswordCheck},
 			{Text: "Vault", Widget: vaultSelect},
-		},
+		}...), sshOptions.FormItems()...),
 		OnSubmit: func() {
-			conn := models.NewConnection(nameEntry.Text, models.Protocol(protocolSelect.Selected))
+			var conn *models.Connection
+			if selectedTemplate != nil {
+				// Keeps the template's tags and advanced settings
+				conn = selectedTemplate.Instantiate(nameEntry.Text)
+				conn.Protocol = models.Protocol(protocolSelect.Selected)
+			} else {
+				conn = models.NewConnection(nameEntry.Text, models.Protocol(protocolSelect.Selected))
+			}
 			conn.Host = hostEntry.Text
 			conn.Username = usernameEntry.Text
 			conn.Password = passwordEntry.Text
 			conn.Domain = domainEntry.Text
 			conn.Description = descriptionEntry.Text
+			conn.JumpHost = strings.TrimSpace(jumpHostEntry.Text)
+			sshOptions.Apply(conn)
 			conn.Created = time.Now().Format(time.RFC3339)
 			conn.Modified = conn.Created
 
@@ -172,6 +244,7 @@ func (w *MainWindow) showAddConnectionDialog() {
 			} else {
 				conn.Port = conn.Protocol.GetDefaultPort()
 			}
+			conn.ExpandPlaceholders(w.manager.GetConfig().IsSecretField)
 
 			// If user wants to store in 1Password, create the item
 			if storeTo1PasswordCheck.Checked && conn.Password != "" && !w.manager.IsOnePasswordReference(conn.Password) {
@@ -227,13 +300,20 @@ func (w *MainWindow) showAddFolderDialog() {
 	folderSelect := widget.NewSelect(folderNames, nil)
 	folderSelect.SetSelected("(Root)")
 
+	descriptionEntry := widget.NewMultiLineEntry()
+	descriptionEntry.SetPlaceHolder("Description")
+
 	form := &widget.Form{
 		Items: []*widget.FormItem{
 			{Text: "Name", Widget: nameEntry},
 			{Text: "Parent Folder", Widget: folderSelect},
+			{Text: "Description", Widget: descriptionEntry},
 		},
 		OnSubmit: func() {
 			folder := models.NewFolder(nameEntry.Text)
+			folder.Description = descriptionEntry.Text
+			folder.Created = time.Now().Format(time.RFC3339)
+			folder.Modified = folder.Created
 
 			// Add to selected folder or root
 			selectedFolder := folderSelect.Selected
@@ -265,7 +345,7 @@ func (w *MainWindow) showEditConnectionDialog(conn *models.Connection) {
 	nameEntry := widget.NewEntry()
 	nameEntry.SetText(conn.Name)
 
-	protocolSelect := widget.NewSelect([]string{"ssh", "rdp", "vnc", "http", "https", "telnet"}, nil)
+	protocolSelect := widget.NewSelect(launcher.ProtocolNames(), nil)
 	protocolSelect.SetSelected(string(conn.Protocol))
 
 	hostEntry := widget.NewEntry()
@@ -286,13 +366,18 @@ func (w *MainWindow) showEditConnectionDialog(conn *models.Connection) {
 	descriptionEntry := widget.NewMultiLineEntry()
 	descriptionEntry.SetText(conn.Description)
 
+	jumpHostEntry := w.newJumpHostEntry(conn)
+	jumpHostEntry.SetText(conn.JumpHost)
+
+	sshOptions := newSSHOptionsEditor()
+	sshOptions.Load(conn)
+	sshOptions.ShowFor(string(conn.Protocol))
+	protocolSelect.OnChanged = sshOptions.ShowFor
+
 	// Folder selection - find current parent folder using recursive search
-	currentFolder := "(Root)"
-	var parentFolder *models.Connection
-	currentFolder, parentFolder = w.findConnectionParent(conn, w.manager.GetConfig().Connections, "")
+	currentFolder, _ := w.findConnectionParent(conn, w.manager.GetConfig().Connections, "")
 	if currentFolder == "" {
 		currentFolder = "(Root)"
-		parentFolder = nil
 	}
 
 	folderNames := []string{"(Root)"}
@@ -317,8 +402,10 @@ func (w *MainWindow) showEditConnectionDialog(conn *models.Connection) {
 		}
 	}
 
+	customFields := w.newCustomFieldsEditor(conn.CustomFields)
+
 	form := &widget.Form{
-		Items: []*widget.FormItem{
+		Items: append([]*widget.FormItem{
 			{Text: "Name", Widget: nameEntry},
 			{Text: "Protocol", Widget: protocolSelect},
 			{Text: "Host", Widget: hostEntry},
@@ -326,12 +413,19 @@ func (w *MainWindow) showEditConnectionDialog(conn *models.Connection) {
 			{Text: "Username", Widget: usernameEntry},
 			{Text: "Password", Widget: passwordEntry},
 			{Text: "Domain", Widget: domainEntry},
+			{Text: "Jump Host", Widget: jumpHostEntry},
 			{Text: "Description", Widget: descriptionEntry},
 			{Text: "Folder", Widget: folderSelect},
 			{Text: "", Widget: storeTo1PasswordCheck},
 			{Text: "Vault", Widget: vaultSelect},
-		},
+		}, append(sshOptions.FormItems(), customFields.FormItems()...)...),
 		OnSubmit: func() {
+			fields, err := customFields.Fields()
+			if err != nil {
+				dialog.ShowError(err, w.window)
+				return
+			}
+
 			// If user wants to push password to 1Password
 			if storeTo1PasswordCheck.Checked && passwordEntry.Text != "" && !w.manager.IsOnePasswordReference(passwordEntry.Text) {
 				vault := vaultSelect.Selected
@@ -353,6 +447,9 @@ func (w *MainWindow) showEditConnectionDialog(conn *models.Connection) {
 			conn.Username = usernameEntry.Text
 			conn.Domain = domainEntry.Text
 			conn.Description = descriptionEntry.Text
+			conn.JumpHost = strings.TrimSpace(jumpHostEntry.Text)
+			sshOptions.Apply(conn)
+			conn.CustomFields = fields
 			conn.Modified = time.Now().Format(time.RFC3339)
 
 			if port, err := strconv.Atoi(portEntry.Text); err == nil {
@@ -360,35 +457,10 @@ func (w *MainWindow) showEditConnectionDialog(conn *models.Connection) {
 			}
 
 			// Handle folder change
-			selectedFolder := folderSelect.Selected
-			if selectedFolder != currentFolder {
-				// Remove from old parent
-				if parentFolder != nil {
-					// Remove from parent's children
-					for i, child := range parentFolder.Children {
-						if child == conn {
-							parentFolder.Children = append(parentFolder.Children[:i], parentFolder.Children[i+1:]...)
-							break
-						}
-					}
-				} else {
-					// Remove from root
-					for i, c := range w.manager.GetConfig().Connections {
-						if c == conn {
-							w.manager.GetConfig().Connections = append(w.manager.GetConfig().Connections[:i], w.manager.GetConfig().Connections[i+1:]...)
-							break
-						}
-					}
-				}
-
-				// Add to new parent
-				if selectedFolder == "(Root)" {
-					w.manager.GetConfig().Connections = append(w.manager.GetConfig().Connections, conn)
-				} else {
-					newParent := folderMap[selectedFolder]
-					if newParent != nil {
-						newParent.Children = append(newParent.Children, conn)
-					}
+			if folderSelect.Selected != currentFolder {
+				if err := w.manager.MoveNode(conn, folderMap[folderSelect.Selected], -1); err != nil {
+					dialog.ShowError(err, w.window)
+					return
 				}
 			}
 
@@ -403,7 +475,7 @@ func (w *MainWindow) showEditConnectionDialog(conn *models.Connection) {
 		},
 	}
 
-	d := dialog.NewCustom("Edit Connection", "Close", form, w.window)
+	d := dialog.NewCustom("Edit Connection", "Close", container.NewVScroll(form), w.window)
 	d.Resize(fyne.NewSize(500, 700))
 	d.Show()
 }
@@ -413,12 +485,34 @@ func (w *MainWindow) showEditFolderDialog(folder *models.Connection) {
 	nameEntry := widget.NewEntry()
 	nameEntry.SetText(folder.Name)
 
+	descriptionEntry := widget.NewMultiLineEntry()
+	descriptionEntry.SetText(folder.Description)
+
+	tagsEntry := widget.NewEntry()
+	tagsEntry.SetText(strings.Join(folder.Tags, ", "))
+	tagsEntry.SetPlaceHolder("comma-separated")
+
+	notesEntry := widget.NewMultiLineEntry()
+	notesEntry.SetText(folder.Notes)
+
 	form := &widget.Form{
 		Items: []*widget.FormItem{
 			{Text: "Name", Widget: nameEntry},
+			{Text: "Description", Widget: descriptionEntry},
+			{Text: "Tags", Widget: tagsEntry},
+			{Text: "Notes", Widget: notesEntry},
 		},
 		OnSubmit: func() {
 			folder.Name = nameEntry.Text
+			folder.Description = descriptionEntry.Text
+			folder.Notes = notesEntry.Text
+			folder.Tags = nil
+			for _, tag := range strings.Split(tagsEntry.Text, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					folder.Tags = append(folder.Tags, tag)
+				}
+			}
+			folder.Modified = time.Now().Format(time.RFC3339)
 
 			if err := w.manager.Save(); err != nil {
 				dialog.ShowError(err, w.window)
@@ -430,5 +524,7 @@ func (w *MainWindow) showEditFolderDialog(folder *models.Connection) {
 		},
 	}
 
-	dialog.NewCustom("Edit Folder", "Close", form, w.window).Show()
+	d := dialog.NewCustom("Edit Folder", "Close", form, w.window)
+	d.Resize(fyne.NewSize(450, 400))
+	d.Show()
 }
@@ -7,11 +7,14 @@ import (
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/jaydenthorup/mremotego/internal/config"
 	"github.com/jaydenthorup/mremotego/internal/launcher"
+	"github.com/jaydenthorup/mremotego/internal/query"
+	"github.com/jaydenthorup/mremotego/internal/tunnel"
 	"github.com/jaydenthorup/mremotego/pkg/models"
 )
 
@@ -31,6 +34,20 @@ type MainWindow struct {
 	statusLabel    *widget.Label
 	activeSessions *widget.List
 	sessionList    []*models.Connection
+
+	connectionPaths map[string]string // folder path -> tree node ID
+	problems        []config.Diagnostic
+	problemsList    *widget.List
+	problemsTitle   *widget.Label
+	problemsPanel   *fyne.Container
+
+	profileSelect *widget.Select
+
+	tunnels      *tunnel.Manager // Tunnels started from the tunnels window
+	tunnelWindow fyne.Window
+	tunnelRows   []*tunnelRow
+
+	dropTarget string // tree ID of the row under the pointer while a row is dragged
 }
 
 // NewMainWindow creates a new main window
@@ -41,8 +58,12 @@ func NewMainWindow(app fyne.App, manager *config.Manager) *MainWindow {
 		manager:        manager,
 		launcher:       launcher.NewLauncher(),
 		connectionData: make(map[string]*models.Connection),
+		tunnels:        tunnel.NewManager(),
 	}
 
+	// Tunnels started here don't outlive the app
+	app.Lifecycle().SetOnStopped(w.tunnels.StopAll)
+
 	w.setupUI()
 	w.setupKeyboardShortcuts()
 	return w
@@ -74,13 +95,16 @@ func (w *MainWindow) setupUI() {
 	// Create details panel
 	detailsContainer := w.createDetailsPanel()
 
-	// Left panel with search and tree
+	// Create problems panel (hidden until validation finds something)
+	problemsPanel := w.createProblemsPanel()
+
+	// Left panel with search, tree and problems
 	leftPanel := container.NewBorder(
-		searchBar, // top
-		nil,       // bottom
-		nil,       // left
-		nil,       // right
-		w.tree,    // center
+		searchBar,     // top
+		problemsPanel, // bottom
+		nil,           // left
+		nil,           // right
+		w.tree,        // center
 	)
 
 	// Create split container
@@ -94,6 +118,9 @@ func (w *MainWindow) setupUI() {
 	w.statusLabel = widget.NewLabel("Ready")
 	statusBar := container.NewHBox(
 		w.statusLabel,
+		layout.NewSpacer(),
+		widget.NewLabel("Profile:"),
+		w.createProfileSelect(),
 	)
 
 	// Main content with toolbar and status bar
@@ -128,23 +155,30 @@ func (w *MainWindow) setupMenuBar() {
 
 	connectMenu := fyne.NewMenu("Connection",
 		fyne.NewMenuItem("Connect", func() { w.connectToSelected() }),
+		fyne.NewMenuItem("Copy Launch Command", func() { w.copySelectedLaunchCommand() }),
 		fyne.NewMenuItemSeparator(),
 		fyne.NewMenuItem("Edit", func() { w.editSelected() }),
 		fyne.NewMenuItem("Delete", func() { w.deleteSelected() }),
 	)
 
+	viewMenu := fyne.NewMenu("View",
+		fyne.NewMenuItem("Refresh", func() { w.refreshTree() }),
+		fyne.NewMenuItem("Problems", func() { w.showProblems() }),
+		fyne.NewMenuItem("Tunnels", func() { w.showTunnels() }),
+	)
+
 	helpMenu := fyne.NewMenu("Help",
 		fyne.NewMenuItem("About", func() { w.showAbout() }),
 	)
 
-	mainMenu := fyne.NewMainMenu(fileMenu, connectMenu, helpMenu)
+	mainMenu := fyne.NewMainMenu(fileMenu, connectMenu, viewMenu, w.createSyncMenu(), helpMenu)
 	w.window.SetMainMenu(mainMenu)
 }
 
 // createSearchBar creates the search bar
 func (w *MainWindow) createSearchBar() *fyne.Container {
 	w.searchEntry = widget.NewEntry()
-	w.searchEntry.SetPlaceHolder("Search connections...")
+	w.searchEntry.SetPlaceHolder("Search connections... (e.g. tag:prod protocol:rdp -tag:decom)")
 
 	w.searchEntry.OnChanged = func(query string) {
 		w.filterConnections(query)
@@ -197,7 +231,7 @@ func (w *MainWindow) createConnectionTree() *widget.Tree {
 			if uid == "" {
 				// Root level
 				// If filtering, show only filtered connections
-				if len(w.filteredIDs) > 0 {
+				if w.filteredIDs != nil {
 					return w.filteredIDs
 				}
 
@@ -236,7 +270,9 @@ func (w *MainWindow) createConnectionTree() *widget.Tree {
 		},
 		// Create
 		func(branch bool) fyne.CanvasObject {
-			return widget.NewLabel("Template")
+			item := w.newTreeItem()
+			item.SetText("Template")
+			return item
 		},
 		// Update
 		func(uid string, branch bool, obj fyne.CanvasObject) {
@@ -245,9 +281,10 @@ func (w *MainWindow) createConnectionTree() *widget.Tree {
 				return
 			}
 
-			label := obj.(*widget.Label)
+			item := obj.(*treeItem)
+			item.uid = uid
 			icon := w.getConnectionIcon(conn)
-			label.SetText(icon + " " + conn.Name)
+			item.SetText(icon + " " + conn.Name)
 		},
 	)
 
@@ -261,27 +298,29 @@ func (w *MainWindow) createConnectionTree() *widget.Tree {
 	return tree
 }
 
-// filterConnections filters the connection list based on search query
-func (w *MainWindow) filterConnections(query string) {
-	if query == "" {
+// filterConnections filters the connection list with a query such as
+// "tag:prod protocol:rdp"; see query.Help for the syntax
+func (w *MainWindow) filterConnections(expr string) {
+	if strings.TrimSpace(expr) == "" {
 		w.filteredIDs = nil
 		w.tree.Refresh()
 		w.updateStatus()
 		return
 	}
 
-	query = strings.ToLower(query)
-	w.filteredIDs = []string{}
+	q, err := query.Parse(expr)
+	if err != nil {
+		// Keep the previous results while the query is being typed
+		w.statusLabel.SetText("Invalid filter: " + err.Error())
+		return
+	}
+	cfg := w.manager.GetConfig()
+	q.IsSecret = cfg.IsSecretField
 
-	for id, conn := range w.connectionData {
-		if !conn.IsFolder() {
-			if strings.Contains(strings.ToLower(conn.Name), query) ||
-				strings.Contains(strings.ToLower(conn.Host), query) ||
-				strings.Contains(strings.ToLower(conn.Username), query) ||
-				strings.Contains(strings.ToLower(string(conn.Protocol)), query) ||
-				strings.Contains(strings.ToLower(conn.Description), query) {
-				w.filteredIDs = append(w.filteredIDs, id)
-			}
+	w.filteredIDs = []string{}
+	for _, result := range q.Filter(cfg.Connections) {
+		if id := w.getConnectionID(result.Connection); id != "" {
+			w.filteredIDs = append(w.filteredIDs, id)
 		}
 	}
 
@@ -289,12 +328,52 @@ func (w *MainWindow) filterConnections(query string) {
 	w.updateStatus()
 }
 
+// noProfile is the profile selector entry for using connections as stored
+const noProfile = "(None)"
+
+// createProfileSelect creates the selector for the active environment profile
+func (w *MainWindow) createProfileSelect() *widget.Select {
+	w.profileSelect = widget.NewSelect(nil, func(name string) {
+		if name == noProfile {
+			name = ""
+		}
+		if err := w.manager.SetProfile(name); err != nil {
+			dialog.ShowError(err, w.window)
+			return
+		}
+		if w.selectedConn != nil {
+			w.updateDetailsPanel(w.selectedConn)
+		}
+		w.updateStatus()
+	})
+	w.refreshProfiles()
+	return w.profileSelect
+}
+
+// refreshProfiles reloads the profile selector's options from the config
+func (w *MainWindow) refreshProfiles() {
+	options := append([]string{noProfile}, w.manager.GetConfig().ProfileNames()...)
+	w.profileSelect.Options = options
+
+	current := w.manager.GetProfile()
+	if current == "" || w.manager.SetProfile(current) != nil {
+		// The profile no longer exists
+		w.manager.SetProfile("")
+		current = noProfile
+	}
+	w.profileSelect.SetSelected(current)
+	w.profileSelect.Refresh()
+}
+
 // updateStatus updates the status bar with current information
 func (w *MainWindow) updateStatus() {
 	totalConns := len(w.manager.ListConnections())
 	configPath := w.manager.GetConfigPath()
+	if profile := w.manager.GetProfile(); profile != "" {
+		configPath += " | profile: " + profile
+	}
 
-	if len(w.filteredIDs) > 0 {
+	if w.filteredIDs != nil {
 		w.statusLabel.SetText(fmt.Sprintf("Showing %d of %d connections | %s", len(w.filteredIDs), totalConns, configPath))
 	} else {
 		w.statusLabel.SetText(fmt.Sprintf("%d connections | %s", totalConns, configPath))
@@ -317,7 +396,23 @@ func (w *MainWindow) updateDetailsPanel(conn *models.Connection) {
 		childCount := len(conn.Children)
 		w.detailsCard.SetTitle("📁 " + conn.Name)
 		w.detailsCard.SetSubTitle("Folder")
-		w.detailsCard.SetContent(widget.NewLabel(fmt.Sprintf("Contains %d item(s)", childCount)))
+		details := container.NewVBox(widget.NewLabel(fmt.Sprintf("Contains %d item(s)", childCount)))
+		if conn.Description != "" {
+			details.Add(widget.NewLabel(""))
+			details.Add(widget.NewLabel("Description:"))
+			details.Add(widget.NewLabel(conn.Description))
+		}
+		if len(conn.Tags) > 0 {
+			details.Add(widget.NewLabel(""))
+			details.Add(widget.NewLabel("Tags: " + strings.Join(conn.Tags, ", ")))
+		}
+		if conn.Notes != "" {
+			details.Add(widget.NewLabel(""))
+			details.Add(widget.NewLabel("Notes:"))
+			details.Add(widget.NewLabel(conn.Notes))
+		}
+		w.addCustomFieldDetails(details, conn)
+		w.detailsCard.SetContent(details)
 		return
 	}
 
@@ -325,19 +420,38 @@ func (w *MainWindow) updateDetailsPanel(conn *models.Connection) {
 	w.detailsCard.SetTitle(icon + " " + conn.Name)
 	w.detailsCard.SetSubTitle(string(conn.Protocol))
 
+	// Show the values the active profile connects to
+	effective := conn
+	if profile := w.manager.GetProfile(); profile != "" {
+		effective = conn.DeepCopy()
+		effective.ApplyProfile(profile)
+	}
+
 	details := container.NewVBox(
-		widget.NewLabel("Host: "+conn.Host),
-		widget.NewLabel(fmt.Sprintf("Port: %d", conn.Port)),
+		widget.NewLabel("Host: "+effective.Host),
+		widget.NewLabel(fmt.Sprintf("Port: %d", effective.Port)),
 	)
 
-	if conn.Username != "" {
-		details.Add(widget.NewLabel("Username: " + conn.Username))
+	if effective.Username != "" {
+		details.Add(widget.NewLabel("Username: " + effective.Username))
 	}
 
 	if conn.Domain != "" {
 		details.Add(widget.NewLabel("Domain: " + conn.Domain))
 	}
 
+	if conn.JumpHost != "" {
+		details.Add(widget.NewLabel("Jump Host: " + conn.JumpHost))
+	}
+
+	if len(conn.Tunnels) > 0 {
+		names := make([]string, len(conn.Tunnels))
+		for i, t := range conn.Tunnels {
+			names[i] = t.Name
+		}
+		details.Add(widget.NewLabel("Tunnels: " + strings.Join(names, ", ") + " (View > Tunnels)"))
+	}
+
 	if conn.Description != "" {
 		details.Add(widget.NewLabel(""))
 		details.Add(widget.NewLabel("Description:"))
@@ -356,6 +470,8 @@ func (w *MainWindow) updateDetailsPanel(conn *models.Connection) {
 		details.Add(widget.NewLabel(tags))
 	}
 
+	w.addCustomFieldDetails(details, conn)
+
 	// Add action buttons
 	details.Add(widget.NewLabel(""))
 	connectBtn := widget.NewButton("🚀 Connect", func() {
@@ -381,20 +497,37 @@ func (w *MainWindow) updateDetailsPanel(conn *models.Connection) {
 // Helper functions
 func (w *MainWindow) buildConnectionMap() {
 	w.connectionData = make(map[string]*models.Connection)
-	w.buildConnectionMapRecursive(w.manager.GetConfig().Connections, "")
+	w.connectionPaths = make(map[string]string)
+	w.buildConnectionMapRecursive(w.manager.GetConfig().Connections, "", "")
 }
 
-func (w *MainWindow) buildConnectionMapRecursive(connections []*models.Connection, prefix string) {
+func (w *MainWindow) buildConnectionMapRecursive(connections []*models.Connection, prefix, pathPrefix string) {
 	for i, conn := range connections {
 		id := fmt.Sprintf("%s%d", prefix, i)
 		w.connectionData[id] = conn
 
+		path := pathPrefix + conn.Name
+		if _, exists := w.connectionPaths[path]; !exists {
+			w.connectionPaths[path] = id
+		}
+
 		if conn.IsFolder() {
-			w.buildConnectionMapRecursive(conn.Children, id+"_")
+			w.buildConnectionMapRecursive(conn.Children, id+"_", path+"/")
 		}
 	}
 }
 
+// revealNode opens every folder above a tree node, then scrolls to and selects it
+func (w *MainWindow) revealNode(uid string) {
+	for i, ch := range uid {
+		if ch == '_' {
+			w.tree.OpenBranch(uid[:i])
+		}
+	}
+	w.tree.ScrollTo(uid)
+	w.tree.Select(uid)
+}
+
 func (w *MainWindow) getConnectionID(conn *models.Connection) string {
 	for id, c := range w.connectionData {
 		if c == conn {
@@ -440,13 +573,55 @@ func (w *MainWindow) connectToSelected() {
 	w.connectToConnection(w.selectedConn)
 }
 
+// connectToConnection launches a connection in the background, since waiting
+// for the tunnel through a jump host can take a while
 func (w *MainWindow) connectToConnection(conn *models.Connection) {
-	if err := w.launcher.Launch(conn); err != nil {
-		dialog.ShowError(fmt.Errorf("Failed to launch connection: %w", err), w.window)
+	resolved, err := w.manager.ResolveConnection(conn, nil)
+	if err != nil {
+		dialog.ShowError(err, w.window)
 		return
 	}
 
-	dialog.ShowInformation("Connected", fmt.Sprintf("Launched connection to %s", conn.Name), w.window)
+	w.statusLabel.SetText(fmt.Sprintf("Connecting to '%s'...", conn.Name))
+	go func() {
+		err := w.launcher.Launch(resolved)
+		fyne.Do(func() {
+			w.updateStatus()
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("Failed to launch connection: %w", err), w.window)
+				return
+			}
+			dialog.ShowInformation("Connected", fmt.Sprintf("Launched connection to %s", conn.Name), w.window)
+		})
+	}()
+}
+
+// copySelectedLaunchCommand copies the launch command of the selected connection
+func (w *MainWindow) copySelectedLaunchCommand() {
+	if w.selectedConn == nil || w.selectedConn.IsFolder() {
+		dialog.ShowInformation("No Selection", "Please select a connection first", w.window)
+		return
+	}
+	w.copyLaunchCommand(w.selectedConn)
+}
+
+// copyLaunchCommand copies the command that would launch a connection to the
+// clipboard, with the password redacted
+func (w *MainWindow) copyLaunchCommand(conn *models.Connection) {
+	resolved, err := w.manager.ResolveConnection(conn, nil)
+	if err != nil {
+		dialog.ShowError(err, w.window)
+		return
+	}
+
+	command, err := w.launcher.LaunchCommand(resolved, false)
+	if err != nil {
+		dialog.ShowError(err, w.window)
+		return
+	}
+
+	w.app.Clipboard().SetContent(command)
+	w.statusLabel.SetText(fmt.Sprintf("Copied launch command for '%s'", conn.Name))
 }
 
 func (w *MainWindow) editSelected() {
@@ -499,14 +674,17 @@ func (w *MainWindow) deleteSelected() {
 func (w *MainWindow) refreshTree() {
 	if err := w.manager.Load(); err != nil {
 		dialog.ShowError(err, w.window)
+		w.validateConfig()
 		return
 	}
 
 	w.buildConnectionMap()
 	w.tree.Refresh()
+	w.refreshProfiles()
 	w.selectedConn = nil
 	w.detailsCard.SetContent(widget.NewLabel("Select a connection to view details"))
 	w.updateStatus()
+	w.validateConfig()
 }
 
 func (w *MainWindow) openConfig() {
@@ -557,6 +735,11 @@ func (w *MainWindow) showAbout() {
 func (w *MainWindow) Show() {
 	w.window.Show()
 
+	// Report a settings file that can't be used; launches use the defaults
+	if err := w.launcher.SettingsError(); err != nil {
+		dialog.ShowError(fmt.Errorf("%w\n\nConnections will use the default terminal and clients.", err), w.window)
+	}
+
 	// Check 1Password CLI authentication status after window is shown
 	w.check1PasswordAuth()
 }
@@ -604,5 +787,7 @@ func (w *MainWindow) check1PasswordAuth() {
 func (w *MainWindow) Reload() {
 	w.buildConnectionMap()
 	w.tree.Refresh()
+	w.refreshProfiles()
 	w.updateStatus()
+	w.validateConfig()
 }
@@ -0,0 +1,101 @@
+package gui
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/jaydenthorup/mremotego/internal/config"
+)
+
+// createProblemsPanel creates the panel listing validation problems in the config file
+func (w *MainWindow) createProblemsPanel() *fyne.Container {
+	w.problemsList = widget.NewList(
+		func() int {
+			return len(w.problems)
+		},
+		func() fyne.CanvasObject {
+			return container.NewHBox(widget.NewIcon(theme.ErrorIcon()), widget.NewLabel("Template"))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(w.problems) {
+				return
+			}
+			d := w.problems[id]
+			row := obj.(*fyne.Container)
+
+			icon := row.Objects[0].(*widget.Icon)
+			if d.Severity == config.SeverityError {
+				icon.SetResource(theme.ErrorIcon())
+			} else {
+				icon.SetResource(theme.WarningIcon())
+			}
+
+			text := fmt.Sprintf("Line %d: %s", d.Line, d.Message)
+			if d.Path != "" {
+				text = fmt.Sprintf("Line %d: %s: %s", d.Line, d.Path, d.Message)
+			}
+			row.Objects[1].(*widget.Label).SetText(text)
+		},
+	)
+
+	w.problemsList.OnSelected = func(id widget.ListItemID) {
+		if id >= len(w.problems) {
+			return
+		}
+		// Jump to the connection the problem refers to
+		if uid, exists := w.connectionPaths[w.problems[id].Path]; exists {
+			w.revealNode(uid)
+		}
+		w.problemsList.Unselect(id)
+	}
+
+	w.problemsTitle = widget.NewLabelWithStyle("Problems", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	closeBtn := widget.NewButtonWithIcon("", theme.CancelIcon(), func() {
+		w.problemsPanel.Hide()
+	})
+
+	header := container.NewBorder(nil, nil, nil, closeBtn, w.problemsTitle)
+	scroll := container.NewVScroll(w.problemsList)
+	scroll.SetMinSize(fyne.NewSize(0, 120))
+
+	w.problemsPanel = container.NewBorder(header, nil, nil, nil, scroll)
+	w.problemsPanel.Hide()
+	return w.problemsPanel
+}
+
+// validateConfig re-checks the config file and shows the problems panel if anything was found
+func (w *MainWindow) validateConfig() {
+	diagnostics, err := w.manager.Validate()
+	if err != nil {
+		// Nothing on disk yet (e.g. a brand new config)
+		w.problems = nil
+	} else {
+		w.problems = diagnostics
+	}
+
+	errors := 0
+	for _, d := range w.problems {
+		if d.Severity == config.SeverityError {
+			errors++
+		}
+	}
+	w.problemsTitle.SetText(fmt.Sprintf("Problems (%d errors, %d warnings)", errors, len(w.problems)-errors))
+	w.problemsList.Refresh()
+
+	if len(w.problems) > 0 {
+		w.problemsPanel.Show()
+	} else {
+		w.problemsPanel.Hide()
+	}
+}
+
+// showProblems validates the config on demand and reports when it is clean
+func (w *MainWindow) showProblems() {
+	w.validateConfig()
+	if len(w.problems) == 0 {
+		w.statusLabel.SetText("No problems found | " + w.manager.GetConfigPath())
+	}
+}
@@ -0,0 +1,116 @@
+package gui
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2/widget"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// sshOptionsEditor holds the widgets editing a connection's SSH options
+type sshOptionsEditor struct {
+	identityFile    *widget.Entry
+	certificateFile *widget.Entry
+	forwardAgent    *widget.Check
+	identitiesOnly  *widget.Check
+	preferredAuth   *widget.Entry
+	localForwards   *widget.Entry // One forward per line
+	remoteForwards  *widget.Entry
+	dynamicForwards *widget.Entry
+	remoteCommand   *widget.Entry
+	requestTTY      *widget.Select
+
+	section *widget.Accordion
+}
+
+// newSSHOptionsEditor creates inputs for the SSH options, collapsed into one
+// section of the connection form
+func newSSHOptionsEditor() *sshOptionsEditor {
+	e := &sshOptionsEditor{
+		identityFile:    widget.NewEntry(),
+		certificateFile: widget.NewEntry(),
+		forwardAgent:    widget.NewCheck("Forward the SSH agent", nil),
+		identitiesOnly:  widget.NewCheck("Only offer this key", nil),
+		preferredAuth:   widget.NewEntry(),
+		localForwards:   widget.NewMultiLineEntry(),
+		remoteForwards:  widget.NewMultiLineEntry(),
+		dynamicForwards: widget.NewMultiLineEntry(),
+		remoteCommand:   widget.NewEntry(),
+		requestTTY:      widget.NewSelect(append([]string{""}, models.RequestTTYValues...), nil),
+	}
+	e.identityFile.SetPlaceHolder("~/.ssh/id_ed25519 (.ppk for PuTTY)")
+	e.certificateFile.SetPlaceHolder("~/.ssh/id_ed25519-cert.pub")
+	e.preferredAuth.SetPlaceHolder("publickey,password")
+	e.localForwards.SetPlaceHolder("8080:localhost:80 (one per line)")
+	e.remoteForwards.SetPlaceHolder("9000:localhost:9000 (one per line)")
+	e.dynamicForwards.SetPlaceHolder("1080 (one per line)")
+	e.remoteCommand.SetPlaceHolder("run instead of the login shell")
+
+	options := widget.NewForm(
+		widget.NewFormItem("Identity File", e.identityFile),
+		widget.NewFormItem("Certificate", e.certificateFile),
+		widget.NewFormItem("", e.identitiesOnly),
+		widget.NewFormItem("", e.forwardAgent),
+		widget.NewFormItem("Auth Methods", e.preferredAuth),
+		widget.NewFormItem("Local Forwards", e.localForwards),
+		widget.NewFormItem("Remote Forwards", e.remoteForwards),
+		widget.NewFormItem("SOCKS Ports", e.dynamicForwards),
+		widget.NewFormItem("Remote Command", e.remoteCommand),
+		widget.NewFormItem("Request TTY", e.requestTTY),
+	)
+	e.section = widget.NewAccordion(widget.NewAccordionItem("SSH Options", options))
+	return e
+}
+
+// FormItems returns the form rows for the editor
+func (e *sshOptionsEditor) FormItems() []*widget.FormItem {
+	return []*widget.FormItem{widget.NewFormItem("", e.section)}
+}
+
+// ShowFor shows the options only for SSH connections
+func (e *sshOptionsEditor) ShowFor(protocol string) {
+	if models.Protocol(protocol) == models.ProtocolSSH {
+		e.section.Show()
+	} else {
+		e.section.Hide()
+	}
+}
+
+// Load fills the inputs from a connection
+func (e *sshOptionsEditor) Load(conn *models.Connection) {
+	e.identityFile.SetText(conn.IdentityFile)
+	e.certificateFile.SetText(conn.CertificateFile)
+	e.forwardAgent.SetChecked(conn.ForwardAgent)
+	e.identitiesOnly.SetChecked(conn.IdentitiesOnly)
+	e.preferredAuth.SetText(conn.PreferredAuth)
+	e.localForwards.SetText(strings.Join(conn.LocalForwards, "\n"))
+	e.remoteForwards.SetText(strings.Join(conn.RemoteForwards, "\n"))
+	e.dynamicForwards.SetText(strings.Join(conn.DynamicForwards, "\n"))
+	e.remoteCommand.SetText(conn.RemoteCommand)
+	e.requestTTY.SetSelected(conn.RequestTTY)
+}
+
+// Apply stores the inputs in a connection
+func (e *sshOptionsEditor) Apply(conn *models.Connection) {
+	conn.IdentityFile = strings.TrimSpace(e.identityFile.Text)
+	conn.CertificateFile = strings.TrimSpace(e.certificateFile.Text)
+	conn.ForwardAgent = e.forwardAgent.Checked
+	conn.IdentitiesOnly = e.identitiesOnly.Checked
+	conn.PreferredAuth = strings.TrimSpace(e.preferredAuth.Text)
+	conn.LocalForwards = splitLines(e.localForwards.Text)
+	conn.RemoteForwards = splitLines(e.remoteForwards.Text)
+	conn.DynamicForwards = splitLines(e.dynamicForwards.Text)
+	conn.RemoteCommand = strings.TrimSpace(e.remoteCommand.Text)
+	conn.RequestTTY = e.requestTTY.Selected
+}
+
+// splitLines returns the non-blank lines of a text, trimmed
+func splitLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
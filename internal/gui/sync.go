@@ -0,0 +1,190 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/jaydenthorup/mremotego/internal/gitsync"
+)
+
+// createSyncMenu creates the Sync menu for git operations on the config file
+func (w *MainWindow) createSyncMenu() *fyne.Menu {
+	return fyne.NewMenu("Sync",
+		fyne.NewMenuItem("Sync Now", func() { w.syncNow() }),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Status...", func() { w.showSyncStatus() }),
+		fyne.NewMenuItem("Commit...", func() { w.showSyncCommitDialog() }),
+		fyne.NewMenuItem("Pull", func() { w.syncPull() }),
+		fyne.NewMenuItem("Push", func() { w.syncPush() }),
+	)
+}
+
+// openSyncRepo opens the git repository holding the config file, explaining
+// to the user if there is none
+func (w *MainWindow) openSyncRepo() *gitsync.Repo {
+	repo, err := gitsync.Open(w.manager.GetConfigPath())
+	if errors.Is(err, gitsync.ErrNotRepository) {
+		dialog.ShowInformation("Not a Git Repository",
+			fmt.Sprintf("The config file is not in a git working tree:\n%s\n\nRun 'git init' in its directory to enable sync.", w.manager.GetConfigPath()),
+			w.window)
+		return nil
+	}
+	if err != nil {
+		dialog.ShowError(err, w.window)
+		return nil
+	}
+	return repo
+}
+
+// runSyncTask runs a slow git operation in the background, showing progress
+// in the status bar, then calls done on the UI thread
+func (w *MainWindow) runSyncTask(status string, task func() error, done func()) {
+	w.statusLabel.SetText(status)
+	go func() {
+		err := task()
+		fyne.Do(func() {
+			w.updateStatus()
+			if err != nil {
+				dialog.ShowError(err, w.window)
+				return
+			}
+			done()
+		})
+	}()
+}
+
+// showSyncStatus shows the branch state and uncommitted connection changes
+func (w *MainWindow) showSyncStatus() {
+	repo := w.openSyncRepo()
+	if repo == nil {
+		return
+	}
+
+	status, err := repo.Status()
+	if err != nil {
+		dialog.ShowError(err, w.window)
+		return
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Repository: %s\nFile: %s\n", repo.Root(), repo.File())
+	if status.Upstream != "" {
+		fmt.Fprintf(&text, "Branch: %s → %s (ahead %d, behind %d)\n", status.Branch, status.Upstream, status.Ahead, status.Behind)
+	} else {
+		fmt.Fprintf(&text, "Branch: %s (no upstream)\n", status.Branch)
+	}
+
+	if !status.Dirty {
+		text.WriteString("\nNo uncommitted changes")
+	} else {
+		text.WriteString("\n")
+		text.WriteString(gitsync.CommitMessage(status.Changes))
+	}
+
+	label := widget.NewLabel(text.String())
+	label.Wrapping = fyne.TextWrapWord
+	scroll := container.NewVScroll(label)
+	scroll.SetMinSize(fyne.NewSize(500, 300))
+
+	dialog.ShowCustom("Sync Status", "Close", scroll, w.window)
+}
+
+// showSyncCommitDialog commits the config file with an editable, generated message
+func (w *MainWindow) showSyncCommitDialog() {
+	repo := w.openSyncRepo()
+	if repo == nil {
+		return
+	}
+
+	message, err := repo.CommitMessage()
+	if err != nil {
+		dialog.ShowError(err, w.window)
+		return
+	}
+
+	messageEntry := widget.NewMultiLineEntry()
+	messageEntry.SetText(message)
+	messageEntry.SetMinRowsVisible(8)
+
+	dialog.ShowCustomConfirm("Commit Changes", "Commit", "Cancel", messageEntry, func(commit bool) {
+		if !commit {
+			return
+		}
+
+		committed, _, err := repo.Commit(strings.TrimSpace(messageEntry.Text))
+		if err != nil {
+			dialog.ShowError(err, w.window)
+			return
+		}
+		if !committed {
+			dialog.ShowInformation("Commit", "Nothing to commit", w.window)
+			return
+		}
+		w.statusLabel.SetText("Committed | " + w.manager.GetConfigPath())
+	}, w.window)
+}
+
+// syncPull rebases onto the upstream branch and reloads the config
+func (w *MainWindow) syncPull() {
+	repo := w.openSyncRepo()
+	if repo == nil {
+		return
+	}
+
+	w.runSyncTask("Pulling...", repo.Pull, func() {
+		w.refreshTree()
+		dialog.ShowInformation("Pull", "Pulled the latest connections", w.window)
+	})
+}
+
+// syncPush pushes committed changes to the upstream branch
+func (w *MainWindow) syncPush() {
+	repo := w.openSyncRepo()
+	if repo == nil {
+		return
+	}
+
+	w.runSyncTask("Pushing...", repo.Push, func() {
+		dialog.ShowInformation("Push", "Pushed committed changes", w.window)
+	})
+}
+
+// syncNow commits, pulls and pushes in one step
+func (w *MainWindow) syncNow() {
+	repo := w.openSyncRepo()
+	if repo == nil {
+		return
+	}
+
+	var result *gitsync.Result
+	w.runSyncTask("Syncing...", func() error {
+		var err error
+		result, err = repo.Sync("")
+		if result != nil && result.Committed && err != nil {
+			// The commit was made; the pull or push can simply be retried
+			return fmt.Errorf("committed, but %w", err)
+		}
+		return err
+	}, func() {
+		w.refreshTree()
+
+		var summary []string
+		if result.Committed {
+			summary = append(summary, "Committed: "+strings.SplitN(result.Message, "\n", 2)[0])
+		} else {
+			summary = append(summary, "Nothing to commit")
+		}
+		switch {
+		case result.Pushed:
+			summary = append(summary, "Pulled and pushed")
+		case !result.Pulled:
+			summary = append(summary, "No upstream branch; skipped pull and push")
+		}
+		dialog.ShowInformation("Sync", strings.Join(summary, "\n"), w.window)
+	})
+}
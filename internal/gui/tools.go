@@ -0,0 +1,80 @@
+package gui
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// showContextMenu shows the right-click menu of a tree row
+func (w *MainWindow) showContextMenu(conn *models.Connection, pos fyne.Position) {
+	var items []*fyne.MenuItem
+	if !conn.IsFolder() {
+		items = append(items,
+			fyne.NewMenuItem("Connect", func() { w.connectToConnection(conn) }),
+			fyne.NewMenuItem("Copy Launch Command", func() { w.copyLaunchCommand(conn) }),
+		)
+	}
+	items = append(items,
+		fyne.NewMenuItem("Edit", func() { w.editSelected() }),
+		fyne.NewMenuItem("Delete", func() { w.deleteSelected() }),
+	)
+
+	if !conn.IsFolder() {
+		tools := fyne.NewMenuItem("External Tools", nil)
+		tools.ChildMenu = w.createToolsMenu(conn)
+		tools.Disabled = len(tools.ChildMenu.Items) == 0
+		items = append(items, fyne.NewMenuItemSeparator(), tools)
+	}
+
+	widget.ShowPopUpMenuAtPosition(fyne.NewMenu("", items...), w.window.Canvas(), pos)
+}
+
+// createToolsMenu lists the configured external tools for a connection
+func (w *MainWindow) createToolsMenu(conn *models.Connection) *fyne.Menu {
+	menu := fyne.NewMenu("External Tools")
+	for _, tool := range w.manager.GetConfig().ExternalTools {
+		tool := tool
+		menu.Items = append(menu.Items, fyne.NewMenuItem(tool.Name, func() { w.runTool(tool, conn) }))
+	}
+	return menu
+}
+
+// runTool runs an external tool against a connection in the background and
+// shows its output when it's captured
+func (w *MainWindow) runTool(tool *models.ExternalTool, conn *models.Connection) {
+	resolved, err := w.manager.ResolveConnection(conn, nil)
+	if err != nil {
+		dialog.ShowError(err, w.window)
+		return
+	}
+
+	w.statusLabel.SetText(fmt.Sprintf("Running '%s' for '%s'...", tool.Name, conn.Name))
+	go func() {
+		output, err := w.launcher.RunTool(tool, resolved)
+		fyne.Do(func() {
+			w.updateStatus()
+			if output != "" {
+				w.showToolOutput(fmt.Sprintf("%s: %s", tool.Name, conn.Name), output)
+			}
+			if err != nil {
+				dialog.ShowError(err, w.window)
+			}
+		})
+	}()
+}
+
+// showToolOutput shows the captured output of an external tool
+func (w *MainWindow) showToolOutput(title, output string) {
+	label := widget.NewLabel(output)
+	label.TextStyle = fyne.TextStyle{Monospace: true}
+	label.Selectable = true
+	scroll := container.NewScroll(label)
+	scroll.SetMinSize(fyne.NewSize(600, 400))
+
+	dialog.ShowCustom(title, "Close", scroll, w.window)
+}
@@ -0,0 +1,120 @@
+package gui
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// treeItem is the label of a tree row. Rows can be dragged onto a folder to
+// move the entry into it, or onto a connection to place the entry before it.
+// Right-clicking a row opens its context menu.
+type treeItem struct {
+	widget.Label
+	window   *MainWindow
+	uid      string
+	dragging bool
+}
+
+var (
+	_ fyne.Draggable         = (*treeItem)(nil)
+	_ fyne.SecondaryTappable = (*treeItem)(nil)
+	_ desktop.Hoverable      = (*treeItem)(nil)
+)
+
+// newTreeItem creates a row label for the connection tree
+func (w *MainWindow) newTreeItem() *treeItem {
+	item := &treeItem{window: w}
+	item.ExtendBaseWidget(item)
+	return item
+}
+
+// Dragged starts or continues dragging the row
+func (i *treeItem) Dragged(*fyne.DragEvent) {
+	if i.dragging || i.uid == "" {
+		return
+	}
+	i.dragging = true
+	i.window.dropTarget = ""
+	if conn, exists := i.window.connectionData[i.uid]; exists {
+		i.window.statusLabel.SetText(fmt.Sprintf("Moving '%s' - drop it on a folder or connection", conn.Name))
+	}
+}
+
+// DragEnd drops the row on the row under the pointer, if any
+func (i *treeItem) DragEnd() {
+	i.dragging = false
+	target := i.window.dropTarget
+	i.window.dropTarget = ""
+	i.window.updateStatus()
+	if target != "" && target != i.uid {
+		i.window.dropNode(i.uid, target)
+	}
+}
+
+// TappedSecondary selects the row and opens its context menu
+func (i *treeItem) TappedSecondary(ev *fyne.PointEvent) {
+	conn, exists := i.window.connectionData[i.uid]
+	if !exists {
+		return
+	}
+	i.window.tree.Select(i.uid)
+	i.window.showContextMenu(conn, ev.AbsolutePosition)
+}
+
+// MouseIn marks the row as the drop target while another row is dragged
+func (i *treeItem) MouseIn(*desktop.MouseEvent) {
+	i.window.dropTarget = i.uid
+}
+
+// MouseMoved is required by desktop.Hoverable
+func (i *treeItem) MouseMoved(*desktop.MouseEvent) {}
+
+// MouseOut clears the drop target when the pointer leaves the row
+func (i *treeItem) MouseOut() {
+	if i.window.dropTarget == i.uid {
+		i.window.dropTarget = ""
+	}
+}
+
+// dropNode moves the entry with tree ID source onto the entry with tree ID
+// target: into it if it is a folder, otherwise just before it
+func (w *MainWindow) dropNode(source, target string) {
+	node, exists := w.connectionData[source]
+	if !exists {
+		return
+	}
+	onto, exists := w.connectionData[target]
+	if !exists {
+		return
+	}
+
+	var err error
+	if onto.IsFolder() {
+		err = w.manager.MoveNode(node, onto, -1)
+	} else {
+		err = w.manager.PlaceNode(node, onto, false)
+	}
+	if err != nil {
+		dialog.ShowError(err, w.window)
+		return
+	}
+
+	if err := w.manager.Save(); err != nil {
+		dialog.ShowError(err, w.window)
+		return
+	}
+
+	w.refreshTree()
+
+	// Reloading replaced the entries; find the moved one again by its id
+	for uid, conn := range w.connectionData {
+		if node.ID != "" && conn.ID == node.ID {
+			w.revealNode(uid)
+			break
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package gui
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/jaydenthorup/mremotego/internal/tunnel"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// tunnelRow is a tunnel listed in the tunnels window, with its status as of
+// the last refresh
+type tunnelRow struct {
+	conn   *models.Connection
+	path   string
+	spec   *models.Tunnel
+	stats  tunnel.Stats
+	local  bool           // Running in this process
+	record *tunnel.Record // Running in the background, started by 'mremotego tunnel up'
+}
+
+// key returns the key of the row's tunnel
+func (r *tunnelRow) key() string {
+	return tunnel.Key(r.path, r.spec.Name)
+}
+
+// running reports whether the tunnel runs here or in the background
+func (r *tunnelRow) running() bool {
+	return r.local || r.record != nil
+}
+
+// showTunnels opens the tunnels window, which lists the tunnels of every SSH
+// connection with their live status and traffic
+func (w *MainWindow) showTunnels() {
+	if w.tunnelWindow != nil {
+		w.tunnelWindow.RequestFocus()
+		return
+	}
+
+	list := widget.NewList(
+		func() int {
+			return len(w.tunnelRows)
+		},
+		func() fyne.CanvasObject {
+			title := widget.NewLabelWithStyle("Template", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			return container.NewBorder(nil, nil, widget.NewIcon(theme.MediaStopIcon()), widget.NewButton("Start", nil),
+				container.NewVBox(title, widget.NewLabel("Template")))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(w.tunnelRows) {
+				return
+			}
+			row := w.tunnelRows[id]
+			item := obj.(*fyne.Container)
+			text := item.Objects[0].(*fyne.Container)
+			text.Objects[0].(*widget.Label).SetText(fmt.Sprintf("%s: %s  (%s)", row.path, row.spec.Name, row.spec))
+			text.Objects[1].(*widget.Label).SetText(tunnelDetails(row))
+			item.Objects[1].(*widget.Icon).SetResource(tunnelIcon(row.stats.State))
+
+			button := item.Objects[2].(*widget.Button)
+			if row.running() {
+				button.SetText("Stop")
+				button.OnTapped = func() { w.stopTunnel(row) }
+			} else {
+				button.SetText("Start")
+				button.OnTapped = func() { w.startTunnel(row) }
+			}
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		list.Unselect(id)
+	}
+
+	empty := widget.NewLabel("No tunnels defined. Add a 'tunnels' list to an SSH connection in the config file.")
+	empty.Wrapping = fyne.TextWrapWord
+	note := widget.NewLabel("Tunnels started here stop when MremoteGO exits; those started with 'mremotego tunnel up' keep running.")
+	note.Wrapping = fyne.TextWrapWord
+	stopAllBtn := widget.NewButton("Stop All", func() {
+		for _, row := range w.tunnelRows {
+			if row.running() {
+				w.stopTunnel(row)
+			}
+		}
+	})
+
+	window := w.app.NewWindow("Tunnels")
+	window.SetContent(container.NewBorder(nil, container.NewBorder(nil, nil, nil, stopAllBtn, note), nil, nil,
+		container.NewStack(list, empty)))
+	window.Resize(fyne.NewSize(800, 400))
+
+	refresh := func() {
+		w.refreshTunnelRows()
+		if len(w.tunnelRows) == 0 {
+			empty.Show()
+		} else {
+			empty.Hide()
+		}
+		list.Refresh()
+	}
+	refresh()
+
+	// Refresh the status and counters every second while the window is open
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				fyne.Do(refresh)
+			}
+		}
+	}()
+	window.SetOnClosed(func() {
+		close(stop)
+		w.tunnelWindow = nil
+	})
+
+	w.tunnelWindow = window
+	window.Show()
+}
+
+// refreshTunnelRows lists the tunnels of every SSH connection with their
+// status in this process or in the background
+func (w *MainWindow) refreshTunnelRows() {
+	var records []*tunnel.Record
+	if dir, err := tunnel.StateDir(); err == nil {
+		records, _ = tunnel.Records(dir)
+	}
+	configPath, _ := filepath.Abs(w.manager.GetConfigPath())
+
+	var rows []*tunnelRow
+	for _, conn := range w.manager.ListConnections() {
+		if conn.Protocol != models.ProtocolSSH {
+			continue
+		}
+		path := w.manager.NodePath(conn)
+		for _, spec := range conn.Tunnels {
+			row := &tunnelRow{conn: conn, path: path, spec: spec, stats: tunnel.Stats{State: tunnel.StateStopped}}
+			if stats, running := w.tunnels.Stats(row.key()); running {
+				row.stats, row.local = stats, true
+			} else if r := tunnel.FindRecord(records, configPath, row.key()); r != nil {
+				row.stats, row.record = r.Stats, r
+			}
+			rows = append(rows, row)
+		}
+	}
+	w.tunnelRows = rows
+}
+
+// startTunnel starts a tunnel in this process; looking up passwords in
+// 1Password can take a while, so it runs in the background
+func (w *MainWindow) startTunnel(row *tunnelRow) {
+	go func() {
+		err := func() error {
+			resolved, err := w.manager.ResolveConnection(row.conn, nil)
+			if err != nil {
+				return err
+			}
+			if err := w.manager.ResolvePasswords(resolved); err != nil {
+				return err
+			}
+			spec, err := resolved.FindTunnel(row.spec.Name)
+			if err != nil {
+				return err
+			}
+			return w.tunnels.Start(row.key(), resolved, spec)
+		}()
+		if err != nil {
+			fyne.Do(func() {
+				dialog.ShowError(fmt.Errorf("failed to start tunnel '%s': %w", row.spec.Name, err), w.tunnelParent())
+			})
+		}
+	}()
+}
+
+// stopTunnel stops a tunnel running here or in the background
+func (w *MainWindow) stopTunnel(row *tunnelRow) {
+	go func() {
+		var err error
+		if row.local {
+			w.tunnels.Stop(row.key())
+		} else if row.record != nil {
+			var dir string
+			if dir, err = tunnel.StateDir(); err == nil {
+				err = tunnel.StopBackground(dir, row.record)
+			}
+		}
+		if err != nil {
+			fyne.Do(func() {
+				dialog.ShowError(err, w.tunnelParent())
+			})
+		}
+	}()
+}
+
+// tunnelParent returns the window to show tunnel errors over: the tunnels
+// window, or the main window once it is closed
+func (w *MainWindow) tunnelParent() fyne.Window {
+	if w.tunnelWindow != nil {
+		return w.tunnelWindow
+	}
+	return w.window
+}
+
+// tunnelDetails describes a tunnel's status and traffic
+func tunnelDetails(row *tunnelRow) string {
+	if !row.running() {
+		if row.spec.Description != "" {
+			return "Stopped - " + row.spec.Description
+		}
+		return "Stopped"
+	}
+
+	stats := row.stats
+	var status string
+	switch stats.State {
+	case tunnel.StateUp:
+		status = fmt.Sprintf("Up for %s", time.Since(stats.Since).Round(time.Second))
+	case tunnel.StateRetrying:
+		wait := time.Until(stats.RetryAt).Round(time.Second)
+		if wait < 0 {
+			wait = 0
+		}
+		status = fmt.Sprintf("Retrying in %s: %s", wait, stats.Error)
+	default:
+		status = "Connecting"
+	}
+	if row.record != nil {
+		status += fmt.Sprintf(" (background, pid %d)", row.record.PID)
+	}
+	return fmt.Sprintf("%s | %d connection(s) | sent %s, received %s", status, stats.Connections,
+		tunnel.FormatBytes(stats.Sent), tunnel.FormatBytes(stats.Received))
+}
+
+// tunnelIcon returns the icon for a tunnel state
+func tunnelIcon(state tunnel.State) fyne.Resource {
+	switch state {
+	case tunnel.StateUp:
+		return theme.ConfirmIcon()
+	case tunnel.StateRetrying:
+		return theme.WarningIcon()
+	case tunnel.StateConnecting:
+		return theme.ViewRefreshIcon()
+	}
+	return theme.MediaStopIcon()
+}
@@ -0,0 +1,142 @@
+package launcher
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// LaunchCommand returns the command Launch would run for a connection, as a
+// shell command line, without running it. The password is redacted unless
+// reveal is set; 1Password references are only resolved when revealed.
+func (l *Launcher) LaunchCommand(conn *models.Connection, reveal bool) (string, error) {
+	if conn.IsFolder() {
+		return "", fmt.Errorf("cannot launch a folder")
+	}
+
+	handler, err := HandlerFor(conn.Protocol)
+	if err != nil {
+		return "", err
+	}
+
+	resolvedConn := *conn
+	if reveal {
+		if err := l.resolvePassword(&resolvedConn); err != nil {
+			return "", err
+		}
+	} else if resolvedConn.Password != "" {
+		// Build with a placeholder so the secret can't leak in any form, and
+		// 1Password references aren't looked up
+		resolvedConn.Password = models.Redacted
+	}
+
+	if err := handler.Validate(&resolvedConn); err != nil {
+		return "", err
+	}
+
+	// The tunnel through the jump hosts runs in the background first
+	text := ""
+	buildConn := &resolvedConn
+	if tunnels(handler, &resolvedConn) {
+		tunneledConn, tunnel, err := l.throughTunnel(handler, &resolvedConn)
+		if err != nil {
+			return "", err
+		}
+		text = FormatCommand(tunnel) + " &\n"
+		buildConn = tunneledConn
+	}
+
+	cmd, err := handler.BuildCommand(l, buildConn)
+	if err != nil {
+		return "", err
+	}
+
+	text += FormatCommand(cmd)
+	if cmd.Err != nil {
+		// Usually a client that isn't installed
+		text += "\n# " + cmd.Err.Error()
+	}
+	return text, nil
+}
+
+// FormatCommand renders a command as a shell command line, preceded by the
+// environment variables it sets on top of the inherited environment, with
+// every occurrence of the given secrets replaced by ********
+func FormatCommand(cmd *exec.Cmd, secrets ...string) string {
+	var parts []string
+	for _, env := range addedEnv(cmd) {
+		name, value, _ := strings.Cut(env, "=")
+		parts = append(parts, name+"="+shellQuote(redact(value, secrets)))
+	}
+
+	args := append([]string{cmd.Path}, cmd.Args[1:]...)
+	for i, arg := range args {
+		args[i] = redact(arg, secrets)
+	}
+	return strings.Join(append(parts, shellJoin(args)), " ")
+}
+
+// addedEnv returns the variables a command sets that differ from the current
+// environment; a nil Env inherits it unchanged
+func addedEnv(cmd *exec.Cmd) []string {
+	if cmd.Env == nil {
+		return nil
+	}
+
+	inherited := make(map[string]bool)
+	for _, env := range os.Environ() {
+		inherited[env] = true
+	}
+
+	var added []string
+	for _, env := range cmd.Env {
+		if !inherited[env] {
+			added = append(added, env)
+		}
+	}
+	return added
+}
+
+// redact replaces secrets in an argument, including their quoted forms inside
+// the shell and AppleScript wrappers built by launchInTerminal
+func redact(text string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		shellQuoted := strings.ReplaceAll(secret, "'", `'\''`)
+		forms := []string{
+			secret,
+			shellQuoted,
+			strings.ReplaceAll(shellQuoted, `"`, `\"`),
+		}
+		for _, form := range forms {
+			text = strings.ReplaceAll(text, form, models.Redacted)
+		}
+	}
+	return text
+}
+
+// shellJoin quotes and joins a command line for a POSIX shell
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// safeShellWord matches arguments that need no quoting
+var safeShellWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes an argument for a POSIX shell when needed
+func shellQuote(arg string) string {
+	if safeShellWord.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
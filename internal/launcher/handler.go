@@ -0,0 +1,104 @@
+package launcher
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// Handler launches connections of one protocol. Handlers are registered with
+// Register; the registry decides the choices offered by the CLI and the GUI.
+// The built-in protocols and their default ports are known to the models
+// package without it; handlers for other protocols are added to them.
+type Handler interface {
+	// Protocol returns the protocol the handler launches
+	Protocol() models.Protocol
+
+	// DefaultPort returns the port used when a connection doesn't set one
+	DefaultPort() int
+
+	// Validate reports settings that prevent the connection from launching
+	Validate(conn *models.Connection) error
+
+	// BuildCommand returns the command that opens the connection, without running it
+	BuildCommand(l *Launcher, conn *models.Connection) (*exec.Cmd, error)
+
+	// Launch opens the connection
+	Launch(l *Launcher, conn *models.Connection) error
+}
+
+// handlers holds the registered handlers by protocol; protocols keeps their order
+var (
+	handlers  = make(map[models.Protocol]Handler)
+	protocols []models.Protocol
+)
+
+// Register adds a protocol handler, replacing an earlier handler for the same
+// protocol, and adds its protocol and default port to those known to the
+// models package
+func Register(h Handler) {
+	protocol := h.Protocol()
+	if _, exists := handlers[protocol]; !exists {
+		protocols = append(protocols, protocol)
+	}
+	handlers[protocol] = h
+	models.RegisterProtocol(protocol, h.DefaultPort())
+}
+
+// HandlerFor returns the handler registered for a protocol
+func HandlerFor(protocol models.Protocol) (Handler, error) {
+	h, exists := handlers[protocol]
+	if !exists {
+		return nil, fmt.Errorf("unsupported protocol: %s", protocol)
+	}
+	return h, nil
+}
+
+// Protocols lists the registered protocols in registration order
+func Protocols() []models.Protocol {
+	return append([]models.Protocol(nil), protocols...)
+}
+
+// ProtocolNames lists the registered protocols as strings, e.g. for a dropdown
+func ProtocolNames() []string {
+	names := make([]string, len(protocols))
+	for i, protocol := range protocols {
+		names[i] = string(protocol)
+	}
+	return names
+}
+
+// ProtocolList returns the registered protocols as a comma-separated list, for help texts
+func ProtocolList() string {
+	return strings.Join(ProtocolNames(), ", ")
+}
+
+// startCommand builds a handler's command and starts it
+func startCommand(l *Launcher, h Handler, conn *models.Connection) error {
+	cmd, err := h.BuildCommand(l, conn)
+	if err != nil {
+		return err
+	}
+	return l.start(cmd)
+}
+
+// portOrDefault returns the connection's port, or the handler's default port
+func portOrDefault(conn *models.Connection, h Handler) int {
+	if conn.Port == 0 {
+		return h.DefaultPort()
+	}
+	return conn.Port
+}
+
+// validateAddress checks the host and port every protocol needs
+func validateAddress(conn *models.Connection) error {
+	if strings.TrimSpace(conn.Host) == "" {
+		return fmt.Errorf("'%s' has no host", conn.Name)
+	}
+	if conn.Port < 0 || conn.Port > 65535 {
+		return fmt.Errorf("invalid port %d for '%s'", conn.Port, conn.Name)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package launcher
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// tunnelIdle is how long, in seconds, a jump host tunnel waits for the client
+// to connect; it then stays open until the client disconnects
+const tunnelIdle = "30"
+
+// tunnelTimeout limits how long a launch waits for a jump host tunnel to open
+const tunnelTimeout = 20 * time.Second
+
+// sshDestination returns the [user@]host of a connection
+func sshDestination(conn *models.Connection) string {
+	if conn.Username != "" {
+		return conn.Username + "@" + conn.Host
+	}
+	return conn.Host
+}
+
+// bracketHost wraps IPv6 addresses in brackets for host:port notation
+func bracketHost(host string) string {
+	if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// hopAuthArgs returns the ssh options that authenticate to a jump host
+func hopAuthArgs(hop *models.Connection) []string {
+	var args []string
+	if hop.IdentityFile != "" {
+		args = append(args, "-i", hop.IdentityFile)
+	}
+	if hop.CertificateFile != "" {
+		args = append(args, "-o", "CertificateFile="+hop.CertificateFile)
+	}
+	if hop.IdentitiesOnly {
+		args = append(args, "-o", "IdentitiesOnly=yes")
+	}
+	if hop.PreferredAuth != "" {
+		args = append(args, "-o", "PreferredAuthentications="+hop.PreferredAuth)
+	}
+	return args
+}
+
+// sshJumpArgs returns the ssh options that go through a chain of jump hosts:
+// -J when the hops need nothing but an address, a ProxyCommand that carries
+// each hop's keys otherwise
+func sshJumpArgs(jumps []*models.Connection) []string {
+	if len(jumps) == 0 {
+		return nil
+	}
+
+	var hops []string
+	for _, hop := range jumps {
+		if len(hopAuthArgs(hop)) > 0 {
+			return []string{"-o", "ProxyCommand=" + proxyCommand(jumps)}
+		}
+		address := bracketHost(hop.Host) + ":" + strconv.Itoa(portOrDefault(hop, sshHandler{}))
+		if hop.Username != "" {
+			address = hop.Username + "@" + address
+		}
+		hops = append(hops, address)
+	}
+	return []string{"-J", strings.Join(hops, ",")}
+}
+
+// proxyCommand returns an ssh command that connects to %h:%p through a chain
+// of jump hosts, each hop going through the ones before it
+func proxyCommand(jumps []*models.Connection) string {
+	command := ""
+	for _, hop := range jumps {
+		args := []string{"ssh", "-p", strconv.Itoa(portOrDefault(hop, sshHandler{})), "-o", "StrictHostKeyChecking=accept-new"}
+		args = append(args, hopAuthArgs(hop)...)
+		if command != "" {
+			// The inner %h and %p are for this hop's ssh, not the outer one
+			args = append(args, "-o", "ProxyCommand="+strings.ReplaceAll(command, "%", "%%"))
+		}
+		args = append(args, "-W", "%h:%p", sshDestination(hop))
+		command = shellJoin(args)
+	}
+	return command
+}
+
+// puttyProxyCommand returns a plink command for PuTTY's -proxycmd that
+// connects to %host:%port through a chain of jump hosts
+func (l *Launcher) puttyProxyCommand(plink string, jumps []*models.Connection) string {
+	command := ""
+	for _, hop := range jumps {
+		args := []string{plink, "-ssh", "-batch", "-P", strconv.Itoa(portOrDefault(hop, sshHandler{}))}
+		if hop.Username != "" {
+			args = append(args, "-l", hop.Username)
+		}
+		if hop.IdentityFile != "" {
+			args = append(args, "-i", l.expandHome(hop.IdentityFile))
+		}
+		if command != "" {
+			args = append(args, "-proxycmd", strings.ReplaceAll(command, "%", "%%"))
+		}
+		args = append(args, "-nc", "%host:%port", hop.Host)
+		command = windowsJoin(args)
+	}
+	return command
+}
+
+// windowsJoin joins a command line for Windows programs, quoting arguments
+// with spaces or quotes
+func windowsJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"") {
+			arg = `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// tunnelCommand returns the ssh command that forwards localPort to the
+// connection's host and port through its jump hosts. The tunnel closes once
+// the client disconnects, or after tunnelIdle seconds if it never connects.
+func tunnelCommand(l *Launcher, conn *models.Connection, port, localPort int) *exec.Cmd {
+	last := conn.Jumps[len(conn.Jumps)-1]
+	args := []string{
+		"-p", strconv.Itoa(portOrDefault(last, sshHandler{})),
+		"-o", "StrictHostKeyChecking=accept-new",
+		"-o", "ExitOnForwardFailure=yes",
+		// Nobody can answer prompts in the background
+		"-o", "BatchMode=yes",
+	}
+	args = append(args, hopAuthArgs(last)...)
+	args = append(args, sshJumpArgs(conn.Jumps[:len(conn.Jumps)-1])...)
+	args = append(args, "-L", fmt.Sprintf("127.0.0.1:%d:%s:%d", localPort, bracketHost(conn.Host), port))
+	args = append(args, sshDestination(last), "sleep", tunnelIdle)
+	return l.runner.Command("ssh", args...)
+}
+
+// tunnels reports whether a connection goes through an SSH tunnel: when it
+// has jump hosts and isn't an SSH connection, which uses them directly
+func tunnels(h Handler, conn *models.Connection) bool {
+	return len(conn.Jumps) > 0 && h.Protocol() != models.ProtocolSSH
+}
+
+// throughTunnel returns a copy of a non-SSH connection that reaches its host
+// through an SSH tunnel over its jump hosts, and the command that opens the
+// tunnel, using a free local port
+func (l *Launcher) throughTunnel(h Handler, conn *models.Connection) (*models.Connection, *exec.Cmd, error) {
+	localPort, err := l.freePort()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find a port for the jump host tunnel: %w", err)
+	}
+
+	tunneled := *conn
+	tunneled.Host = "127.0.0.1"
+	tunneled.Port = localPort
+	tunneled.Jumps = nil
+	return &tunneled, tunnelCommand(l, conn, portOrDefault(conn, h), localPort), nil
+}
+
+// openTunnel starts a jump host tunnel and waits until it accepts
+// connections; a tunnel that doesn't is closed again
+func (l *Launcher) openTunnel(tunnel *exec.Cmd, localPort int) error {
+	if err := l.runner.Start(tunnel); err != nil {
+		return fmt.Errorf("failed to start the jump host tunnel: %w", err)
+	}
+	if err := l.waitForPort(tunnel, localPort); err != nil {
+		closeTunnel(tunnel)
+		return fmt.Errorf("jump host tunnel failed: %w (jump hosts must accept keys or the SSH agent)", err)
+	}
+	return nil
+}
+
+// closeTunnel stops a started jump host tunnel, e.g. when the client it was
+// opened for fails to launch
+func closeTunnel(tunnel *exec.Cmd) {
+	if tunnel.Process != nil {
+		_ = tunnel.Process.Kill()
+	}
+}
+
+// freeLocalPort returns a TCP port on the loopback interface nothing listens on
+func freeLocalPort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// waitForLocalPort waits until a started command listens on a local port,
+// failing if the command exits first
+func waitForLocalPort(cmd *exec.Cmd, port int) error {
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	address := "127.0.0.1:" + strconv.Itoa(port)
+	deadline := time.Now().Add(tunnelTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = fmt.Errorf("ssh exited")
+			}
+			return err
+		default:
+		}
+		if c, err := net.DialTimeout("tcp", address, time.Second); err == nil {
+			c.Close()
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("no answer on port %d after %s", port, tunnelTimeout)
+}
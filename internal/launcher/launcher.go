@@ -6,177 +6,182 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
-	"strconv"
 	"strings"
 
+	"github.com/jaydenthorup/mremotego/internal/runner"
 	"github.com/jaydenthorup/mremotego/internal/secrets"
+	"github.com/jaydenthorup/mremotego/internal/settings"
 	"github.com/jaydenthorup/mremotego/pkg/models"
 )
 
 // Launcher handles launching connections
 type Launcher struct {
 	onePasswordProvider *secrets.OnePasswordProvider
+	runner              runner.CommandRunner
+	settings            *settings.Settings
+	settingsErr         error // Why the settings file couldn't be used, if it couldn't
+
+	// goos and getenv describe the platform; tests replace them
+	goos   string
+	getenv func(string) string
+
+	// target overrides the session target of the settings
+	target string
+
+	// attached makes commands that run in the current terminal block until they exit
+	attached bool
+
+	// freePort and waitForPort find a port for a jump host tunnel and wait
+	// for the tunnel to listen on it; tests replace them
+	freePort    func() (int, error)
+	waitForPort func(tunnel *exec.Cmd, port int) error
 }
 
-// NewLauncher creates a new launcher
+// NewLauncher creates a new launcher that honors the settings file
 func NewLauncher() *Launcher {
+	l := NewLauncherWithRunner(runner.System{})
+	s, err := settings.LoadDefault()
+	if err != nil {
+		// Launching with the built-in programs beats not launching at all
+		fmt.Fprintf(os.Stderr, "Warning: %v (using default settings)\n", err)
+		l.settingsErr = err
+		return l
+	}
+	l.settings = s
+	return l
+}
+
+// NewLauncherWithRunner creates a launcher with the default settings that runs
+// every command, including the 1Password CLI, through r
+func NewLauncherWithRunner(r runner.CommandRunner) *Launcher {
 	return &Launcher{
-		onePasswordProvider: secrets.NewOnePasswordProvider(),
+		onePasswordProvider: secrets.NewOnePasswordProviderWithRunner(r),
+		runner:              r,
+		settings:            &settings.Settings{},
+		goos:                runtime.GOOS,
+		getenv:              os.Getenv,
+		freePort:            freeLocalPort,
+		waitForPort:         waitForLocalPort,
 	}
 }
 
+// NewAttachedLauncher creates a launcher for the terminal UI: when no terminal
+// emulator is available and a command runs in the current terminal, Launch
+// waits for it to exit so the UI can take the terminal back afterwards
+func NewAttachedLauncher() *Launcher {
+	l := NewLauncher()
+	l.attached = true
+	return l
+}
+
 // GetOnePasswordProvider returns the 1Password provider for checking authentication status
 func (l *Launcher) GetOnePasswordProvider() *secrets.OnePasswordProvider {
 	return l.onePasswordProvider
 }
 
-// Launch launches a connection based on its protocol
+// Launch launches a connection with the handler registered for its protocol
 func (l *Launcher) Launch(conn *models.Connection) error {
 	if conn.IsFolder() {
 		return fmt.Errorf("cannot launch a folder")
 	}
 
+	handler, err := HandlerFor(conn.Protocol)
+	if err != nil {
+		return err
+	}
+
 	// Resolve 1Password reference if needed (make a copy to avoid modifying the original)
 	resolvedConn := *conn
-	if l.onePasswordProvider.IsReference(conn.Password) {
-		resolved, err := l.onePasswordProvider.ResolveSecret(conn.Password)
-		if err != nil {
-			// For RDP, we can continue without a password (will prompt)
-			// For other protocols that require a password, return the error
-			if conn.Protocol != models.ProtocolRDP {
-				return fmt.Errorf("failed to resolve password from 1Password: %w", err)
-			}
-			// RDP: Clear the password so it doesn't try to use the op:// reference
-			fmt.Printf("Warning: Failed to resolve password from 1Password: %v (RDP will prompt for credentials)\n", err)
-			resolvedConn.Password = ""
-		} else {
-			resolvedConn.Password = resolved
-		}
+	if err := l.resolvePassword(&resolvedConn); err != nil {
+		return err
 	}
 
-	switch resolvedConn.Protocol {
-	case models.ProtocolSSH:
-		return l.launchSSH(&resolvedConn)
-	case models.ProtocolRDP:
-		return l.launchRDP(&resolvedConn)
-	case models.ProtocolVNC:
-		return l.launchVNC(&resolvedConn)
-	case models.ProtocolHTTP, models.ProtocolHTTPS:
-		return l.launchHTTP(&resolvedConn)
-	case models.ProtocolTelnet:
-		return l.launchTelnet(&resolvedConn)
-	default:
-		return fmt.Errorf("unsupported protocol: %s", resolvedConn.Protocol)
+	if err := handler.Validate(&resolvedConn); err != nil {
+		return err
 	}
-}
-
-// launchSSH launches an SSH connection using PuTTY on Windows, ssh otherwise
-func (l *Launcher) launchSSH(conn *models.Connection) error {
-	port := conn.Port
-	if port == 0 {
-		port = models.ProtocolSSH.GetDefaultPort()
+	if !tunnels(handler, &resolvedConn) {
+		return handler.Launch(l, &resolvedConn)
 	}
 
-	if runtime.GOOS == "windows" {
-		// Use PuTTY on Windows
-		args := []string{}
-
-		// Add SSH protocol
-		args = append(args, "-ssh")
-
-		// Add port
-		args = append(args, "-P", strconv.Itoa(port))
-
-		// Add username if provided
-		if conn.Username != "" {
-			args = append(args, "-l", conn.Username)
-		}
-
-		// Add password if provided (for auto-login)
-		if conn.Password != "" {
-			args = append(args, "-pw", conn.Password)
-		}
-
-		// Add extra args if provided
-		if conn.ExtraArgs != "" {
-			args = append(args, conn.ExtraArgs)
-		}
-
-		// Add hostname last
-		args = append(args, conn.Host)
-
-		// Try putty.exe first, fall back to ssh if not found
-		cmd := exec.Command("putty.exe", args...)
-		// Don't hide putty - it's a GUI application we want to see
-		if err := cmd.Start(); err != nil {
-			// Fall back to ssh command
-			return l.launchSSHFallback(conn)
-		}
-		return nil
+	// Other protocols reach the host through a port forward over the jump hosts
+	tunneledConn, tunnel, err := l.throughTunnel(handler, &resolvedConn)
+	if err != nil {
+		return err
 	}
-
-	// Use ssh on Linux/Mac
-	return l.launchSSHFallback(conn)
+	if err := l.openTunnel(tunnel, tunneledConn.Port); err != nil {
+		return err
+	}
+	if err := handler.Launch(l, tunneledConn); err != nil {
+		closeTunnel(tunnel)
+		return err
+	}
+	return nil
 }
 
-// launchSSHFallback uses the standard ssh command as fallback
-func (l *Launcher) launchSSHFallback(conn *models.Connection) error {
-	port := conn.Port
-	if port == 0 {
-		port = models.ProtocolSSH.GetDefaultPort()
+// resolvePassword replaces a 1Password reference in the connection's password
+// with the secret it points to
+func (l *Launcher) resolvePassword(conn *models.Connection) error {
+	if !l.onePasswordProvider.IsReference(conn.Password) {
+		return nil
 	}
 
-	args := []string{}
-
-	// Add port
-	args = append(args, "-p", strconv.Itoa(port))
-
-	// Automatically accept new host keys (security note: this trusts on first use)
-	// This prevents the "authenticity of host" prompt from blocking connections
-	args = append(args, "-o", "StrictHostKeyChecking=accept-new")
-
-	// Add username if provided
-	target := conn.Host
-	if conn.Username != "" {
-		target = conn.Username + "@" + conn.Host
+	resolved, err := l.onePasswordProvider.ResolveSecret(conn.Password)
+	if err != nil {
+		// For RDP, we can continue without a password (will prompt)
+		// For other protocols that require a password, return the error
+		if conn.Protocol != models.ProtocolRDP {
+			return fmt.Errorf("failed to resolve password from 1Password: %w", err)
+		}
+		// RDP: Clear the password so it doesn't try to use the op:// reference
+		fmt.Printf("Warning: Failed to resolve password from 1Password: %v (RDP will prompt for credentials)\n", err)
+		conn.Password = ""
+		return nil
 	}
+	conn.Password = resolved
+	return nil
+}
 
-	args = append(args, target)
-
-	// Add extra args if provided
-	if conn.ExtraArgs != "" {
-		args = append(args, conn.ExtraArgs)
+// start starts a command, waiting for it if it shares the current terminal
+// and the launcher is attached
+func (l *Launcher) start(cmd *exec.Cmd) error {
+	if !l.attached || cmd.Stdin != os.Stdin {
+		return l.runner.Start(cmd)
 	}
-
-	var cmd *exec.Cmd
-
-	// If password is provided, try to use sshpass (if available)
-	if conn.Password != "" {
-		// Check if sshpass is available
-		if _, err := exec.LookPath("sshpass"); err == nil {
-			// Use sshpass to provide password
-			sshpassArgs := []string{"-p", conn.Password, "ssh"}
-			sshpassArgs = append(sshpassArgs, args...)
-
-			// Launch in a terminal emulator
-			cmd = l.launchInTerminal("sshpass", sshpassArgs...)
-		} else {
-			// sshpass not available, just use ssh (will prompt for password)
-			cmd = l.launchInTerminal("ssh", args...)
+	if err := l.runner.Run(cmd); err != nil {
+		// The session ran; its exit status is not a launch failure
+		if _, ok := err.(*exec.ExitError); !ok {
+			return err
 		}
-	} else {
-		cmd = l.launchInTerminal("ssh", args...)
 	}
+	return nil
+}
 
-	if cmd == nil {
-		return fmt.Errorf("failed to create terminal command")
+// SetSettings replaces the launcher's settings
+func (l *Launcher) SetSettings(s *settings.Settings) {
+	l.settings = s
+	l.settingsErr = nil
+}
+
+// SetTarget overrides where console sessions open; see settings.Targets
+func (l *Launcher) SetTarget(target string) error {
+	if err := settings.ValidateTarget(target); err != nil {
+		return err
 	}
+	l.target = target
+	return nil
+}
 
-	return cmd.Start()
+// SettingsError returns why the settings file was ignored, nil if it was used
+func (l *Launcher) SettingsError() error {
+	return l.settingsErr
 }
 
-// launchInTerminal launches a command in a terminal emulator
-func (l *Launcher) launchInTerminal(command string, args ...string) *exec.Cmd {
+// launchInTerminal returns the command that runs a console program where the
+// session target says: a tmux or screen window named title, the current
+// terminal, or a terminal window: the terminal from the settings, Terminal.app
+// on macOS or the first terminal emulator found on Linux. Without one, the
+// program runs in the current terminal unless the settings make that an error.
+func (l *Launcher) launchInTerminal(title, command string, args ...string) (*exec.Cmd, error) {
 	// Build the command with proper shell quoting
 	cmdParts := []string{command}
 	cmdParts = append(cmdParts, args...)
@@ -191,21 +196,10 @@ func (l *Launcher) launchInTerminal(command string, args ...string) *exec.Cmd {
 
 	// For SSH commands on Linux, wrap with error detection for host key changes
 	var wrappedCmd string
-	if runtime.GOOS == "linux" && (command == "ssh" || command == "sshpass") {
+	wrapped := l.goos == "linux" && (command == "ssh" || command == "sshpass")
+	if wrapped {
 		// Extract hostname from args for ssh-keygen -R
-		hostname := ""
-		for i, arg := range args {
-			if !strings.HasPrefix(arg, "-") && i > 0 {
-				// This is likely the hostname or user@hostname
-				parts := strings.Split(arg, "@")
-				if len(parts) > 1 {
-					hostname = parts[1]
-				} else {
-					hostname = arg
-				}
-				break
-			}
-		}
+		hostname := sshTarget(args)
 
 		// Create wrapper that detects host key mismatch and shows helpful message
 		// Use a temp file to capture stderr for error detection
@@ -239,7 +233,25 @@ func (l *Launcher) launchInTerminal(command string, args ...string) *exec.Cmd {
 		wrappedCmd = fullCmd
 	}
 
-	if runtime.GOOS == "darwin" {
+	session := append([]string{command}, args...)
+	if wrapped {
+		session = []string{"bash", "-c", wrappedCmd}
+	}
+	switch target := l.sessionTarget(); target {
+	case settings.TargetTmux, settings.TargetTmuxPane, settings.TargetScreen:
+		return l.multiplexerCommand(target, title, session), nil
+	case settings.TargetCurrent:
+		return l.currentTerminalCommand(command, args...), nil
+	}
+
+	if l.settings.Terminal != "" {
+		if wrapped {
+			return l.terminalCommand("bash", "-c", wrappedCmd)
+		}
+		return l.terminalCommand(command, args...)
+	}
+
+	if l.goos == "darwin" {
 		// macOS - use Terminal.app with osascript
 		// AppleScript to open Terminal and run command
 		script := fmt.Sprintf(`tell application "Terminal"
@@ -247,9 +259,10 @@ func (l *Launcher) launchInTerminal(command string, args ...string) *exec.Cmd {
 			activate
 		end tell`, strings.ReplaceAll(wrappedCmd, `"`, `\"`))
 
-		return exec.Command("osascript", "-e", script)
-	} else if runtime.GOOS == "linux" {
-		// Try common terminal emulators on Linux
+		return l.runner.Command("osascript", "-e", script), nil
+	} else if l.goos == "linux" && (l.getenv("DISPLAY") != "" || l.getenv("WAYLAND_DISPLAY") != "") {
+		// Try common terminal emulators on Linux; without a display (e.g. over
+		// SSH) they can't open, so the command runs in the current terminal
 		terminals := []struct {
 			name     string
 			argStyle string // "dash-e", "dash-dash", "direct"
@@ -263,223 +276,138 @@ func (l *Launcher) launchInTerminal(command string, args ...string) *exec.Cmd {
 		}
 
 		for _, term := range terminals {
-			if termPath, err := exec.LookPath(term.name); err == nil {
+			if termPath, err := l.runner.LookPath(term.name); err == nil {
 				switch term.argStyle {
 				case "dash-dash":
 					// gnome-terminal uses -- to separate
-					return exec.Command(termPath, "--", "bash", "-c", wrappedCmd)
+					return l.runner.Command(termPath, "--", "bash", "-c", wrappedCmd), nil
 				case "dash-e":
 					// Most terminals use -e
-					return exec.Command(termPath, "-e", "bash", "-c", wrappedCmd)
+					return l.runner.Command(termPath, "-e", "bash", "-c", wrappedCmd), nil
 				}
 			}
 		}
 
-		fmt.Println("Warning: No terminal emulator found")
+		fmt.Fprintln(os.Stderr, "Warning: No terminal emulator found")
+	}
+
+	if l.settings.TerminalFallback == settings.FallbackError {
+		return nil, fmt.Errorf("no terminal window can be opened for %s; set 'terminal' in the settings file", command)
 	}
 
 	// Fallback: try to run without terminal (will need stdin/stdout)
-	fmt.Println("Fallback: Running without terminal")
-	cmd := exec.Command(command, args...)
+	fmt.Fprintln(os.Stderr, "Fallback: Running without terminal")
+	return l.currentTerminalCommand(command, args...), nil
+}
+
+// currentTerminalCommand runs a console program in the terminal MremoteGO was
+// started from
+func (l *Launcher) currentTerminalCommand(command string, args ...string) *exec.Cmd {
+	cmd := l.runner.Command(command, args...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd
 }
 
-// launchRDP launches an RDP connection
-func (l *Launcher) launchRDP(conn *models.Connection) error {
-	port := conn.Port
-	if port == 0 {
-		port = models.ProtocolRDP.GetDefaultPort()
-	}
-
-	target := conn.Host
-	if port != 3389 {
-		target = fmt.Sprintf("%s:%d", conn.Host, port)
-	}
-
-	var cmd *exec.Cmd
-
-	switch runtime.GOOS {
-	case "windows":
-		// Store credentials in Windows Credential Manager if password is provided
-		if conn.Username != "" && conn.Password != "" {
-			if err := l.storeWindowsCredential(conn); err != nil {
-				// Continue anyway - mstsc will prompt if credentials aren't stored
-				fmt.Printf("Warning: Failed to store credentials: %v\n", err)
-			}
-		}
-
-		// Create a temporary .rdp file with connection settings
-		rdpFile, err := l.createRDPFile(conn, target)
-		if err != nil {
-			return fmt.Errorf("failed to create RDP file: %w", err)
-		}
-
-		// Launch mstsc with the RDP file
-		cmd = exec.Command("mstsc", rdpFile)
-		// Don't hide mstsc - it's a GUI application we want to see
-
-	case "darwin":
-		// Use Microsoft Remote Desktop on macOS
-		// Format: rdp://[username@]hostname[:port]
-		rdpURL := "rdp://"
-		if conn.Username != "" {
-			rdpURL += conn.Username
-			if conn.Domain != "" {
-				rdpURL += "@" + conn.Domain
-			}
-			rdpURL += "@"
-		}
-		rdpURL += conn.Host
-		if port != 3389 {
-			rdpURL += fmt.Sprintf(":%d", port)
-		}
-
-		// Use 'open' to launch Microsoft Remote Desktop with rdp:// URL
-		cmd = exec.Command("open", rdpURL)
-		// Note: Password cannot be passed via URL for security reasons
-		// Microsoft Remote Desktop will prompt or use saved credentials
-
-	case "linux":
-		// Use xfreerdp on Linux
-		args := []string{
-			"/v:" + target,
-			"/cert:ignore",
-		}
-
-		if conn.Username != "" {
-			args = append(args, "/u:"+conn.Username)
-		}
-
-		if conn.Domain != "" {
-			args = append(args, "/d:"+conn.Domain)
-		}
-
-		if conn.Password != "" {
-			args = append(args, "/p:"+conn.Password)
-		}
-
-		if conn.Resolution != "" {
-			args = append(args, "/size:"+conn.Resolution)
-		} else {
-			args = append(args, "/f") // Fullscreen by default
-		}
-
-		if conn.ColorDepth > 0 {
-			args = append(args, fmt.Sprintf("/bpp:%d", conn.ColorDepth))
+// terminalCommand runs a command in the terminal set in the settings, putting
+// it in place of the {args} or {command} placeholder, or at the end
+func (l *Launcher) terminalCommand(command string, args ...string) (*exec.Cmd, error) {
+	template, err := splitArgs(l.settings.Terminal)
+	if err != nil || len(template) == 0 {
+		return nil, fmt.Errorf("invalid terminal setting %q: %v", l.settings.Terminal, err)
+	}
+
+	commandLine := append([]string{command}, args...)
+	var argv []string
+	placed := false
+	for _, part := range template {
+		switch part {
+		case settings.ArgsPlaceholder:
+			argv = append(argv, commandLine...)
+			placed = true
+		case settings.CommandPlaceholder:
+			argv = append(argv, shellJoin(commandLine))
+			placed = true
+		default:
+			argv = append(argv, part)
 		}
-
-		if conn.ExtraArgs != "" {
-			args = append(args, conn.ExtraArgs)
-		}
-
-		cmd = exec.Command("xfreerdp", args...)
-		// Don't hide xfreerdp - it's a GUI application we want to see
-
-	default:
-		return fmt.Errorf("RDP not supported on this platform")
 	}
-
-	cmd.Stdin = nil
-	cmd.Stdout = nil
-	cmd.Stderr = nil
-
-	return cmd.Start()
+	if !placed {
+		argv = append(argv, commandLine...)
+	}
+	return l.runner.Command(argv[0], argv[1:]...), nil
 }
 
-// launchVNC launches a VNC connection
-func (l *Launcher) launchVNC(conn *models.Connection) error {
-	port := conn.Port
-	if port == 0 {
-		port = models.ProtocolVNC.GetDefaultPort()
+// client returns the program and arguments that open a connection: name and
+// args, or the client set for the protocol in the settings, which gets args
+// unless it sets its own
+func (l *Launcher) client(conn *models.Connection, name string, args []string) (string, []string, error) {
+	client := l.settings.Client(conn.Protocol)
+	if client == nil {
+		return name, args, nil
 	}
-
-	target := fmt.Sprintf("%s:%d", conn.Host, port)
-
-	var cmd *exec.Cmd
-
-	switch runtime.GOOS {
-	case "windows":
-		// Try common VNC clients on Windows
-		cmd = exec.Command("vncviewer", target)
-	case "linux":
-		// Try vncviewer on Linux
-		cmd = exec.Command("vncviewer", target)
-	case "darwin":
-		// Use open with vnc:// protocol on Mac
-		target = fmt.Sprintf("vnc://%s:%d", conn.Host, port)
-		cmd = exec.Command("open", target)
-	default:
-		return fmt.Errorf("VNC not supported on this platform")
+	if client.Args == "" {
+		return client.Command, args, nil
 	}
 
-	cmd.Stdin = nil
-	cmd.Stdout = nil
-	cmd.Stderr = nil
-	// Don't hide VNC - it's a GUI application we want to see
-
-	return cmd.Start()
-}
-
-// launchHTTP launches an HTTP/HTTPS connection in default browser
-func (l *Launcher) launchHTTP(conn *models.Connection) error {
-	url := fmt.Sprintf("%s://%s", conn.Protocol, conn.Host)
-
-	if conn.Port != 0 {
-		url = fmt.Sprintf("%s:%d", url, conn.Port)
+	custom, err := l.expandArgs(client.Args, conn)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid %s client arguments: %w", conn.Protocol, err)
 	}
+	return client.Command, custom, nil
+}
 
-	var cmd *exec.Cmd
-
-	switch runtime.GOOS {
-	case "windows":
-		cmd = exec.Command("cmd", "/c", "start", url)
-		hideConsoleWindow(cmd)
-	case "darwin":
-		cmd = exec.Command("open", url)
-	case "linux":
-		cmd = exec.Command("xdg-open", url)
-	default:
-		return fmt.Errorf("cannot open browser on this platform")
+// clientCommand returns the command that runs a protocol's client; see client
+func (l *Launcher) clientCommand(conn *models.Connection, name string, args ...string) (*exec.Cmd, error) {
+	name, args, err := l.client(conn, name, args)
+	if err != nil {
+		return nil, err
 	}
-
-	return cmd.Start()
+	return l.runner.Command(name, args...), nil
 }
 
-// launchTelnet launches a telnet connection
-func (l *Launcher) launchTelnet(conn *models.Connection) error {
-	port := conn.Port
-	if port == 0 {
-		port = models.ProtocolTelnet.GetDefaultPort()
+// sshOptionsWithValue lists the ssh (and sshpass) options that take a value
+const sshOptionsWithValue = "BbcDEeFIiJLlmOopQRSWw"
+
+// sshTarget returns the host of an ssh or sshpass command line, skipping
+// options and their values
+func sshTarget(args []string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "ssh":
+			// sshpass runs ssh, followed by ssh's own options
+		case strings.HasPrefix(arg, "-"):
+			if len(arg) == 2 && strings.ContainsRune(sshOptionsWithValue, rune(arg[1])) {
+				i++
+			}
+		default:
+			// The target may be user@hostname
+			if at := strings.LastIndex(arg, "@"); at >= 0 {
+				return arg[at+1:]
+			}
+			return arg
+		}
 	}
+	return ""
+}
 
-	args := []string{conn.Host, strconv.Itoa(port)}
-
-	cmd := exec.Command("telnet", args...)
-	cmd.Stdin = nil
-	cmd.Stdout = nil
-	cmd.Stderr = nil
-	// Don't hide telnet - it needs a terminal window
-
-	return cmd.Start()
+// rdpFilePath returns the path of the temporary .rdp file of a connection
+func rdpFilePath(conn *models.Connection) string {
+	// Sanitize connection name for filename (remove invalid characters)
+	return filepath.Join(os.TempDir(), "mremotego", sanitizeFilename(conn.Name)+".rdp")
 }
 
-// createRDPFile creates a temporary .rdp file with connection settings
-func (l *Launcher) createRDPFile(conn *models.Connection, target string) (string, error) {
+// writeRDPFile writes the temporary .rdp file with connection settings
+func writeRDPFile(conn *models.Connection, target string) error {
+	rdpPath := rdpFilePath(conn)
+
 	// Create temp directory for RDP files
-	tempDir := filepath.Join(os.TempDir(), "mremotego")
-	if err := os.MkdirAll(tempDir, 0700); err != nil {
-		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	if err := os.MkdirAll(filepath.Dir(rdpPath), 0700); err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
 	}
 
-	// Sanitize connection name for filename (remove invalid characters)
-	safeName := sanitizeFilename(conn.Name)
-
-	// Create RDP file with .rdp extension
-	rdpPath := filepath.Join(tempDir, safeName+".rdp")
-
 	// Build RDP file content (UTF-8 encoding)
 	rdpContent := fmt.Sprintf("full address:s:%s\r\n", target)
 
@@ -556,10 +484,10 @@ func (l *Launcher) createRDPFile(conn *models.Connection, target string) (string
 
 	// Write RDP file
 	if err := os.WriteFile(rdpPath, []byte(rdpContent), 0600); err != nil {
-		return "", fmt.Errorf("failed to write RDP file: %w", err)
+		return fmt.Errorf("failed to write RDP file: %w", err)
 	}
 
-	return rdpPath, nil
+	return nil
 }
 
 // sanitizeFilename removes or replaces invalid characters from a filename
@@ -581,7 +509,7 @@ func sanitizeFilename(name string) string {
 
 // storeWindowsCredential stores RDP credentials in Windows Credential Manager
 func (l *Launcher) storeWindowsCredential(conn *models.Connection) error {
-	if runtime.GOOS != "windows" {
+	if l.goos != "windows" {
 		return nil
 	}
 
@@ -603,10 +531,10 @@ func (l *Launcher) storeWindowsCredential(conn *models.Connection) error {
 
 	// Use cmdkey to store the credential
 	// cmdkey /generic:TERMSRV/hostname /user:username /pass:password
-	cmd := exec.Command("cmdkey", "/generic:TERMSRV/"+target, "/user:"+username, "/pass:"+conn.Password)
+	cmd := l.runner.Command("cmdkey", "/generic:TERMSRV/"+target, "/user:"+username, "/pass:"+conn.Password)
 	hideConsoleWindow(cmd)
 
-	output, err := cmd.CombinedOutput()
+	output, err := l.runner.CombinedOutput(cmd)
 	if err != nil {
 		return fmt.Errorf("cmdkey failed: %w, output: %s", err, string(output))
 	}
@@ -616,7 +544,7 @@ func (l *Launcher) storeWindowsCredential(conn *models.Connection) error {
 
 // RemoveWindowsCredential removes RDP credentials from Windows Credential Manager
 func (l *Launcher) RemoveWindowsCredential(conn *models.Connection) error {
-	if runtime.GOOS != "windows" {
+	if l.goos != "windows" {
 		return nil
 	}
 
@@ -632,10 +560,10 @@ func (l *Launcher) RemoveWindowsCredential(conn *models.Connection) error {
 
 	// Use cmdkey to delete the credential
 	// cmdkey /delete:TERMSRV/hostname
-	cmd := exec.Command("cmdkey", "/delete:TERMSRV/"+target)
+	cmd := l.runner.Command("cmdkey", "/delete:TERMSRV/"+target)
 	hideConsoleWindow(cmd)
 
-	output, err := cmd.CombinedOutput()
+	output, err := l.runner.CombinedOutput(cmd)
 	if err != nil {
 		// Don't return error if credential doesn't exist
 		if !strings.Contains(string(output), "not found") {
@@ -648,7 +576,7 @@ func (l *Launcher) RemoveWindowsCredential(conn *models.Connection) error {
 
 // CleanupAllCredentials removes all MremoteGO-stored credentials from Windows Credential Manager
 func (l *Launcher) CleanupAllCredentials(connections []*models.Connection) error {
-	if runtime.GOOS != "windows" {
+	if l.goos != "windows" {
 		return nil
 	}
 
@@ -0,0 +1,147 @@
+package launcher
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/internal/settings"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// sessionTarget returns where console sessions open: the launcher's target,
+// else the one from the settings, with auto resolved from the environment
+func (l *Launcher) sessionTarget() string {
+	target := l.target
+	if target == "" {
+		target = l.settings.SessionTarget
+	}
+	if target != "" && target != settings.TargetAuto {
+		return target
+	}
+
+	switch {
+	case l.getenv("TMUX") != "":
+		return settings.TargetTmux
+	case l.getenv("STY") != "":
+		return settings.TargetScreen
+	}
+	return settings.TargetTerminal
+}
+
+// multiplexerCommand returns the command that opens a console program in a new
+// tmux window, a new tmux pane or a new screen window named title
+func (l *Launcher) multiplexerCommand(target, title string, argv []string) *exec.Cmd {
+	switch target {
+	case settings.TargetTmuxPane:
+		return l.runner.Command("tmux", "split-window", shellJoin(argv))
+	case settings.TargetScreen:
+		// Inside screen, this opens a window in the current session
+		return l.runner.Command("screen", append([]string{"-t", title}, argv...)...)
+	}
+	return l.runner.Command("tmux", "new-window", "-n", title, shellJoin(argv))
+}
+
+// LaunchTmuxSession opens connections side by side in one window of a tmux
+// session, with their input synchronized, and attaches to it. The session is
+// created when it doesn't exist; otherwise the window is added to it.
+func (l *Launcher) LaunchTmuxSession(session string, conns []*models.Connection) error {
+	if session == "" || strings.ContainsAny(session, ".:") {
+		return fmt.Errorf("invalid tmux session name '%s'", session)
+	}
+	if len(conns) == 0 {
+		return fmt.Errorf("no connections to open")
+	}
+
+	var commands []string
+	for _, conn := range conns {
+		line, err := l.sessionCommandLine(conn)
+		if err != nil {
+			return fmt.Errorf("failed to open '%s': %w", conn.Name, err)
+		}
+		commands = append(commands, line)
+	}
+
+	exact := "=" + session
+	window := exact + ":"
+	if l.runner.Run(l.runner.Command("tmux", "has-session", "-t", exact)) == nil {
+		if err := l.tmux("new-window", "-t", window, "-n", session, commands[0]); err != nil {
+			return err
+		}
+	} else if err := l.tmux("new-session", "-d", "-s", session, "-n", session, commands[0]); err != nil {
+		return err
+	}
+
+	for _, command := range commands[1:] {
+		if err := l.tmux("split-window", "-t", window, command); err != nil {
+			return err
+		}
+		// Re-tile after every split so the window doesn't run out of room
+		if err := l.tmux("select-layout", "-t", window, "tiled"); err != nil {
+			return err
+		}
+	}
+	if err := l.tmux("set-window-option", "-t", window, "synchronize-panes", "on"); err != nil {
+		return err
+	}
+
+	if l.getenv("TMUX") != "" {
+		return l.tmux("switch-client", "-t", exact)
+	}
+	cmd := l.currentTerminalCommand("tmux", "attach-session", "-t", exact)
+	if err := l.runner.Run(cmd); err != nil {
+		return fmt.Errorf("failed to attach to tmux session '%s': %w", session, err)
+	}
+	return nil
+}
+
+// sessionCommandLine returns the shell command line that runs a connection in
+// the current terminal, for connections with console sessions
+func (l *Launcher) sessionCommandLine(conn *models.Connection) (string, error) {
+	if conn.IsFolder() {
+		return "", fmt.Errorf("cannot launch a folder")
+	}
+
+	handler, err := HandlerFor(conn.Protocol)
+	if err != nil {
+		return "", err
+	}
+
+	resolvedConn := *conn
+	if err := l.resolvePassword(&resolvedConn); err != nil {
+		return "", err
+	}
+	if err := handler.Validate(&resolvedConn); err != nil {
+		return "", err
+	}
+	if tunnels(handler, &resolvedConn) {
+		return "", fmt.Errorf("%s connections can't go through jump hosts in a tmux session", conn.Protocol)
+	}
+
+	current := *l
+	current.target = settings.TargetCurrent
+	cmd, err := handler.BuildCommand(&current, &resolvedConn)
+	if err != nil {
+		return "", err
+	}
+	if cmd.Stdin != os.Stdin {
+		return "", fmt.Errorf("%s connections don't run in a terminal", conn.Protocol)
+	}
+	if cmd.Err != nil {
+		return "", cmd.Err
+	}
+	return shellJoin(cmd.Args), nil
+}
+
+// tmux runs a tmux command, reporting what tmux printed when it fails
+func (l *Launcher) tmux(args ...string) error {
+	output, err := l.runner.CombinedOutput(l.runner.Command("tmux", args...))
+	if err != nil {
+		if message := strings.TrimSpace(string(output)); message != "" {
+			return fmt.Errorf("tmux %s failed: %s", args[0], message)
+		}
+		return fmt.Errorf("tmux %s failed: %w", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,476 @@
+package launcher
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// The built-in protocol handlers, in the order they are offered
+func init() {
+	Register(sshHandler{})
+	Register(rdpHandler{})
+	Register(vncHandler{})
+	Register(webHandler{protocol: models.ProtocolHTTP})
+	Register(webHandler{protocol: models.ProtocolHTTPS})
+	Register(telnetHandler{})
+}
+
+// sshHandler launches SSH sessions with PuTTY on Windows, ssh otherwise
+type sshHandler struct{}
+
+func (sshHandler) Protocol() models.Protocol { return models.ProtocolSSH }
+
+func (sshHandler) DefaultPort() int { return models.ProtocolSSH.GetDefaultPort() }
+
+func (sshHandler) Validate(conn *models.Connection) error { return validateAddress(conn) }
+
+func (h sshHandler) Launch(l *Launcher, conn *models.Connection) error {
+	return startCommand(l, h, conn)
+}
+
+func (h sshHandler) BuildCommand(l *Launcher, conn *models.Connection) (*exec.Cmd, error) {
+	port := portOrDefault(conn, h)
+
+	extraArgs, err := splitArgs(conn.ExtraArgs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extra arguments: %w", err)
+	}
+
+	if l.goos == "windows" && l.settings.Client(conn.Protocol) == nil && puttySupports(conn) {
+		// Use PuTTY on Windows when it's installed (with plink for jump
+		// hosts), ssh otherwise
+		_, puttyErr := l.runner.LookPath("putty.exe")
+		proxyArgs, proxyOK := l.puttyProxyArgs(conn)
+		if puttyErr == nil && proxyOK {
+			args := []string{"-ssh", "-P", strconv.Itoa(port)}
+
+			// Add username if provided
+			if conn.Username != "" {
+				args = append(args, "-l", conn.Username)
+			}
+
+			// Add password if provided (for auto-login)
+			if conn.Password != "" {
+				args = append(args, "-pw", conn.Password)
+			}
+
+			args = append(args, l.puttyOptionArgs(conn)...)
+			args = append(args, proxyArgs...)
+			args = append(args, extraArgs...)
+
+			// Add hostname last; PuTTY is a GUI application we want to see
+			args = append(args, conn.Host)
+			return l.runner.Command("putty.exe", args...), nil
+		}
+	}
+
+	args := []string{}
+
+	// Add port
+	args = append(args, "-p", strconv.Itoa(port))
+
+	// Automatically accept new host keys (security note: this trusts on first use)
+	// This prevents the "authenticity of host" prompt from blocking connections
+	args = append(args, "-o", "StrictHostKeyChecking=accept-new")
+
+	// Options, including the extra args, go before the target; anything
+	// after it is the remote command
+	args = append(args, sshJumpArgs(conn.Jumps)...)
+	args = append(args, sshOptionArgs(conn)...)
+	args = append(args, extraArgs...)
+
+	// Add username if provided
+	target := conn.Host
+	if conn.Username != "" {
+		target = conn.Username + "@" + conn.Host
+	}
+
+	args = append(args, target)
+
+	if conn.RemoteCommand != "" {
+		args = append(args, conn.RemoteCommand)
+	}
+
+	// Use the ssh client from the settings, if any
+	name, args, err := l.client(conn, "ssh", args)
+	if err != nil {
+		return nil, err
+	}
+
+	// If password is provided, try to use sshpass (if available)
+	if conn.Password != "" {
+		// Check if sshpass is available
+		if _, err := l.runner.LookPath("sshpass"); err == nil {
+			// Use sshpass to provide password
+			sshpassArgs := []string{"-p", conn.Password, name}
+			sshpassArgs = append(sshpassArgs, args...)
+
+			// Launch in a terminal emulator
+			return l.launchInTerminal(conn.Name, "sshpass", sshpassArgs...)
+		}
+		// sshpass not available, just use ssh (will prompt for password)
+	}
+
+	return l.launchInTerminal(conn.Name, name, args...)
+}
+
+// sshOptionArgs returns the OpenSSH options for a connection's SSH settings
+func sshOptionArgs(conn *models.Connection) []string {
+	var args []string
+	if conn.IdentityFile != "" {
+		args = append(args, "-i", conn.IdentityFile)
+	}
+	if conn.CertificateFile != "" {
+		args = append(args, "-o", "CertificateFile="+conn.CertificateFile)
+	}
+	if conn.IdentitiesOnly {
+		args = append(args, "-o", "IdentitiesOnly=yes")
+	}
+	if conn.PreferredAuth != "" {
+		args = append(args, "-o", "PreferredAuthentications="+conn.PreferredAuth)
+	}
+	if conn.ForwardAgent {
+		args = append(args, "-A")
+	}
+	for _, forward := range conn.LocalForwards {
+		args = append(args, "-L", forward)
+	}
+	for _, forward := range conn.RemoteForwards {
+		args = append(args, "-R", forward)
+	}
+	for _, forward := range conn.DynamicForwards {
+		args = append(args, "-D", forward)
+	}
+
+	requestTTY := conn.RequestTTY
+	if requestTTY == "" && conn.RemoteCommand != "" {
+		// The session opens in a terminal, so the command likely expects one
+		requestTTY = "yes"
+	}
+	if requestTTY != "" && requestTTY != "auto" {
+		args = append(args, "-o", "RequestTTY="+requestTTY)
+	}
+	return args
+}
+
+// puttySupports reports whether PuTTY can open a connection with its SSH
+// settings; it has no options for certificates, authentication order,
+// IdentitiesOnly or remote commands, for the connection or its jump hosts
+func puttySupports(conn *models.Connection) bool {
+	if conn.RemoteCommand != "" {
+		return false
+	}
+	for _, hop := range append([]*models.Connection{conn}, conn.Jumps...) {
+		if hop.CertificateFile != "" || hop.IdentitiesOnly || hop.PreferredAuth != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// puttyProxyArgs returns the PuTTY options that go through a connection's jump
+// hosts with plink, which comes with PuTTY; false if plink is needed but
+// isn't installed
+func (l *Launcher) puttyProxyArgs(conn *models.Connection) ([]string, bool) {
+	if len(conn.Jumps) == 0 {
+		return nil, true
+	}
+	plink, err := l.runner.LookPath("plink.exe")
+	if err != nil {
+		return nil, false
+	}
+	return []string{"-proxycmd", l.puttyProxyCommand(plink, conn.Jumps)}, true
+}
+
+// puttyOptionArgs returns the PuTTY options for a connection's SSH settings
+func (l *Launcher) puttyOptionArgs(conn *models.Connection) []string {
+	var args []string
+	if conn.IdentityFile != "" {
+		// PuTTY doesn't expand ~ itself and needs a .ppk key
+		args = append(args, "-i", l.expandHome(conn.IdentityFile))
+	}
+	if conn.ForwardAgent {
+		args = append(args, "-A")
+	}
+	for _, forward := range conn.LocalForwards {
+		args = append(args, "-L", forward)
+	}
+	for _, forward := range conn.RemoteForwards {
+		args = append(args, "-R", forward)
+	}
+	for _, forward := range conn.DynamicForwards {
+		args = append(args, "-D", forward)
+	}
+	switch conn.RequestTTY {
+	case "yes", "force":
+		args = append(args, "-t")
+	case "no":
+		args = append(args, "-T")
+	}
+	return args
+}
+
+// expandHome replaces a leading ~ in a path with the home directory
+func (l *Launcher) expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path
+	}
+	home := l.getenv("HOME")
+	if l.goos == "windows" {
+		home = l.getenv("USERPROFILE")
+	}
+	if home == "" {
+		return path
+	}
+	return home + path[1:]
+}
+
+// rdpHandler launches RDP sessions with mstsc, Microsoft Remote Desktop or xfreerdp
+type rdpHandler struct{}
+
+func (rdpHandler) Protocol() models.Protocol { return models.ProtocolRDP }
+
+func (rdpHandler) DefaultPort() int { return models.ProtocolRDP.GetDefaultPort() }
+
+func (rdpHandler) Validate(conn *models.Connection) error { return validateAddress(conn) }
+
+func (h rdpHandler) Launch(l *Launcher, conn *models.Connection) error {
+	// Store credentials in Windows Credential Manager if password is provided
+	if l.goos == "windows" && conn.Username != "" && conn.Password != "" {
+		if err := l.storeWindowsCredential(conn); err != nil {
+			// Continue anyway - mstsc will prompt if credentials aren't stored
+			fmt.Printf("Warning: Failed to store credentials: %v\n", err)
+		}
+	}
+	if l.goos == "windows" {
+		// mstsc opens a temporary .rdp file with the connection settings
+		if err := writeRDPFile(conn, h.target(conn)); err != nil {
+			return fmt.Errorf("failed to create RDP file: %w", err)
+		}
+	}
+	return startCommand(l, h, conn)
+}
+
+// target returns the host, with the port unless it is the default
+func (h rdpHandler) target(conn *models.Connection) string {
+	if port := portOrDefault(conn, h); port != 3389 {
+		return fmt.Sprintf("%s:%d", conn.Host, port)
+	}
+	return conn.Host
+}
+
+// BuildCommand returns the RDP client command; on Windows it opens the .rdp
+// file that Launch writes
+func (h rdpHandler) BuildCommand(l *Launcher, conn *models.Connection) (*exec.Cmd, error) {
+	port := portOrDefault(conn, h)
+	target := h.target(conn)
+
+	var cmd *exec.Cmd
+
+	switch l.goos {
+	case "windows":
+		// Launch mstsc with the RDP file
+		var err error
+		cmd, err = l.clientCommand(conn, "mstsc", rdpFilePath(conn))
+		if err != nil {
+			return nil, err
+		}
+		// Don't hide mstsc - it's a GUI application we want to see
+
+	case "darwin":
+		// Use Microsoft Remote Desktop on macOS
+		// Format: rdp://[username@]hostname[:port]
+		rdpURL := "rdp://"
+		if conn.Username != "" {
+			rdpURL += conn.Username
+			if conn.Domain != "" {
+				rdpURL += "@" + conn.Domain
+			}
+			rdpURL += "@"
+		}
+		rdpURL += conn.Host
+		if port != 3389 {
+			rdpURL += fmt.Sprintf(":%d", port)
+		}
+
+		// Use 'open' to launch Microsoft Remote Desktop with rdp:// URL
+		var err error
+		cmd, err = l.clientCommand(conn, "open", rdpURL)
+		if err != nil {
+			return nil, err
+		}
+		// Note: Password cannot be passed via URL for security reasons
+		// Microsoft Remote Desktop will prompt or use saved credentials
+
+	case "linux":
+		// Use xfreerdp on Linux
+		args := []string{
+			"/v:" + target,
+			"/cert:ignore",
+		}
+
+		if conn.Username != "" {
+			args = append(args, "/u:"+conn.Username)
+		}
+
+		if conn.Domain != "" {
+			args = append(args, "/d:"+conn.Domain)
+		}
+
+		if conn.Password != "" {
+			args = append(args, "/p:"+conn.Password)
+		}
+
+		if conn.Resolution != "" {
+			args = append(args, "/size:"+conn.Resolution)
+		} else {
+			args = append(args, "/f") // Fullscreen by default
+		}
+
+		if conn.ColorDepth > 0 {
+			args = append(args, fmt.Sprintf("/bpp:%d", conn.ColorDepth))
+		}
+
+		if conn.ExtraArgs != "" {
+			args = append(args, conn.ExtraArgs)
+		}
+
+		var err error
+		cmd, err = l.clientCommand(conn, "xfreerdp", args...)
+		if err != nil {
+			return nil, err
+		}
+		// Don't hide xfreerdp - it's a GUI application we want to see
+
+	default:
+		return nil, fmt.Errorf("RDP not supported on this platform")
+	}
+
+	cmd.Stdin = nil
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+	return cmd, nil
+}
+
+// vncHandler launches VNC sessions with vncviewer, or the built-in client on macOS
+type vncHandler struct{}
+
+func (vncHandler) Protocol() models.Protocol { return models.ProtocolVNC }
+
+func (vncHandler) DefaultPort() int { return models.ProtocolVNC.GetDefaultPort() }
+
+func (vncHandler) Validate(conn *models.Connection) error { return validateAddress(conn) }
+
+func (h vncHandler) Launch(l *Launcher, conn *models.Connection) error {
+	return startCommand(l, h, conn)
+}
+
+func (h vncHandler) BuildCommand(l *Launcher, conn *models.Connection) (*exec.Cmd, error) {
+	port := portOrDefault(conn, h)
+	target := fmt.Sprintf("%s:%d", conn.Host, port)
+
+	var client string
+
+	switch l.goos {
+	case "windows":
+		// Try common VNC clients on Windows
+		client = "vncviewer"
+	case "linux":
+		// Try vncviewer on Linux
+		client = "vncviewer"
+	case "darwin":
+		// Use open with vnc:// protocol on Mac
+		client = "open"
+		target = fmt.Sprintf("vnc://%s:%d", conn.Host, port)
+	default:
+		return nil, fmt.Errorf("VNC not supported on this platform")
+	}
+
+	cmd, err := l.clientCommand(conn, client, target)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd.Stdin = nil
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+	// Don't hide VNC - it's a GUI application we want to see
+
+	return cmd, nil
+}
+
+// webHandler opens HTTP and HTTPS connections in the default browser
+type webHandler struct {
+	protocol models.Protocol
+}
+
+func (h webHandler) Protocol() models.Protocol { return h.protocol }
+
+func (h webHandler) DefaultPort() int { return h.protocol.GetDefaultPort() }
+
+func (webHandler) Validate(conn *models.Connection) error { return validateAddress(conn) }
+
+func (h webHandler) Launch(l *Launcher, conn *models.Connection) error {
+	return startCommand(l, h, conn)
+}
+
+func (h webHandler) BuildCommand(l *Launcher, conn *models.Connection) (*exec.Cmd, error) {
+	url := fmt.Sprintf("%s://%s", h.protocol, conn.Host)
+
+	if conn.Port != 0 {
+		url = fmt.Sprintf("%s:%d", url, conn.Port)
+	}
+
+	// A browser from the settings opens the URL itself
+	if l.settings.Client(conn.Protocol) != nil {
+		return l.clientCommand(conn, "", url)
+	}
+
+	var cmd *exec.Cmd
+
+	switch l.goos {
+	case "windows":
+		cmd = l.runner.Command("cmd", "/c", "start", url)
+		hideConsoleWindow(cmd)
+	case "darwin":
+		cmd = l.runner.Command("open", url)
+	case "linux":
+		cmd = l.runner.Command("xdg-open", url)
+	default:
+		return nil, fmt.Errorf("cannot open browser on this platform")
+	}
+
+	return cmd, nil
+}
+
+// telnetHandler launches telnet sessions
+type telnetHandler struct{}
+
+func (telnetHandler) Protocol() models.Protocol { return models.ProtocolTelnet }
+
+func (telnetHandler) DefaultPort() int { return models.ProtocolTelnet.GetDefaultPort() }
+
+func (telnetHandler) Validate(conn *models.Connection) error { return validateAddress(conn) }
+
+func (h telnetHandler) Launch(l *Launcher, conn *models.Connection) error {
+	return startCommand(l, h, conn)
+}
+
+func (h telnetHandler) BuildCommand(l *Launcher, conn *models.Connection) (*exec.Cmd, error) {
+	args := []string{conn.Host, strconv.Itoa(portOrDefault(conn, h))}
+
+	cmd, err := l.clientCommand(conn, "telnet", args...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin = nil
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+	// Don't hide telnet - it needs a terminal window
+
+	return cmd, nil
+}
@@ -0,0 +1,184 @@
+package launcher
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// toolVariablePattern matches %NAME% and %CUSTOM:name% references in tool commands
+var toolVariablePattern = regexp.MustCompile(`%([A-Z]+)(?::([^%]+))?%`)
+
+// BuildToolCommand returns the command an external tool runs for a connection,
+// without running it
+func (l *Launcher) BuildToolCommand(tool *models.ExternalTool, conn *models.Connection) (*exec.Cmd, error) {
+	if conn.IsFolder() {
+		return nil, fmt.Errorf("cannot run a tool on a folder")
+	}
+
+	args, err := l.expandArgs(tool.Args, conn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid arguments for tool '%s': %w", tool.Name, err)
+	}
+	command, err := l.toolExpander(conn)(tool.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	if tool.RunInTerminal {
+		return l.launchInTerminal(tool.Name+": "+conn.Name, command, args...)
+	}
+	return l.runner.Command(command, args...), nil
+}
+
+// RunTool runs an external tool against a connection. Captured output is
+// returned when the tool has capture_output set; it is also returned when the
+// tool fails, along with the error.
+func (l *Launcher) RunTool(tool *models.ExternalTool, conn *models.Connection) (string, error) {
+	cmd, err := l.BuildToolCommand(tool, conn)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case tool.CaptureOutput && !tool.RunInTerminal:
+		output, err := l.runner.CombinedOutput(cmd)
+		if err != nil {
+			return string(output), fmt.Errorf("tool '%s' failed: %w", tool.Name, err)
+		}
+		return string(output), nil
+	case tool.Wait:
+		if l.attached && cmd.Stdin == nil {
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		}
+		if err := l.runner.Run(cmd); err != nil {
+			return "", fmt.Errorf("tool '%s' failed: %w", tool.Name, err)
+		}
+		return "", nil
+	default:
+		if err := l.start(cmd); err != nil {
+			return "", fmt.Errorf("failed to start tool '%s': %w", tool.Name, err)
+		}
+		return "", nil
+	}
+}
+
+// expandArgs splits a command line template and replaces the connection
+// variables in each argument, so values with spaces stay one argument
+func (l *Launcher) expandArgs(template string, conn *models.Connection) ([]string, error) {
+	args, err := splitArgs(template)
+	if err != nil {
+		return nil, err
+	}
+
+	expand := l.toolExpander(conn)
+	for i, arg := range args {
+		if args[i], err = expand(arg); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+// toolExpander returns a function that replaces connection variables in a
+// tool argument. The password is resolved from 1Password only when used.
+func (l *Launcher) toolExpander(conn *models.Connection) func(string) (string, error) {
+	password, passwordResolved := conn.Password, false
+
+	return func(s string) (string, error) {
+		var expandErr error
+		expanded := toolVariablePattern.ReplaceAllStringFunc(s, func(match string) string {
+			parts := toolVariablePattern.FindStringSubmatch(match)
+			if parts[2] != "" {
+				if parts[1] != "CUSTOM" {
+					return match
+				}
+				if _, exists := conn.CustomFields[parts[2]]; !exists {
+					expandErr = fmt.Errorf("'%s' has no custom field '%s'", conn.Name, parts[2])
+					return match
+				}
+				return conn.CustomFields.String(parts[2])
+			}
+
+			switch parts[1] {
+			case "NAME":
+				return conn.Name
+			case "HOST":
+				return conn.Host
+			case "PORT":
+				if conn.Port == 0 {
+					return strconv.Itoa(conn.Protocol.GetDefaultPort())
+				}
+				return strconv.Itoa(conn.Port)
+			case "PROTOCOL":
+				return string(conn.Protocol)
+			case "USERNAME":
+				return conn.Username
+			case "DOMAIN":
+				return conn.Domain
+			case "DESCRIPTION":
+				return conn.Description
+			case "PASSWORD":
+				if !passwordResolved && l.onePasswordProvider.IsReference(password) {
+					resolved, err := l.onePasswordProvider.ResolveSecret(password)
+					if err != nil {
+						expandErr = fmt.Errorf("failed to resolve password from 1Password: %w", err)
+						return match
+					}
+					password = resolved
+				}
+				passwordResolved = true
+				return password
+			}
+			return match
+		})
+		return expanded, expandErr
+	}
+}
+
+// splitArgs splits a command line into arguments like a shell: arguments are
+// separated by spaces, and single or double quotes group text containing spaces.
+// Backslashes are kept as they are so Windows paths need no escaping.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
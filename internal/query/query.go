@@ -0,0 +1,363 @@
+// Package query implements the filter language used to select connections in
+// the CLI and the GUI, e.g. "tag:prod protocol:rdp host:*.corp.local -tag:decom".
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// Help describes the query syntax, for command help and tooltips
+const Help = `Queries are made of space-separated terms that must all match:
+
+  web                   name, host, username, protocol, description, tags or
+                        custom fields contain "web"
+  field:pattern         field matches the pattern; * and ? are wildcards and
+                        matching ignores case
+  field:a,b             field matches a or b
+  -term                 term must not match
+  has:field             field is set
+  name:"Web Server"     quote values that contain spaces
+
+Fields: name, host, port, protocol, username, domain, description, notes,
+tag, folder, path, id, resolution, extra_args and custom_fields.<name>
+(or cf.<name>). folder:Production (or folder:Production/*) matches
+everything inside Production, including its subfolders; folder:/ matches the
+top level.`
+
+// fieldAliases maps alternative field names to their canonical name
+var fieldAliases = map[string]string{
+	"user":      "username",
+	"tags":      "tag",
+	"proto":     "protocol",
+	"desc":      "description",
+	"extraargs": "extra_args",
+}
+
+// knownFields lists the fields that can be used in terms
+var knownFields = map[string]bool{
+	"name": true, "host": true, "port": true, "protocol": true, "username": true,
+	"domain": true, "description": true, "notes": true, "tag": true, "folder": true,
+	"path": true, "id": true, "resolution": true, "extra_args": true,
+}
+
+// Query is a parsed filter expression. The zero value matches everything.
+type Query struct {
+	terms []term
+
+	// IsSecret reports custom fields whose values must not be searched; optional
+	IsSecret func(name string) bool
+}
+
+// term is a single condition of a query
+type term struct {
+	negate   bool
+	field    string // "" for free text, "has" for presence checks
+	custom   string // custom field name when field is "custom_fields"
+	text     string // lowercased free text
+	patterns []*regexp.Regexp
+}
+
+// Result is a connection selected by a query with the path of its folder
+type Result struct {
+	Connection *models.Connection
+	Folder     string // "" for the top level
+}
+
+// Path returns the full path of the connection, e.g. "Production/Web/web1"
+func (r Result) Path() string {
+	if r.Folder == "" {
+		return r.Connection.Name
+	}
+	return r.Folder + "/" + r.Connection.Name
+}
+
+// Parse parses a query. An empty expression matches every connection.
+func Parse(expr string) (*Query, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Query{}
+	for _, token := range tokens {
+		t, err := parseTerm(token)
+		if err != nil {
+			return nil, err
+		}
+		q.terms = append(q.terms, t)
+	}
+	return q, nil
+}
+
+// Empty reports whether the query has no terms
+func (q *Query) Empty() bool {
+	return q == nil || len(q.terms) == 0
+}
+
+// Match reports whether a connection in the given folder (path joined with
+// "/", "" for the top level) matches every term of the query
+func (q *Query) Match(conn *models.Connection, folder string) bool {
+	if q == nil {
+		return true
+	}
+	for _, t := range q.terms {
+		if q.matchTerm(t, conn, folder) == t.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the connections in a tree that match the query, in tree order.
+// Folders themselves are never returned.
+func (q *Query) Filter(connections []*models.Connection) []Result {
+	var results []Result
+	var walk func(connections []*models.Connection, folder string)
+	walk = func(connections []*models.Connection, folder string) {
+		for _, conn := range connections {
+			if conn.IsFolder() {
+				path := conn.Name
+				if folder != "" {
+					path = folder + "/" + conn.Name
+				}
+				walk(conn.Children, path)
+			} else if q.Match(conn, folder) {
+				results = append(results, Result{Connection: conn, Folder: folder})
+			}
+		}
+	}
+	walk(connections, "")
+	return results
+}
+
+// matchTerm reports whether a single term matches, ignoring its negation
+func (q *Query) matchTerm(t term, conn *models.Connection, folder string) bool {
+	if t.field == "" {
+		for _, value := range q.freeTextValues(conn) {
+			if strings.Contains(strings.ToLower(value), t.text) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if t.field == "has" {
+		if t.text == "custom_fields" && t.custom != "" {
+			_, exists := conn.CustomFields[t.custom]
+			return exists
+		}
+		for _, value := range q.fieldValues(t.text, t.custom, conn, folder) {
+			if value != "" {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, value := range q.fieldValues(t.field, t.custom, conn, folder) {
+		for _, pattern := range t.patterns {
+			if pattern.MatchString(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// freeTextValues lists the values searched by terms without a field
+func (q *Query) freeTextValues(conn *models.Connection) []string {
+	values := []string{conn.Name, conn.Host, conn.Username, string(conn.Protocol), conn.Description}
+	values = append(values, conn.Tags...)
+	for _, name := range conn.CustomFields.Keys() {
+		if q.IsSecret == nil || !q.IsSecret(name) {
+			values = append(values, conn.CustomFields.String(name))
+		}
+	}
+	return values
+}
+
+// fieldValues returns the values of a field that a pattern is matched against
+func (q *Query) fieldValues(field, custom string, conn *models.Connection, folder string) []string {
+	switch field {
+	case "name":
+		return []string{conn.Name}
+	case "host":
+		return []string{conn.Host}
+	case "port":
+		if conn.Port == 0 {
+			return []string{""}
+		}
+		return []string{strconv.Itoa(conn.Port)}
+	case "protocol":
+		return []string{string(conn.Protocol)}
+	case "username":
+		return []string{conn.Username}
+	case "domain":
+		return []string{conn.Domain}
+	case "description":
+		return []string{conn.Description}
+	case "notes":
+		return []string{conn.Notes}
+	case "id":
+		return []string{conn.ID}
+	case "resolution":
+		return []string{conn.Resolution}
+	case "extra_args":
+		return []string{conn.ExtraArgs}
+	case "tag":
+		return conn.Tags
+	case "path":
+		if folder == "" {
+			return []string{conn.Name}
+		}
+		return []string{folder + "/" + conn.Name}
+	case "folder":
+		// The folder and every folder above it, so a folder matches its whole subtree
+		if folder == "" {
+			return []string{""}
+		}
+		parts := strings.Split(folder, "/")
+		values := make([]string, len(parts))
+		for i := range parts {
+			values[i] = strings.Join(parts[:i+1], "/")
+		}
+		return values
+	case "custom_fields":
+		if _, exists := conn.CustomFields[custom]; !exists {
+			return nil
+		}
+		if q.IsSecret != nil && q.IsSecret(custom) {
+			return nil
+		}
+		return []string{conn.CustomFields.String(custom)}
+	}
+	return nil
+}
+
+// parseTerm parses one token such as "-tag:prod" or "web"
+func parseTerm(token string) (term, error) {
+	t := term{}
+	if strings.HasPrefix(token, "-") && len(token) > 1 {
+		t.negate = true
+		token = token[1:]
+	}
+
+	key, value, found := strings.Cut(token, ":")
+	if !found || !fieldKeyPattern.MatchString(key) {
+		t.text = strings.ToLower(unquote(token))
+		return t, nil
+	}
+
+	value = unquote(value)
+	field, custom, err := resolveField(key)
+	if err != nil {
+		return t, err
+	}
+
+	if field == "has" {
+		if value == "" {
+			return t, fmt.Errorf("missing field name after 'has:'")
+		}
+		t.field = "has"
+		t.text, t.custom, err = resolveField(value)
+		return t, err
+	}
+
+	t.field, t.custom = field, custom
+	for _, alternative := range strings.Split(value, ",") {
+		if field == "folder" {
+			// Folder values are paths without slashes at either end; a trailing
+			// /* means anywhere under the folder, which the folder's own value
+			// already matches since folders match their whole subtree
+			alternative = strings.Trim(alternative, "/")
+			if trimmed := strings.TrimSuffix(alternative, "/*"); trimmed != "" {
+				alternative = trimmed
+			}
+		}
+		t.patterns = append(t.patterns, globPattern(alternative))
+	}
+	return t, nil
+}
+
+// fieldKeyPattern matches the field part of a "field:pattern" term
+var fieldKeyPattern = regexp.MustCompile(`^[A-Za-z_][\w.-]*$`)
+
+// resolveField returns the canonical name of a field and, for custom fields,
+// the custom field's name
+func resolveField(key string) (string, string, error) {
+	lower := strings.ToLower(key)
+	for _, prefix := range []string{models.CustomFieldPrefix, "cf."} {
+		if strings.HasPrefix(lower, prefix) && len(key) > len(prefix) {
+			// Custom field names are case-sensitive
+			return "custom_fields", key[len(prefix):], nil
+		}
+	}
+	if alias, exists := fieldAliases[lower]; exists {
+		lower = alias
+	}
+	if lower == "has" || knownFields[lower] {
+		return lower, "", nil
+	}
+	return "", "", fmt.Errorf("unknown field '%s' in query", key)
+}
+
+// globPattern compiles a case-insensitive pattern where * matches any text and
+// ? any single character; the whole value must match
+func globPattern(glob string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("(?is)^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+// tokenize splits a query on whitespace, keeping double-quoted text together
+func tokenize(expr string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	inQuotes, started := false, false
+
+	for _, r := range expr {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			current.WriteRune(r)
+			started = true
+		case !inQuotes && (r == ' ' || r == '\t' || r == '\n'):
+			if started {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				started = false
+			}
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote in query")
+	}
+	if started {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
+
+// unquote removes the double quotes from a token
+func unquote(s string) string {
+	return strings.ReplaceAll(s, `"`, "")
+}
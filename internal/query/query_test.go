@@ -0,0 +1,171 @@
+package query
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// testTree returns web1 and db1 in Production/Web and Production, rdp1 in
+// Staging and jump at the top level
+func testTree() []*models.Connection {
+	return []*models.Connection{
+		{Name: "Production", Type: models.NodeTypeFolder, Children: []*models.Connection{
+			{Name: "Web", Type: models.NodeTypeFolder, Children: []*models.Connection{
+				{Name: "web1", Type: models.NodeTypeConnection, Protocol: models.ProtocolSSH, Host: "web1.corp.local",
+					Port: 22, Username: "deploy", Tags: []string{"prod", "linux"}, ID: "abc123"},
+			}},
+			{Name: "db1", Type: models.NodeTypeConnection, Protocol: models.ProtocolSSH, Host: "db1.corp.local",
+				Tags: []string{"prod", "decom"}, CustomFields: models.CustomFields{"owner": "DBA Team", "token": "s3cret"}},
+		}},
+		{Name: "Staging", Type: models.NodeTypeFolder, Children: []*models.Connection{
+			{Name: "rdp1", Type: models.NodeTypeConnection, Protocol: models.ProtocolRDP, Host: "rdp1.example.com",
+				Description: "Windows Server", Tags: []string{"staging"}, CustomFields: models.CustomFields{"owner": "ops"}},
+		}},
+		{Name: "jump", Type: models.NodeTypeConnection, Protocol: models.ProtocolSSH, Host: "bastion.example.com"},
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string // Matching names in tree order, comma-separated
+	}{
+		{"", "web1,db1,rdp1,jump"},
+		{"web", "web1"},
+		{"WINDOWS", "rdp1"},
+		{"corp.local", "web1,db1"},
+		{"protocol:ssh", "web1,db1,jump"},
+		{"proto:RDP", "rdp1"},
+		{"protocol:ssh,rdp", "web1,db1,rdp1,jump"},
+		{"host:*.corp.local", "web1,db1"},
+		{"host:web?.corp.local", "web1"},
+		{"host:corp", ""},
+		{"name:*1", "web1,db1,rdp1"},
+		{"port:22", "web1"},
+		{"id:abc123", "web1"},
+		{"user:deploy", "web1"},
+		{"tag:prod", "web1,db1"},
+		{"tags:PROD -tag:decom", "web1"},
+		{"-tag:prod", "rdp1,jump"},
+		{"-web", "db1,rdp1,jump"},
+		{"tag:prod protocol:ssh host:*.corp.local -tag:decom", "web1"},
+		{"folder:Production", "web1,db1"},
+		{"folder:Production/*", "web1,db1"},
+		{"folder:/Production/", "web1,db1"},
+		{"folder:production/web", "web1"},
+		{"folder:Production/Web/*", "web1"},
+		{"folder:*/Web", "web1"},
+		{"folder:Prod*", "web1,db1"},
+		{"folder:/", "jump"},
+		{"folder:/*", "web1,db1,rdp1,jump"},
+		{"-folder:Production/*", "rdp1,jump"},
+		{"folder:Staging,/", "rdp1,jump"},
+		{"path:Production/db1", "db1"},
+		{"path:*/web1", "web1"},
+		{"cf.owner:ops", "rdp1"},
+		{"custom_fields.owner:dba*", "db1"},
+		{`cf.owner:"DBA Team"`, "db1"},
+		{`"dba team"`, "db1"},
+		{"has:cf.owner", "db1,rdp1"},
+		{"has:description", "rdp1"},
+		{"-has:tag", "jump"},
+		{"s3cret", ""},
+		{"cf.token:s3cret", ""},
+		{"has:cf.token", "db1"},
+	}
+
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.query, err)
+			continue
+		}
+		q.IsSecret = func(name string) bool { return name == "token" }
+
+		var names []string
+		for _, result := range q.Filter(testTree()) {
+			names = append(names, result.Connection.Name)
+		}
+		if got := strings.Join(names, ","); got != tt.want {
+			t.Errorf("%q matched %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		terms int
+		err   string
+	}{
+		{"", 0, ""},
+		{"   ", 0, ""},
+		{"web  tag:prod", 2, ""},
+		{`name:"Web Server" -tag:decom`, 2, ""},
+		{`"two words"`, 1, ""},
+		{"-", 1, ""},
+		{"color:red", 0, "unknown field 'color'"},
+		{"has:", 0, "missing field name"},
+		{"has:color", 0, "unknown field 'color'"},
+		{`name:"web`, 0, "unterminated quote"},
+	}
+
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Parse(%q) error = %v, want %q", tt.query, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.query, err)
+			continue
+		}
+		if len(q.terms) != tt.terms {
+			t.Errorf("Parse(%q) has %d terms, want %d", tt.query, len(q.terms), tt.terms)
+		}
+		if q.Empty() != (tt.terms == 0) {
+			t.Errorf("Parse(%q).Empty() = %v", tt.query, q.Empty())
+		}
+	}
+}
+
+func TestParseTerm(t *testing.T) {
+	tests := []struct {
+		token  string
+		negate bool
+		field  string
+		custom string
+		text   string
+	}{
+		{"Web", false, "", "", "web"},
+		{"-tag:prod", true, "tag", "", ""},
+		{"USER:bob", false, "username", "", ""},
+		{"cf.Owner:x", false, "custom_fields", "Owner", ""},
+		{"custom_fields.owner:x", false, "custom_fields", "owner", ""},
+		{"has:cf.owner", false, "has", "owner", "custom_fields"},
+		{`"a:b c"`, false, "", "", "a:b c"},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTerm(tt.token)
+		if err != nil {
+			t.Errorf("parseTerm(%q): %v", tt.token, err)
+			continue
+		}
+		if got.negate != tt.negate || got.field != tt.field || got.custom != tt.custom || got.text != tt.text {
+			t.Errorf("parseTerm(%q) = %+v", tt.token, got)
+		}
+	}
+}
+
+func TestResultPath(t *testing.T) {
+	q, _ := Parse("web1")
+	results := q.Filter(testTree())
+	if len(results) != 1 || results[0].Path() != "Production/Web/web1" || results[0].Folder != "Production/Web" {
+		t.Errorf("results = %+v", results)
+	}
+}
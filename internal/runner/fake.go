@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// Fake is a CommandRunner for tests: it records commands instead of running
+// them and answers them with canned output
+type Fake struct {
+	// Paths lists the programs LookPath finds, mapped to their location
+	Paths map[string]string
+
+	// Commands records every command run or started, in order
+	Commands []*exec.Cmd
+
+	responses []fakeResponse
+}
+
+// fakeResponse is the result of commands whose arguments start with prefix
+type fakeResponse struct {
+	prefix []string
+	output string
+	err    error
+}
+
+var _ CommandRunner = (*Fake)(nil)
+
+// NewFake creates a fake runner that finds the given programs on PATH
+func NewFake(programs ...string) *Fake {
+	f := &Fake{Paths: make(map[string]string)}
+	for _, program := range programs {
+		f.Paths[program] = "/usr/bin/" + program
+	}
+	return f
+}
+
+// Respond sets the output and error of commands whose program and arguments
+// start with args; later calls take precedence. Commands of installed programs
+// without a response succeed with no output.
+func (f *Fake) Respond(output string, err error, args ...string) {
+	f.responses = append(f.responses, fakeResponse{prefix: args, output: output, err: err})
+}
+
+// Command returns a command that refers to the program by name, or by its
+// location when it is listed in Paths
+func (f *Fake) Command(name string, args ...string) *exec.Cmd {
+	path := name
+	if found, exists := f.Paths[name]; exists {
+		path = found
+	}
+	return &exec.Cmd{Path: path, Args: append([]string{name}, args...)}
+}
+
+// LookPath finds programs listed in Paths
+func (f *Fake) LookPath(name string) (string, error) {
+	if path, exists := f.Paths[name]; exists {
+		return path, nil
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+func (f *Fake) Run(cmd *exec.Cmd) error {
+	_, err := f.record(cmd)
+	return err
+}
+
+func (f *Fake) Start(cmd *exec.Cmd) error {
+	_, err := f.record(cmd)
+	return err
+}
+
+func (f *Fake) Output(cmd *exec.Cmd) ([]byte, error) { return f.record(cmd) }
+
+func (f *Fake) CombinedOutput(cmd *exec.Cmd) ([]byte, error) { return f.record(cmd) }
+
+// CommandLines returns the recorded commands as space-separated arguments,
+// e.g. "ssh -p 22 web1"
+func (f *Fake) CommandLines() []string {
+	lines := make([]string, len(f.Commands))
+	for i, cmd := range f.Commands {
+		lines[i] = strings.Join(cmd.Args, " ")
+	}
+	return lines
+}
+
+// record stores a command and returns its response. Like os/exec, running a
+// program that isn't installed fails; programs are found when they are listed
+// in Paths or given by location.
+func (f *Fake) record(cmd *exec.Cmd) ([]byte, error) {
+	f.Commands = append(f.Commands, cmd)
+	if !strings.ContainsAny(cmd.Path, `/\`) {
+		return nil, &exec.Error{Name: cmd.Path, Err: exec.ErrNotFound}
+	}
+	for i := len(f.responses) - 1; i >= 0; i-- {
+		if r := f.responses[i]; hasPrefix(cmd.Args, r.prefix) {
+			return []byte(r.output), r.err
+		}
+	}
+	return nil, nil
+}
+
+// hasPrefix reports whether args starts with prefix
+func hasPrefix(args, prefix []string) bool {
+	if len(prefix) > len(args) {
+		return false
+	}
+	for i, arg := range prefix {
+		if args[i] != arg {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+// Package runner runs external programs. The launcher and the secrets
+// providers create and run every command through a CommandRunner, so tests
+// can record commands with a Fake instead of spawning processes.
+package runner
+
+import "os/exec"
+
+// CommandRunner creates and runs external commands
+type CommandRunner interface {
+	// Command returns the command that runs a program with arguments
+	Command(name string, args ...string) *exec.Cmd
+
+	// LookPath searches PATH for a program, like exec.LookPath
+	LookPath(name string) (string, error)
+
+	// Run runs a command and waits for it to exit
+	Run(cmd *exec.Cmd) error
+
+	// Start starts a command without waiting for it
+	Start(cmd *exec.Cmd) error
+
+	// Output runs a command and returns its standard output
+	Output(cmd *exec.Cmd) ([]byte, error)
+
+	// CombinedOutput runs a command and returns its standard output and error
+	CombinedOutput(cmd *exec.Cmd) ([]byte, error)
+}
+
+// System runs commands on the local machine with os/exec
+type System struct{}
+
+var _ CommandRunner = System{}
+
+func (System) Command(name string, args ...string) *exec.Cmd {
+	return exec.Command(name, args...)
+}
+
+func (System) LookPath(name string) (string, error) { return exec.LookPath(name) }
+
+func (System) Run(cmd *exec.Cmd) error { return cmd.Run() }
+
+func (System) Start(cmd *exec.Cmd) error { return cmd.Start() }
+
+func (System) Output(cmd *exec.Cmd) ([]byte, error) { return cmd.Output() }
+
+func (System) CombinedOutput(cmd *exec.Cmd) ([]byte, error) { return cmd.CombinedOutput() }
@@ -3,27 +3,35 @@ package secrets
 import (
 	"fmt"
 	"net/url"
-	"os/exec"
 	"strings"
+
+	"github.com/jaydenthorup/mremotego/internal/runner"
 )
 
 // OnePasswordProvider handles retrieving secrets from 1Password CLI
 type OnePasswordProvider struct {
 	enabled bool
+	runner  runner.CommandRunner
 }
 
 // NewOnePasswordProvider creates a new 1Password provider
 func NewOnePasswordProvider() *OnePasswordProvider {
-	return &OnePasswordProvider{
-		enabled: isOnePasswordCLIAvailable(),
-	}
+	return NewOnePasswordProviderWithRunner(runner.System{})
 }
 
-// isOnePasswordCLIAvailable checks if the 1Password CLI (op) is installed
-func isOnePasswordCLIAvailable() bool {
-	cmd := exec.Command("op", "--version")
+// NewOnePasswordProviderWithRunner creates a 1Password provider that runs the
+// op CLI through r
+func NewOnePasswordProviderWithRunner(r runner.CommandRunner) *OnePasswordProvider {
+	p := &OnePasswordProvider{runner: r}
+	p.enabled = p.isCLIAvailable()
+	return p
+}
+
+// isCLIAvailable checks if the 1Password CLI (op) is installed
+func (p *OnePasswordProvider) isCLIAvailable() bool {
+	cmd := p.runner.Command("op", "--version")
 	hideConsoleWindow(cmd)
-	return cmd.Run() == nil
+	return p.runner.Run(cmd) == nil
 }
 
 // IsEnabled returns whether 1Password CLI is available
@@ -37,9 +45,9 @@ func (p *OnePasswordProvider) IsAuthenticated() bool {
 		return false
 	}
 
-	cmd := exec.Command("op", "whoami")
+	cmd := p.runner.Command("op", "whoami")
 	hideConsoleWindow(cmd)
-	return cmd.Run() == nil
+	return p.runner.Run(cmd) == nil
 }
 
 // GetAuthenticationInstructions returns instructions for authenticating with 1Password CLI
@@ -95,10 +103,10 @@ func (p *OnePasswordProvider) ResolveSecret(reference string) (string, error) {
 
 	// Use 'op item get' which handles special characters in item names
 	// This is more robust than 'op read' for items with parentheses, spaces, etc.
-	cmd := exec.Command("op", "item", "get", item, "--vault="+vault, "--fields", "label="+field, "--reveal")
+	cmd := p.runner.Command("op", "item", "get", item, "--vault="+vault, "--fields", "label="+field, "--reveal")
 	hideConsoleWindow(cmd)
 
-	output, err := cmd.CombinedOutput()
+	output, err := p.runner.CombinedOutput(cmd)
 	if err != nil {
 		// Provide helpful error message based on the output
 		errorMsg := string(output)
@@ -114,6 +122,12 @@ func (p *OnePasswordProvider) ResolveSecret(reference string) (string, error) {
 // Input: op://vault/item/field
 // Output: vault, item, field, error
 func (p *OnePasswordProvider) parseReference(reference string) (string, string, string, error) {
+	return ParseReference(reference)
+}
+
+// ParseReference splits a 1Password reference (op://vault/item/field) into its parts
+// The item name is URL-decoded if it was encoded
+func ParseReference(reference string) (string, string, string, error) {
 	if !strings.HasPrefix(reference, "op://") {
 		return "", "", "", fmt.Errorf("reference must start with op://")
 	}
@@ -131,6 +145,10 @@ func (p *OnePasswordProvider) parseReference(reference string) (string, string,
 	item := parts[1]
 	field := parts[2]
 
+	if vault == "" || item == "" || field == "" {
+		return "", "", "", fmt.Errorf("reference must be in format op://vault/item/field")
+	}
+
 	// URL-decode the item name in case it was encoded
 	decodedItem, err := url.PathUnescape(item)
 	if err != nil {
@@ -169,10 +187,10 @@ func (p *OnePasswordProvider) CheckItemExists(vault, title string) (string, bool
 	}
 
 	// Try to get the item
-	cmd := exec.Command("op", "item", "get", title, "--vault="+vault, "--format=json")
+	cmd := p.runner.Command("op", "item", "get", title, "--vault="+vault, "--format=json")
 	hideConsoleWindow(cmd)
 
-	output, err := cmd.CombinedOutput()
+	output, err := p.runner.CombinedOutput(cmd)
 	if err != nil {
 		// Check if error is because item doesn't exist
 		errorMsg := string(output)
@@ -223,10 +241,10 @@ func (p *OnePasswordProvider) CreateItem(vault, title, username, password string
 			args = append(args, "password="+password)
 		}
 
-		cmd := exec.Command("op", args...)
+		cmd := p.runner.Command("op", args...)
 		hideConsoleWindow(cmd)
 
-		output, err := cmd.CombinedOutput()
+		output, err := p.runner.CombinedOutput(cmd)
 		if err != nil {
 			return "", fmt.Errorf("failed to update existing 1Password item: %w, output: %s", err, string(output))
 		}
@@ -247,10 +265,10 @@ func (p *OnePasswordProvider) CreateItem(vault, title, username, password string
 			args = append(args, "password="+password)
 		}
 
-		cmd := exec.Command("op", args...)
+		cmd := p.runner.Command("op", args...)
 		hideConsoleWindow(cmd)
 
-		output, err := cmd.CombinedOutput()
+		output, err := p.runner.CombinedOutput(cmd)
 		if err != nil {
 			return "", fmt.Errorf("failed to create 1Password item: %w, output: %s", err, string(output))
 		}
@@ -269,10 +287,10 @@ func (p *OnePasswordProvider) ListVaults() ([]string, error) {
 		return nil, fmt.Errorf("1Password CLI is not available")
 	}
 
-	cmd := exec.Command("op", "vault", "list", "--format=json")
+	cmd := p.runner.Command("op", "vault", "list", "--format=json")
 	hideConsoleWindow(cmd)
 
-	_, err := cmd.Output()
+	_, err := p.runner.Output(cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list vaults: %w", err)
 	}
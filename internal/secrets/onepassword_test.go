@@ -0,0 +1,228 @@
+package secrets
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jaydenthorup/mremotego/internal/runner"
+)
+
+// newTestProvider returns a provider whose op commands are recorded by a fake
+// runner; installed controls whether the op CLI is found
+func newTestProvider(installed bool) (*OnePasswordProvider, *runner.Fake) {
+	fake := runner.NewFake()
+	if installed {
+		fake = runner.NewFake("op")
+	}
+	p := NewOnePasswordProviderWithRunner(fake)
+	fake.Commands = nil // Forget the CLI check
+	return p, fake
+}
+
+var errExit = errors.New("exit status 1")
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		reference               string
+		vault, item, field, err string
+	}{
+		{reference: "op://Private/web1/password", vault: "Private", item: "web1", field: "password"},
+		{reference: "op://Private/My%20Server%20%28prod%29/password", vault: "Private", item: "My Server (prod)", field: "password"},
+		{reference: "op://Private/web1/section/field", vault: "Private", item: "web1", field: "section/field"},
+		{reference: "op://Private/100%/password", vault: "Private", item: "100%", field: "password"},
+		{reference: "Private/web1/password", err: "must start with op://"},
+		{reference: "op://Private/web1", err: "op://vault/item/field"},
+		{reference: "op://Private//password", err: "op://vault/item/field"},
+	}
+
+	for _, tt := range tests {
+		vault, item, field, err := ParseReference(tt.reference)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseReference(%q) error = %v, want %q", tt.reference, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseReference(%q): %v", tt.reference, err)
+			continue
+		}
+		if vault != tt.vault || item != tt.item || field != tt.field {
+			t.Errorf("ParseReference(%q) = %q, %q, %q, want %q, %q, %q",
+				tt.reference, vault, item, field, tt.vault, tt.item, tt.field)
+		}
+	}
+}
+
+func TestProviderDetectsCLI(t *testing.T) {
+	if p, _ := newTestProvider(true); !p.IsEnabled() {
+		t.Error("installed op CLI is not enabled")
+	}
+	if p, _ := newTestProvider(false); p.IsEnabled() {
+		t.Error("missing op CLI is enabled")
+	}
+
+	p, fake := newTestProvider(true)
+	fake.Respond("", errExit, "op", "whoami")
+	if p.IsAuthenticated() {
+		t.Error("failed 'op whoami' counts as signed in")
+	}
+}
+
+func TestResolveSecret(t *testing.T) {
+	p, fake := newTestProvider(true)
+	fake.Respond("  s3cret \n", nil, "op", "item", "get")
+
+	secret, err := p.ResolveSecret("op://Private/My%20Server/password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret != "s3cret" {
+		t.Errorf("secret = %q, want it trimmed", secret)
+	}
+
+	want := []string{"op", "item", "get", "My Server", "--vault=Private", "--fields", "label=password", "--reveal"}
+	if got := fake.Commands[0].Args; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("ran %q, want %q", got, want)
+	}
+}
+
+func TestResolveSecretErrors(t *testing.T) {
+	t.Run("op fails", func(t *testing.T) {
+		p, fake := newTestProvider(true)
+		fake.Respond("[ERROR] \"web1\" isn't an item in the \"Private\" vault\n", errExit, "op", "item", "get")
+
+		_, err := p.ResolveSecret("op://Private/web1/password")
+		if err == nil || !strings.Contains(err.Error(), `isn't an item`) {
+			t.Errorf("error = %v, want the op output", err)
+		}
+	})
+
+	t.Run("CLI not installed", func(t *testing.T) {
+		p, fake := newTestProvider(false)
+		_, err := p.ResolveSecret("op://Private/web1/password")
+		if err == nil || !strings.Contains(err.Error(), "not available") {
+			t.Errorf("error = %v, want the CLI to be reported missing", err)
+		}
+		if len(fake.Commands) != 0 {
+			t.Errorf("ran %q", fake.CommandLines())
+		}
+	})
+
+	t.Run("not a reference", func(t *testing.T) {
+		p, _ := newTestProvider(true)
+		if _, err := p.ResolveSecret("hunter2"); err == nil || !strings.Contains(err.Error(), "not a 1Password reference") {
+			t.Errorf("error = %v", err)
+		}
+	})
+
+	t.Run("malformed reference", func(t *testing.T) {
+		p, fake := newTestProvider(true)
+		if _, err := p.ResolveSecret("op://Private/web1"); err == nil || !strings.Contains(err.Error(), "invalid reference format") {
+			t.Errorf("error = %v", err)
+		}
+		if len(fake.Commands) != 0 {
+			t.Errorf("ran %q", fake.CommandLines())
+		}
+	})
+}
+
+func TestResolveIfReference(t *testing.T) {
+	p, fake := newTestProvider(true)
+	if got := p.ResolveIfReference("plain"); got != "plain" {
+		t.Errorf("plain value became %q", got)
+	}
+
+	fake.Respond("not signed in", errExit, "op")
+	if got := p.ResolveIfReference("op://Private/web1/password"); got != "" {
+		t.Errorf("failed lookup returned %q, want an empty string", got)
+	}
+}
+
+func TestCheckItemExists(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		err    error
+		exists bool
+		errMsg string
+	}{
+		{name: "exists", output: `{"id":"abc"}`, exists: true},
+		{name: "missing", output: `[ERROR] "web1" isn't an item in the "Private" vault`, err: errExit},
+		{name: "ambiguous", output: "[ERROR] More than one item matches \"web1\"", err: errExit, errMsg: "multiple items found"},
+		{name: "other failure", output: "[ERROR] session expired", err: errExit, errMsg: "session expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, fake := newTestProvider(true)
+			fake.Respond(tt.output, tt.err, "op", "item", "get")
+
+			_, exists, err := p.CheckItemExists("Private", "web1")
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("error = %v, want %q", err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exists != tt.exists {
+				t.Errorf("exists = %v, want %v", exists, tt.exists)
+			}
+		})
+	}
+}
+
+func TestCreateItem(t *testing.T) {
+	t.Run("new item", func(t *testing.T) {
+		p, fake := newTestProvider(true)
+		fake.Respond("[ERROR] item not found", errExit, "op", "item", "get")
+
+		reference, err := p.CreateItem("Private", "My Server", "bob", "s3cret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reference != "op://Private/My%20Server/password" {
+			t.Errorf("reference = %q", reference)
+		}
+
+		lines := fake.CommandLines()
+		want := "op item create --category=login --title=My Server --vault=Private username=bob password=s3cret"
+		if len(lines) != 2 || lines[1] != want {
+			t.Errorf("ran %q, want the lookup and %q", lines, want)
+		}
+	})
+
+	t.Run("existing item is updated", func(t *testing.T) {
+		p, fake := newTestProvider(true)
+
+		if _, err := p.CreateItem("Private", "web1", "", "s3cret"); err != nil {
+			t.Fatal(err)
+		}
+		lines := fake.CommandLines()
+		if want := "op item edit web1 --vault=Private password=s3cret"; len(lines) != 2 || lines[1] != want {
+			t.Errorf("ran %q, want the lookup and %q", lines, want)
+		}
+	})
+
+	t.Run("failure includes op output", func(t *testing.T) {
+		p, fake := newTestProvider(true)
+		fake.Respond("[ERROR] item not found", errExit, "op", "item", "get")
+		fake.Respond("[ERROR] vault Nope not found", errExit, "op", "item", "create")
+
+		_, err := p.CreateItem("Nope", "web1", "bob", "s3cret")
+		if err == nil || !strings.Contains(err.Error(), "vault Nope not found") {
+			t.Errorf("error = %v, want the op output", err)
+		}
+	})
+
+	t.Run("vault and title are required", func(t *testing.T) {
+		p, _ := newTestProvider(true)
+		if _, err := p.CreateItem("", "web1", "", ""); err == nil {
+			t.Error("missing vault was accepted")
+		}
+	})
+}
@@ -0,0 +1,156 @@
+// Package settings reads the application settings file. Settings describe the
+// machine MremoteGO runs on, such as the terminal emulator and the client
+// programs to launch, so they are kept apart from the connection file, which
+// may be shared between machines and users.
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Values of TerminalFallback
+const (
+	FallbackCurrent = "current" // Run in the terminal MremoteGO was started from
+	FallbackError   = "error"   // Fail the launch
+)
+
+// Values of SessionTarget, where console sessions such as SSH open
+const (
+	TargetAuto     = "auto"      // tmux inside tmux, screen inside screen, a terminal window otherwise
+	TargetTerminal = "terminal"  // A terminal window
+	TargetCurrent  = "current"   // The terminal MremoteGO was started from
+	TargetTmux     = "tmux"      // A new tmux window
+	TargetTmuxPane = "tmux-pane" // A new pane split from the current tmux window
+	TargetScreen   = "screen"    // A new screen window
+)
+
+// Targets lists the values of SessionTarget
+var Targets = []string{TargetAuto, TargetTerminal, TargetCurrent, TargetTmux, TargetTmuxPane, TargetScreen}
+
+// Placeholders of the Terminal command
+const (
+	ArgsPlaceholder    = "{args}"    // The command and its arguments, as separate arguments
+	CommandPlaceholder = "{command}" // The command line as one shell-quoted argument
+)
+
+// Settings are the application preferences
+type Settings struct {
+	// Terminal opens console sessions such as SSH in a new window, e.g.
+	// "kitty -- {args}" or "tmux new-window {command}"; the built-in list of
+	// terminal emulators is tried when it's empty
+	Terminal string `yaml:"terminal,omitempty"`
+
+	// TerminalFallback decides what happens when no terminal window can be
+	// opened: "current" (the default) or "error"
+	TerminalFallback string `yaml:"terminal_fallback,omitempty"`
+
+	// SessionTarget decides where console sessions open; see Targets
+	SessionTarget string `yaml:"session_target,omitempty"`
+
+	// Clients replaces the program launched for a protocol, keyed by protocol
+	Clients map[string]*Client `yaml:"clients,omitempty"`
+}
+
+// Client is the program that opens connections of one protocol
+type Client struct {
+	Command string `yaml:"command"`
+	Args    string `yaml:"args,omitempty"` // Replaces the built-in arguments; may use the variables of external tools
+}
+
+// DefaultPath returns the settings file next to the default connection file
+func DefaultPath() (string, error) {
+	if appData := os.Getenv("APPDATA"); appData != "" {
+		// Windows
+		return filepath.Join(appData, "mremotego", "settings.yaml"), nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".config", "mremotego", "settings.yaml"), nil
+}
+
+// Load reads a settings file; a missing file gives the default settings
+func Load(path string) (*Settings, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Settings{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settings file: %w", err)
+	}
+
+	s := &Settings{}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse settings file %s: %w", path, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid settings file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// LoadDefault reads the settings file at DefaultPath
+func LoadDefault() (*Settings, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return Load(path)
+}
+
+// Validate reports settings that can't be used
+func (s *Settings) Validate() error {
+	switch s.TerminalFallback {
+	case "", FallbackCurrent, FallbackError:
+	default:
+		return fmt.Errorf("terminal_fallback must be '%s' or '%s', not '%s'",
+			FallbackCurrent, FallbackError, s.TerminalFallback)
+	}
+
+	if s.SessionTarget != "" {
+		if err := ValidateTarget(s.SessionTarget); err != nil {
+			return fmt.Errorf("session_target: %w", err)
+		}
+	}
+
+	if s.Terminal != "" && strings.TrimSpace(s.Terminal) == "" {
+		return fmt.Errorf("terminal is blank")
+	}
+
+	for protocol, client := range s.Clients {
+		if !models.Protocol(protocol).IsSupported() {
+			return fmt.Errorf("clients: unknown protocol '%s'", protocol)
+		}
+		if client == nil || strings.TrimSpace(client.Command) == "" {
+			return fmt.Errorf("clients: %s is missing 'command'", protocol)
+		}
+	}
+	return nil
+}
+
+// ValidateTarget checks a session target
+func ValidateTarget(target string) error {
+	for _, known := range Targets {
+		if target == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown session target '%s' (use %s)", target, strings.Join(Targets, ", "))
+}
+
+// Client returns the client configured for a protocol, nil for the built-in one
+func (s *Settings) Client(protocol models.Protocol) *Client {
+	if s == nil {
+		return nil
+	}
+	return s.Clients[string(protocol)]
+}
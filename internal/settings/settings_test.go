@@ -0,0 +1,84 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// writeSettings writes a settings file to a temporary directory
+func writeSettings(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "settings.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeSettings(t, `terminal: kitty -- {args}
+terminal_fallback: error
+clients:
+  rdp:
+    command: remmina
+    args: -c rdp://%HOST%
+`)
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Terminal != "kitty -- {args}" || s.TerminalFallback != FallbackError {
+		t.Errorf("unexpected settings %+v", s)
+	}
+	if client := s.Client(models.ProtocolRDP); client == nil || client.Command != "remmina" || client.Args != "-c rdp://%HOST%" {
+		t.Errorf("rdp client = %+v", client)
+	}
+	if client := s.Client(models.ProtocolSSH); client != nil {
+		t.Errorf("ssh client = %+v, want the built-in one", client)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "settings.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Terminal != "" || len(s.Clients) != 0 {
+		t.Errorf("missing file gave %+v, want defaults", s)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"syntax", "terminal: [", "failed to parse"},
+		{"fallback", "terminal_fallback: never", "terminal_fallback must be"},
+		{"unknown protocol", "clients:\n  gopher:\n    command: lynx", "unknown protocol 'gopher'"},
+		{"missing command", "clients:\n  rdp:\n    args: -c", "rdp is missing 'command'"},
+		{"blank terminal", "terminal: ' '", "terminal is blank"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeSettings(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientOfNilSettings(t *testing.T) {
+	var s *Settings
+	if s.Client(models.ProtocolSSH) != nil {
+		t.Error("nil settings returned a client")
+	}
+}
@@ -0,0 +1,846 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jaydenthorup/mremotego/internal/config"
+	"github.com/jaydenthorup/mremotego/internal/launcher"
+	"github.com/jaydenthorup/mremotego/internal/query"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// appMode selects how key presses are handled
+type appMode int
+
+const (
+	modeBrowse appMode = iota
+	modeSearch
+	modeConfirm
+	modeForm
+	modeHelp
+)
+
+// helpLines describes the key bindings
+var helpLines = []string{
+	"Keys",
+	"",
+	"↑ ↓ / k j     move",
+	"PgUp PgDn     move a page",
+	"g G           first / last",
+	"→ / l         open folder",
+	"← / h         close folder, go to parent",
+	"Enter         connect, or open/close folder",
+	"c             connect",
+	"/             search (same syntax as --filter)",
+	"Esc           clear the search",
+	"a             add a connection",
+	"f             add a folder",
+	"e             edit",
+	"d / Del       delete",
+	"?             this help",
+	"q             quit",
+	"",
+	"Changes are saved immediately.",
+}
+
+// searchPrompt is shown before the search query
+const searchPrompt = "/"
+
+// keyHints is the bottom line of the browse screen
+const keyHints = "↑↓ move  ←→ fold  Enter connect  / search  a add  f folder  e edit  d delete  ? help  q quit"
+
+// row is a visible line of the tree
+type row struct {
+	node  *models.Connection
+	depth int
+}
+
+// app is the state of the terminal UI
+type app struct {
+	manager  *config.Manager
+	launcher *launcher.Launcher
+	term     *terminal
+
+	expanded map[*models.Connection]bool
+	rows     []row
+	cursor   int
+	offset   int
+
+	mode      appMode
+	search    []rune
+	shown     map[*models.Connection]bool // nodes matching the search and their folders; nil without a search
+	matches   int
+	form      *form
+	confirm   string
+	onConfirm func() error
+
+	status    string
+	statusErr bool
+	quit      bool
+}
+
+// Run shows the full-screen terminal UI for browsing, searching, launching and
+// editing the manager's connections until the user quits. Changes are saved
+// as soon as they are made.
+func Run(manager *config.Manager) error {
+	t, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	defer t.close()
+
+	a := &app{
+		manager:  manager,
+		launcher: launcher.NewAttachedLauncher(),
+		term:     t,
+		expanded: make(map[*models.Connection]bool),
+	}
+	a.rebuild()
+	a.setStatus("Press ? for help", false)
+
+	for !a.quit {
+		width, height := t.size()
+		t.draw(a.render(width, height))
+
+		events, err := t.readKeys()
+		if err != nil {
+			return err
+		}
+		for _, ev := range events {
+			a.handle(ev, height)
+			if a.quit {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// setStatus shows a message on the status line until the next key press
+func (a *app) setStatus(message string, isError bool) {
+	a.status, a.statusErr = message, isError
+}
+
+// selected returns the node under the cursor, or nil when the tree is empty
+func (a *app) selected() *models.Connection {
+	if a.cursor < 0 || a.cursor >= len(a.rows) {
+		return nil
+	}
+	return a.rows[a.cursor].node
+}
+
+// rebuild recomputes the visible rows, keeping the selected node if it's still shown
+func (a *app) rebuild() {
+	selected := a.selected()
+
+	a.rows = a.rows[:0]
+	var walk func(nodes []*models.Connection, depth int)
+	walk = func(nodes []*models.Connection, depth int) {
+		for _, node := range nodes {
+			if a.shown != nil && !a.shown[node] {
+				continue
+			}
+			a.rows = append(a.rows, row{node: node, depth: depth})
+			if node.IsFolder() && (a.expanded[node] || a.shown != nil) {
+				walk(node.Children, depth+1)
+			}
+		}
+	}
+	walk(a.manager.GetConfig().Connections, 0)
+
+	for i, r := range a.rows {
+		if r.node == selected {
+			a.cursor = i
+			return
+		}
+	}
+	a.move(0)
+}
+
+// applySearch filters the tree with the search query. An invalid query keeps
+// the previous results, as it's usually still being typed.
+func (a *app) applySearch() {
+	expr := string(a.search)
+	if strings.TrimSpace(expr) == "" {
+		a.shown = nil
+		a.rebuild()
+		return
+	}
+
+	q, err := query.Parse(expr)
+	if err != nil {
+		a.setStatus("Invalid search: "+err.Error(), true)
+		return
+	}
+	cfg := a.manager.GetConfig()
+	q.IsSecret = cfg.IsSecretField
+
+	results := q.Filter(cfg.Connections)
+	a.shown = make(map[*models.Connection]bool)
+	for _, result := range results {
+		for node := result.Connection; node != nil; node = a.manager.ParentOf(node) {
+			a.shown[node] = true
+		}
+	}
+	a.matches = len(results)
+	a.rebuild()
+
+	// Select the first match unless the selection still matches
+	if selected := a.selected(); selected == nil || selected.IsFolder() {
+		for i, r := range a.rows {
+			if !r.node.IsFolder() {
+				a.cursor = i
+				break
+			}
+		}
+	}
+}
+
+// refresh redraws the tree after a change, re-running the search
+func (a *app) refresh() {
+	if a.shown != nil {
+		a.applySearch()
+		return
+	}
+	a.rebuild()
+}
+
+// move moves the cursor, keeping it within the rows
+func (a *app) move(delta int) {
+	a.cursor += delta
+	if a.cursor >= len(a.rows) {
+		a.cursor = len(a.rows) - 1
+	}
+	if a.cursor < 0 {
+		a.cursor = 0
+	}
+}
+
+// handle applies a key press according to the current mode
+func (a *app) handle(ev keyEvent, height int) {
+	switch a.mode {
+	case modeSearch:
+		a.handleSearch(ev)
+	case modeConfirm:
+		a.mode = modeBrowse
+		if ev.key == keyRune && (ev.r == 'y' || ev.r == 'Y') {
+			if err := a.onConfirm(); err != nil {
+				a.setStatus(err.Error(), true)
+			}
+		} else {
+			a.setStatus("Cancelled", false)
+		}
+	case modeForm:
+		switch a.form.handle(ev) {
+		case formSubmitted:
+			a.mode, a.form = modeBrowse, nil
+		case formCancelled:
+			a.mode, a.form = modeBrowse, nil
+			a.setStatus("Cancelled", false)
+		}
+	case modeHelp:
+		a.mode = modeBrowse
+	default:
+		a.setStatus("", false)
+		a.handleBrowse(ev, height)
+	}
+}
+
+// handleSearch edits the search query, filtering as it's typed
+func (a *app) handleSearch(ev keyEvent) {
+	switch ev.key {
+	case keyEnter:
+		a.mode = modeBrowse
+		return
+	case keyCancel, keyInterrupt:
+		a.mode, a.search = modeBrowse, nil
+	case keyBackspace:
+		if len(a.search) > 0 {
+			a.search = a.search[:len(a.search)-1]
+		}
+	case keyDeleteWord:
+		a.search = deleteWord(a.search)
+	case keyClear:
+		a.search = nil
+	case keyUp, keyDown:
+		// Let the selection move while searching
+		a.mode = modeBrowse
+		a.handleBrowse(ev, 0)
+		a.mode = modeSearch
+		return
+	case keyRune:
+		a.search = append(a.search, ev.r)
+	default:
+		return
+	}
+	a.setStatus("", false)
+	a.applySearch()
+}
+
+// handleBrowse handles navigation and commands
+func (a *app) handleBrowse(ev keyEvent, height int) {
+	page := height - 4
+	if page < 1 {
+		page = 1
+	}
+	node := a.selected()
+
+	switch ev.key {
+	case keyUp:
+		a.move(-1)
+	case keyDown:
+		a.move(1)
+	case keyPageUp:
+		a.move(-page)
+	case keyPageDown:
+		a.move(page)
+	case keyHome:
+		a.cursor = 0
+	case keyEnd:
+		a.move(len(a.rows))
+	case keyRight:
+		a.expand(node)
+	case keyLeft:
+		a.collapse(node)
+	case keyEnter:
+		if node != nil && node.IsFolder() {
+			a.toggle(node)
+		} else {
+			a.connect(node)
+		}
+	case keyDelete:
+		a.delete(node)
+	case keyCancel:
+		if a.shown != nil {
+			a.search = nil
+			a.applySearch()
+		}
+	case keyInterrupt:
+		a.quit = true
+	case keyRune:
+		switch ev.r {
+		case 'k':
+			a.move(-1)
+		case 'j':
+			a.move(1)
+		case 'g':
+			a.cursor = 0
+		case 'G':
+			a.move(len(a.rows))
+		case 'l':
+			a.expand(node)
+		case 'h':
+			a.collapse(node)
+		case 'c':
+			a.connect(node)
+		case 'e':
+			a.edit(node)
+		case 'a':
+			a.add(node)
+		case 'f':
+			a.addFolder(node)
+		case 'd':
+			a.delete(node)
+		case '/':
+			a.mode = modeSearch
+		case '?':
+			a.mode = modeHelp
+		case 'q':
+			a.quit = true
+		}
+	}
+}
+
+// expand opens a folder, or moves into it when it's already open
+func (a *app) expand(node *models.Connection) {
+	if node == nil || !node.IsFolder() {
+		return
+	}
+	if a.expanded[node] || a.shown != nil {
+		a.move(1)
+		return
+	}
+	a.expanded[node] = true
+	a.rebuild()
+}
+
+// collapse closes a folder, or moves to the folder above
+func (a *app) collapse(node *models.Connection) {
+	if node == nil {
+		return
+	}
+	if node.IsFolder() && a.expanded[node] && a.shown == nil {
+		a.expanded[node] = false
+		a.rebuild()
+		return
+	}
+	parent := a.manager.ParentOf(node)
+	for i, r := range a.rows {
+		if parent != nil && r.node == parent {
+			a.cursor = i
+			return
+		}
+	}
+}
+
+// toggle opens or closes a folder
+func (a *app) toggle(folder *models.Connection) {
+	if a.shown != nil {
+		return
+	}
+	a.expanded[folder] = !a.expanded[folder]
+	a.rebuild()
+}
+
+// folderPath returns the path of the folder holding a node, "" for the top level
+func (a *app) folderPath(node *models.Connection) string {
+	if parent := a.manager.ParentOf(node); parent != nil {
+		return a.manager.NodePath(parent)
+	}
+	return ""
+}
+
+// targetFolder returns the folder new entries are added to: the selected
+// folder or the folder of the selected connection (nil for the top level)
+func (a *app) targetFolder(node *models.Connection) *models.Connection {
+	if node == nil {
+		return nil
+	}
+	if node.IsFolder() {
+		return node
+	}
+	return a.manager.ParentOf(node)
+}
+
+// save writes the config and reports the outcome on the status line
+func (a *app) save(message string) error {
+	if err := a.manager.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	a.refresh()
+	a.setStatus("✓ "+message, false)
+	return nil
+}
+
+// connect launches a connection. The UI steps aside while it runs, so
+// sessions without a terminal emulator (e.g. over SSH) use this terminal.
+func (a *app) connect(node *models.Connection) {
+	if node == nil || node.IsFolder() {
+		return
+	}
+
+	resolved, err := a.manager.ResolveConnection(node, nil)
+	if err != nil {
+		a.setStatus(err.Error(), true)
+		return
+	}
+
+	a.term.suspend()
+	fmt.Fprintf(os.Stdout, "Connecting to %s...\n", node.Name)
+	err = a.launcher.Launch(resolved)
+	if resumeErr := a.term.resume(); resumeErr != nil {
+		a.quit = true
+		return
+	}
+
+	if err != nil {
+		a.setStatus("Failed to launch connection: "+err.Error(), true)
+		return
+	}
+	a.setStatus(fmt.Sprintf("✓ Launched connection to '%s'", node.Name), false)
+}
+
+// delete asks to confirm, then removes a connection or folder
+func (a *app) delete(node *models.Connection) {
+	if node == nil {
+		return
+	}
+
+	a.confirm = fmt.Sprintf("Delete '%s'? (y/N)", node.Name)
+	if node.IsFolder() && len(node.Children) > 0 {
+		a.confirm = fmt.Sprintf("Delete folder '%s' and its %d item(s)? (y/N)", node.Name, len(node.Children))
+	}
+	a.onConfirm = func() error {
+		if err := a.manager.DeleteNode(node); err != nil {
+			return err
+		}
+		return a.save(fmt.Sprintf("Deleted '%s'", node.Name))
+	}
+	a.mode = modeConfirm
+}
+
+// add opens a form for a new connection in the target folder
+func (a *app) add(node *models.Connection) {
+	folder := a.targetFolder(node)
+	folderPath := ""
+	title := "New connection"
+	if folder != nil {
+		folderPath = a.manager.NodePath(folder)
+		title += " in " + folderPath
+	}
+
+	f := &form{title: title}
+	f.addField("name", "Name", "", false)
+	f.addField("protocol", "Protocol", string(models.ProtocolSSH), false)
+	f.addField("host", "Host", "", false)
+	f.addField("port", "Port", "", false)
+	f.addField("username", "Username", "", false)
+	f.addField("password", "Password", "", true)
+	f.addField("description", "Description", "", false)
+	f.addField("tags", "Tags", "", false)
+	f.submit = func(values map[string]string) error {
+		if values["name"] == "" {
+			return fmt.Errorf("name cannot be empty")
+		}
+		if _, err := a.manager.FindConnection(values["name"]); err == nil {
+			return fmt.Errorf("connection '%s' already exists", values["name"])
+		}
+
+		conn := models.NewConnection(values["name"], "")
+		if err := setConnectionValues(conn, values); err != nil {
+			return err
+		}
+		if err := a.manager.AddConnection(conn, folderPath); err != nil {
+			return err
+		}
+		if folder != nil {
+			a.expanded[folder] = true
+		}
+		if err := a.save(fmt.Sprintf("Added '%s'", conn.Name)); err != nil {
+			return err
+		}
+		a.selectNode(conn)
+		return nil
+	}
+	a.form, a.mode = f, modeForm
+}
+
+// addFolder opens a form for a new folder in the target folder
+func (a *app) addFolder(node *models.Connection) {
+	parent := a.targetFolder(node)
+	title := "New folder"
+	if parent != nil {
+		title += " in " + a.manager.NodePath(parent)
+	}
+
+	f := &form{title: title}
+	f.addField("name", "Name", "", false)
+	f.addField("description", "Description", "", false)
+	f.addField("tags", "Tags", "", false)
+	f.submit = func(values map[string]string) error {
+		if values["name"] == "" {
+			return fmt.Errorf("name cannot be empty")
+		}
+		if strings.Contains(values["name"], "/") {
+			return fmt.Errorf("folder names can't contain '/'")
+		}
+		path := values["name"]
+		if parent != nil {
+			path = a.manager.NodePath(parent) + "/" + path
+		}
+
+		folder, err := a.manager.CreateFolder(path, false)
+		if err != nil {
+			return err
+		}
+		folder.Description = values["description"]
+		folder.Tags = splitTags(values["tags"])
+		if parent != nil {
+			a.expanded[parent] = true
+		}
+		if err := a.save(fmt.Sprintf("Added folder '%s'", path)); err != nil {
+			return err
+		}
+		a.selectNode(folder)
+		return nil
+	}
+	a.form, a.mode = f, modeForm
+}
+
+// edit opens a form with the common settings of a connection or folder; only
+// the settings that are changed are written
+func (a *app) edit(node *models.Connection) {
+	if node == nil {
+		return
+	}
+
+	f := &form{title: "Edit " + a.manager.NodePath(node)}
+	f.addField("name", "Name", node.Name, false)
+	if !node.IsFolder() {
+		port := ""
+		if node.Port != 0 {
+			port = strconv.Itoa(node.Port)
+		}
+		f.addField("protocol", "Protocol", string(node.Protocol), false)
+		f.addField("host", "Host", node.Host, false)
+		f.addField("port", "Port", port, false)
+		f.addField("username", "Username", node.Username, false)
+		f.addField("password", "Password", node.Password, true)
+		f.addField("domain", "Domain", node.Domain, false)
+		f.addField("jump_host", "Jump Host", node.JumpHost, false)
+	}
+	f.addField("description", "Description", node.Description, false)
+	f.addField("tags", "Tags", strings.Join(node.Tags, ", "), false)
+
+	original := f.values()
+	f.submit = func(values map[string]string) error {
+		updates := node.DeepCopy()
+		if err := setConnectionValues(updates, values); err != nil {
+			return err
+		}
+
+		var mask []string
+		for _, field := range f.fields {
+			if values[field.name] != original[field.name] {
+				mask = append(mask, field.name)
+			}
+		}
+		if len(mask) == 0 {
+			a.setStatus("Nothing changed", false)
+			return nil
+		}
+
+		if err := a.manager.UpdateNodeFields(node, updates, mask); err != nil {
+			return err
+		}
+		return a.save(fmt.Sprintf("Updated '%s'", node.Name))
+	}
+	a.form, a.mode = f, modeForm
+}
+
+// setConnectionValues copies form values onto a connection; fields that
+// aren't in the form are left alone
+func setConnectionValues(conn *models.Connection, values map[string]string) error {
+	for name, value := range values {
+		switch name {
+		case "name":
+			conn.Name = value
+		case "protocol":
+			protocol := models.NormalizeProtocol(value)
+			if !protocol.IsSupported() {
+				return fmt.Errorf("unsupported protocol '%s'", value)
+			}
+			conn.Protocol = protocol
+		case "host":
+			conn.Host = value
+		case "port":
+			conn.Port = 0
+			if value != "" {
+				port, err := strconv.Atoi(value)
+				if err != nil || port < 1 || port > 65535 {
+					return fmt.Errorf("invalid port '%s'", value)
+				}
+				conn.Port = port
+			}
+		case "username":
+			conn.Username = value
+		case "password":
+			conn.Password = value
+		case "domain":
+			conn.Domain = value
+		case "jump_host":
+			conn.JumpHost = value
+		case "description":
+			conn.Description = value
+		case "tags":
+			conn.Tags = splitTags(value)
+		}
+	}
+	return nil
+}
+
+// splitTags splits a comma-separated list of tags
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// selectNode moves the cursor to a node, opening the folders above it
+func (a *app) selectNode(node *models.Connection) {
+	for parent := a.manager.ParentOf(node); parent != nil; parent = a.manager.ParentOf(parent) {
+		a.expanded[parent] = true
+	}
+	a.rebuild()
+	for i, r := range a.rows {
+		if r.node == node {
+			a.cursor = i
+			return
+		}
+	}
+}
+
+// render draws the title, the tree, the details pane and the status lines
+func (a *app) render(width, height int) string {
+	rows := height - 3
+	if rows < 1 {
+		rows = 1
+	}
+	if a.cursor < a.offset {
+		a.offset = a.cursor
+	}
+	if a.cursor >= a.offset+rows {
+		a.offset = a.cursor - rows + 1
+	}
+
+	treeWidth, paneWidth := width, 0
+	if width >= 60 {
+		treeWidth = width * 2 / 5
+		paneWidth = width - treeWidth - 3
+	}
+
+	pane, cursorLine, cursorColumn := a.paneLines(paneWidth)
+
+	var b strings.Builder
+	b.WriteString(hideCursor + home)
+	b.WriteString(reverse + pad(a.title(), width) + reset + clearLine)
+
+	for i := 0; i < rows; i++ {
+		b.WriteString("\r\n")
+		if index := a.offset + i; index < len(a.rows) {
+			b.WriteString(a.treeLine(a.rows[index], index == a.cursor, treeWidth))
+		} else if i == 0 && len(a.rows) == 0 {
+			b.WriteString(colorDetail + pad(a.emptyMessage(), treeWidth) + colorNormal)
+		} else {
+			b.WriteString(strings.Repeat(" ", treeWidth))
+		}
+		if paneWidth > 0 {
+			b.WriteString(colorDetail + " │ " + colorNormal)
+			if i < len(pane) {
+				b.WriteString(pane[i])
+			}
+		}
+		b.WriteString(clearLine)
+	}
+
+	b.WriteString("\r\n" + a.statusLine(width) + clearLine)
+	b.WriteString("\r\n" + colorDetail + truncate(keyHints, width) + colorNormal + clearLine)
+	b.WriteString(clearBelow)
+
+	// Show the text cursor where text is being typed
+	switch a.mode {
+	case modeSearch:
+		column := min(utf8.RuneCountInString(searchPrompt)+len(a.search)+1, width)
+		fmt.Fprintf(&b, "\x1b[%d;%dH%s", height-1, column, showCursor)
+	case modeForm:
+		if paneWidth > 0 {
+			fmt.Fprintf(&b, "\x1b[%d;%dH%s", cursorLine+2, treeWidth+4+cursorColumn, showCursor)
+		}
+	}
+	return b.String()
+}
+
+// title returns the top line: the config file and the active profile
+func (a *app) title() string {
+	title := " MremoteGO · " + a.manager.GetConfigPath()
+	if profile := a.manager.GetProfile(); profile != "" {
+		title += " · profile: " + profile
+	}
+	return title
+}
+
+// emptyMessage explains an empty tree
+func (a *app) emptyMessage() string {
+	if a.shown != nil {
+		return "No connections match the search"
+	}
+	return "No connections yet; press a to add one"
+}
+
+// treeLine draws one row of the tree
+func (a *app) treeLine(r row, selected bool, width int) string {
+	node := r.node
+	label := strings.Repeat("  ", r.depth)
+	detail := ""
+	if node.IsFolder() {
+		if a.expanded[node] || a.shown != nil {
+			label += "▾ "
+		} else {
+			label += "▸ "
+		}
+		label += node.Name
+	} else {
+		label += "  " + node.Name
+		detail = "  " + node.Host
+	}
+
+	text := []rune(truncate(label+detail, width))
+	if selected {
+		return reverse + pad(string(text), width) + reset
+	}
+
+	labelLength := utf8.RuneCountInString(label)
+	line := string(text)
+	if len(text) > labelLength {
+		line = string(text[:labelLength]) + colorDetail + string(text[labelLength:]) + colorNormal
+	}
+	if node.IsFolder() {
+		line = colorFolder + line + colorNormal
+	}
+	return line + strings.Repeat(" ", width-len(text))
+}
+
+// paneLines returns the right-hand pane: the form, the help or the selected
+// node's details, with the form's text cursor position
+func (a *app) paneLines(width int) ([]string, int, int) {
+	if width <= 0 {
+		return nil, 0, 0
+	}
+
+	var lines []string
+	switch {
+	case a.mode == modeForm:
+		return a.form.render(width)
+	case a.mode == modeHelp:
+		lines = helpLines
+	case a.selected() != nil:
+		node := a.selected()
+		if node.IsFolder() {
+			lines = folderPreview(node, a.manager.NodePath(node))
+		} else {
+			result := query.Result{Connection: node, Folder: a.folderPath(node)}
+			lines = connectionPreview(result, a.manager.GetConfig())
+		}
+	}
+
+	truncated := make([]string, len(lines))
+	for i, line := range lines {
+		truncated[i] = truncate(line, width)
+	}
+	return truncated, 0, 0
+}
+
+// statusLine returns the line above the key hints: the search being typed,
+// a question, the last message or the active search
+func (a *app) statusLine(width int) string {
+	switch {
+	case a.mode == modeSearch:
+		return truncate(searchPrompt+string(a.search), width)
+	case a.mode == modeConfirm:
+		return bold + truncate(a.confirm, width) + reset
+	case a.status != "" && a.statusErr:
+		return colorError + truncate(a.status, width) + colorNormal
+	case a.status != "":
+		return truncate(a.status, width)
+	case a.shown != nil:
+		return truncate(fmt.Sprintf("Search: %s · %d match(es) · Esc clears", string(a.search), a.matches), width)
+	}
+	return ""
+}
+
+// pad truncates or pads s with spaces to exactly width runes
+func pad(s string, width int) string {
+	s = truncate(s, width)
+	if n := utf8.RuneCountInString(s); n < width {
+		s += strings.Repeat(" ", width-n)
+	}
+	return s
+}
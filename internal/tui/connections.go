@@ -0,0 +1,107 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jaydenthorup/mremotego/internal/query"
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// ConnectionItems builds picker items for connections: the label is the
+// connection's path and the detail its host and tags, so all of them can be
+// searched. Secret custom fields are left out of the preview.
+func ConnectionItems(results []query.Result, cfg *models.Config) []Item {
+	items := make([]Item, len(results))
+	for i, result := range results {
+		conn := result.Connection
+
+		detail := conn.Host
+		for _, tag := range conn.Tags {
+			detail += " #" + tag
+		}
+
+		items[i] = Item{
+			Label:   result.Path(),
+			Detail:  strings.TrimSpace(detail),
+			Preview: connectionPreview(result, cfg),
+		}
+	}
+	return items
+}
+
+// connectionPreview lists a connection's details for the preview pane
+func connectionPreview(result query.Result, cfg *models.Config) []string {
+	conn := result.Connection
+	lines := []string{conn.Name, ""}
+	add := func(label, value string) {
+		if value != "" {
+			lines = append(lines, fmt.Sprintf("%-12s %s", label+":", singleLine(value)))
+		}
+	}
+
+	folder := result.Folder
+	if folder == "" {
+		folder = "/"
+	}
+	add("Folder", folder)
+	add("Protocol", string(conn.Protocol))
+	address := conn.Host
+	if conn.Port != 0 {
+		address = fmt.Sprintf("%s:%d", conn.Host, conn.Port)
+	}
+	add("Host", address)
+	add("Username", conn.Username)
+	add("Domain", conn.Domain)
+	add("Jump Host", conn.JumpHost)
+	add("Description", conn.Description)
+	add("Tags", strings.Join(conn.Tags, ", "))
+
+	for _, name := range conn.CustomFields.Keys() {
+		label := name
+		if schema := cfg.FindCustomField(name); schema != nil {
+			label = schema.DisplayLabel()
+		}
+		if cfg.IsSecretField(name) {
+			add(label, "••••••••")
+		} else {
+			add(label, conn.CustomFields.String(name))
+		}
+	}
+
+	return appendNotes(lines, conn.Notes)
+}
+
+// folderPreview lists a folder's details for the details pane
+func folderPreview(folder *models.Connection, path string) []string {
+	lines := []string{folder.Name, ""}
+	add := func(label, value string) {
+		if value != "" {
+			lines = append(lines, fmt.Sprintf("%-12s %s", label+":", singleLine(value)))
+		}
+	}
+
+	add("Path", path)
+	add("Items", fmt.Sprintf("%d", len(folder.Children)))
+	add("Description", folder.Description)
+	add("Tags", strings.Join(folder.Tags, ", "))
+
+	return appendNotes(lines, folder.Notes)
+}
+
+// appendNotes adds a notes section to preview lines
+func appendNotes(lines []string, notes string) []string {
+	if notes == "" {
+		return lines
+	}
+	lines = append(lines, "", "Notes:")
+	for _, line := range strings.Split(strings.TrimRight(notes, "\n"), "\n") {
+		lines = append(lines, "  "+singleLine(line))
+	}
+	return lines
+}
+
+// singleLine replaces tabs and line breaks, which would break the layout
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", " ", "\t", " ").Replace(s)
+}
@@ -0,0 +1,125 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// formField is one input of a form
+type formField struct {
+	name   string // key passed to submit
+	label  string
+	value  []rune
+	secret bool // shown masked, e.g. passwords
+}
+
+// form is a list of text inputs shown in place of the details pane, used to
+// add and edit connections and folders
+type form struct {
+	title  string
+	fields []formField
+	focus  int
+	err    string
+
+	// submit saves the values, keyed by field name; an error keeps the form open
+	submit func(values map[string]string) error
+}
+
+// formDone reports how handling a key affected a form
+type formDone int
+
+const (
+	formOpen formDone = iota
+	formSubmitted
+	formCancelled
+)
+
+// addField appends an input with an initial value
+func (f *form) addField(name, label, value string, secret bool) {
+	f.fields = append(f.fields, formField{name: name, label: label, value: []rune(value), secret: secret})
+}
+
+// values returns the current value of every field
+func (f *form) values() map[string]string {
+	values := make(map[string]string, len(f.fields))
+	for _, field := range f.fields {
+		value := string(field.value)
+		if !field.secret {
+			value = strings.TrimSpace(value)
+		}
+		values[field.name] = value
+	}
+	return values
+}
+
+// handle applies a key press to the focused field
+func (f *form) handle(ev keyEvent) formDone {
+	field := &f.fields[f.focus]
+	switch ev.key {
+	case keyCancel, keyInterrupt:
+		return formCancelled
+	case keyEnter:
+		if err := f.submit(f.values()); err != nil {
+			f.err = err.Error()
+			return formOpen
+		}
+		return formSubmitted
+	case keyTab, keyDown:
+		f.focus = (f.focus + 1) % len(f.fields)
+	case keyBackTab, keyUp:
+		f.focus = (f.focus + len(f.fields) - 1) % len(f.fields)
+	case keyBackspace:
+		if len(field.value) > 0 {
+			field.value = field.value[:len(field.value)-1]
+		}
+	case keyDeleteWord:
+		field.value = deleteWord(field.value)
+	case keyClear:
+		field.value = nil
+	case keyRune:
+		field.value = append(field.value, ev.r)
+	}
+	return formOpen
+}
+
+// formLabelWidth is the width of the label column
+const formLabelWidth = 13
+
+// render returns the form's lines and the position of the text cursor within
+// them (line, column), both counted from 0
+func (f *form) render(width int) ([]string, int, int) {
+	lines := []string{bold + truncate(f.title, width) + reset, ""}
+	cursorLine, cursorColumn := 0, 0
+
+	for i, field := range f.fields {
+		value := string(field.value)
+		if field.secret {
+			value = strings.Repeat("•", len(field.value))
+		}
+
+		marker := "  "
+		if i == f.focus {
+			marker = "> "
+			cursorLine = len(lines)
+			cursorColumn = len(marker) + formLabelWidth + utf8.RuneCountInString(value)
+		}
+		label := fmt.Sprintf("%-*s", formLabelWidth, field.label+":")
+		line := truncate(marker+label+value, width)
+		if i == f.focus {
+			line = bold + line + reset
+		}
+		lines = append(lines, line)
+	}
+
+	lines = append(lines, "")
+	if f.err != "" {
+		lines = append(lines, colorError+truncate(f.err, width)+colorNormal)
+	}
+	lines = append(lines, colorDetail+truncate("Tab/↑↓ move · Enter save · Esc cancel", width)+colorNormal)
+
+	if cursorColumn >= width {
+		cursorColumn = width - 1
+	}
+	return lines, cursorLine, cursorColumn
+}
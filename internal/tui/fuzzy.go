@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Scores for fuzzy matches; a matched rune earns scoreMatch plus bonuses when
+// it follows the previous match or starts a word
+const (
+	scoreMatch       = 16
+	bonusConsecutive = 8
+	bonusBoundary    = 8
+	penaltyGap       = 1
+)
+
+// Match fuzzy-matches a pattern against text. Each space-separated word of the
+// pattern must appear in text with its runes in order, ignoring case. It
+// returns a score (higher is better) and the rune positions in text that
+// matched, for highlighting.
+func Match(pattern, text string) (int, []int, bool) {
+	runes := []rune(strings.ToLower(text))
+	original := []rune(text)
+
+	score := 0
+	var positions []int
+	for _, word := range strings.Fields(strings.ToLower(pattern)) {
+		wordScore, wordPositions, ok := matchWord([]rune(word), runes, original)
+		if !ok {
+			return 0, nil, false
+		}
+		score += wordScore
+		positions = append(positions, wordPositions...)
+	}
+	return score, positions, true
+}
+
+// matchWord finds the shortest match of a word: a forward scan finds where the
+// first full match ends, a backward scan from there finds its latest start
+func matchWord(word, runes, original []rune) (int, []int, bool) {
+	if len(word) == 0 {
+		return 0, nil, true
+	}
+
+	end, wi := -1, 0
+	for i, r := range runes {
+		if r == word[wi] {
+			wi++
+			if wi == len(word) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	start, wi := end, len(word)-1
+	for i := end; i >= 0; i-- {
+		if runes[i] == word[wi] {
+			wi--
+			if wi < 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	score := 0
+	positions := make([]int, 0, len(word))
+	wi = 0
+	for i := start; i <= end && wi < len(word); i++ {
+		if runes[i] != word[wi] {
+			score -= penaltyGap
+			continue
+		}
+		score += scoreMatch
+		if len(positions) > 0 && positions[len(positions)-1] == i-1 {
+			score += bonusConsecutive
+		}
+		if isWordStart(original, i) {
+			score += bonusBoundary
+		}
+		positions = append(positions, i)
+		wi++
+	}
+	return score, positions, true
+}
+
+// isWordStart reports whether the rune at i starts a word, e.g. after a path
+// separator or space, or at a lower-to-upper case change
+func isWordStart(runes []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev, cur := runes[i-1], runes[i]
+	if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+		return true
+	}
+	return unicode.IsLower(prev) && unicode.IsUpper(cur)
+}
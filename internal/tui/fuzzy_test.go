@@ -0,0 +1,108 @@
+package tui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		text      string
+		ok        bool
+		positions []int
+	}{
+		// Empty patterns match everything
+		{"", "web1", true, nil},
+		{"   ", "web1", true, nil},
+		{"", "", true, nil},
+
+		// Runes match in order, with gaps
+		{"web", "web1", true, []int{0, 1, 2}},
+		{"wb1", "web1", true, []int{0, 2, 3}},
+		{"pw1", "Production/web1", true, []int{0, 11, 14}},
+		{"bew", "web1", false, nil},
+		{"web12", "web1", false, nil},
+		{"x", "", false, nil},
+
+		// Case is ignored on both sides
+		{"WEB", "web1", true, []int{0, 1, 2}},
+		{"pweb", "Production/WEB1", true, []int{0, 11, 12, 13}},
+		{"istanbul", "İstanbul", true, []int{0, 1, 2, 3, 4, 5, 6, 7}},
+		{"ß", "Straße", true, []int{4}},
+
+		// The shortest match is used
+		{"ab", "a-x-a-b", true, []int{4, 6}},
+
+		// Every word must match, in any order
+		{"web prod", "Production/web1", true, []int{11, 12, 13, 0, 1, 2, 3}},
+		{"web staging", "Production/web1", false, nil},
+	}
+
+	for _, tt := range tests {
+		_, positions, ok := Match(tt.pattern, tt.text)
+		if ok != tt.ok || !reflect.DeepEqual(positions, tt.positions) {
+			t.Errorf("Match(%q, %q) = %v, %v; want %v, %v", tt.pattern, tt.text, positions, ok, tt.positions, tt.ok)
+		}
+	}
+}
+
+func TestMatchRanking(t *testing.T) {
+	tests := []struct {
+		pattern string
+		better  string
+		worse   string
+	}{
+		{"web", "web1", "wide-eb"},                          // consecutive over scattered
+		{"web", "webserver", "a-w-e-b"},                     // prefix over scattered word starts
+		{"db", "Production/db1", "Production/dashboard"},    // word start over a gap
+		{"srv", "app-srv", "serverless"},                    // consecutive over gaps
+		{"web", "Web1", "someweb"},                          // prefix over the middle of a word
+		{"ws", "WebServer", "wasabi sauce"},                 // case changes start words
+		{"web1", "Production/web1", "Production/web/line1"}, // fewer gaps
+	}
+
+	for _, tt := range tests {
+		better, _, okBetter := Match(tt.pattern, tt.better)
+		worse, _, okWorse := Match(tt.pattern, tt.worse)
+		if !okBetter || !okWorse {
+			t.Errorf("%q: expected both %q and %q to match", tt.pattern, tt.better, tt.worse)
+			continue
+		}
+		if better <= worse {
+			t.Errorf("%q: %q scores %d, not above %q with %d", tt.pattern, tt.better, better, tt.worse, worse)
+		}
+	}
+}
+
+func TestPickerFilter(t *testing.T) {
+	items := []Item{
+		{Label: "Staging/wide-eb"},
+		{Label: "Production/db1", Detail: "db1.example.com"},
+		{Label: "Production/web1", Detail: "web1.example.com"},
+		{Label: "web2"},
+	}
+
+	tests := []struct {
+		query string
+		want  string // Labels of the matches, best first
+	}{
+		{"", "Staging/wide-eb,Production/db1,Production/web1,web2"}, // Empty queries keep the item order
+		{"web", "Production/web1,web2,Staging/wide-eb"},             // Equal scores keep the item order
+		{"WEB1", "Production/web1"},
+		{"example db1", "Production/db1,Production/web1"},
+		{"vnc", ""},
+	}
+
+	for _, tt := range tests {
+		p := newPicker(items, "> ", tt.query)
+		labels := make([]string, len(p.matches))
+		for i, m := range p.matches {
+			labels[i] = items[m.index].Label
+		}
+		if got := strings.Join(labels, ","); got != tt.want {
+			t.Errorf("%q: matches %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
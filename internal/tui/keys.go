@@ -0,0 +1,137 @@
+package tui
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// key identifies a key press read from a terminal in raw mode
+type key int
+
+const (
+	keyRune key = iota
+	keyEnter
+	keyBackspace
+	keyDeleteWord
+	keyClear
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyTab
+	keyBackTab
+	keyDelete
+	keyPageUp
+	keyPageDown
+	keyCancel
+	keyInterrupt
+	keyEOF
+	keyUnknown
+)
+
+// keyEvent is a key press; r is set for keyRune
+type keyEvent struct {
+	key key
+	r   rune
+}
+
+// escapeKeys maps the escape sequences of special keys
+var escapeKeys = map[string]key{
+	"[A":  keyUp,
+	"[B":  keyDown,
+	"[C":  keyRight,
+	"[D":  keyLeft,
+	"OA":  keyUp,
+	"OB":  keyDown,
+	"OC":  keyRight,
+	"OD":  keyLeft,
+	"[H":  keyHome,
+	"[F":  keyEnd,
+	"OH":  keyHome,
+	"OF":  keyEnd,
+	"[1~": keyHome,
+	"[4~": keyEnd,
+	"[3~": keyDelete,
+	"[Z":  keyBackTab,
+	"[5~": keyPageUp,
+	"[6~": keyPageDown,
+}
+
+// parseKeys splits raw terminal input into key presses
+func parseKeys(buf []byte) []keyEvent {
+	var events []keyEvent
+	for len(buf) > 0 {
+		b := buf[0]
+		switch {
+		case b == 0x1b:
+			if len(buf) == 1 {
+				// A lone escape is the Esc key
+				return append(events, keyEvent{key: keyCancel})
+			}
+			n, k := parseEscape(buf[1:])
+			events = append(events, keyEvent{key: k})
+			buf = buf[1+n:]
+			continue
+		case b == '\r':
+			events = append(events, keyEvent{key: keyEnter})
+		case b == '\t':
+			events = append(events, keyEvent{key: keyTab})
+		case b == 0x7f || b == 0x08:
+			events = append(events, keyEvent{key: keyBackspace})
+		case b == 0x03:
+			events = append(events, keyEvent{key: keyInterrupt})
+		case b == 0x04:
+			events = append(events, keyEvent{key: keyEOF})
+		case b == 0x17:
+			events = append(events, keyEvent{key: keyDeleteWord})
+		case b == 0x15:
+			events = append(events, keyEvent{key: keyClear})
+		case b == 0x10 || b == 0x0b: // Ctrl+P, Ctrl+K
+			events = append(events, keyEvent{key: keyUp})
+		case b == 0x0e || b == 0x0a: // Ctrl+N, Ctrl+J
+			events = append(events, keyEvent{key: keyDown})
+		case b < 0x20:
+			events = append(events, keyEvent{key: keyUnknown})
+		default:
+			r, size := utf8.DecodeRune(buf)
+			if r != utf8.RuneError && unicode.IsPrint(r) {
+				events = append(events, keyEvent{key: keyRune, r: r})
+			}
+			buf = buf[size:]
+			continue
+		}
+		buf = buf[1:]
+	}
+	return events
+}
+
+// parseEscape reads the escape sequence after an ESC byte and returns its
+// length; unrecognised sequences are consumed as keyUnknown
+func parseEscape(buf []byte) (int, key) {
+	if buf[0] != '[' && buf[0] != 'O' {
+		// Alt+key; the key itself is read as usual
+		return 0, keyUnknown
+	}
+	for i := 1; i < len(buf); i++ {
+		// A sequence ends with a byte in the range @ to ~
+		if buf[i] >= 0x40 && buf[i] <= 0x7e {
+			if k, exists := escapeKeys[string(buf[:i+1])]; exists {
+				return i + 1, k
+			}
+			return i + 1, keyUnknown
+		}
+	}
+	return len(buf), keyUnknown
+}
+
+// deleteWord removes the last word of typed text, as Ctrl+W does in a shell
+func deleteWord(text []rune) []rune {
+	trimmed := strings.TrimRight(string(text), " ")
+	if i := strings.LastIndex(trimmed, " "); i >= 0 {
+		return []rune(trimmed[:i+1])
+	}
+	return nil
+}
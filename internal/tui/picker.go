@@ -0,0 +1,262 @@
+// Package tui implements the interactive terminal interfaces of the CLI, such
+// as the fuzzy connection picker used by 'mremotego connect'.
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrCancelled is returned when the picker is left without choosing an item
+var ErrCancelled = errors.New("cancelled")
+
+// Item is one entry of a picker
+type Item struct {
+	Label   string   // shown in the list, e.g. the connection's path
+	Detail  string   // shown dimmed after the label, e.g. host and tags
+	Preview []string // lines shown in the preview pane while the item is selected
+}
+
+// text returns what the query is matched against
+func (i Item) text() string {
+	if i.Detail == "" {
+		return i.Label
+	}
+	return i.Label + "  " + i.Detail
+}
+
+// minPreviewWidth is the narrowest terminal that shows the preview pane
+const minPreviewWidth = 80
+
+// Pick shows a full-screen fuzzy picker over items, starting with the given
+// query, and returns the index of the chosen item. It returns ErrCancelled
+// when the user presses Esc or Ctrl+C.
+func Pick(items []Item, prompt, initial string) (int, error) {
+	t, err := openTerminal()
+	if err != nil {
+		return -1, err
+	}
+	defer t.close()
+
+	p := newPicker(items, prompt, initial)
+	for {
+		width, height := t.size()
+		t.draw(p.render(width, height))
+
+		events, err := t.readKeys()
+		if err != nil {
+			return -1, err
+		}
+		for _, ev := range events {
+			if done, index, err := p.handle(ev, height); done {
+				return index, err
+			}
+		}
+	}
+}
+
+// match is an item that matches the current query
+type match struct {
+	index     int
+	score     int
+	positions []int
+}
+
+// picker holds the state of a running picker
+type picker struct {
+	items   []Item
+	prompt  string
+	query   []rune
+	matches []match
+	cursor  int // selected entry of matches
+	offset  int // first visible entry of matches
+}
+
+func newPicker(items []Item, prompt, initial string) *picker {
+	p := &picker{items: items, prompt: prompt, query: []rune(initial)}
+	p.filter()
+	return p
+}
+
+// filter matches every item against the query, best matches first
+func (p *picker) filter() {
+	p.matches = p.matches[:0]
+	query := string(p.query)
+	for i, item := range p.items {
+		if score, positions, ok := Match(query, item.text()); ok {
+			p.matches = append(p.matches, match{index: i, score: score, positions: positions})
+		}
+	}
+	sort.SliceStable(p.matches, func(a, b int) bool {
+		return p.matches[a].score > p.matches[b].score
+	})
+	p.cursor, p.offset = 0, 0
+}
+
+// handle applies a key press; done is set when the picker should close
+func (p *picker) handle(ev keyEvent, height int) (done bool, index int, err error) {
+	page := listRows(height)
+	switch ev.key {
+	case keyEnter:
+		if len(p.matches) > 0 {
+			return true, p.matches[p.cursor].index, nil
+		}
+	case keyCancel, keyInterrupt:
+		return true, -1, ErrCancelled
+	case keyEOF:
+		if len(p.query) == 0 {
+			return true, -1, ErrCancelled
+		}
+	case keyUp:
+		p.move(-1)
+	case keyDown:
+		p.move(1)
+	case keyPageUp:
+		p.move(-page)
+	case keyPageDown:
+		p.move(page)
+	case keyBackspace:
+		if len(p.query) > 0 {
+			p.query = p.query[:len(p.query)-1]
+			p.filter()
+		}
+	case keyDeleteWord:
+		p.query = deleteWord(p.query)
+		p.filter()
+	case keyClear:
+		p.query = nil
+		p.filter()
+	case keyRune:
+		p.query = append(p.query, ev.r)
+		p.filter()
+	}
+	return false, -1, nil
+}
+
+// move moves the selection, keeping it within the matches
+func (p *picker) move(delta int) {
+	p.cursor += delta
+	if p.cursor >= len(p.matches) {
+		p.cursor = len(p.matches) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+}
+
+// listRows returns how many entries fit below the prompt and status lines
+func listRows(height int) int {
+	if height < 3 {
+		return 1
+	}
+	return height - 2
+}
+
+// render draws the prompt, the status line, the list and the preview pane
+func (p *picker) render(width, height int) string {
+	rows := listRows(height)
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+rows {
+		p.offset = p.cursor - rows + 1
+	}
+
+	listWidth, previewWidth := width, 0
+	if width >= minPreviewWidth {
+		listWidth = width / 2
+		previewWidth = width - listWidth - 3
+	}
+
+	var preview []string
+	if len(p.matches) > 0 {
+		preview = p.items[p.matches[p.cursor].index].Preview
+	}
+
+	var b strings.Builder
+	b.WriteString(home)
+	b.WriteString(truncate(p.prompt+string(p.query), width) + clearLine + "\r\n")
+	b.WriteString(colorDetail + truncate(fmt.Sprintf("  %d/%d", len(p.matches), len(p.items)), width) + colorNormal + clearLine)
+
+	for row := 0; row < rows; row++ {
+		b.WriteString("\r\n")
+		used := 0
+		if i := p.offset + row; i < len(p.matches) {
+			used = p.writeEntry(&b, p.matches[i], i == p.cursor, listWidth)
+		}
+		if previewWidth > 0 {
+			b.WriteString(strings.Repeat(" ", listWidth-used))
+			b.WriteString(colorDetail + " │ " + colorNormal)
+			if row < len(preview) {
+				b.WriteString(truncate(preview[row], previewWidth))
+			}
+		}
+		b.WriteString(clearLine)
+	}
+	b.WriteString(clearBelow)
+
+	// Leave the cursor at the end of the query
+	column := utf8.RuneCountInString(p.prompt) + len(p.query) + 1
+	if column > width {
+		column = width
+	}
+	fmt.Fprintf(&b, "\x1b[1;%dH", column)
+
+	return b.String()
+}
+
+// writeEntry draws one list entry with its matched runes highlighted and
+// returns the number of columns used
+func (p *picker) writeEntry(b *strings.Builder, m match, selected bool, width int) int {
+	item := p.items[m.index]
+	text := []rune(item.text())
+	labelLength := utf8.RuneCountInString(item.Label)
+
+	marker := "  "
+	if selected {
+		marker = bold + "> "
+	}
+	b.WriteString(marker)
+
+	matched := make(map[int]bool, len(m.positions))
+	for _, position := range m.positions {
+		matched[position] = true
+	}
+
+	available := width - 2
+	if len(text) > available {
+		text = text[:max(available, 0)]
+	}
+	color := colorNormal
+	for i, r := range text {
+		want := colorNormal
+		switch {
+		case matched[i]:
+			want = colorMatch
+		case i >= labelLength:
+			want = colorDetail
+		}
+		if want != color {
+			b.WriteString(want)
+			color = want
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString(reset)
+	return 2 + len(text)
+}
+
+// truncate shortens s to at most width runes
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Terminal control sequences
+const (
+	enterScreen = "\x1b[?1049h" // switch to the alternate screen
+	leaveScreen = "\x1b[?1049l"
+	home        = "\x1b[H"
+	clearLine   = "\x1b[K"
+	clearBelow  = "\x1b[J"
+	showCursor  = "\x1b[?25h"
+	hideCursor  = "\x1b[?25l"
+	bold        = "\x1b[1m"
+	reverse     = "\x1b[7m"
+	reset       = "\x1b[0m"
+	colorNormal = "\x1b[39m"
+	colorMatch  = "\x1b[33m"
+	colorDetail = "\x1b[90m"
+	colorError  = "\x1b[31m"
+	colorFolder = "\x1b[34m"
+)
+
+// IsTerminal reports whether stdin and stdout are both terminals, so the
+// picker and the terminal UI can be shown
+func IsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// terminal is the controlling terminal in raw mode, showing the alternate screen
+type terminal struct {
+	fd    int
+	state *term.State
+	buf   []byte
+}
+
+// openTerminal switches the terminal to raw mode and the alternate screen
+func openTerminal() (*terminal, error) {
+	if !IsTerminal() {
+		return nil, fmt.Errorf("an interactive terminal is required")
+	}
+	t := &terminal{fd: int(os.Stdin.Fd()), buf: make([]byte, 256)}
+	if err := t.resume(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// suspend restores the terminal, e.g. to run a command in it
+func (t *terminal) suspend() {
+	fmt.Fprint(os.Stdout, showCursor+leaveScreen)
+	term.Restore(t.fd, t.state)
+}
+
+// resume switches back to raw mode and the alternate screen after suspend
+func (t *terminal) resume() error {
+	state, err := term.MakeRaw(t.fd)
+	if err != nil {
+		return fmt.Errorf("failed to set up the terminal: %w", err)
+	}
+	t.state = state
+	fmt.Fprint(os.Stdout, enterScreen)
+	return nil
+}
+
+// close restores the terminal for good
+func (t *terminal) close() {
+	t.suspend()
+}
+
+// size returns the terminal's width and height, assuming 80x24 when unknown
+func (t *terminal) size() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// readKeys waits for input and returns the keys pressed
+func (t *terminal) readKeys() ([]keyEvent, error) {
+	n, err := os.Stdin.Read(t.buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from the terminal: %w", err)
+	}
+	return parseKeys(t.buf[:n]), nil
+}
+
+// draw writes a rendered frame in one go to avoid flicker
+func (t *terminal) draw(frame string) {
+	os.Stdout.WriteString(frame)
+}
@@ -0,0 +1,311 @@
+package tunnel
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// dialTimeout limits how long connecting to each hop may take
+const dialTimeout = 15 * time.Second
+
+// defaultKeys are the private keys tried when a connection sets no identity file
+var defaultKeys = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// session is an SSH connection to a host and to the jump hosts in front of it
+type session struct {
+	client *ssh.Client
+	hops   []*ssh.Client // Jump host connections, first hop first
+}
+
+// Close closes the connection to the host and then the jump hosts
+func (s *session) Close() error {
+	err := s.client.Close()
+	for i := len(s.hops) - 1; i >= 0; i-- {
+		s.hops[i].Close()
+	}
+	return err
+}
+
+// dial connects to a resolved SSH connection through its jump hosts. Passwords
+// must already be resolved; 1Password references are not looked up here.
+func dial(conn *models.Connection) (*session, error) {
+	chain := append(append([]*models.Connection{}, conn.Jumps...), conn)
+
+	s := &session{}
+	var client *ssh.Client
+	for _, hop := range chain {
+		address := net.JoinHostPort(hop.Host, strconv.Itoa(portOf(hop)))
+		config, err := clientConfig(hop)
+		if err != nil {
+			s.closeHops()
+			return nil, err
+		}
+
+		var netConn net.Conn
+		if client == nil {
+			netConn, err = net.DialTimeout("tcp", address, dialTimeout)
+		} else {
+			// Through the previous hop, like ssh -J
+			netConn, err = client.Dial("tcp", address)
+		}
+		if err != nil {
+			s.closeHops()
+			return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+		}
+
+		sshConn, chans, reqs, err := ssh.NewClientConn(netConn, address, config)
+		if err != nil {
+			netConn.Close()
+			s.closeHops()
+			return nil, fmt.Errorf("failed to log in to %s: %w", address, err)
+		}
+		if client != nil {
+			s.hops = append(s.hops, client)
+		}
+		client = ssh.NewClient(sshConn, chans, reqs)
+	}
+	s.client = client
+	return s, nil
+}
+
+// closeHops closes the jump host connections of a session that failed to open
+func (s *session) closeHops() {
+	for i := len(s.hops) - 1; i >= 0; i-- {
+		s.hops[i].Close()
+	}
+}
+
+// portOf returns the SSH port of a connection
+func portOf(conn *models.Connection) int {
+	if conn.Port == 0 {
+		return models.ProtocolSSH.GetDefaultPort()
+	}
+	return conn.Port
+}
+
+// clientConfig returns the login settings of one hop: its keys, the SSH agent
+// and its password, with host keys checked against ~/.ssh/known_hosts
+func clientConfig(conn *models.Connection) (*ssh.ClientConfig, error) {
+	username := conn.Username
+	if username == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("no username for '%s': %w", conn.Name, err)
+		}
+		// Windows user names include the domain
+		username = current.Username[strings.LastIndex(current.Username, `\`)+1:]
+	}
+
+	home, _ := os.UserHomeDir()
+	signers, err := keySigners(conn, home)
+	if err != nil {
+		return nil, err
+	}
+
+	var methods []ssh.AuthMethod
+	if len(signers) > 0 || !conn.IdentitiesOnly {
+		methods = append(methods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			if conn.IdentitiesOnly {
+				return signers, nil
+			}
+			return append(signers, agentSigners()...), nil
+		}))
+	}
+	if conn.Password != "" {
+		password := conn.Password
+		methods = append(methods,
+			ssh.Password(password),
+			ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}))
+	}
+
+	knownHosts := filepath.Join(home, ".ssh", "known_hosts")
+	return &ssh.ClientConfig{
+		User:              username,
+		Auth:              methods,
+		HostKeyCallback:   acceptNewHostKeys(knownHosts),
+		HostKeyAlgorithms: knownKeyAlgorithms(knownHosts, net.JoinHostPort(conn.Host, strconv.Itoa(portOf(conn)))),
+		Timeout:           dialTimeout,
+	}, nil
+}
+
+// keySigners loads the connection's identity file and certificate, or the
+// default keys in ~/.ssh when it sets none. Keys protected by a passphrase
+// are skipped; the SSH agent can offer them instead.
+func keySigners(conn *models.Connection, home string) ([]ssh.Signer, error) {
+	if conn.IdentityFile == "" {
+		var signers []ssh.Signer
+		for _, name := range defaultKeys {
+			if signer, err := loadKey(filepath.Join(home, ".ssh", name)); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+		return signers, nil
+	}
+
+	signer, err := loadKey(expandHome(conn.IdentityFile, home))
+	var passphraseErr *ssh.PassphraseMissingError
+	if errors.As(err, &passphraseErr) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load identity file of '%s': %w", conn.Name, err)
+	}
+
+	if conn.CertificateFile != "" {
+		data, err := os.ReadFile(expandHome(conn.CertificateFile, home))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificate of '%s': %w", conn.Name, err)
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate of '%s': %w", conn.Name, err)
+		}
+		cert, ok := key.(*ssh.Certificate)
+		if !ok {
+			return nil, fmt.Errorf("certificate file of '%s' holds no certificate", conn.Name)
+		}
+		if signer, err = ssh.NewCertSigner(cert, signer); err != nil {
+			return nil, fmt.Errorf("certificate of '%s' doesn't match its key: %w", conn.Name, err)
+		}
+	}
+	return []ssh.Signer{signer}, nil
+}
+
+// loadKey reads an unencrypted private key file
+func loadKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKey(data)
+}
+
+// sshAgent is the connection to the SSH agent, shared by every login and
+// opened again when it breaks
+var sshAgent struct {
+	sync.Mutex
+	conn   net.Conn
+	client agent.ExtendedAgent
+}
+
+// agentSigners returns the keys of the SSH agent at SSH_AUTH_SOCK, if any
+func agentSigners() []ssh.Signer {
+	sshAgent.Lock()
+	defer sshAgent.Unlock()
+
+	if sshAgent.client != nil {
+		if signers, err := sshAgent.client.Signers(); err == nil {
+			return signers
+		}
+		sshAgent.conn.Close()
+		sshAgent.client = nil
+	}
+
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil
+	}
+	client := agent.NewClient(conn)
+	signers, err := client.Signers()
+	if err != nil {
+		conn.Close()
+		return nil
+	}
+	sshAgent.conn, sshAgent.client = conn, client
+	return signers
+}
+
+// expandHome replaces a leading ~ in a path with the home directory
+func expandHome(path, home string) string {
+	if home == "" || (path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`)) {
+		return path
+	}
+	return home + path[1:]
+}
+
+// probeKey is a public key no host has, for asking a known_hosts file which
+// key types it lists for a host
+type probeKey struct{}
+
+func (probeKey) Type() string                        { return "probe" }
+func (probeKey) Marshal() []byte                     { return []byte("probe") }
+func (probeKey) Verify([]byte, *ssh.Signature) error { return errors.New("probe key") }
+
+// knownKeyAlgorithms returns the host key algorithms of the keys a known_hosts
+// file lists for an address, so the server offers a key that can be checked;
+// nil, for any algorithm, when the host isn't listed
+func knownKeyAlgorithms(path, address string) []string {
+	check, err := knownhosts.New(path)
+	if err != nil {
+		return nil
+	}
+	var keyErr *knownhosts.KeyError
+	if !errors.As(check(address, &net.TCPAddr{IP: net.IPv4zero}, probeKey{}), &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		if known.Key.Type() == ssh.KeyAlgoRSA {
+			// RSA keys sign with SHA-2 on current servers
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		}
+		algorithms = append(algorithms, known.Key.Type())
+	}
+	return algorithms
+}
+
+// acceptNewHostKeys checks host keys against a known_hosts file and adds the
+// keys of hosts it doesn't list, like ssh's StrictHostKeyChecking=accept-new.
+// A changed key fails the login.
+func acceptNewHostKeys(path string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		check, err := knownhosts.New(path)
+		if err == nil {
+			err = check(hostname, remote, key)
+			var keyErr *knownhosts.KeyError
+			if !errors.As(err, &keyErr) {
+				return err
+			}
+			if len(keyErr.Want) > 0 {
+				return fmt.Errorf("host key of %s has changed (run: ssh-keygen -R %s)", hostname, knownhosts.Normalize(hostname))
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return fmt.Errorf("failed to add host key: %w", err)
+		}
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to add host key: %w", err)
+		}
+		defer file.Close()
+		_, err = fmt.Fprintln(file, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+		return err
+	}
+}
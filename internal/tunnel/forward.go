@@ -0,0 +1,156 @@
+package tunnel
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// listen opens the local listener of a local or dynamic tunnel. It stays open
+// across reconnects, so clients are turned away rather than refused while the
+// connection is down.
+func (t *Tunnel) listen() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.listener != nil {
+		return nil
+	}
+
+	address, _ := t.spec.ListenAddress()
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+	t.listener = listener
+	go t.acceptLocal(listener)
+	return nil
+}
+
+// closeListener closes the local listener, if any
+func (t *Tunnel) closeListener() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.listener != nil {
+		t.listener.Close()
+		t.listener = nil
+	}
+}
+
+// acceptLocal forwards the connections to a local or dynamic tunnel's listener
+// through the SSH server
+func (t *Tunnel) acceptLocal(listener net.Listener) {
+	for {
+		local, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			client := t.currentClient()
+			if client == nil {
+				local.Close()
+				return
+			}
+
+			target := t.spec.Target
+			if t.spec.Type == models.TunnelDynamic {
+				requested, err := socksHandshake(local)
+				if err != nil {
+					local.Close()
+					return
+				}
+				target = requested
+			}
+
+			remote, err := client.Dial("tcp", target)
+			if t.spec.Type == models.TunnelDynamic {
+				// Tell the SOCKS client whether the server could connect
+				if replyErr := socksReply(local, err); replyErr != nil && err == nil {
+					remote.Close()
+					err = replyErr
+				}
+			}
+			if err != nil {
+				local.Close()
+				return
+			}
+			t.pipe(local, remote)
+		}()
+	}
+}
+
+// acceptRemote forwards the connections to a remote tunnel's listener on the
+// SSH server to the target, from this machine
+func (t *Tunnel) acceptRemote(listener net.Listener) {
+	for {
+		remote, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			local, err := net.DialTimeout("tcp", t.spec.Target, dialTimeout)
+			if err != nil {
+				remote.Close()
+				return
+			}
+			t.pipe(remote, local)
+		}()
+	}
+}
+
+// pipe copies between a client and the target until both sides are done,
+// counting the bytes each way
+func (t *Tunnel) pipe(client, target net.Conn) {
+	t.active.Add(1)
+	defer t.active.Add(-1)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		copyCounting(target, client, &t.sent)
+	}()
+	go func() {
+		defer wg.Done()
+		copyCounting(client, target, &t.received)
+	}()
+
+	// Closing on stop ends both copies
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-t.stop:
+		case <-done:
+		}
+		client.Close()
+		target.Close()
+	}()
+	wg.Wait()
+	close(done)
+}
+
+// copyCounting copies until src ends, then closes dst for writing so the other
+// side sees the end of the stream
+func copyCounting(dst, src net.Conn, counter *atomic.Uint64) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				break
+			}
+			counter.Add(uint64(n))
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if closer, ok := dst.(interface{ CloseWrite() error }); ok {
+		closer.CloseWrite()
+	} else {
+		dst.Close()
+	}
+}
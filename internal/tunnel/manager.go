@@ -0,0 +1,84 @@
+package tunnel
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+)
+
+// Key identifies a tunnel by the path of its connection and its name
+func Key(connectionPath, tunnelName string) string {
+	return connectionPath + ":" + tunnelName
+}
+
+// Manager runs tunnels in this process, by key
+type Manager struct {
+	mu      sync.Mutex
+	tunnels map[string]*Tunnel
+}
+
+// NewManager creates a manager with no tunnels running
+func NewManager() *Manager {
+	return &Manager{tunnels: make(map[string]*Tunnel)}
+}
+
+// Start starts a tunnel over a resolved SSH connection; see New
+func (m *Manager) Start(key string, conn *models.Connection, spec *models.Tunnel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, running := m.tunnels[key]; running {
+		return fmt.Errorf("tunnel %s is already running", key)
+	}
+
+	t, err := New(conn, spec)
+	if err != nil {
+		return err
+	}
+	m.tunnels[key] = t
+	t.Start()
+	return nil
+}
+
+// Stop stops a tunnel; false if it wasn't running
+func (m *Manager) Stop(key string) bool {
+	m.mu.Lock()
+	t, running := m.tunnels[key]
+	delete(m.tunnels, key)
+	m.mu.Unlock()
+
+	if running {
+		t.Stop()
+	}
+	return running
+}
+
+// StopAll stops every tunnel
+func (m *Manager) StopAll() {
+	m.mu.Lock()
+	tunnels := m.tunnels
+	m.tunnels = make(map[string]*Tunnel)
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, t := range tunnels {
+		wg.Add(1)
+		go func(t *Tunnel) {
+			defer wg.Done()
+			t.Stop()
+		}(t)
+	}
+	wg.Wait()
+}
+
+// Stats returns the status of a running tunnel; false if it isn't running
+func (m *Manager) Stats(key string) (Stats, bool) {
+	m.mu.Lock()
+	t, running := m.tunnels[key]
+	m.mu.Unlock()
+
+	if !running {
+		return Stats{}, false
+	}
+	return t.Stats(), true
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package tunnel
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach starts a command in a session of its own, so it outlives the
+// terminal it was started from
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// terminate asks a process to exit; a process that is gone already is fine
+func terminate(pid int) error {
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil && err != syscall.ESRCH {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package tunnel
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 protocol values (RFC 1928)
+const (
+	socksVersion        = 5
+	socksNoAuth         = 0
+	socksNoMethods      = 0xff
+	socksConnect        = 1
+	socksIPv4           = 1
+	socksDomain         = 3
+	socksIPv6           = 4
+	socksSucceeded      = 0
+	socksFailed         = 1
+	socksNotSupported   = 7
+	socksBadAddressType = 8
+)
+
+// socksTimeout limits how long a client may take to say where to connect
+const socksTimeout = 10 * time.Second
+
+// socksHandshake reads a SOCKS5 CONNECT request without authentication and
+// returns the host:port the client asks for
+func socksHandshake(conn net.Conn) (string, error) {
+	conn.SetDeadline(time.Now().Add(socksTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	if header[0] != socksVersion {
+		return "", fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+	method := byte(socksNoMethods)
+	for _, m := range methods {
+		if m == socksNoAuth {
+			method = socksNoAuth
+		}
+	}
+	if _, err := conn.Write([]byte{socksVersion, method}); err != nil {
+		return "", err
+	}
+	if method == socksNoMethods {
+		return "", fmt.Errorf("SOCKS client requires authentication")
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", err
+	}
+	if request[1] != socksConnect {
+		writeSocksReply(conn, socksNotSupported)
+		return "", fmt.Errorf("unsupported SOCKS command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socksIPv4, socksIPv6:
+		ip := make(net.IP, 4)
+		if request[3] == socksIPv6 {
+			ip = make(net.IP, 16)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = ip.String()
+	case socksDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", err
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		writeSocksReply(conn, socksBadAddressType)
+		return "", fmt.Errorf("unsupported SOCKS address type %d", request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// socksReply tells a SOCKS client whether its connection was made
+func socksReply(conn net.Conn, dialErr error) error {
+	if dialErr != nil {
+		return writeSocksReply(conn, socksFailed)
+	}
+	return writeSocksReply(conn, socksSucceeded)
+}
+
+// writeSocksReply sends a reply without a bound address, which clients ignore
+func writeSocksReply(conn net.Conn, status byte) error {
+	_, err := conn.Write([]byte{socksVersion, status, 0, socksIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
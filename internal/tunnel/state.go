@@ -0,0 +1,215 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jaydenthorup/mremotego/internal/settings"
+)
+
+// publishInterval is how often a background tunnel updates its state file
+const publishInterval = time.Second
+
+// staleAfter is how old a state file may get before its process is taken for
+// dead, e.g. after a crash or a reboot
+const staleAfter = 10 * time.Second
+
+// stopTimeout limits how long StopBackground waits for a tunnel to exit
+const stopTimeout = 5 * time.Second
+
+// Record is what a background tunnel process publishes in its state file
+type Record struct {
+	PID        int    `json:"pid"`
+	Config     string `json:"config"` // Absolute path of the connection file
+	Connection string `json:"connection"`
+	Name       string `json:"name"`
+	Forward    string `json:"forward"`
+	Stats
+	Updated time.Time `json:"updated"`
+}
+
+// Key returns the key of the record's tunnel
+func (r *Record) Key() string {
+	return Key(r.Connection, r.Name)
+}
+
+// StateDir returns the directory of the state files, next to the settings file
+func StateDir() (string, error) {
+	path, err := settings.DefaultPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "tunnels"), nil
+}
+
+// recordPath returns the state file of a process
+func recordPath(dir string, pid int) string {
+	return filepath.Join(dir, strconv.Itoa(pid)+".json")
+}
+
+// Records reads the state files of the running background tunnels, sorted by
+// connection file and key, and removes those left behind by dead processes
+func Records(dir string) ([]*Record, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*Record
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // Stopped meanwhile
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tunnel state: %w", err)
+		}
+		r := &Record{}
+		if err := json.Unmarshal(data, r); err != nil || time.Since(r.Updated) > staleAfter {
+			os.Remove(path)
+			continue
+		}
+		records = append(records, r)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Config != records[j].Config {
+			return records[i].Config < records[j].Config
+		}
+		return records[i].Key() < records[j].Key()
+	})
+	return records, nil
+}
+
+// FindRecord returns the record of a tunnel of a connection file, nil if it
+// isn't running in the background
+func FindRecord(records []*Record, config, key string) *Record {
+	for _, r := range records {
+		if r.Config == config && r.Key() == key {
+			return r
+		}
+	}
+	return nil
+}
+
+// publish writes a record to its state file, replacing it in one step so
+// readers never see half a file
+func publish(dir string, r *Record) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := recordPath(dir, r.PID)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Serve runs a tunnel in this process until stop is closed, publishing its
+// status in a state file in dir about every second
+func Serve(dir string, record Record, t *Tunnel, stop <-chan struct{}) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create tunnel state directory: %w", err)
+	}
+	record.PID = os.Getpid()
+	defer os.Remove(recordPath(dir, record.PID))
+
+	update := func() error {
+		record.Stats = t.Stats()
+		record.Updated = time.Now()
+		return publish(dir, &record)
+	}
+	if err := update(); err != nil {
+		return fmt.Errorf("failed to write tunnel state: %w", err)
+	}
+
+	t.Start()
+	defer t.Stop()
+	ticker := time.NewTicker(publishInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-t.Done():
+			return nil
+		case <-ticker.C:
+			if err := update(); err != nil {
+				return fmt.Errorf("failed to write tunnel state: %w", err)
+			}
+		}
+	}
+}
+
+// StartBackground starts a program detached from this process and terminal,
+// with its output appended to tunnels.log in dir, and returns its process ID
+func StartBackground(dir, program string, args ...string) (int, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return 0, fmt.Errorf("failed to create tunnel state directory: %w", err)
+	}
+	logFile, err := os.OpenFile(filepath.Join(dir, "tunnels.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open tunnel log: %w", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(program, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start tunnel process: %w", err)
+	}
+	pid := cmd.Process.Pid
+	cmd.Process.Release()
+	return pid, nil
+}
+
+// WaitForRecord waits until a background process has published its state,
+// up to timeout, and returns its record; nil if it didn't
+func WaitForRecord(dir string, pid int, timeout time.Duration, ready func(*Record) bool) *Record {
+	deadline := time.Now().Add(timeout)
+	var last *Record
+	for time.Now().Before(deadline) {
+		data, err := os.ReadFile(recordPath(dir, pid))
+		if err == nil {
+			r := &Record{}
+			if json.Unmarshal(data, r) == nil {
+				last = r
+				if ready(r) {
+					return r
+				}
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return last
+}
+
+// StopBackground stops a background tunnel and waits for it to exit
+func StopBackground(dir string, r *Record) error {
+	if err := terminate(r.PID); err != nil {
+		return fmt.Errorf("failed to stop tunnel %s: %w", r.Key(), err)
+	}
+
+	path := recordPath(dir, r.PID)
+	deadline := time.Now().Add(stopTimeout)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// Killed processes can't remove their state file
+	os.Remove(path)
+	return nil
+}
@@ -0,0 +1,269 @@
+// Package tunnel runs the named port forwards of SSH connections with the
+// built-in SSH client: local and remote forwards and SOCKS proxies, each
+// reconnecting with backoff when its connection drops. Tunnels run in the
+// GUI's process, or in a background process per tunnel started by
+// 'mremotego tunnel up', which publishes its status in a state file.
+package tunnel
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// State is what a tunnel is doing
+type State string
+
+const (
+	StateConnecting State = "connecting" // Logging in to the SSH server
+	StateUp         State = "up"         // Forwarding
+	StateRetrying   State = "retrying"   // Waiting to reconnect after a failure
+	StateStopped    State = "stopped"
+)
+
+// Reconnect delays: the first retry waits minBackoff, each failure doubles the
+// wait up to maxBackoff, and a connection that stayed up for stableAfter
+// starts over from minBackoff
+const (
+	minBackoff  = time.Second
+	maxBackoff  = time.Minute
+	stableAfter = 30 * time.Second
+)
+
+// keepaliveInterval is how often an idle connection is checked; a server that
+// doesn't answer is reconnected
+const keepaliveInterval = 15 * time.Second
+
+// Stats is a snapshot of a tunnel's status
+type Stats struct {
+	State       State     `json:"state"`
+	Error       string    `json:"error,omitempty"`    // Why the last connection failed
+	Since       time.Time `json:"since"`              // When State last changed
+	RetryAt     time.Time `json:"retry_at,omitempty"` // When a retrying tunnel reconnects
+	Connections int       `json:"connections"`        // Connections being forwarded now
+	Sent        uint64    `json:"sent"`               // Bytes sent towards the target
+	Received    uint64    `json:"received"`           // Bytes received from the target
+}
+
+// Tunnel runs one port forward over an SSH connection until it is stopped
+type Tunnel struct {
+	conn *models.Connection // Resolved, with its jump hosts and passwords
+	spec models.Tunnel
+
+	dial func(conn *models.Connection) (*session, error) // Connects to the SSH server
+
+	mu       sync.Mutex
+	stats    Stats
+	client   *ssh.Client  // The current connection, nil while down
+	listener net.Listener // Local listener of local and dynamic tunnels
+
+	sent, received atomic.Uint64
+	active         atomic.Int64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// New returns a tunnel over a resolved SSH connection, whose passwords and
+// those of its jump hosts are already looked up
+func New(conn *models.Connection, spec *models.Tunnel) (*Tunnel, error) {
+	if conn.Protocol != models.ProtocolSSH {
+		return nil, fmt.Errorf("'%s' is not an ssh connection", conn.Name)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &Tunnel{
+		conn:  conn,
+		spec:  *spec,
+		dial:  dial,
+		stats: Stats{State: StateStopped, Since: time.Now()},
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}, nil
+}
+
+// Spec returns the forward the tunnel runs
+func (t *Tunnel) Spec() models.Tunnel {
+	return t.spec
+}
+
+// Start connects the tunnel in the background; it reconnects until Stop
+func (t *Tunnel) Start() {
+	go t.run()
+}
+
+// Stop closes the tunnel and its connections and waits for it to finish
+func (t *Tunnel) Stop() {
+	t.stopOnce.Do(func() { close(t.stop) })
+	<-t.done
+}
+
+// Done is closed once the tunnel has stopped
+func (t *Tunnel) Done() <-chan struct{} {
+	return t.done
+}
+
+// Stats returns the tunnel's current status
+func (t *Tunnel) Stats() Stats {
+	t.mu.Lock()
+	stats := t.stats
+	t.mu.Unlock()
+	stats.Connections = int(t.active.Load())
+	stats.Sent = t.sent.Load()
+	stats.Received = t.received.Load()
+	return stats
+}
+
+// setState records a change of state and, for failures, why
+func (t *Tunnel) setState(state State, err error, retryAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.State = state
+	t.stats.Since = time.Now()
+	t.stats.RetryAt = retryAt
+	if err != nil {
+		t.stats.Error = err.Error()
+	} else if state == StateUp {
+		t.stats.Error = ""
+	}
+}
+
+// stopped reports whether Stop was called
+func (t *Tunnel) stopped() bool {
+	select {
+	case <-t.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// run connects and forwards until stopped, waiting longer after each failure
+func (t *Tunnel) run() {
+	defer close(t.done)
+	defer t.closeListener()
+
+	delay := minBackoff
+	for {
+		t.setState(StateConnecting, nil, time.Time{})
+		started := time.Now()
+		err := t.serve()
+		if t.stopped() {
+			t.setState(StateStopped, nil, time.Time{})
+			return
+		}
+
+		if time.Since(started) >= stableAfter {
+			delay = minBackoff
+		}
+		t.setState(StateRetrying, err, time.Now().Add(delay))
+		select {
+		case <-t.stop:
+			t.setState(StateStopped, nil, time.Time{})
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxBackoff {
+			delay = maxBackoff
+		}
+	}
+}
+
+// serve forwards over one SSH connection until it drops or the tunnel stops
+func (t *Tunnel) serve() error {
+	if t.spec.Type != models.TunnelRemote {
+		if err := t.listen(); err != nil {
+			return err
+		}
+	}
+
+	s, err := t.dial(t.conn)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if t.spec.Type == models.TunnelRemote {
+		address, _ := t.spec.ListenAddress()
+		listener, err := s.client.Listen("tcp", address)
+		if err != nil {
+			return fmt.Errorf("server refused to listen on %s: %w", address, err)
+		}
+		defer listener.Close()
+		go t.acceptRemote(listener)
+	}
+
+	t.mu.Lock()
+	t.client = s.client
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		t.client = nil
+		t.mu.Unlock()
+	}()
+	t.setState(StateUp, nil, time.Time{})
+
+	closed := make(chan error, 1)
+	go func() { closed <- s.client.Wait() }()
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return nil
+		case err := <-closed:
+			if err == nil {
+				err = fmt.Errorf("connection closed by the server")
+			}
+			return err
+		case <-keepalive.C:
+			if err := t.keepalive(s.client); err != nil {
+				return fmt.Errorf("server stopped answering: %w", err)
+			}
+		}
+	}
+}
+
+// keepalive checks that the server still answers
+func (t *Tunnel) keepalive(client *ssh.Client) error {
+	answered := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		answered <- err
+	}()
+	select {
+	case err := <-answered:
+		return err
+	case <-time.After(keepaliveInterval):
+		return fmt.Errorf("no answer in %s", keepaliveInterval)
+	}
+}
+
+// currentClient returns the SSH connection forwards go over, nil while down
+func (t *Tunnel) currentClient() *ssh.Client {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.client
+}
+
+// FormatBytes renders a byte count for people, e.g. "1.5 MB"
+func FormatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,465 @@
+package tunnel
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaydenthorup/mremotego/pkg/models"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testServer is an SSH server that logs in bob with the password "secret" and
+// forwards ports like sshd
+type testServer struct {
+	listener net.Listener
+	hostKey  ssh.Signer
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+// newTestServer starts an SSH server on a free local port
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{listener: listener, hostKey: hostKey}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if meta.User() == "bob" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, io.ErrUnexpectedEOF
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.handle(conn, config)
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		s.dropConnections()
+	})
+	return s
+}
+
+// connection returns an SSH connection to the server
+func (s *testServer) connection() *models.Connection {
+	port := s.listener.Addr().(*net.TCPAddr).Port
+	return &models.Connection{Name: "test", Protocol: models.ProtocolSSH, Host: "127.0.0.1", Port: port,
+		Username: "bob", Password: "secret"}
+}
+
+// dropConnections closes every client connection, as if the network failed
+func (s *testServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testServer) handle(netConn net.Conn, config *ssh.ServerConfig) {
+	sshConn, chans, reqs, err := ssh.NewServerConn(netConn, config)
+	if err != nil {
+		return
+	}
+	defer sshConn.Close()
+
+	go func() {
+		for req := range reqs {
+			switch req.Type {
+			case "tcpip-forward":
+				var request struct {
+					Address string
+					Port    uint32
+				}
+				ssh.Unmarshal(req.Payload, &request)
+				listener, err := net.Listen("tcp", net.JoinHostPort(request.Address, strconv.Itoa(int(request.Port))))
+				if err != nil {
+					req.Reply(false, nil)
+					continue
+				}
+				port := uint32(listener.Addr().(*net.TCPAddr).Port)
+				req.Reply(true, ssh.Marshal(struct{ Port uint32 }{port}))
+				go s.forwardRemote(sshConn, listener, request.Address, port)
+			default:
+				req.Reply(req.WantReply, nil)
+			}
+		}
+	}()
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		var request struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		ssh.Unmarshal(newChannel.ExtraData(), &request)
+		target, err := net.Dial("tcp", net.JoinHostPort(request.Host, strconv.Itoa(int(request.Port))))
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			target.Close()
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+		go proxy(channel, target)
+	}
+}
+
+// forwardRemote opens a forwarded-tcpip channel for each connection to a
+// remote forward's listener
+func (s *testServer) forwardRemote(sshConn *ssh.ServerConn, listener net.Listener, address string, port uint32) {
+	go func() {
+		sshConn.Wait()
+		listener.Close()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		payload := ssh.Marshal(struct {
+			Address    string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}{address, port, "127.0.0.1", 1})
+		channel, requests, err := sshConn.OpenChannel("forwarded-tcpip", payload)
+		if err != nil {
+			conn.Close()
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+		go proxy(channel, conn)
+	}
+}
+
+// proxy copies both ways between a channel and a connection
+func proxy(channel ssh.Channel, conn net.Conn) {
+	go func() {
+		io.Copy(channel, conn)
+		channel.CloseWrite()
+	}()
+	io.Copy(conn, channel)
+	conn.Close()
+	channel.Close()
+}
+
+// newEchoServer starts a TCP server that sends back what it receives
+func newEchoServer(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// freePort returns a local port nothing listens on
+func freePort(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+}
+
+// startTunnel starts a tunnel with a temporary home directory, stopped when
+// the test ends
+func startTunnel(t *testing.T, conn *models.Connection, spec *models.Tunnel) *Tunnel {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SSH_AUTH_SOCK", "")
+	tunnel, err := New(conn, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tunnel.Start()
+	t.Cleanup(tunnel.Stop)
+	return tunnel
+}
+
+// waitForState waits until a tunnel reaches a state
+func waitForState(t *testing.T, tunnel *Tunnel, state State) Stats {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if stats := tunnel.Stats(); stats.State == state {
+			return stats
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("tunnel is %s (%s), want %s", tunnel.Stats().State, tunnel.Stats().Error, state)
+	return Stats{}
+}
+
+// echo sends a message over a connection and checks it comes back
+func echo(t *testing.T, conn net.Conn, message string) {
+	t.Helper()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte(message)); err != nil {
+		t.Fatal(err)
+	}
+	reply := make([]byte, len(message))
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatal(err)
+	}
+	if string(reply) != message {
+		t.Errorf("got %q back, want %q", reply, message)
+	}
+}
+
+func TestLocalTunnel(t *testing.T) {
+	server := newTestServer(t)
+	port := freePort(t)
+	tunnel := startTunnel(t, server.connection(), &models.Tunnel{Name: "echo", Type: models.TunnelLocal,
+		Listen: port, Target: newEchoServer(t)})
+	waitForState(t, tunnel, StateUp)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:"+port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo(t, conn, "hello")
+	conn.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for tunnel.Stats().Connections > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := tunnel.Stats(); stats.Sent != 5 || stats.Received != 5 || stats.Connections != 0 {
+		t.Errorf("stats = %+v, want 5 bytes each way and no connections", stats)
+	}
+
+	// The host key was trusted on first use
+	data, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "ssh-ed25519") {
+		t.Errorf("known_hosts = %q, want the server's key", data)
+	}
+
+	tunnel.Stop()
+	if state := tunnel.Stats().State; state != StateStopped {
+		t.Errorf("state after Stop = %s", state)
+	}
+	if _, err := net.Dial("tcp", "127.0.0.1:"+port); err == nil {
+		t.Error("port still open after Stop")
+	}
+}
+
+func TestDynamicTunnel(t *testing.T) {
+	server := newTestServer(t)
+	port := freePort(t)
+	tunnel := startTunnel(t, server.connection(), &models.Tunnel{Name: "socks", Type: models.TunnelDynamic, Listen: port})
+	waitForState(t, tunnel, StateUp)
+
+	target := newEchoServer(t)
+	host, targetPort, _ := net.SplitHostPort(target)
+	n, _ := strconv.Atoi(targetPort)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:"+port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Greeting without authentication, then CONNECT by domain name
+	conn.Write([]byte{5, 1, 0})
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil || reply[1] != 0 {
+		t.Fatalf("greeting reply %v, %v", reply, err)
+	}
+	request := append([]byte{5, 1, 0, 3, byte(len(host))}, host...)
+	request = binary.BigEndian.AppendUint16(request, uint16(n))
+	conn.Write(request)
+	reply = make([]byte, 10)
+	if _, err := io.ReadFull(conn, reply); err != nil || reply[1] != 0 {
+		t.Fatalf("connect reply %v, %v", reply, err)
+	}
+
+	echo(t, conn, "through socks")
+}
+
+func TestRemoteTunnel(t *testing.T) {
+	server := newTestServer(t)
+	port := freePort(t)
+	tunnel := startTunnel(t, server.connection(), &models.Tunnel{Name: "back", Type: models.TunnelRemote,
+		Listen: port, Target: newEchoServer(t)})
+	waitForState(t, tunnel, StateUp)
+
+	// The test server listens on this machine too
+	conn, err := net.Dial("tcp", "127.0.0.1:"+port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	echo(t, conn, "from the server")
+}
+
+func TestTunnelReconnects(t *testing.T) {
+	server := newTestServer(t)
+	port := freePort(t)
+	tunnel := startTunnel(t, server.connection(), &models.Tunnel{Name: "echo", Type: models.TunnelLocal,
+		Listen: port, Target: newEchoServer(t)})
+	waitForState(t, tunnel, StateUp)
+
+	server.dropConnections()
+	stats := waitForState(t, tunnel, StateRetrying)
+	if stats.Error == "" || stats.RetryAt.IsZero() {
+		t.Errorf("retrying without a reason or time: %+v", stats)
+	}
+
+	waitForState(t, tunnel, StateUp)
+	conn, err := net.Dial("tcp", "127.0.0.1:"+port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	echo(t, conn, "again")
+}
+
+func TestTunnelRejectsChangedHostKey(t *testing.T) {
+	server := newTestServer(t)
+	conn := server.connection()
+	home := t.TempDir()
+
+	// known_hosts has another key for the server
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	other, _ := ssh.NewSignerFromKey(private)
+	address := net.JoinHostPort(conn.Host, strconv.Itoa(conn.Port))
+	line := knownhosts.Line([]string{knownhosts.Normalize(address)}, other.PublicKey())
+	os.MkdirAll(filepath.Join(home, ".ssh"), 0700)
+	os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), []byte(line+"\n"), 0600)
+
+	tunnel, err := New(conn, &models.Tunnel{Name: "echo", Type: models.TunnelLocal, Listen: freePort(t), Target: "127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	tunnel.Start()
+	defer tunnel.Stop()
+
+	stats := waitForState(t, tunnel, StateRetrying)
+	if !strings.Contains(stats.Error, "host key of "+address+" has changed") {
+		t.Errorf("error = %q, want a changed host key", stats.Error)
+	}
+}
+
+func TestNewRejectsInvalidTunnels(t *testing.T) {
+	ssh := &models.Connection{Name: "web1", Protocol: models.ProtocolSSH, Host: "web1"}
+	tests := []struct {
+		name string
+		conn *models.Connection
+		spec models.Tunnel
+		want string
+	}{
+		{"not ssh", &models.Connection{Name: "win1", Protocol: models.ProtocolRDP}, models.Tunnel{Name: "t", Type: models.TunnelDynamic, Listen: "1080"}, "not an ssh connection"},
+		{"unknown type", ssh, models.Tunnel{Name: "t", Type: "sideways", Listen: "1080"}, "unknown type 'sideways'"},
+		{"bad listen", ssh, models.Tunnel{Name: "t", Type: models.TunnelDynamic, Listen: "socks"}, "listen must be a port"},
+		{"missing target", ssh, models.Tunnel{Name: "t", Type: models.TunnelLocal, Listen: "5432"}, "needs a target host:port"},
+		{"dynamic target", ssh, models.Tunnel{Name: "t", Type: models.TunnelDynamic, Listen: "1080", Target: "db:5432"}, "can't have a target"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.conn, &tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecords(t *testing.T) {
+	dir := t.TempDir()
+	fresh := &Record{PID: 10, Config: "/c.yaml", Connection: "Prod/db1", Name: "pg", Updated: time.Now()}
+	stale := &Record{PID: 11, Config: "/c.yaml", Connection: "Prod/db2", Name: "pg", Updated: time.Now().Add(-time.Minute)}
+	for _, r := range []*Record{fresh, stale} {
+		if err := publish(dir, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := Records(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Key() != "Prod/db1:pg" {
+		t.Fatalf("records = %+v, want only the fresh one", records)
+	}
+	if _, err := os.Stat(recordPath(dir, 11)); !os.IsNotExist(err) {
+		t.Errorf("stale state file left behind: %v", err)
+	}
+	if FindRecord(records, "/c.yaml", "Prod/db1:pg") == nil || FindRecord(records, "/other.yaml", "Prod/db1:pg") != nil {
+		t.Error("FindRecord doesn't match by connection file and key")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[uint64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KB", 5 << 20: "5.0 MB"} {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -1,5 +1,11 @@
 package models
 
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
 // Protocol represents the type of connection protocol
 type Protocol string
 
@@ -13,6 +19,62 @@ const (
 	ProtocolUnknown Protocol = "unknown"
 )
 
+// supportedProtocols lists the protocols that can be launched in the order
+// they are offered; defaultPorts holds their default ports. Both start with
+// the built-in protocols, and protocol handlers added by the launcher extend
+// them through RegisterProtocol.
+var (
+	supportedProtocols = []Protocol{ProtocolSSH, ProtocolRDP, ProtocolVNC, ProtocolHTTP, ProtocolHTTPS, ProtocolTelnet}
+	defaultPorts       = map[Protocol]int{
+		ProtocolSSH:    22,
+		ProtocolRDP:    3389,
+		ProtocolVNC:    5900,
+		ProtocolHTTP:   80,
+		ProtocolHTTPS:  443,
+		ProtocolTelnet: 23,
+	}
+)
+
+// RegisterProtocol records a protocol that can be launched and its default
+// port, adding to the built-in protocols or changing the port of one
+func RegisterProtocol(p Protocol, defaultPort int) {
+	if _, exists := defaultPorts[p]; !exists {
+		supportedProtocols = append(supportedProtocols, p)
+	}
+	defaultPorts[p] = defaultPort
+}
+
+// SupportedProtocols lists the protocols that can be launched, built-in protocols first
+func SupportedProtocols() []Protocol {
+	return append([]Protocol(nil), supportedProtocols...)
+}
+
+// IsSupported returns true if the protocol can be launched
+func (p Protocol) IsSupported() bool {
+	_, exists := defaultPorts[p]
+	return exists
+}
+
+// protocolAliases maps alternative spellings to their canonical protocol
+var protocolAliases = map[string]Protocol{
+	"ssh2":     ProtocolSSH,
+	"rdesktop": ProtocolRDP,
+	"mstsc":    ProtocolRDP,
+}
+
+// NormalizeProtocol returns the canonical spelling of a protocol name
+// (lowercase, aliases resolved). Unknown names are only lowercased.
+func NormalizeProtocol(name string) Protocol {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	if alias, exists := protocolAliases[normalized]; exists {
+		return alias
+	}
+	return Protocol(normalized)
+}
+
+// RequestTTYValues lists the values of Connection.RequestTTY, as in ssh_config
+var RequestTTYValues = []string{"auto", "yes", "no", "force"}
+
 // NodeType represents whether this is a connection or a folder
 type NodeType string
 
@@ -40,17 +102,56 @@ type Connection struct {
 	Resolution string `yaml:"resolution,omitempty"`  // For RDP
 	ExtraArgs  string `yaml:"extra_args,omitempty"`  // Additional protocol-specific args
 
+	// SSH options
+	IdentityFile    string   `yaml:"identity_file,omitempty"`    // Private key
+	CertificateFile string   `yaml:"certificate_file,omitempty"` // Certificate signing the key
+	ForwardAgent    bool     `yaml:"forward_agent,omitempty"`
+	IdentitiesOnly  bool     `yaml:"identities_only,omitempty"`  // Only offer the identity file, not every agent key
+	PreferredAuth   string   `yaml:"preferred_auth,omitempty"`   // Authentication methods in order, e.g. publickey,password
+	LocalForwards   []string `yaml:"local_forwards,omitempty"`   // [bind:]port:host:hostport, as ssh -L
+	RemoteForwards  []string `yaml:"remote_forwards,omitempty"`  // [bind:]port:host:hostport, as ssh -R
+	DynamicForwards []string `yaml:"dynamic_forwards,omitempty"` // [bind:]port, as ssh -D
+	RemoteCommand   string   `yaml:"remote_command,omitempty"`   // Run instead of the login shell
+	RequestTTY      string   `yaml:"request_tty,omitempty"`      // See RequestTTYValues
+
+	// JumpHost is the SSH connection, by ID, path or name, that this one is
+	// reached through; it may have a jump host of its own
+	JumpHost string `yaml:"jump_host,omitempty"`
+
+	// Jumps is the chain of jump hosts, first hop first, filled in when the
+	// connection is resolved for launching
+	Jumps []*Connection `yaml:"-"`
+
+	// Named port forwards run by the tunnel manager (SSH)
+	Tunnels []*Tunnel `yaml:"tunnels,omitempty"`
+
+	// Values for {{name}} references in connection fields; folder variables apply to all children
+	Variables map[string]string `yaml:"variables,omitempty"`
+
+	// Host, port or username to use instead while a profile is active, by profile name
+	Profiles map[string]*ProfileOverride `yaml:"profiles,omitempty"`
+
 	// Metadata
-	Tags     []string `yaml:"tags,omitempty"`
-	Notes    string   `yaml:"notes,omitempty"`
-	Created  string   `yaml:"created,omitempty"`
-	Modified string   `yaml:"modified,omitempty"`
+	ID           string       `yaml:"id,omitempty"` // Stable identifier used to track entries across edits, moves and merges
+	Tags         []string     `yaml:"tags,omitempty"`
+	CustomFields CustomFields `yaml:"custom_fields,omitempty"` // User-defined values, see Config.CustomFieldSchema
+	Notes        string       `yaml:"notes,omitempty"`
+	Created      string       `yaml:"created,omitempty"`
+	Modified     string       `yaml:"modified,omitempty"`
 }
 
 // Config represents the root configuration
 type Config struct {
-	Version     string        `yaml:"version"`
-	Connections []*Connection `yaml:"connections"`
+	Version   string            `yaml:"version"`
+	Variables map[string]string `yaml:"variables,omitempty"` // Values for {{name}} references, available to every connection
+	Profiles  []*Profile        `yaml:"profiles,omitempty"`
+
+	// CustomFieldSchema optionally declares the custom fields connections carry and their types
+	CustomFieldSchema []*CustomFieldSchema `yaml:"custom_field_schema,omitempty"`
+
+	Templates     []*Template     `yaml:"templates,omitempty"`
+	ExternalTools []*ExternalTool `yaml:"external_tools,omitempty"`
+	Connections   []*Connection   `yaml:"connections"`
 }
 
 // NewConfig creates a new empty configuration
@@ -67,6 +168,7 @@ func NewConnection(name string, protocol Protocol) *Connection {
 		Name:     name,
 		Type:     NodeTypeConnection,
 		Protocol: protocol,
+		ID:       NewID(),
 	}
 }
 
@@ -76,9 +178,20 @@ func NewFolder(name string) *Connection {
 		Name:     name,
 		Type:     NodeTypeFolder,
 		Children: make([]*Connection, 0),
+		ID:       NewID(),
 	}
 }
 
+// NewID generates a random identifier for a connection or folder
+func NewID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand never fails on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
 // IsFolder returns true if this node is a folder
 func (c *Connection) IsFolder() bool {
 	return c.Type == NodeTypeFolder
@@ -91,24 +204,9 @@ func (c *Connection) AddChild(child *Connection) {
 	}
 }
 
-// GetDefaultPort returns the default port for a protocol
+// GetDefaultPort returns the default port for a protocol, 0 if it's unknown
 func (p Protocol) GetDefaultPort() int {
-	switch p {
-	case ProtocolSSH:
-		return 22
-	case ProtocolRDP:
-		return 3389
-	case ProtocolVNC:
-		return 5900
-	case ProtocolHTTP:
-		return 80
-	case ProtocolHTTPS:
-		return 443
-	case ProtocolTelnet:
-		return 23
-	default:
-		return 0
-	}
+	return defaultPorts[p]
 }
 
 // DeepCopy creates a deep copy of a Connection
@@ -131,9 +229,24 @@ func (c *Connection) DeepCopy() *Connection {
 		ColorDepth:  c.ColorDepth,
 		Resolution:  c.Resolution,
 		ExtraArgs:   c.ExtraArgs,
-		Notes:       c.Notes,
-		Created:     c.Created,
-		Modified:    c.Modified,
+
+		IdentityFile:    c.IdentityFile,
+		CertificateFile: c.CertificateFile,
+		ForwardAgent:    c.ForwardAgent,
+		IdentitiesOnly:  c.IdentitiesOnly,
+		PreferredAuth:   c.PreferredAuth,
+		LocalForwards:   copyStrings(c.LocalForwards),
+		RemoteForwards:  copyStrings(c.RemoteForwards),
+		DynamicForwards: copyStrings(c.DynamicForwards),
+		RemoteCommand:   c.RemoteCommand,
+		RequestTTY:      c.RequestTTY,
+		JumpHost:        c.JumpHost,
+		Tunnels:         copyTunnels(c.Tunnels),
+
+		ID:       c.ID,
+		Notes:    c.Notes,
+		Created:  c.Created,
+		Modified: c.Modified,
 	}
 
 	// Deep copy tags
@@ -142,6 +255,30 @@ func (c *Connection) DeepCopy() *Connection {
 		copy(connCopy.Tags, c.Tags)
 	}
 
+	connCopy.CustomFields = c.CustomFields.DeepCopy()
+
+	// Deep copy variables
+	if len(c.Variables) > 0 {
+		connCopy.Variables = copyVariables(c.Variables)
+	}
+
+	// Deep copy profile overrides
+	if len(c.Profiles) > 0 {
+		connCopy.Profiles = make(map[string]*ProfileOverride, len(c.Profiles))
+		for name, override := range c.Profiles {
+			if override != nil {
+				overrideCopy := *override
+				override = &overrideCopy
+			}
+			connCopy.Profiles[name] = override
+		}
+	}
+
+	// Deep copy the resolved jump hosts
+	for _, jump := range c.Jumps {
+		connCopy.Jumps = append(connCopy.Jumps, jump.DeepCopy())
+	}
+
 	// Deep copy children
 	if len(c.Children) > 0 {
 		connCopy.Children = make([]*Connection, len(c.Children))
@@ -153,6 +290,25 @@ func (c *Connection) DeepCopy() *Connection {
 	return connCopy
 }
 
+// Duplicate creates a deep copy of a connection or folder with new IDs, for
+// use as a separate entry alongside the original
+func (c *Connection) Duplicate() *Connection {
+	if c == nil {
+		return nil
+	}
+
+	dup := c.DeepCopy()
+	var renew func(conn *Connection)
+	renew = func(conn *Connection) {
+		conn.ID = NewID()
+		for _, child := range conn.Children {
+			renew(child)
+		}
+	}
+	renew(dup)
+	return dup
+}
+
 // DeepCopy creates a deep copy of a Config
 func (cfg *Config) DeepCopy() *Config {
 	if cfg == nil {
@@ -163,6 +319,45 @@ func (cfg *Config) DeepCopy() *Config {
 		Version: cfg.Version,
 	}
 
+	// Deep copy variables
+	if len(cfg.Variables) > 0 {
+		cfgCopy.Variables = copyVariables(cfg.Variables)
+	}
+
+	// Deep copy profiles
+	if len(cfg.Profiles) > 0 {
+		cfgCopy.Profiles = make([]*Profile, len(cfg.Profiles))
+		for i, p := range cfg.Profiles {
+			cfgCopy.Profiles[i] = p.DeepCopy()
+		}
+	}
+
+	// Deep copy custom field schema
+	if len(cfg.CustomFieldSchema) > 0 {
+		cfgCopy.CustomFieldSchema = make([]*CustomFieldSchema, len(cfg.CustomFieldSchema))
+		for i, schema := range cfg.CustomFieldSchema {
+			schemaCopy := *schema
+			cfgCopy.CustomFieldSchema[i] = &schemaCopy
+		}
+	}
+
+	// Deep copy templates
+	if len(cfg.Templates) > 0 {
+		cfgCopy.Templates = make([]*Template, len(cfg.Templates))
+		for i, t := range cfg.Templates {
+			cfgCopy.Templates[i] = t.DeepCopy()
+		}
+	}
+
+	// Deep copy external tools
+	if len(cfg.ExternalTools) > 0 {
+		cfgCopy.ExternalTools = make([]*ExternalTool, len(cfg.ExternalTools))
+		for i, tool := range cfg.ExternalTools {
+			toolCopy := *tool
+			cfgCopy.ExternalTools[i] = &toolCopy
+		}
+	}
+
 	// Deep copy connections
 	if len(cfg.Connections) > 0 {
 		cfgCopy.Connections = make([]*Connection, len(cfg.Connections))
@@ -173,3 +368,20 @@ func (cfg *Config) DeepCopy() *Config {
 
 	return cfgCopy
 }
+
+// copyVariables copies a variables map
+func copyVariables(vars map[string]string) map[string]string {
+	varsCopy := make(map[string]string, len(vars))
+	for k, v := range vars {
+		varsCopy[k] = v
+	}
+	return varsCopy
+}
+
+// copyStrings copies a string slice, keeping nil as nil
+func copyStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string(nil), values...)
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FieldType is the type of a custom field
+type FieldType string
+
+const (
+	FieldTypeString FieldType = "string"
+	FieldTypeNumber FieldType = "number"
+	FieldTypeBool   FieldType = "bool"
+	FieldTypeURL    FieldType = "url"
+	FieldTypeSecret FieldType = "secret" // Encrypted like passwords and never displayed
+)
+
+// FieldTypes lists the supported custom field types
+var FieldTypes = []FieldType{FieldTypeString, FieldTypeNumber, FieldTypeBool, FieldTypeURL, FieldTypeSecret}
+
+// Redacted stands in for passwords and secret custom fields wherever they are
+// printed: in command output, diffs and dry-run commands
+const Redacted = "********"
+
+// CustomFieldSchema declares a custom field that connections may carry
+type CustomFieldSchema struct {
+	Name        string    `yaml:"name"`
+	Type        FieldType `yaml:"type,omitempty"` // Defaults to string
+	Label       string    `yaml:"label,omitempty"`
+	Description string    `yaml:"description,omitempty"`
+	Required    bool      `yaml:"required,omitempty"`
+}
+
+// DisplayLabel returns the label to show for the field
+func (s *CustomFieldSchema) DisplayLabel() string {
+	if s.Label != "" {
+		return s.Label
+	}
+	return s.Name
+}
+
+// FieldType returns the declared type, defaulting to string
+func (s *CustomFieldSchema) FieldType() FieldType {
+	if s.Type == "" {
+		return FieldTypeString
+	}
+	return s.Type
+}
+
+// CustomFields holds user-defined values on a connection. Values are strings,
+// numbers or booleans.
+type CustomFields map[string]interface{}
+
+// Keys returns the field names in alphabetical order
+func (f CustomFields) Keys() []string {
+	keys := make([]string, 0, len(f))
+	for key := range f {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String returns a field's value as text, or "" if it is not set
+func (f CustomFields) String(name string) string {
+	value, exists := f[name]
+	if !exists || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// DeepCopy creates a copy of the fields
+func (f CustomFields) DeepCopy() CustomFields {
+	if f == nil {
+		return nil
+	}
+	fieldsCopy := make(CustomFields, len(f))
+	for k, v := range f {
+		fieldsCopy[k] = v
+	}
+	return fieldsCopy
+}
+
+// FindCustomField returns the schema of a custom field, or nil if it isn't declared
+func (cfg *Config) FindCustomField(name string) *CustomFieldSchema {
+	for _, s := range cfg.CustomFieldSchema {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// IsSecretField reports whether a custom field is declared as a secret
+func (cfg *Config) IsSecretField(name string) bool {
+	s := cfg.FindCustomField(name)
+	return s != nil && s.FieldType() == FieldTypeSecret
+}
+
+// ParseFieldValue converts text entered by a user into a value of the given type
+func ParseFieldValue(fieldType FieldType, text string) (interface{}, error) {
+	text = strings.TrimSpace(text)
+	switch fieldType {
+	case FieldTypeNumber:
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return int(n), nil
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a number", text)
+		}
+		return f, nil
+	case FieldTypeBool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not true or false", text)
+		}
+		return b, nil
+	case FieldTypeURL:
+		if err := ValidateURL(text); err != nil {
+			return nil, err
+		}
+		return text, nil
+	default:
+		return text, nil
+	}
+}
+
+// CheckFieldValue reports whether a stored value matches a field type
+func CheckFieldValue(fieldType FieldType, value interface{}) error {
+	switch fieldType {
+	case FieldTypeNumber:
+		switch value.(type) {
+		case int, int64, float64:
+			return nil
+		}
+		return fmt.Errorf("expected a number, got '%v'", value)
+	case FieldTypeBool:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("expected true or false, got '%v'", value)
+		}
+		return nil
+	case FieldTypeURL:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected a URL, got '%v'", value)
+		}
+		return ValidateURL(s)
+	default:
+		switch value.(type) {
+		case string, int, int64, float64, bool:
+			return nil
+		}
+		return fmt.Errorf("expected a single value")
+	}
+}
+
+// ValidateURL checks that a value is an absolute URL
+func ValidateURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "") {
+		return fmt.Errorf("'%s' is not an absolute URL", value)
+	}
+	return nil
+}
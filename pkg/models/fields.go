@@ -0,0 +1,147 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// CustomFieldPrefix selects a single custom field in an update mask, e.g. "custom_fields.asset_id"
+const CustomFieldPrefix = "custom_fields."
+
+// EditableField describes a connection setting that can be changed through an update mask
+type EditableField struct {
+	Name        string // YAML key, used in update masks
+	Description string
+	index       int
+}
+
+// EditableFields lists the connection settings that can be edited, in file order.
+// Structural fields (type, id, children) and timestamps are managed by the config manager.
+var EditableFields = newEditableFields([]EditableField{
+	{Name: "name", Description: "Connection name"},
+	{Name: "protocol", Description: "Protocol"},
+	{Name: "host", Description: "Host address"},
+	{Name: "port", Description: "Port number"},
+	{Name: "username", Description: "Username"},
+	{Name: "password", Description: "Password"},
+	{Name: "domain", Description: "Domain (RDP)"},
+	{Name: "description", Description: "Description"},
+	{Name: "use_credssp", Description: "Use CredSSP (RDP)"},
+	{Name: "color_depth", Description: "Color depth (RDP)"},
+	{Name: "resolution", Description: "Resolution (RDP)"},
+	{Name: "extra_args", Description: "Additional protocol-specific arguments"},
+	{Name: "identity_file", Description: "Private key file (SSH)"},
+	{Name: "certificate_file", Description: "Certificate file (SSH)"},
+	{Name: "forward_agent", Description: "Forward the SSH agent (SSH)"},
+	{Name: "identities_only", Description: "Only offer the identity file (SSH)"},
+	{Name: "preferred_auth", Description: "Authentication methods in order (SSH)"},
+	{Name: "local_forwards", Description: "Local port forwards (SSH)"},
+	{Name: "remote_forwards", Description: "Remote port forwards (SSH)"},
+	{Name: "dynamic_forwards", Description: "SOCKS proxy ports (SSH)"},
+	{Name: "remote_command", Description: "Command to run instead of the shell (SSH)"},
+	{Name: "request_tty", Description: "Request a terminal: auto, yes, no or force (SSH)"},
+	{Name: "jump_host", Description: "SSH connection to go through (ID, path or name)"},
+	{Name: "tunnels", Description: "Named port forwards for the tunnel manager (SSH)"},
+	{Name: "variables", Description: "Variables for {{name}} references"},
+	{Name: "profiles", Description: "Host, port and username overrides per profile"},
+	{Name: "tags", Description: "Tags"},
+	{Name: "custom_fields", Description: "User-defined custom fields"},
+	{Name: "notes", Description: "Notes"},
+})
+
+// newEditableFields resolves each field's position in the Connection struct
+func newEditableFields(fields []EditableField) []EditableField {
+	t := reflect.TypeOf(Connection{})
+	for i := range fields {
+		fields[i].index = -1
+		for j := 0; j < t.NumField(); j++ {
+			if strings.Split(t.Field(j).Tag.Get("yaml"), ",")[0] == fields[i].Name {
+				fields[i].index = j
+				break
+			}
+		}
+		if fields[i].index < 0 {
+			panic("unknown connection field: " + fields[i].Name)
+		}
+	}
+	return fields
+}
+
+// FindEditableField returns the editable field with the given YAML key
+func FindEditableField(name string) (*EditableField, error) {
+	for i := range EditableFields {
+		if EditableFields[i].Name == name {
+			return &EditableFields[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown field '%s'", name)
+}
+
+// EditableFieldNames lists the YAML keys of the editable fields
+func EditableFieldNames() []string {
+	names := make([]string, len(EditableFields))
+	for i, field := range EditableFields {
+		names[i] = field.Name
+	}
+	return names
+}
+
+// CheckFieldMask reports the first entry of an update mask that doesn't name an editable field
+func CheckFieldMask(mask []string) error {
+	for _, name := range mask {
+		if strings.HasPrefix(name, CustomFieldPrefix) {
+			if strings.TrimPrefix(name, CustomFieldPrefix) == "" {
+				return fmt.Errorf("missing custom field name in '%s'", name)
+			}
+			continue
+		}
+		if _, err := FindEditableField(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyField copies one field, named as in an update mask, from another
+// connection. Empty values are copied too, clearing the field.
+func (c *Connection) CopyField(from *Connection, name string) error {
+	if strings.HasPrefix(name, CustomFieldPrefix) {
+		key := strings.TrimPrefix(name, CustomFieldPrefix)
+		if value, exists := from.CustomFields[key]; exists {
+			if c.CustomFields == nil {
+				c.CustomFields = make(CustomFields)
+			}
+			c.CustomFields[key] = value
+		} else {
+			delete(c.CustomFields, key)
+			if len(c.CustomFields) == 0 {
+				c.CustomFields = nil
+			}
+		}
+		return nil
+	}
+
+	field, err := FindEditableField(name)
+	if err != nil {
+		return err
+	}
+
+	// Copy through DeepCopy so the connections don't share tags or maps
+	source := reflect.ValueOf(from.DeepCopy()).Elem().Field(field.index)
+	reflect.ValueOf(c).Elem().Field(field.index).Set(source)
+	return nil
+}
+
+// FieldValue returns the value of one field, named as in an update mask
+func (c *Connection) FieldValue(name string) (interface{}, error) {
+	if strings.HasPrefix(name, CustomFieldPrefix) {
+		return c.CustomFields[strings.TrimPrefix(name, CustomFieldPrefix)], nil
+	}
+
+	field, err := FindEditableField(name)
+	if err != nil {
+		return nil, err
+	}
+	return reflect.ValueOf(c).Elem().Field(field.index).Interface(), nil
+}
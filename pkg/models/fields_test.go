@@ -0,0 +1,89 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+// fieldTestConnection returns a connection with every editable field set
+func fieldTestConnection(suffix string) *Connection {
+	return &Connection{
+		Name: "web" + suffix, Protocol: ProtocolSSH, Host: "host" + suffix, Port: len(suffix) + 22,
+		Username: "user" + suffix, Password: "pass" + suffix, Domain: "domain" + suffix,
+		Description: "desc" + suffix, UseCredSSP: suffix == "", ColorDepth: len(suffix) + 16,
+		Resolution: "res" + suffix, ExtraArgs: "args" + suffix, IdentityFile: "key" + suffix,
+		CertificateFile: "cert" + suffix, ForwardAgent: suffix == "", IdentitiesOnly: suffix == "",
+		PreferredAuth: "auth" + suffix, LocalForwards: []string{"80" + suffix},
+		RemoteForwards: []string{"81" + suffix}, DynamicForwards: []string{"82" + suffix},
+		RemoteCommand: "cmd" + suffix, RequestTTY: "tty" + suffix, JumpHost: "jump" + suffix,
+		Tunnels:   []*Tunnel{{Name: "t" + suffix}},
+		Variables: map[string]string{"v": suffix},
+		Profiles:  map[string]*ProfileOverride{"p" + suffix: {Host: "h" + suffix}},
+		Tags:      []string{"tag" + suffix}, CustomFields: CustomFields{"a": "a" + suffix, "b": "b" + suffix},
+		Notes: "notes" + suffix, ID: "id" + suffix, Created: "created" + suffix, Modified: "modified" + suffix,
+	}
+}
+
+func TestCopyField(t *testing.T) {
+	for _, field := range EditableFields {
+		conn, from := fieldTestConnection(""), fieldTestConnection("2")
+		if err := conn.CopyField(from, field.Name); err != nil {
+			t.Fatalf("%s: %v", field.Name, err)
+		}
+
+		// Only the named field changes
+		want := fieldTestConnection("")
+		reflect.ValueOf(want).Elem().Field(field.index).Set(reflect.ValueOf(fieldTestConnection("2")).Elem().Field(field.index))
+		if !reflect.DeepEqual(conn, want) {
+			t.Errorf("%s: copied\n%+v\nwant\n%+v", field.Name, conn, want)
+		}
+
+		// The copy doesn't share slices or maps with the source
+		from.LocalForwards[0], from.Tags[0] = "changed", "changed"
+		from.Tunnels[0].Name, from.Profiles["p2"].Host = "changed", "changed"
+		from.Variables["v"], from.CustomFields["a"] = "changed", "changed"
+		if !reflect.DeepEqual(conn, want) {
+			t.Errorf("%s: copy changed with its source", field.Name)
+		}
+	}
+}
+
+func TestCopyCustomField(t *testing.T) {
+	conn, from := fieldTestConnection(""), fieldTestConnection("2")
+	if err := conn.CopyField(from, "custom_fields.a"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(conn.CustomFields, CustomFields{"a": "a2", "b": "b"}) {
+		t.Errorf("custom fields = %v", conn.CustomFields)
+	}
+
+	// Fields missing from the source are removed, and the map with the last one
+	delete(from.CustomFields, "b")
+	conn.CustomFields = CustomFields{"b": "b"}
+	if err := conn.CopyField(from, "custom_fields.b"); err != nil {
+		t.Fatal(err)
+	}
+	if conn.CustomFields != nil {
+		t.Errorf("custom fields = %v, want nil", conn.CustomFields)
+	}
+
+	if err := conn.CopyField(from, "children"); err == nil {
+		t.Error("expected an error for a field that isn't editable")
+	}
+}
+
+func TestCheckFieldMask(t *testing.T) {
+	for _, tt := range []struct {
+		mask []string
+		ok   bool
+	}{
+		{nil, true},
+		{[]string{"host", "profiles", "custom_fields.owner"}, true},
+		{[]string{"host", "id"}, false},
+		{[]string{"custom_fields."}, false},
+	} {
+		if err := CheckFieldMask(tt.mask); (err == nil) != tt.ok {
+			t.Errorf("CheckFieldMask(%v) = %v", tt.mask, err)
+		}
+	}
+}
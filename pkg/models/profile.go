@@ -0,0 +1,86 @@
+package models
+
+// Profile is a named environment (such as dev, staging or prod) that one
+// connection tree can be switched between
+type Profile struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description,omitempty"`
+	Variables   map[string]string `yaml:"variables,omitempty"` // Values for {{name}} references while the profile is active
+}
+
+// ProfileOverride replaces connection settings while a profile is active
+type ProfileOverride struct {
+	Host     string `yaml:"host,omitempty"`
+	Port     int    `yaml:"port,omitempty"`
+	Username string `yaml:"username,omitempty"`
+}
+
+// DeepCopy creates a deep copy of a Profile
+func (p *Profile) DeepCopy() *Profile {
+	if p == nil {
+		return nil
+	}
+
+	profileCopy := *p
+	if len(p.Variables) > 0 {
+		profileCopy.Variables = copyVariables(p.Variables)
+	}
+	return &profileCopy
+}
+
+// FindProfile returns the profile with the given name, or nil if the config
+// doesn't declare it
+func (cfg *Config) FindProfile(name string) *Profile {
+	for _, p := range cfg.Profiles {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// ProfileNames lists the declared profiles followed by any profiles that are
+// only used in connection overrides
+func (cfg *Config) ProfileNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, p := range cfg.Profiles {
+		if !seen[p.Name] {
+			seen[p.Name] = true
+			names = append(names, p.Name)
+		}
+	}
+
+	var collect func(connections []*Connection)
+	collect = func(connections []*Connection) {
+		for _, conn := range connections {
+			for name := range conn.Profiles {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+			collect(conn.Children)
+		}
+	}
+	collect(cfg.Connections)
+
+	return names
+}
+
+// ApplyProfile applies the connection's overrides for a profile, if it has any
+func (c *Connection) ApplyProfile(name string) {
+	override, exists := c.Profiles[name]
+	if !exists || override == nil {
+		return
+	}
+	if override.Host != "" {
+		c.Host = override.Host
+	}
+	if override.Port != 0 {
+		c.Port = override.Port
+	}
+	if override.Username != "" {
+		c.Username = override.Username
+	}
+}
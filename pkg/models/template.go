@@ -0,0 +1,166 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+)
+
+// Template holds shared settings that new connections can be created from
+type Template struct {
+	Name        string   `yaml:"name"`
+	Protocol    Protocol `yaml:"protocol,omitempty"`
+	Host        string   `yaml:"host,omitempty"`
+	Port        int      `yaml:"port,omitempty"`
+	Username    string   `yaml:"username,omitempty"`
+	Password    string   `yaml:"password,omitempty"`
+	Domain      string   `yaml:"domain,omitempty"`
+	Description string   `yaml:"description,omitempty"`
+
+	// Advanced options
+	UseCredSSP bool   `yaml:"use_credssp,omitempty"`
+	ColorDepth int    `yaml:"color_depth,omitempty"`
+	Resolution string `yaml:"resolution,omitempty"`
+	ExtraArgs  string `yaml:"extra_args,omitempty"`
+
+	// SSH options
+	IdentityFile    string   `yaml:"identity_file,omitempty"`
+	CertificateFile string   `yaml:"certificate_file,omitempty"`
+	ForwardAgent    bool     `yaml:"forward_agent,omitempty"`
+	IdentitiesOnly  bool     `yaml:"identities_only,omitempty"`
+	PreferredAuth   string   `yaml:"preferred_auth,omitempty"`
+	LocalForwards   []string `yaml:"local_forwards,omitempty"`
+	RemoteForwards  []string `yaml:"remote_forwards,omitempty"`
+	DynamicForwards []string `yaml:"dynamic_forwards,omitempty"`
+	RemoteCommand   string   `yaml:"remote_command,omitempty"`
+	RequestTTY      string   `yaml:"request_tty,omitempty"`
+	JumpHost        string   `yaml:"jump_host,omitempty"`
+
+	// Metadata
+	Tags         []string     `yaml:"tags,omitempty"`
+	CustomFields CustomFields `yaml:"custom_fields,omitempty"`
+	Notes        string       `yaml:"notes,omitempty"`
+}
+
+// Instantiate creates a new connection from the template. Placeholders are
+// left in place so that fields set afterwards (such as the host) can be
+// referenced; call ExpandPlaceholders once the connection is complete.
+func (t *Template) Instantiate(name string) *Connection {
+	conn := NewConnection(name, t.Protocol)
+	conn.Host = t.Host
+	conn.Port = t.Port
+	conn.Username = t.Username
+	conn.Password = t.Password
+	conn.Domain = t.Domain
+	conn.Description = t.Description
+	conn.UseCredSSP = t.UseCredSSP
+	conn.ColorDepth = t.ColorDepth
+	conn.Resolution = t.Resolution
+	conn.ExtraArgs = t.ExtraArgs
+	conn.IdentityFile = t.IdentityFile
+	conn.CertificateFile = t.CertificateFile
+	conn.ForwardAgent = t.ForwardAgent
+	conn.IdentitiesOnly = t.IdentitiesOnly
+	conn.PreferredAuth = t.PreferredAuth
+	conn.LocalForwards = copyStrings(t.LocalForwards)
+	conn.RemoteForwards = copyStrings(t.RemoteForwards)
+	conn.DynamicForwards = copyStrings(t.DynamicForwards)
+	conn.RemoteCommand = t.RemoteCommand
+	conn.RequestTTY = t.RequestTTY
+	conn.JumpHost = t.JumpHost
+	conn.Notes = t.Notes
+	conn.CustomFields = t.CustomFields.DeepCopy()
+
+	if len(t.Tags) > 0 {
+		conn.Tags = make([]string, len(t.Tags))
+		copy(conn.Tags, t.Tags)
+	}
+
+	return conn
+}
+
+// DeepCopy creates a deep copy of a Template
+func (t *Template) DeepCopy() *Template {
+	if t == nil {
+		return nil
+	}
+
+	templateCopy := *t
+	templateCopy.CustomFields = t.CustomFields.DeepCopy()
+	templateCopy.LocalForwards = copyStrings(t.LocalForwards)
+	templateCopy.RemoteForwards = copyStrings(t.RemoteForwards)
+	templateCopy.DynamicForwards = copyStrings(t.DynamicForwards)
+	if len(t.Tags) > 0 {
+		templateCopy.Tags = make([]string, len(t.Tags))
+		copy(templateCopy.Tags, t.Tags)
+	}
+	return &templateCopy
+}
+
+// FindTemplate returns the template with the given name
+func (cfg *Config) FindTemplate(name string) (*Template, error) {
+	for _, t := range cfg.Templates {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("template not found: %s", name)
+}
+
+// placeholderPattern matches template placeholders such as {{.Name}}, {{ .Host }}
+// or {{.CustomFields.asset_id}}
+var placeholderPattern = regexp.MustCompile(`\{\{\s*\.(\w+)(?:\.([\w-]+))?\s*\}\}`)
+
+// secretFields lists the connection fields placeholders can't refer to
+var secretFields = map[string]struct{}{"Password": {}}
+
+// ExpandPlaceholders replaces {{.Field}} placeholders in the connection's text
+// fields with the value of the named field, e.g. {{.Name}}, {{.Host}},
+// {{.Port}} or {{.CustomFields.asset_id}}. Unknown placeholders are left
+// untouched, and so are those of secrets: the password and the custom fields
+// isSecret (which may be nil) reports, so templates can't copy them into
+// fields that are displayed.
+func (c *Connection) ExpandPlaceholders(isSecret func(string) bool) {
+	value := reflect.ValueOf(c).Elem()
+	customFields := c.CustomFields.DeepCopy()
+
+	expand := func(s string) string {
+		return placeholderPattern.ReplaceAllStringFunc(s, func(match string) string {
+			parts := placeholderPattern.FindStringSubmatch(match)
+			if parts[2] != "" {
+				if parts[1] == "CustomFields" && (isSecret == nil || !isSecret(parts[2])) {
+					if _, exists := customFields[parts[2]]; exists {
+						return customFields.String(parts[2])
+					}
+				}
+				return match
+			}
+			if _, secret := secretFields[parts[1]]; secret {
+				return match
+			}
+			field := value.FieldByName(parts[1])
+			if !field.IsValid() {
+				return match
+			}
+			switch field.Kind() {
+			case reflect.String, reflect.Int, reflect.Bool:
+				return fmt.Sprint(field.Interface())
+			}
+			return match
+		})
+	}
+
+	// Expand from the original values so fields can't see each other's expansions
+	host, username, domain := expand(c.Host), expand(c.Username), expand(c.Domain)
+	description, extraArgs, notes := expand(c.Description), expand(c.ExtraArgs), expand(c.Notes)
+	for key, fieldValue := range customFields {
+		if text, ok := fieldValue.(string); ok {
+			c.CustomFields[key] = expand(text)
+		}
+	}
+	c.Host, c.Username, c.Domain = host, username, domain
+	c.Description, c.ExtraArgs, c.Notes = description, extraArgs, notes
+
+	identityFile, certificateFile, remoteCommand := expand(c.IdentityFile), expand(c.CertificateFile), expand(c.RemoteCommand)
+	c.IdentityFile, c.CertificateFile, c.RemoteCommand = identityFile, certificateFile, remoteCommand
+}
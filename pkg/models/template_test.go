@@ -0,0 +1,22 @@
+package models
+
+import "testing"
+
+func TestExpandPlaceholdersSkipsSecrets(t *testing.T) {
+	conn := &Connection{
+		Name:         "web1",
+		Host:         "web1.example.com",
+		Password:     "hunter2",
+		Description:  "{{.Name}} on {{.Host}}, {{.Password}}",
+		Notes:        "asset {{.CustomFields.asset}}, token {{.CustomFields.token}}",
+		CustomFields: CustomFields{"asset": "A-1", "token": "s3cret"},
+	}
+	conn.ExpandPlaceholders(func(name string) bool { return name == "token" })
+
+	if want := "web1 on web1.example.com, {{.Password}}"; conn.Description != want {
+		t.Errorf("description = %q, want %q", conn.Description, want)
+	}
+	if want := "asset A-1, token {{.CustomFields.token}}"; conn.Notes != want {
+		t.Errorf("notes = %q, want %q", conn.Notes, want)
+	}
+}
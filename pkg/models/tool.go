@@ -0,0 +1,29 @@
+package models
+
+import "fmt"
+
+// ExternalTool is a user-defined command run against a connection, such as
+// WinSCP, ping or a script. Command and Args may reference the connection with
+// %HOST%, %PORT%, %USERNAME%, %PASSWORD%, %DOMAIN%, %NAME%, %PROTOCOL%,
+// %DESCRIPTION% and %CUSTOM:name%.
+type ExternalTool struct {
+	Name        string `yaml:"name"`
+	Command     string `yaml:"command"`
+	Args        string `yaml:"args,omitempty"` // Split like a shell command line; quote arguments containing spaces
+	Description string `yaml:"description,omitempty"`
+
+	// How the tool is run; by default it is started in the background
+	RunInTerminal bool `yaml:"run_in_terminal,omitempty"` // Open a terminal window for console tools
+	Wait          bool `yaml:"wait,omitempty"`            // Wait for the tool to exit
+	CaptureOutput bool `yaml:"capture_output,omitempty"`  // Wait and show the tool's output
+}
+
+// FindExternalTool returns the external tool with the given name
+func (cfg *Config) FindExternalTool(name string) (*ExternalTool, error) {
+	for _, tool := range cfg.ExternalTools {
+		if tool.Name == name {
+			return tool, nil
+		}
+	}
+	return nil, fmt.Errorf("external tool not found: %s", name)
+}
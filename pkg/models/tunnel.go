@@ -0,0 +1,115 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Tunnel types
+const (
+	TunnelLocal   = "local"   // Listen here and connect to the target from the SSH server
+	TunnelRemote  = "remote"  // Listen on the SSH server and connect to the target from here
+	TunnelDynamic = "dynamic" // A SOCKS5 proxy here that connects from the SSH server
+)
+
+// TunnelTypes lists the values of Tunnel.Type
+var TunnelTypes = []string{TunnelLocal, TunnelRemote, TunnelDynamic}
+
+// Tunnel is a named port forward over an SSH connection, started and stopped
+// with 'mremotego tunnel' or the GUI's tunnel panel
+type Tunnel struct {
+	Name        string `yaml:"name"`
+	Type        string `yaml:"type"`             // See TunnelTypes
+	Listen      string `yaml:"listen"`           // [address:]port; the address defaults to 127.0.0.1
+	Target      string `yaml:"target,omitempty"` // host:port to connect to; dynamic tunnels connect where clients ask
+	Description string `yaml:"description,omitempty"`
+}
+
+// Validate reports a tunnel that can't be started
+func (t *Tunnel) Validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("tunnel is missing a name")
+	}
+	known := false
+	for _, tunnelType := range TunnelTypes {
+		known = known || t.Type == tunnelType
+	}
+	if !known {
+		return fmt.Errorf("tunnel '%s' has unknown type '%s' (use %s)", t.Name, t.Type, strings.Join(TunnelTypes, ", "))
+	}
+	if _, err := t.ListenAddress(); err != nil {
+		return fmt.Errorf("tunnel '%s': %w", t.Name, err)
+	}
+	if t.Type == TunnelDynamic {
+		if t.Target != "" {
+			return fmt.Errorf("dynamic tunnel '%s' can't have a target", t.Name)
+		}
+		return nil
+	}
+	if _, port, err := net.SplitHostPort(t.Target); err != nil || !validPort(port) {
+		return fmt.Errorf("tunnel '%s' needs a target host:port, not '%s'", t.Name, t.Target)
+	}
+	return nil
+}
+
+// ListenAddress returns the address:port the tunnel listens on, on this
+// machine or, for remote tunnels, on the SSH server
+func (t *Tunnel) ListenAddress() (string, error) {
+	listen := t.Listen
+	if validPort(listen) {
+		return net.JoinHostPort("127.0.0.1", listen), nil
+	}
+	if _, port, err := net.SplitHostPort(listen); err != nil || !validPort(port) {
+		return "", fmt.Errorf("listen must be a port or address:port, not '%s'", listen)
+	}
+	return listen, nil
+}
+
+// String describes the forward, e.g. "127.0.0.1:5432 -> db:5432"
+func (t *Tunnel) String() string {
+	listen, err := t.ListenAddress()
+	if err != nil {
+		listen = t.Listen
+	}
+	switch t.Type {
+	case TunnelDynamic:
+		return listen + " (SOCKS)"
+	case TunnelRemote:
+		return "remote " + listen + " -> " + t.Target
+	}
+	return listen + " -> " + t.Target
+}
+
+// FindTunnel returns the connection's tunnel with the given name
+func (c *Connection) FindTunnel(name string) (*Tunnel, error) {
+	for _, tunnel := range c.Tunnels {
+		if tunnel.Name == name {
+			return tunnel, nil
+		}
+	}
+	return nil, fmt.Errorf("'%s' has no tunnel '%s'", c.Name, name)
+}
+
+// copyTunnels deep copies a list of tunnels
+func copyTunnels(tunnels []*Tunnel) []*Tunnel {
+	if tunnels == nil {
+		return nil
+	}
+	copied := make([]*Tunnel, len(tunnels))
+	for i, tunnel := range tunnels {
+		if tunnel != nil {
+			tunnelCopy := *tunnel
+			tunnel = &tunnelCopy
+		}
+		copied[i] = tunnel
+	}
+	return copied
+}
+
+// validPort reports whether a string is a TCP port number
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}